@@ -1,20 +1,297 @@
+-- ACCOUNT GROUPS
+CREATE TABLE IF NOT EXISTS account_groups (
+	id      UUID PRIMARY KEY,
+	name    TEXT NOT NULL,
+	user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_account_groups_user_id ON account_groups(user_id);
+
 -- ACCOUNTS
 CREATE TABLE IF NOT EXISTS bank_accounts (
 	id      UUID PRIMARY KEY,
 	name    TEXT NOT NULL,
 	type    TEXT NOT NULL,
-	user_id TEXT REFERENCES "user"(id) ON DELETE CASCADE
+	user_id TEXT REFERENCES "user"(id) ON DELETE CASCADE,
+	sort_order INT NOT NULL DEFAULT 0,
+	default_transaction_type TEXT CHECK (default_transaction_type IN ('income', 'expense')),
+	last_used_at TIMESTAMPTZ,
+	currency TEXT NOT NULL DEFAULT 'USD' CHECK (currency ~ '^[A-Z]{3}$'),
+	group_id UUID REFERENCES account_groups(id) ON DELETE SET NULL,
+	opening_balance NUMERIC(18,4) NOT NULL DEFAULT 0,
+	allow_negative BOOLEAN NOT NULL DEFAULT true,
+	transaction_seq BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 CREATE INDEX IF NOT EXISTS idx_bank_accounts_user_id ON bank_accounts(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bank_accounts_user_name_type ON bank_accounts(user_id, lower(name), type);
+CREATE INDEX IF NOT EXISTS idx_bank_accounts_group_id ON bank_accounts(group_id) WHERE group_id IS NOT NULL;
+
+-- CATEGORIES
+CREATE TABLE IF NOT EXISTS categories (
+	id      UUID PRIMARY KEY,
+	name    TEXT NOT NULL,
+	user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_categories_user_id ON categories(user_id);
+
+-- CATEGORY RULES
+CREATE TABLE IF NOT EXISTS category_rules (
+	id          UUID PRIMARY KEY,
+	user_id     TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+	pattern     TEXT NOT NULL,
+	match_type  TEXT NOT NULL DEFAULT 'literal' CHECK (match_type IN ('literal', 'regex')),
+	type        TEXT CHECK (type IN ('income', 'expense')),
+	category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+	priority    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_category_rules_user_id ON category_rules(user_id, priority);
 
 -- TRANSACTIONS
 CREATE TABLE IF NOT EXISTS transactions (
 	id         UUID PRIMARY KEY,
 	account_id UUID NOT NULL REFERENCES bank_accounts(id) ON DELETE CASCADE,
+	seq        BIGINT NOT NULL,
 	amount     NUMERIC(18,4) NOT NULL,
 	date       TIMESTAMPTZ NOT NULL,
 	description TEXT NOT NULL DEFAULT '',
-	type       TEXT NOT NULL CHECK (type IN ('income', 'expense'))
+	type       TEXT NOT NULL CHECK (type IN ('income', 'expense')),
+	transfer_group UUID,
+	external_id TEXT,
+	content_hash TEXT,
+	cleared    BOOLEAN NOT NULL DEFAULT false,
+	status     TEXT NOT NULL DEFAULT 'posted' CHECK (status IN ('pending', 'posted')),
+	scheduled  BOOLEAN NOT NULL DEFAULT false,
+	currency   TEXT CHECK (currency ~ '^[A-Z]{3}$'),
+	original_amount NUMERIC(18,4),
+	import_batch_id UUID,
+	category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+	tags       TEXT[] NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CONSTRAINT transactions_original_pair CHECK ((currency IS NULL) = (original_amount IS NULL))
 );
 CREATE INDEX IF NOT EXISTS idx_transactions_account_id ON transactions(account_id);
 CREATE INDEX IF NOT EXISTS idx_transactions_date ON transactions(account_id, date DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_updated_at ON transactions(account_id, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_group ON transactions(transfer_group) WHERE transfer_group IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external_id ON transactions(account_id, external_id) WHERE external_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_content_hash ON transactions(account_id, content_hash) WHERE content_hash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_category_id ON transactions(account_id, category_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_import_batch_id ON transactions(account_id, import_batch_id) WHERE import_batch_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_seq ON transactions(account_id, seq);
+CREATE INDEX IF NOT EXISTS idx_transactions_scheduled ON transactions(date) WHERE scheduled;
+
+-- Numbers transactions 1, 2, 3... per account. Bumping the account's
+-- counter row serializes concurrent inserts into the same account.
+CREATE OR REPLACE FUNCTION assign_transaction_seq() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'INSERT' OR NEW.account_id IS DISTINCT FROM OLD.account_id THEN
+		UPDATE bank_accounts SET transaction_seq = transaction_seq + 1
+		WHERE id = NEW.account_id
+		RETURNING transaction_seq INTO NEW.seq;
+		-- Report a missing account as the foreign key would, not as a
+		-- NULL seq.
+		IF NOT FOUND THEN
+			RAISE foreign_key_violation USING MESSAGE = format('account %s does not exist', NEW.account_id);
+		END IF;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transaction_seq
+	BEFORE INSERT OR UPDATE OF account_id ON transactions
+	FOR EACH ROW EXECUTE FUNCTION assign_transaction_seq();
+
+-- TRANSACTION SPLITS
+CREATE TABLE IF NOT EXISTS transaction_splits (
+	id             UUID PRIMARY KEY,
+	transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+	amount         NUMERIC(18,4) NOT NULL,
+	description    TEXT NOT NULL DEFAULT '',
+	category_id    UUID REFERENCES categories(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction_id ON transaction_splits(transaction_id);
+
+-- SAVINGS GOALS
+CREATE TABLE IF NOT EXISTS savings_goals (
+	id            UUID PRIMARY KEY,
+	account_id    UUID NOT NULL REFERENCES bank_accounts(id) ON DELETE CASCADE,
+	name          TEXT NOT NULL,
+	target_amount NUMERIC(18,4) NOT NULL CHECK (target_amount > 0),
+	target_date   DATE,
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_savings_goals_account_id ON savings_goals(account_id);
+
+-- WEBHOOKS
+CREATE TABLE IF NOT EXISTS webhooks (
+	id         UUID PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+	url        TEXT NOT NULL,
+	events     TEXT[] NOT NULL,
+	secret     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+-- TRANSACTION AUDIT
+CREATE TABLE IF NOT EXISTS transaction_audit (
+	id             BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	account_id     UUID NOT NULL REFERENCES bank_accounts(id) ON DELETE CASCADE,
+	transaction_id UUID NOT NULL,
+	action         TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+	changed_fields TEXT[] NOT NULL,
+	before         JSONB,
+	after          JSONB,
+	changed_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_audit_account_id ON transaction_audit(account_id, id DESC);
+
+CREATE OR REPLACE FUNCTION record_transaction_audit() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+	old_row JSONB;
+	new_row JSONB;
+	account UUID;
+	fields  TEXT[];
+BEGIN
+	-- Amounts are kept as text so snapshots stay exact decimals.
+	IF TG_OP = 'DELETE' THEN
+		-- Deleting an account cascades to its transactions and its history.
+		IF NOT EXISTS (SELECT 1 FROM bank_accounts WHERE id = OLD.account_id) THEN
+			RETURN NULL;
+		END IF;
+		old_row := (to_jsonb(OLD) - 'updated_at') || jsonb_build_object('amount', OLD.amount::text);
+		account := OLD.account_id;
+	ELSE
+		new_row := (to_jsonb(NEW) - 'updated_at') || jsonb_build_object('amount', NEW.amount::text);
+		account := NEW.account_id;
+		IF TG_OP = 'UPDATE' THEN
+			old_row := (to_jsonb(OLD) - 'updated_at') || jsonb_build_object('amount', OLD.amount::text);
+		END IF;
+	END IF;
+
+	SELECT COALESCE(array_agg(key ORDER BY key), '{}') INTO fields
+	FROM jsonb_object_keys(COALESCE(new_row, old_row)) AS key
+	WHERE old_row -> key IS DISTINCT FROM new_row -> key;
+	IF TG_OP = 'UPDATE' AND cardinality(fields) = 0 THEN
+		RETURN NULL;
+	END IF;
+
+	INSERT INTO transaction_audit (account_id, transaction_id, action, changed_fields, before, after)
+	VALUES (
+		account,
+		(COALESCE(new_row, old_row) ->> 'id')::uuid,
+		CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END,
+		fields,
+		old_row,
+		new_row
+	);
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transaction_audit
+	AFTER INSERT OR UPDATE OR DELETE ON transactions
+	FOR EACH ROW EXECUTE FUNCTION record_transaction_audit();
+
+CREATE OR REPLACE FUNCTION refuse_overdraft(lowered UUID[]) RETURNS void
+LANGUAGE plpgsql AS $$
+DECLARE
+	overdrawn UUID;
+BEGIN
+	IF cardinality(lowered) = 0 THEN
+		RETURN;
+	END IF;
+	-- Lock first, in id order, so concurrent writes to one account queue up
+	-- and the balance below is read with every earlier write committed. NO
+	-- KEY UPDATE does not conflict with the KEY SHARE lock the foreign key
+	-- check already took, so two racing inserts cannot deadlock here.
+	PERFORM 1 FROM bank_accounts
+	WHERE id = ANY(lowered) AND NOT allow_negative
+	ORDER BY id
+	FOR NO KEY UPDATE;
+	-- Same rule as ACCOUNT_BALANCE in lib/balance.mts: active, posted
+	-- transactions on top of the opening balance.
+	SELECT a.id INTO overdrawn
+	FROM bank_accounts a
+	LEFT JOIN transactions t
+		ON t.account_id = a.id AND NOT t.scheduled AND t.status = 'posted'
+	WHERE a.id = ANY(lowered) AND NOT a.allow_negative
+	GROUP BY a.id
+	HAVING a.opening_balance + COALESCE(SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END), 0) < 0
+	LIMIT 1;
+	IF FOUND THEN
+		RAISE EXCEPTION 'account % would have a negative balance', overdrawn
+			USING ERRCODE = 'EL001';
+	END IF;
+END;
+$$;
+
+-- Only accounts whose balance the statement lowered are checked, so
+-- deposits, recategorizing or tagging still work on an account that is
+-- already below zero.
+CREATE OR REPLACE FUNCTION transactions_refuse_overdraft() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM new_rows
+			WHERE NOT scheduled AND status = 'posted'
+			GROUP BY account_id
+			HAVING SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) < 0
+		));
+	ELSIF TG_OP = 'DELETE' THEN
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM old_rows
+			WHERE NOT scheduled AND status = 'posted'
+			GROUP BY account_id
+			HAVING SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) > 0
+		));
+	ELSE
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM (
+				SELECT account_id, CASE WHEN type = 'income' THEN amount ELSE -amount END AS delta
+				FROM new_rows
+				WHERE NOT scheduled AND status = 'posted'
+				UNION ALL
+				SELECT account_id, CASE WHEN type = 'income' THEN -amount ELSE amount END
+				FROM old_rows
+				WHERE NOT scheduled AND status = 'posted'
+			) c
+			GROUP BY account_id
+			HAVING SUM(delta) < 0
+		));
+	END IF;
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_insert
+	AFTER INSERT ON transactions
+	REFERENCING NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_update
+	AFTER UPDATE ON transactions
+	REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_delete
+	AFTER DELETE ON transactions
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+
+CREATE OR REPLACE FUNCTION bank_accounts_refuse_overdraft() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM refuse_overdraft(ARRAY[NEW.id]);
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER bank_accounts_refuse_overdraft
+	AFTER UPDATE OF opening_balance ON bank_accounts
+	FOR EACH ROW
+	WHEN (NEW.opening_balance < OLD.opening_balance)
+	EXECUTE FUNCTION bank_accounts_refuse_overdraft();
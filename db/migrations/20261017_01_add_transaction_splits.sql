@@ -0,0 +1,9 @@
+-- Split a transaction into line items whose amounts sum to the parent amount.
+
+CREATE TABLE IF NOT EXISTS transaction_splits (
+	id             UUID PRIMARY KEY,
+	transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+	amount         NUMERIC(18,4) NOT NULL,
+	description    TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction_id ON transaction_splits(transaction_id);
@@ -0,0 +1,8 @@
+-- Link the income and expense legs of a transfer between accounts.
+-- Reports exclude rows with a transfer_group from income/expense totals.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS transfer_group UUID;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_transfer_group
+  ON transactions(transfer_group) WHERE transfer_group IS NOT NULL;
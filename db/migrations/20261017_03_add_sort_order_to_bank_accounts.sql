@@ -0,0 +1,13 @@
+-- User-controlled display order for bank accounts.
+-- Existing accounts keep alphabetical order until they are reordered.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0;
+
+UPDATE bank_accounts a
+SET sort_order = ranked.position
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY name) AS position
+  FROM bank_accounts
+) ranked
+WHERE a.id = ranked.id AND a.sort_order = 0;
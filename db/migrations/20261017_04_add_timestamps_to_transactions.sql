@@ -0,0 +1,9 @@
+-- Track when each transaction was entered and last changed.
+-- Existing rows are stamped with the time the migration runs.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS idx_transactions_updated_at
+  ON transactions(account_id, updated_at DESC);
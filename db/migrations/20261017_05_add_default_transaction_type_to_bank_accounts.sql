@@ -0,0 +1,5 @@
+-- Optional per-account type used when a new transaction omits `type`.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS default_transaction_type TEXT
+    CHECK (default_transaction_type IN ('income', 'expense'));
@@ -0,0 +1,13 @@
+-- When a transaction was last created in the account, for "recent" ordering.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+UPDATE bank_accounts a
+SET last_used_at = t.last_created
+FROM (
+  SELECT account_id, MAX(created_at) AS last_created
+  FROM transactions
+  GROUP BY account_id
+) t
+WHERE t.account_id = a.id AND a.last_used_at IS NULL;
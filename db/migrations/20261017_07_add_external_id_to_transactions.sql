@@ -0,0 +1,8 @@
+-- Bank-assigned transaction id (e.g. OFX FITID) used to skip re-imports.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS external_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_external_id
+  ON transactions(account_id, external_id)
+  WHERE external_id IS NOT NULL;
@@ -0,0 +1,5 @@
+-- ISO 4217 currency of each account; existing accounts are assumed USD.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD'
+    CHECK (currency ~ '^[A-Z]{3}$');
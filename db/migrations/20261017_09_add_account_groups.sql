@@ -0,0 +1,13 @@
+-- Optional folders for organizing accounts. Deleting a group keeps its
+-- accounts and just ungroups them.
+
+CREATE TABLE IF NOT EXISTS account_groups (
+	id      UUID PRIMARY KEY,
+	name    TEXT NOT NULL,
+	user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_account_groups_user_id ON account_groups(user_id);
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS group_id UUID REFERENCES account_groups(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_bank_accounts_group_id ON bank_accounts(group_id) WHERE group_id IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Reconciliation: whether a transaction has cleared the bank. Existing rows
+-- start out pending.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS cleared BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- The import run that created a transaction, so a bad import can be rolled
+-- back as a unit. Manually entered transactions have no batch.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS import_batch_id UUID;
+CREATE INDEX IF NOT EXISTS idx_transactions_import_batch_id ON transactions(account_id, import_batch_id) WHERE import_batch_id IS NOT NULL;
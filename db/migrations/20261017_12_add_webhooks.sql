@@ -0,0 +1,12 @@
+-- Outgoing notifications of transaction changes. The secret signs each
+-- delivery so receivers can verify it came from the ledger.
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id         UUID PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+	url        TEXT NOT NULL,
+	events     TEXT[] NOT NULL,
+	secret     TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
@@ -0,0 +1,3 @@
+-- Supports looking accounts up by name, case-insensitively.
+
+CREATE INDEX IF NOT EXISTS idx_bank_accounts_user_name ON bank_accounts(user_id, lower(name));
@@ -0,0 +1,13 @@
+-- Per-user spending categories. Deleting a category leaves its
+-- transactions uncategorized.
+
+CREATE TABLE IF NOT EXISTS categories (
+	id      UUID PRIMARY KEY,
+	name    TEXT NOT NULL,
+	user_id TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_categories_user_id ON categories(user_id);
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES categories(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_transactions_category_id ON transactions(account_id, category_id);
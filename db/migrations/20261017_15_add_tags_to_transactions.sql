@@ -0,0 +1,4 @@
+-- Free-form labels on transactions, kept sorted and without duplicates.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,13 @@
+-- Auto-categorization rules. Lower priority runs first; a rule only ever
+-- fills in a missing category. Rules go with their category.
+
+CREATE TABLE IF NOT EXISTS category_rules (
+	id          UUID PRIMARY KEY,
+	user_id     TEXT NOT NULL REFERENCES "user"(id) ON DELETE CASCADE,
+	pattern     TEXT NOT NULL,
+	match_type  TEXT NOT NULL DEFAULT 'literal' CHECK (match_type IN ('literal', 'regex')),
+	type        TEXT CHECK (type IN ('income', 'expense')),
+	category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+	priority    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_category_rules_user_id ON category_rules(user_id, priority);
@@ -0,0 +1,5 @@
+-- Balance carried over from before the account's first transaction; every
+-- balance is opening_balance plus the account's signed transaction amounts.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS opening_balance NUMERIC(18,4) NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+-- Fingerprint of a CSV row imported with dedupe=true, so importing the same
+-- file again skips the rows already present.
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_content_hash
+  ON transactions(account_id, content_hash) WHERE content_hash IS NOT NULL;
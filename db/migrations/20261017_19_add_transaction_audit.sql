@@ -0,0 +1,62 @@
+-- History of every change to a transaction. A trigger writes it in the
+-- same database transaction as the change, whichever endpoint made it.
+
+CREATE TABLE IF NOT EXISTS transaction_audit (
+	id             BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	account_id     UUID NOT NULL REFERENCES bank_accounts(id) ON DELETE CASCADE,
+	transaction_id UUID NOT NULL,
+	action         TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+	changed_fields TEXT[] NOT NULL,
+	before         JSONB,
+	after          JSONB,
+	changed_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_transaction_audit_account_id ON transaction_audit(account_id, id DESC);
+
+CREATE OR REPLACE FUNCTION record_transaction_audit() RETURNS trigger
+LANGUAGE plpgsql AS $$
+DECLARE
+	old_row JSONB;
+	new_row JSONB;
+	account UUID;
+	fields  TEXT[];
+BEGIN
+	-- Amounts are kept as text so snapshots stay exact decimals.
+	IF TG_OP = 'DELETE' THEN
+		-- Deleting an account cascades to its transactions and its history.
+		IF NOT EXISTS (SELECT 1 FROM bank_accounts WHERE id = OLD.account_id) THEN
+			RETURN NULL;
+		END IF;
+		old_row := (to_jsonb(OLD) - 'updated_at') || jsonb_build_object('amount', OLD.amount::text);
+		account := OLD.account_id;
+	ELSE
+		new_row := (to_jsonb(NEW) - 'updated_at') || jsonb_build_object('amount', NEW.amount::text);
+		account := NEW.account_id;
+		IF TG_OP = 'UPDATE' THEN
+			old_row := (to_jsonb(OLD) - 'updated_at') || jsonb_build_object('amount', OLD.amount::text);
+		END IF;
+	END IF;
+
+	SELECT COALESCE(array_agg(key ORDER BY key), '{}') INTO fields
+	FROM jsonb_object_keys(COALESCE(new_row, old_row)) AS key
+	WHERE old_row -> key IS DISTINCT FROM new_row -> key;
+	IF TG_OP = 'UPDATE' AND cardinality(fields) = 0 THEN
+		RETURN NULL;
+	END IF;
+
+	INSERT INTO transaction_audit (account_id, transaction_id, action, changed_fields, before, after)
+	VALUES (
+		account,
+		(COALESCE(new_row, old_row) ->> 'id')::uuid,
+		CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END,
+		fields,
+		old_row,
+		new_row
+	);
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transaction_audit
+	AFTER INSERT OR UPDATE OR DELETE ON transactions
+	FOR EACH ROW EXECUTE FUNCTION record_transaction_audit();
@@ -0,0 +1,12 @@
+-- Savings targets for an account, compared against its balance by the
+-- goal progress endpoint. Goals go with their account.
+
+CREATE TABLE IF NOT EXISTS savings_goals (
+	id            UUID PRIMARY KEY,
+	account_id    UUID NOT NULL REFERENCES bank_accounts(id) ON DELETE CASCADE,
+	name          TEXT NOT NULL,
+	target_amount NUMERIC(18,4) NOT NULL CHECK (target_amount > 0),
+	target_date   DATE,
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_savings_goals_account_id ON savings_goals(account_id);
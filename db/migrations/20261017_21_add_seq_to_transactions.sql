@@ -0,0 +1,43 @@
+-- Human-friendly transaction numbers, 1, 2, 3... within each account.
+-- bank_accounts.transaction_seq holds the last number handed out; the
+-- trigger bumps it with an UPDATE, whose row lock makes concurrent inserts
+-- into one account take numbers one at a time. A transaction moved to
+-- another account takes that account's next number. Numbers of deleted
+-- transactions are not reused.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS transaction_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS seq BIGINT;
+
+UPDATE transactions t SET seq = n.seq
+FROM (
+	SELECT id, row_number() OVER (PARTITION BY account_id ORDER BY created_at, id) AS seq
+	FROM transactions
+) n
+WHERE n.id = t.id AND t.seq IS NULL;
+UPDATE bank_accounts a
+SET transaction_seq = COALESCE((SELECT MAX(t.seq) FROM transactions t WHERE t.account_id = a.id), 0);
+
+ALTER TABLE transactions ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_seq ON transactions(account_id, seq);
+
+CREATE OR REPLACE FUNCTION assign_transaction_seq() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'INSERT' OR NEW.account_id IS DISTINCT FROM OLD.account_id THEN
+		UPDATE bank_accounts SET transaction_seq = transaction_seq + 1
+		WHERE id = NEW.account_id
+		RETURNING transaction_seq INTO NEW.seq;
+		-- Report a missing account as the foreign key would, not as a
+		-- NULL seq.
+		IF NOT FOUND THEN
+			RAISE foreign_key_violation USING MESSAGE = format('account %s does not exist', NEW.account_id);
+		END IF;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transaction_seq
+	BEFORE INSERT OR UPDATE OF account_id ON transactions
+	FOR EACH ROW EXECUTE FUNCTION assign_transaction_seq();
@@ -0,0 +1,5 @@
+-- Accounts that must never go below zero, such as prepaid cards. When
+-- false, creating a transaction that would overdraw the account fails.
+
+ALTER TABLE bank_accounts
+  ADD COLUMN IF NOT EXISTS allow_negative BOOLEAN NOT NULL DEFAULT true;
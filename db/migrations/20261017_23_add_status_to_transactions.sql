@@ -0,0 +1,6 @@
+-- Pending (authorized, not yet settled) versus posted transactions, as bank
+-- feeds report them. Existing rows were entered as final, so they are
+-- posted.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'posted' CHECK (status IN ('pending', 'posted'));
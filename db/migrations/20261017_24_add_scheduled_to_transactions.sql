@@ -0,0 +1,7 @@
+-- Future-dated one-off transactions that stay out of the balance until
+-- their date arrives and scheduled_activate clears the flag. The partial
+-- index keeps finding the due ones cheap while almost every row is active.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS scheduled BOOLEAN NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_transactions_scheduled ON transactions(date) WHERE scheduled;
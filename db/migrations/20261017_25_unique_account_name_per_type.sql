@@ -0,0 +1,18 @@
+-- Account names are unique per user and type, ignoring case: a "Cash" bank
+-- account and a "Cash" cash account may coexist, two "Cash" cash accounts
+-- may not. Existing clashes keep their oldest-sorted account as is; the
+-- others get the start of their id appended so the index can be built.
+-- The unique index also serves name lookups, replacing the plain one.
+
+UPDATE bank_accounts a
+SET name = a.name || ' (' || left(a.id::text, 8) || ')'
+FROM (
+  SELECT id, row_number() OVER (
+    PARTITION BY user_id, lower(name), type ORDER BY sort_order, id
+  ) AS n
+  FROM bank_accounts
+) d
+WHERE d.id = a.id AND d.n > 1;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bank_accounts_user_name_type ON bank_accounts(user_id, lower(name), type);
+DROP INDEX IF EXISTS idx_bank_accounts_user_name;
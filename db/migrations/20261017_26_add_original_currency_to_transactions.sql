@@ -0,0 +1,12 @@
+-- An occasional purchase in another currency: amount stays in the account
+-- currency (and alone drives balances), while the charged currency and
+-- amount are kept for reference. Both are set or neither is.
+
+ALTER TABLE transactions
+  ADD COLUMN IF NOT EXISTS currency TEXT CHECK (currency ~ '^[A-Z]{3}$'),
+  ADD COLUMN IF NOT EXISTS original_amount NUMERIC(18,4);
+ALTER TABLE transactions
+  DROP CONSTRAINT IF EXISTS transactions_original_pair;
+ALTER TABLE transactions
+  ADD CONSTRAINT transactions_original_pair
+  CHECK ((currency IS NULL) = (original_amount IS NULL));
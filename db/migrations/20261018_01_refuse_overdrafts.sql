@@ -0,0 +1,104 @@
+-- Accounts with allow_negative off may not be overdrawn by any write, not
+-- only by creating a transaction: statement-level triggers check every
+-- insert, update and delete of transactions, and a lowered opening
+-- balance, and fail with SQLSTATE EL001 (PG_OVERDRAWN in lib/db.mts),
+-- rolling the whole write back.
+
+CREATE OR REPLACE FUNCTION refuse_overdraft(lowered UUID[]) RETURNS void
+LANGUAGE plpgsql AS $$
+DECLARE
+	overdrawn UUID;
+BEGIN
+	IF cardinality(lowered) = 0 THEN
+		RETURN;
+	END IF;
+	-- Lock first, in id order, so concurrent writes to one account queue up
+	-- and the balance below is read with every earlier write committed. NO
+	-- KEY UPDATE does not conflict with the KEY SHARE lock the foreign key
+	-- check already took, so two racing inserts cannot deadlock here.
+	PERFORM 1 FROM bank_accounts
+	WHERE id = ANY(lowered) AND NOT allow_negative
+	ORDER BY id
+	FOR NO KEY UPDATE;
+	-- Same rule as ACCOUNT_BALANCE in lib/balance.mts: active, posted
+	-- transactions on top of the opening balance.
+	SELECT a.id INTO overdrawn
+	FROM bank_accounts a
+	LEFT JOIN transactions t
+		ON t.account_id = a.id AND NOT t.scheduled AND t.status = 'posted'
+	WHERE a.id = ANY(lowered) AND NOT a.allow_negative
+	GROUP BY a.id
+	HAVING a.opening_balance + COALESCE(SUM(CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END), 0) < 0
+	LIMIT 1;
+	IF FOUND THEN
+		RAISE EXCEPTION 'account % would have a negative balance', overdrawn
+			USING ERRCODE = 'EL001';
+	END IF;
+END;
+$$;
+
+-- Only accounts whose balance the statement lowered are checked, so
+-- deposits, recategorizing or tagging still work on an account that is
+-- already below zero.
+CREATE OR REPLACE FUNCTION transactions_refuse_overdraft() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM new_rows
+			WHERE NOT scheduled AND status = 'posted'
+			GROUP BY account_id
+			HAVING SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) < 0
+		));
+	ELSIF TG_OP = 'DELETE' THEN
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM old_rows
+			WHERE NOT scheduled AND status = 'posted'
+			GROUP BY account_id
+			HAVING SUM(CASE WHEN type = 'income' THEN amount ELSE -amount END) > 0
+		));
+	ELSE
+		PERFORM refuse_overdraft(ARRAY(
+			SELECT account_id FROM (
+				SELECT account_id, CASE WHEN type = 'income' THEN amount ELSE -amount END AS delta
+				FROM new_rows
+				WHERE NOT scheduled AND status = 'posted'
+				UNION ALL
+				SELECT account_id, CASE WHEN type = 'income' THEN -amount ELSE amount END
+				FROM old_rows
+				WHERE NOT scheduled AND status = 'posted'
+			) c
+			GROUP BY account_id
+			HAVING SUM(delta) < 0
+		));
+	END IF;
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_insert
+	AFTER INSERT ON transactions
+	REFERENCING NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_update
+	AFTER UPDATE ON transactions
+	REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+CREATE OR REPLACE TRIGGER transactions_refuse_overdraft_delete
+	AFTER DELETE ON transactions
+	REFERENCING OLD TABLE AS old_rows
+	FOR EACH STATEMENT EXECUTE FUNCTION transactions_refuse_overdraft();
+
+CREATE OR REPLACE FUNCTION bank_accounts_refuse_overdraft() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	PERFORM refuse_overdraft(ARRAY[NEW.id]);
+	RETURN NULL;
+END;
+$$;
+
+CREATE OR REPLACE TRIGGER bank_accounts_refuse_overdraft
+	AFTER UPDATE OF opening_balance ON bank_accounts
+	FOR EACH ROW
+	WHEN (NEW.opening_balance < OLD.opening_balance)
+	EXECUTE FUNCTION bank_accounts_refuse_overdraft();
@@ -0,0 +1,7 @@
+-- A split may carry its own category, so reports can break a mixed
+-- purchase down by line item. Splits without one fall back to their
+-- transaction's category; deleting a category clears it like on
+-- transactions.
+
+ALTER TABLE transaction_splits
+  ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES categories(id) ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+-- When an account's own fields last changed through PATCH. Cached reports
+-- fold it into their key, so editing the opening balance refreshes them.
+-- Touching last_used_at or reordering leaves it alone.
+
+ALTER TABLE bank_accounts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,63 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import {
+  apiHandler,
+  deleteMissing,
+  err,
+  json,
+  readJson,
+  saved,
+  serverError,
+} from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      const [row] =
+        await sql`SELECT id, name FROM account_groups WHERE id = ${id} AND user_id = ${userId}`
+      if (!row) return err('Not found', 404)
+      return json(row)
+    }
+
+    if (method === 'PATCH') {
+      const read = await readJson<{ name?: unknown }>(req, { name: 'string' })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const name = typeof body.name === 'string' ? body.name.trim() : ''
+      if (!name) return err('name cannot be empty', 400)
+      const [updated] = await sql`
+        UPDATE account_groups SET name = ${name}
+        WHERE id = ${id} AND user_id = ${userId}
+        RETURNING id, name
+      `
+      if (!updated) return err('Not found', 404)
+      return saved(req, updated)
+    }
+
+    if (method === 'DELETE') {
+      // Member accounts are kept: the foreign key sets their group_id to
+      // NULL.
+      const [deleted] =
+        await sql`DELETE FROM account_groups WHERE id = ${id} AND user_id = ${userId} RETURNING id`
+      if (!deleted) return deleteMissing(url)
+      return new Response(null, { status: 204 })
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
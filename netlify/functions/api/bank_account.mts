@@ -1,96 +1,211 @@
 import type { Context } from '@netlify/functions'
-import { neon } from '@neondatabase/serverless'
+import {
+  ACCOUNT_BODY,
+  accountNameTaken,
+  isAccountNameTaken,
+  presentAccounts,
+} from '../lib/accounts.mts'
+import { parseAmountIn, presentAmounts } from '../lib/amount.mts'
 import { getSessionFromRequest } from '../lib/auth.mts'
-import { handlePreflight, withCors } from '../lib/cors.mts'
+import { parseCurrency } from '../lib/currency.mts'
+import { PG_OVERDRAWN, getDb, isPgError } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import {
+  apiHandler,
+  choiceErr,
+  deleteMissing,
+  err,
+  json,
+  prefersMinimal,
+  readJson,
+  saved,
+  serverError,
+} from '../lib/http.mts'
+import {
+  ACCOUNT_TYPES,
+  TRANSACTION_TYPES,
+  isUuid,
+  parseAccountType,
+  parseTransactionType,
+} from '../lib/params.mts'
 
-const DATABASE_URL = process.env.DATABASE_URL
-
-async function getDb() {
-  if (!DATABASE_URL) throw new Response('DATABASE_URL not set', { status: 500 })
-  return neon(DATABASE_URL)
-}
-
-function json<T>(data: T, status = 200) {
-  return new Response(JSON.stringify(data), {
-    status,
-    headers: { 'Content-Type': 'application/json' },
-  })
-}
-
-function err(message: string, status: number) {
-  return json({ error: message }, status)
-}
-
-export default async (req: Request, _context: Context) => {
-  const preflight = handlePreflight(req)
-  if (preflight) return preflight
+/** `?includeRecent` with no count embeds this many transactions. */
+const DEFAULT_RECENT = 5
+const MAX_RECENT = 50
 
+export default apiHandler(async (req: Request, context: Context) => {
   const session = await getSessionFromRequest(req)
-  if (!session) return withCors(req, err('Unauthorized', 401))
+  if (!session) return err('Unauthorized', 401)
   const userId = session.user.id
 
   const url = new URL(req.url)
   const id = url.searchParams.get('id')
-  if (!id) return withCors(req, err('id query parameter is required', 400))
+  if (!id) return err('id query parameter is required', 400)
 
   const method = req.method
+  const units = requestAmountUnits(req)
 
   try {
     const sql = await getDb()
 
     if (method === 'GET') {
+      const rawRecent = url.searchParams.get('includeRecent')
+      const recent = rawRecent?.trim() ? Number(rawRecent) : DEFAULT_RECENT
+      if (
+        rawRecent !== null &&
+        (!Number.isInteger(recent) || recent < 1 || recent > MAX_RECENT)
+      )
+        return err(`includeRecent must be between 1 and ${MAX_RECENT}`, 400)
+
       const [row] =
-        await sql`SELECT id, name, type FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
-      if (!row) return withCors(req, err('Not found', 404))
-      return withCors(req, json(row))
+        await sql`SELECT id, name, type, currency, sort_order, default_transaction_type, last_used_at, group_id, opening_balance::text, allow_negative FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+      if (!row) return err('Not found', 404)
+      const [account] = presentAccounts([row], units)
+      if (rawRecent === null) return json(account)
+
+      // One call for an account page: the account and its latest activity.
+      const recentTransactions = await sql`
+        SELECT id, account_id, amount::text, date, description, type, transfer_group, cleared, status, scheduled, currency, original_amount::text, category_id, tags, seq
+        FROM transactions
+        WHERE account_id = ${id}
+        ORDER BY date DESC, id DESC
+        LIMIT ${recent}
+      `
+      return json({
+        account,
+        recentTransactions: presentAmounts(recentTransactions, units),
+      })
     }
 
     if (method === 'PATCH') {
-      let body: { name?: string; type?: string }
-      try {
-        body = (await req.json()) as { name?: string; type?: string }
-      } catch {
-        return withCors(req, err('Invalid JSON', 400))
-      }
+      const read = await readJson<{
+        name?: string
+        type?: string
+        currency?: string
+        default_transaction_type?: string | null
+        group_id?: string | null
+        opening_balance?: number | string
+        allow_negative?: boolean | null
+      }>(req, ACCOUNT_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
       const name =
         body.name !== undefined ? String(body.name).trim() : undefined
       const type =
-        body.type !== undefined ? String(body.type).trim() : undefined
-      if (name !== undefined && !name)
-        return withCors(req, err('name cannot be empty', 400))
-      if (type !== undefined && !type)
-        return withCors(req, err('type cannot be empty', 400))
-      if (name === undefined && type === undefined) {
-        return withCors(req, err('No fields to update', 400))
+        body.type !== undefined ? parseAccountType(body.type) : undefined
+      const defaultType =
+        body.default_transaction_type == null
+          ? body.default_transaction_type
+          : parseTransactionType(body.default_transaction_type)
+      const currency =
+        body.currency !== undefined ? parseCurrency(body.currency) : undefined
+      if (name !== undefined && !name) return err('name cannot be empty', 400)
+      if (type === null)
+        return choiceErr(
+          `type must be one of ${ACCOUNT_TYPES.join(', ')}`,
+          body.type,
+          ACCOUNT_TYPES,
+        )
+      if (currency === null)
+        return err('currency must be a 3-letter ISO 4217 code', 400)
+      if (body.default_transaction_type != null && !defaultType)
+        return choiceErr(
+          'default_transaction_type must be income or expense',
+          body.default_transaction_type,
+          TRANSACTION_TYPES,
+        )
+      const groupId = body.group_id
+      if (
+        groupId != null &&
+        (typeof groupId !== 'string' || !isUuid(groupId))
+      )
+        return err('group_id must be a UUID', 400)
+      const openingBalance =
+        body.opening_balance != null
+          ? parseAmountIn(body.opening_balance, units)
+          : undefined
+      if (openingBalance === null)
+        return err('opening_balance must be a number', 400)
+      const allowNegative = body.allow_negative ?? undefined
+      if (
+        name === undefined &&
+        type === undefined &&
+        currency === undefined &&
+        defaultType === undefined &&
+        groupId === undefined &&
+        openingBalance === undefined &&
+        allowNegative === undefined
+      ) {
+        return err('No fields to update', 400)
       }
-      let updated: { id: string; name: string; type: string } | null
-      if (name !== undefined && type !== undefined) {
-        ;[updated] = await sql`
-          UPDATE bank_accounts SET name = ${name}, type = ${type} WHERE id = ${id} AND user_id = ${userId} RETURNING id, name, type
-        `
-      } else if (name !== undefined) {
-        ;[updated] = await sql`
-          UPDATE bank_accounts SET name = ${name} WHERE id = ${id} AND user_id = ${userId} RETURNING id, name, type
-        `
-      } else {
-        ;[updated] = await sql`
-          UPDATE bank_accounts SET type = ${type} WHERE id = ${id} AND user_id = ${userId} RETURNING id, name, type
+      if (groupId) {
+        const [group] =
+          await sql`SELECT id FROM account_groups WHERE id = ${groupId} AND user_id = ${userId}`
+        if (!group) return err('group not found', 400)
+      }
+      // Omitted fields keep their value; default_transaction_type and
+      // group_id may be cleared with an explicit null. The CTE reads the
+      // row as it was before the update.
+      try {
+        const [updated] = await sql`
+          WITH previous AS (
+            SELECT type FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}
+          )
+          UPDATE bank_accounts
+          SET name = COALESCE(${name ?? null}, name),
+            type = COALESCE(${type ?? null}, type),
+            currency = COALESCE(${currency ?? null}, currency),
+            opening_balance = COALESCE(${openingBalance ?? null}::numeric, opening_balance),
+            allow_negative = COALESCE(${allowNegative ?? null}::boolean, allow_negative),
+            default_transaction_type = CASE
+              WHEN ${defaultType !== undefined} THEN ${defaultType ?? null}
+              ELSE default_transaction_type
+            END,
+            group_id = CASE
+              WHEN ${groupId !== undefined} THEN ${groupId ?? null}::uuid
+              ELSE group_id
+            END,
+            updated_at = now()
+          WHERE id = ${id} AND user_id = ${userId}
+          RETURNING id, name, type, currency, sort_order, default_transaction_type, group_id, opening_balance::text, allow_negative,
+            (SELECT type FROM previous) AS previous_type
         `
+        if (!updated) return err('Not found', 404)
+        const { previous_type: previousType, ...stored } = updated
+        const [account] = presentAccounts([stored], units)
+        if (account.type === previousType) return saved(req, account)
+        // Reports and type rules read the new type for every existing
+        // transaction too, so the change goes through with a warning.
+        const [{ count }] =
+          await sql`SELECT COUNT(*)::int AS count FROM transactions WHERE account_id = ${id}`
+        if (count === 0 || prefersMinimal(req)) return saved(req, account)
+        return json({
+          account,
+          warnings: [`type changed with ${count} existing transactions`],
+        })
+      } catch (e) {
+        // Lowering the opening balance of an account with allow_negative
+        // off is refused like any other overdraft.
+        if (isPgError(e, PG_OVERDRAWN))
+          return err('opening_balance would make the balance negative', 409)
+        if (!isAccountNameTaken(e)) throw e
+        // Only one of name and type may be in the body; report the pair
+        // that clashed.
+        const [current] =
+          await sql`SELECT name, type FROM bank_accounts WHERE id = ${id}`
+        return accountNameTaken(name ?? current.name, type ?? current.type)
       }
-      if (!updated) return withCors(req, err('Not found', 404))
-      return withCors(req, json(updated))
     }
 
     if (method === 'DELETE') {
       const [deleted] =
         await sql`DELETE FROM bank_accounts WHERE id = ${id} AND user_id = ${userId} RETURNING id`
-      if (!deleted) return withCors(req, err('Not found', 404))
-      return withCors(req, new Response(null, { status: 204 }))
+      if (!deleted) return deleteMissing(url)
+      return new Response(null, { status: 204 })
     }
 
-    return withCors(req, err('Method not allowed', 405))
+    return err('Method not allowed', 405)
   } catch (e) {
-    console.error(e)
-    return withCors(req, err('Internal server error', 500))
+    return serverError(req, context, e)
   }
-}
+})
@@ -0,0 +1,129 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_account.mts'
+
+const account = { id: 'acc-1', name: 'Checking', type: 'bank' }
+
+function get(query: string) {
+  return handler(apiRequest(`bank_account?id=acc-1${query}`), context)
+}
+
+describe('GET bank_account', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('returns the bare account without includeRecent', async () => {
+    sql.mockResolvedValueOnce([account])
+    const res = await get('')
+    expect(await res.json()).toEqual(account)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('embeds the most recent transactions', async () => {
+    sql.mockResolvedValueOnce([account])
+    sql.mockResolvedValueOnce([{ id: 'tx-2' }, { id: 'tx-1' }])
+    const res = await get('&includeRecent=2')
+    expect(await res.json()).toEqual({
+      account,
+      recentTransactions: [{ id: 'tx-2' }, { id: 'tx-1' }],
+    })
+    expect(sql.mock.calls[1]).toContain(2)
+  })
+
+  it('defaults the count when includeRecent has no value', async () => {
+    sql.mockResolvedValueOnce([account])
+    sql.mockResolvedValueOnce([])
+    await get('&includeRecent')
+    expect(sql.mock.calls[1]).toContain(5)
+  })
+
+  it('rejects a count above the cap', async () => {
+    const res = await get('&includeRecent=51')
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'includeRecent must be between 1 and 50',
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
+
+describe('PATCH bank_account', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  function patch(body: unknown) {
+    return handler(
+      apiRequest('bank_account?id=acc-1', {
+        method: 'PATCH',
+        body: JSON.stringify(body),
+      }),
+      context,
+    )
+  }
+
+  it('warns when the type of an account with transactions changes', async () => {
+    sql.mockResolvedValueOnce([
+      { ...account, type: 'card', previous_type: 'bank' },
+    ])
+    sql.mockResolvedValueOnce([{ count: 12 }])
+    const res = await patch({ type: 'card' })
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      account: { ...account, type: 'card' },
+      warnings: ['type changed with 12 existing transactions'],
+    })
+  })
+
+  it('does not warn for an account without transactions', async () => {
+    sql.mockResolvedValueOnce([
+      { ...account, type: 'card', previous_type: 'bank' },
+    ])
+    sql.mockResolvedValueOnce([{ count: 0 }])
+    const res = await patch({ type: 'card' })
+    expect(await res.json()).toEqual({ ...account, type: 'card' })
+  })
+
+  it('skips the count when the type stays the same', async () => {
+    sql.mockResolvedValueOnce([{ ...account, previous_type: 'bank' }])
+    const res = await patch({ name: 'Checking' })
+    expect(await res.json()).toEqual(account)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+})
+
+describe('DELETE bank_account', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  function remove(query = '') {
+    return handler(
+      apiRequest(`bank_account?id=acc-1${query}`, {
+        method: 'DELETE',
+      }),
+      context,
+    )
+  }
+
+  it('returns 204 with an empty body', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    const res = await remove()
+    expect(res.status).toBe(204)
+    expect(await res.text()).toBe('')
+  })
+
+  it('returns 404 for a missing account by default', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await remove()
+    expect(res.status).toBe(404)
+  })
+
+  it('treats a missing account as deleted with idempotent=true', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await remove('&idempotent=true')
+    expect(res.status).toBe(204)
+    expect(await res.text()).toBe('')
+  })
+})
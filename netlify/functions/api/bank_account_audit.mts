@@ -0,0 +1,66 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePagination } from '../lib/pagination.mts'
+
+/**
+ * Sync clients replay the change history in bulk, deletes included, so
+ * they may page further than the interactive lists.
+ */
+const SYNC_MAX_PAGE_SIZE = 1000
+
+/**
+ * The account's change history, newest first: every create, update and
+ * delete of its transactions, with the fields that changed and snapshots
+ * of the row before and after. The rows are written by a database trigger,
+ * so nothing that changes a transaction can skip them.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const paging = parsePagination(url, SYNC_MAX_PAGE_SIZE)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Ids grow with each change, so they order entries that share a
+    // timestamp (one statement touching many rows).
+    const [rows, [{ total }]] = await Promise.all([
+      sql`
+        SELECT id, transaction_id AS "transactionId", action,
+          changed_fields AS "changedFields", changed_at AS "changedAt",
+          before, after
+        FROM transaction_audit
+        WHERE account_id = ${id}
+        ORDER BY id DESC
+        LIMIT ${pageSize} OFFSET ${offset}
+      `,
+      sql`
+        SELECT COUNT(*)::int AS total
+        FROM transaction_audit
+        WHERE account_id = ${id}
+      `,
+    ])
+
+    return json({ data: rows, total, page, pageSize })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
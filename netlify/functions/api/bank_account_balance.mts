@@ -0,0 +1,84 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import {
+  IS_ACTIVE,
+  SIGNED_AMOUNT,
+  accountBalance,
+  countedInBalance,
+} from '../lib/balance.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import {
+  moneyFormatter,
+  requestLocale,
+  wantsFormatted,
+} from '../lib/money-format.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const rawAsOf = url.searchParams.get('asOf')?.trim()
+  const asOf = rawAsOf ? new Date(rawAsOf) : null
+  if (asOf && Number.isNaN(asOf.getTime()))
+    return err('asOf must be a valid date', 400)
+
+  const includePending = url.searchParams.get('includePending') === 'true'
+
+  try {
+    const sql = await getDb()
+
+    const counted = countedInBalance(includePending)
+    const q = new QueryBuilder()
+    q.where(`a.id = ${q.param(id)}`)
+    q.where(`a.user_id = ${q.param(userId)}`)
+    const dateFilter = asOf ? `AND t.date <= ${q.param(asOf.toISOString())}` : ''
+
+    const [row] = await sql.query(
+      `SELECT (${accountBalance(includePending)})::text AS balance,
+         (a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE ${counted} AND t.cleared), 0))::text AS cleared,
+         COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE ${counted} AND NOT t.cleared), 0)::text AS uncleared,
+         COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE t.status = 'pending'), 0)::text AS pending,
+         a.currency
+       FROM bank_accounts a
+       LEFT JOIN transactions t ON t.account_id = a.id AND ${IS_ACTIVE} ${dateFilter}
+       ${q.whereSql()}
+       GROUP BY a.id`,
+      q.params,
+    )
+    if (!row) return err('Not found', 404)
+
+    // Reconciliation view: cleared is what the bank statement should show,
+    // uncleared is still in flight. The opening balance counts as cleared.
+    // pending is the net of transactions the bank has not posted yet.
+    const balance = {
+      balance: row.balance,
+      cleared: row.cleared,
+      uncleared: row.uncleared,
+      pending: row.pending,
+      includePending,
+      asOf,
+    }
+    if (!wantsFormatted(url)) return json(balance)
+    const format = moneyFormatter(row.currency, requestLocale(req, url))
+    return json({
+      ...balance,
+      balanceFormatted: format(row.balance),
+      clearedFormatted: format(row.cleared),
+      unclearedFormatted: format(row.uncleared),
+      pendingFormatted: format(row.pending),
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,115 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_account_balance.mts'
+
+describe('GET bank_account_balance', () => {
+  beforeEach(() => {
+    sql.query.mockReset()
+  })
+
+  it('reports the opening balance of an account with no transactions', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        balance: '250.0000',
+        cleared: '250.0000',
+        uncleared: '0',
+        pending: '0',
+      },
+    ])
+    const res = await handler(
+      apiRequest('bank_account_balance?id=acc-1'),
+      context,
+    )
+    expect(await res.json()).toEqual({
+      balance: '250.0000',
+      cleared: '250.0000',
+      uncleared: '0',
+      pending: '0',
+      includePending: false,
+      asOf: null,
+    })
+    // The account row drives the query, so it is found with no transactions
+    // joined and its opening balance is the whole balance.
+    const [text] = sql.query.mock.calls[0]
+    expect(text).toContain('FROM bank_accounts a')
+    expect(text).toContain('LEFT JOIN transactions t')
+    expect(text).toContain('a.opening_balance + COALESCE(SUM(')
+  })
+
+  it('adds display strings in the account currency with formatted=true', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        balance: '1234.5000',
+        cleared: '1000.0000',
+        uncleared: '234.5000',
+        pending: '0',
+        currency: 'EUR',
+      },
+    ])
+    const res = await handler(
+      apiRequest('bank_account_balance?id=acc-1&formatted=true', {
+        headers: { 'accept-language': 'de-DE,en;q=0.5' },
+      }),
+      context,
+    )
+    expect(await res.json()).toMatchObject({
+      balance: '1234.5000',
+      balanceFormatted: '1.234,50\u00a0€',
+      clearedFormatted: '1.000,00\u00a0€',
+      unclearedFormatted: '234,50\u00a0€',
+      pendingFormatted: '0,00\u00a0€',
+    })
+  })
+
+  it('leaves pending transactions out of the balance by default', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        balance: '100.0000',
+        cleared: '100.0000',
+        uncleared: '0',
+        pending: '-20',
+      },
+    ])
+    const res = await handler(
+      apiRequest('bank_account_balance?id=acc-1'),
+      context,
+    )
+    expect(await res.json()).toMatchObject({ pending: '-20' })
+    const [text] = sql.query.mock.calls[0]
+    expect(text).toContain(
+      "FILTER (WHERE NOT t.scheduled AND t.status = 'posted')",
+    )
+    expect(text).toContain("FILTER (WHERE t.status = 'pending')")
+    // Scheduled transactions wait for activation whatever their status.
+    expect(text).toContain('ON t.account_id = a.id AND NOT t.scheduled')
+  })
+
+  it('counts pending transactions with includePending=true', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        balance: '80.0000',
+        cleared: '100.0000',
+        uncleared: '-20',
+        pending: '-20',
+      },
+    ])
+    const res = await handler(
+      apiRequest('bank_account_balance?id=acc-1&includePending=true'),
+      context,
+    )
+    expect(await res.json()).toMatchObject({
+      balance: '80.0000',
+      includePending: true,
+    })
+    expect(sql.query.mock.calls[0][0]).not.toContain("t.status = 'posted'")
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.query.mockResolvedValueOnce([])
+    const res = await handler(
+      apiRequest('bank_account_balance?id=acc-2'),
+      context,
+    )
+    expect(res.status).toBe(404)
+  })
+})
@@ -0,0 +1,107 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import {
+  BALANCE_SUM,
+  SIGNED_AMOUNT,
+  countedInBalance,
+} from '../lib/balance.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { parseInterval } from '../lib/reports.mts'
+
+/** Upper bound on points per series, e.g. about 10 years of days. */
+const MAX_POINTS = 3660
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsedInterval = parseInterval(url)
+  if ('error' in parsedInterval) return err(parsedInterval.error, 400)
+  const { interval } = parsedInterval
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const q = new QueryBuilder()
+    const accountParam = q.param(id)
+    // Without from/to the series spans the account's transactions.
+    const lo = `COALESCE(${q.param(from ?? null)}::timestamptz, MIN(t.date))`
+    const hi = `COALESCE(${q.param(to ?? null)}::timestamptz, MAX(t.date))`
+    const upTo = to ? `AND t.date <= ${q.param(to)}::timestamptz` : ''
+
+    // The opening balance is the account's opening_balance plus everything
+    // before the first bucket; each point then adds a running total of
+    // per-bucket net amounts. Buckets without transactions come from the
+    // generated series with a net of zero, so they carry the previous
+    // balance forward. interval is
+    // allowlisted by parseInterval.
+    const rows = await sql.query(
+      `WITH bounds AS (
+         SELECT date_trunc('${interval}', ${lo}) AS lo,
+           date_trunc('${interval}', ${hi}) AS hi
+         FROM transactions t
+         WHERE t.account_id = ${accountParam}
+       ),
+       series AS (
+         SELECT generate_series(lo, hi, interval '1 ${interval}') AS bucket
+         FROM bounds
+         LIMIT ${MAX_POINTS + 1}
+       ),
+       opening AS (
+         SELECT a.opening_balance + (
+           SELECT ${BALANCE_SUM}
+           FROM transactions t
+           WHERE t.account_id = a.id
+             AND t.date < (SELECT lo FROM bounds)
+         ) AS balance
+         FROM bank_accounts a
+         WHERE a.id = ${accountParam}
+       ),
+       buckets AS (
+         SELECT date_trunc('${interval}', t.date) AS bucket,
+           SUM(${SIGNED_AMOUNT}) AS net
+         FROM transactions t
+         WHERE t.account_id = ${accountParam}
+           AND ${countedInBalance()}
+           AND t.date >= (SELECT lo FROM bounds) ${upTo}
+         GROUP BY 1
+       )
+       SELECT s.bucket AS "periodStart",
+         ((SELECT balance FROM opening)
+           + SUM(COALESCE(b.net, 0)) OVER (ORDER BY s.bucket))::text AS balance
+       FROM series s
+       LEFT JOIN buckets b ON b.bucket = s.bucket
+       ORDER BY s.bucket`,
+      q.params,
+    )
+
+    if (rows.length > MAX_POINTS) {
+      return err(
+        `range spans more than ${MAX_POINTS} intervals; use a larger interval`,
+        400,
+      )
+    }
+    return json({ interval, points: rows })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,52 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+/**
+ * What `DELETE bank_account` would remove, for a confirmation dialog:
+ * every transaction (scheduled and pending included) with its date range
+ * and totals, their splits, and the account's savings goals. Transfers
+ * are counted separately, as deleting one leg leaves the other account's
+ * leg behind. Nothing is changed.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const [preview] = await sql`
+      SELECT a.id, a.name,
+        COUNT(t.id)::int AS "transactionCount",
+        MIN(t.date) AS "firstTransactionAt",
+        MAX(t.date) AS "lastTransactionAt",
+        COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)::text AS income,
+        COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0)::text AS expense,
+        COUNT(t.id) FILTER (WHERE t.transfer_group IS NOT NULL)::int AS "transferCount",
+        (SELECT COUNT(*)::int FROM transaction_splits s
+          JOIN transactions st ON st.id = s.transaction_id
+          WHERE st.account_id = a.id) AS "splitCount",
+        (SELECT COUNT(*)::int FROM savings_goals g
+          WHERE g.account_id = a.id) AS "savingsGoalCount"
+      FROM bank_accounts a
+      LEFT JOIN transactions t ON t.account_id = a.id
+      WHERE a.id = ${id} AND a.user_id = ${userId}
+      GROUP BY a.id
+    `
+    if (!preview) return err('Not found', 404)
+    return json(preview)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
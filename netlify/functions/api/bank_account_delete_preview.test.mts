@@ -0,0 +1,56 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_account_delete_preview.mts'
+
+function get(method = 'GET') {
+  return handler(
+    apiRequest('bank_account_delete_preview?id=acc-1', {
+      method,
+    }),
+    context,
+  )
+}
+
+describe('GET bank_account_delete_preview', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('reports zeros and no date range for an empty account', async () => {
+    const empty = {
+      id: 'acc-1',
+      name: 'Old wallet',
+      transactionCount: 0,
+      firstTransactionAt: null,
+      lastTransactionAt: null,
+      income: '0',
+      expense: '0',
+      transferCount: 0,
+      splitCount: 0,
+      savingsGoalCount: 0,
+    }
+    sql.mockResolvedValueOnce([empty])
+    const res = await get()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual(empty)
+  })
+
+  it('only reads', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', transactionCount: 3 }])
+    await get()
+    expect(sql).toHaveBeenCalledTimes(1)
+    expect(sql.mock.calls[0][0].join('')).not.toMatch(/DELETE|UPDATE/)
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await get()
+    expect(res.status).toBe(404)
+  })
+
+  it('rejects other methods', async () => {
+    const res = await get('DELETE')
+    expect(res.status).toBe(405)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
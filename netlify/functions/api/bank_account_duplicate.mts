@@ -0,0 +1,57 @@
+import type { Context } from '@netlify/functions'
+import {
+  accountNameTaken,
+  isAccountNameTaken,
+  presentAccounts,
+} from '../lib/accounts.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, created, err, serverError } from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    try {
+      // Read and insert in one statement so the copy is atomic. Transactions
+      // are intentionally not copied.
+      const [row] = await sql`
+        INSERT INTO bank_accounts (id, name, type, currency, user_id, sort_order, default_transaction_type, opening_balance, allow_negative)
+        SELECT ${newId()}, name || ' (copy)', type, currency, user_id,
+          (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM bank_accounts WHERE user_id = ${userId}),
+          default_transaction_type, opening_balance, allow_negative
+        FROM bank_accounts
+        WHERE id = ${id} AND user_id = ${userId}
+        RETURNING id, name, type, currency, sort_order, default_transaction_type, opening_balance::text, allow_negative
+      `
+      if (!row) return err('Not found', 404)
+      return created(
+        req,
+        `bank_account?id=${encodeURIComponent(row.id)}`,
+        presentAccounts([row], requestAmountUnits(req))[0],
+      )
+    } catch (e) {
+      // The account was already copied once and the copy kept its name.
+      if (!isAccountNameTaken(e)) throw e
+      const [source] =
+        await sql`SELECT name, type FROM bank_accounts WHERE id = ${id}`
+      return accountNameTaken(`${source.name} (copy)`, source.type)
+    }
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
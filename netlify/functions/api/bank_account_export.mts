@@ -0,0 +1,69 @@
+import type { Context } from '@netlify/functions'
+import { loadBackup } from '../lib/account-backup.mts'
+import type { AccountRow } from '../lib/account-backup.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { toQif } from '../lib/qif.mts'
+import type { QifTransaction } from '../lib/qif.mts'
+import { withRanges } from '../lib/range.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const format = url.searchParams.get('format') ?? 'json'
+  if (format !== 'json' && format !== 'qif')
+    return err('format must be json or qif', 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id, name, type, currency, default_transaction_type, opening_balance::text FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    if (format === 'qif') {
+      const rows = await sql`
+        SELECT t.amount::text, t.date, t.description, t.type, c.name AS category
+        FROM transactions t
+        LEFT JOIN categories c ON c.id = t.category_id
+        WHERE t.account_id = ${id}
+        ORDER BY t.date, t.created_at
+      `
+      const res = new Response(
+        toQif(account.type, rows as QifTransaction[]),
+        {
+          headers: {
+            'Content-Type': 'application/qif; charset=utf-8',
+            'Content-Disposition': `attachment; filename="account-${id}.qif"`,
+          },
+        },
+      )
+      return await withRanges(req, res)
+    }
+
+    const backup = await loadBackup(
+      sql,
+      account as AccountRow & { id: string },
+    )
+    const res = json(backup)
+    res.headers.set(
+      'Content-Disposition',
+      `attachment; filename="account-${id}.json"`,
+    )
+    // Large exports can be resumed with a Range request.
+    return await withRanges(req, res)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
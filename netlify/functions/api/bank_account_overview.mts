@@ -0,0 +1,56 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { ACCOUNT_BALANCE, IS_ACTIVE } from '../lib/balance.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+/**
+ * An account's profile in one query: first and last transaction dates,
+ * lifetime income and expense, transaction count and current balance.
+ * Scheduled transactions are left out until activated. Lifetime totals
+ * include transfers and pending transactions, as they are all money that
+ * moved through the account; the balance only counts posted ones, like
+ * the default `bank_account_balance`. With no transactions the dates are
+ * null and the totals zero.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const q = new QueryBuilder()
+    q.where(`a.id = ${q.param(id)}`)
+    q.where(`a.user_id = ${q.param(userId)}`)
+
+    const [overview] = await sql.query(
+      `SELECT a.id, a.name, a.type, a.currency,
+         MIN(t.date) AS "firstTransactionAt",
+         MAX(t.date) AS "lastTransactionAt",
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)::text AS income,
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0)::text AS expense,
+         COUNT(t.id)::int AS "transactionCount",
+         (${ACCOUNT_BALANCE})::text AS balance
+       FROM bank_accounts a
+       LEFT JOIN transactions t ON t.account_id = a.id AND ${IS_ACTIVE}
+       ${q.whereSql()}
+       GROUP BY a.id`,
+      q.params,
+    )
+    if (!overview) return err('Not found', 404)
+    return json(overview)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,74 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_account_overview.mts'
+
+const account = {
+  id: 'acc-1',
+  name: 'Checking',
+  type: 'bank',
+  currency: 'USD',
+}
+
+function get(query = 'id=acc-1') {
+  return handler(apiRequest(`bank_account_overview?${query}`), context)
+}
+
+describe('GET bank_account_overview', () => {
+  beforeEach(() => {
+    sql.query.mockReset()
+  })
+
+  it('returns the lifetime figures from a single query', async () => {
+    const overview = {
+      ...account,
+      firstTransactionAt: '2024-01-03T00:00:00.000Z',
+      lastTransactionAt: '2025-02-01T00:00:00.000Z',
+      income: '5000.0000',
+      expense: '3200.5000',
+      transactionCount: 48,
+      balance: '1899.5000',
+    }
+    sql.query.mockResolvedValueOnce([overview])
+    const res = await get()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual(overview)
+    expect(sql.query).toHaveBeenCalledTimes(1)
+    expect(sql.query.mock.calls[0][1]).toEqual(['acc-1', 'user-1'])
+  })
+
+  it('returns null dates and zero totals for an account without transactions', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        ...account,
+        firstTransactionAt: null,
+        lastTransactionAt: null,
+        income: '0',
+        expense: '0',
+        transactionCount: 0,
+        balance: '100.0000',
+      },
+    ])
+    const res = await get()
+    expect(await res.json()).toEqual({
+      ...account,
+      firstTransactionAt: null,
+      lastTransactionAt: null,
+      income: '0',
+      expense: '0',
+      transactionCount: 0,
+      balance: '100.0000',
+    })
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.query.mockResolvedValueOnce([])
+    const res = await get()
+    expect(res.status).toBe(404)
+  })
+
+  it('requires an id', async () => {
+    const res = await get('')
+    expect(res.status).toBe(400)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,112 @@
+import type { Context } from '@netlify/functions'
+import { presentAmount, presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { SIGNED_AMOUNT, countedInBalance } from '../lib/balance.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parseMonth } from '../lib/params.mts'
+
+/**
+ * Transactions a statement shows: posted and active, as in the default
+ * account balance, so the closing balance matches it at month end.
+ */
+const ON_STATEMENT = countedInBalance()
+
+/**
+ * A monthly statement in one payload: the account, opening and closing
+ * balances, totals, and the month's transactions oldest first with the
+ * balance after each. Both queries run in one transaction so they read
+ * the same snapshot and the running balances end on the closing balance.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const range = parseMonth(url.searchParams.get('month'))
+  if (!range) return err('month must be in YYYY-MM format', 400)
+  const units = requestAmountUnits(req)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(
+      sql,
+      id,
+      url,
+      range.end,
+      async () => {
+        const params = [id, range.start, range.end]
+        const [[header], rows] = await sql.transaction([
+          sql.query(
+            `SELECT a.id, a.name, a.type, a.currency,
+               (a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE t.date < $2), 0))::text AS "openingBalance",
+               (a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}), 0))::text AS "closingBalance",
+               COALESCE(SUM(t.amount) FILTER (WHERE t.date >= $2 AND t.type = 'income'), 0)::text AS income,
+               COALESCE(SUM(t.amount) FILTER (WHERE t.date >= $2 AND t.type = 'expense'), 0)::text AS expense
+             FROM bank_accounts a
+             LEFT JOIN transactions t
+               ON t.account_id = a.id AND t.date < $3 AND ${ON_STATEMENT}
+             WHERE a.id = $1
+             GROUP BY a.id`,
+            params,
+          ),
+          sql.query(
+            `SELECT t.id, t.seq, t.date, t.description, t.type, t.amount::text, t.category_id, t.transfer_group,
+               (opening.balance + SUM(${SIGNED_AMOUNT}) OVER (ORDER BY t.date, t.id))::text AS "runningBalance"
+             FROM transactions t
+             CROSS JOIN (
+               SELECT a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}), 0) AS balance
+               FROM bank_accounts a
+               LEFT JOIN transactions t
+                 ON t.account_id = a.id AND t.date < $2 AND ${ON_STATEMENT}
+               WHERE a.id = $1
+               GROUP BY a.id
+             ) opening
+             WHERE t.account_id = $1 AND t.date >= $2 AND t.date < $3
+               AND ${ON_STATEMENT}
+             ORDER BY t.date, t.id`,
+            params,
+          ),
+        ])
+
+        const { openingBalance, closingBalance, income, expense, ...details } =
+          header
+        return {
+          account: details,
+          month: range.month,
+          periodStart: range.start,
+          periodEnd: range.end,
+          openingBalance: presentAmount(openingBalance, units),
+          closingBalance: presentAmount(closingBalance, units),
+          totals: {
+            income: presentAmount(income, units),
+            expense: presentAmount(expense, units),
+            count: rows.length,
+          },
+          transactions: presentAmounts(rows, units, [
+            'amount',
+            'runningBalance',
+          ]),
+        }
+      },
+      units,
+    )
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
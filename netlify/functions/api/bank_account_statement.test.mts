@@ -0,0 +1,107 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_account_statement.mts'
+
+const { amounts } = vi.hoisted(() => ({
+  amounts: { units: 'decimal' as 'decimal' | 'minor' },
+}))
+
+vi.mock('../lib/features.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/features.mts')>()),
+  requestAmountUnits: () => amounts.units,
+}))
+
+function statement(query: string) {
+  return handler(apiRequest(`bank_account_statement?${query}`), context)
+}
+
+describe('GET bank_account_statement', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+    sql.transaction.mockReset()
+    amounts.units = 'decimal'
+  })
+
+  it('assembles the month with opening, running and closing balances', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ fingerprint: '2:x' }])
+    sql.transaction.mockResolvedValueOnce([
+      [
+        {
+          id: 'acc-1',
+          name: 'Checking',
+          type: 'bank',
+          currency: 'USD',
+          openingBalance: '100.0000',
+          closingBalance: '130.0000',
+          income: '50.0000',
+          expense: '20.0000',
+        },
+      ],
+      [
+        { id: 'tx-1', amount: '50.0000', runningBalance: '150.0000' },
+        { id: 'tx-2', amount: '20.0000', runningBalance: '130.0000' },
+      ],
+    ])
+    const res = await statement('id=acc-1&month=2025-02')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      account: { id: 'acc-1', name: 'Checking', type: 'bank', currency: 'USD' },
+      month: '2025-02',
+      periodStart: '2025-02-01T00:00:00.000Z',
+      periodEnd: '2025-03-01T00:00:00.000Z',
+      openingBalance: '100.0000',
+      closingBalance: '130.0000',
+      totals: { income: '50.0000', expense: '20.0000', count: 2 },
+      transactions: [
+        { id: 'tx-1', amount: '50.0000', runningBalance: '150.0000' },
+        { id: 'tx-2', amount: '20.0000', runningBalance: '130.0000' },
+      ],
+    })
+    const [text, params] = sql.query.mock.calls[1]
+    expect(text).toContain('OVER (ORDER BY t.date, t.id)')
+    expect(params).toEqual([
+      'acc-1',
+      '2025-02-01T00:00:00.000Z',
+      '2025-03-01T00:00:00.000Z',
+    ])
+  })
+
+  it('presents every balance and total in minor units', async () => {
+    amounts.units = 'minor'
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ fingerprint: '1:y' }])
+    sql.transaction.mockResolvedValueOnce([
+      [
+        {
+          id: 'acc-1',
+          openingBalance: '100.0000',
+          closingBalance: '95.5000',
+          income: '0',
+          expense: '4.5000',
+        },
+      ],
+      [{ id: 'tx-1', amount: '4.5000', runningBalance: '95.5000' }],
+    ])
+    const res = await statement('id=acc-1&month=2025-04')
+    expect(await res.json()).toMatchObject({
+      openingBalance: 10000,
+      closingBalance: 9550,
+      totals: { income: 0, expense: 450, count: 1 },
+      transactions: [{ id: 'tx-1', amount: 450, runningBalance: 9550 }],
+    })
+  })
+
+  it('rejects a malformed month', async () => {
+    const res = await statement('id=acc-1&month=2025-2')
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await statement('id=acc-2&month=2025-02')
+    expect(res.status).toBe(404)
+  })
+})
@@ -0,0 +1,58 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { statsByType } from '../lib/reports.mts'
+import type { AmountStats } from '../lib/reports.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, id, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(id)}`)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+
+      const rows = await sql.query(
+        `SELECT t.type,
+           COUNT(*)::int AS count,
+           SUM(t.amount)::text AS total,
+           ROUND(AVG(t.amount), 4)::text AS average,
+           MIN(t.amount)::text AS min,
+           MAX(t.amount)::text AS max
+         FROM transactions t
+         ${q.whereSql()}
+         GROUP BY t.type`,
+        q.params,
+      )
+      return statsByType(rows as Array<AmountStats & { type: string }>)
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,42 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+/**
+ * The distinct tags used on an account's transactions with how many
+ * transactions carry each, most used first, for building a tag filter.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${id} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Tags are kept distinct per row, so each count is a transaction count.
+    const tags = await sql`
+      SELECT tag, COUNT(*)::int AS count
+      FROM transactions, unnest(tags) AS tag
+      WHERE account_id = ${id}
+      GROUP BY tag
+      ORDER BY count DESC, tag
+    `
+    return json(tags)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
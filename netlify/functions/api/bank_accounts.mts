@@ -1,67 +1,155 @@
 import type { Context } from '@netlify/functions'
-import { neon } from '@neondatabase/serverless'
+import {
+  ACCOUNT_BODY,
+  ACCOUNT_SORTS,
+  DEFAULT_ACCOUNT_SORT,
+  accountNameTaken,
+  isAccountNameTaken,
+  isAccountSort,
+  presentAccounts,
+  validateAccountCreate,
+} from '../lib/accounts.mts'
 import { getSessionFromRequest } from '../lib/auth.mts'
-import { handlePreflight, withCors } from '../lib/cors.mts'
-
-const DATABASE_URL = process.env.DATABASE_URL
-
-async function getDb() {
-  if (!DATABASE_URL) throw new Response('DATABASE_URL not set', { status: 500 })
-  return neon(DATABASE_URL)
-}
-
-function json<T>(data: T, status = 200) {
-  return new Response(JSON.stringify(data), {
-    status,
-    headers: { 'Content-Type': 'application/json' },
-  })
-}
-
-function err(message: string, status: number) {
-  return json({ error: message }, status)
-}
-
-export default async (req: Request, _context: Context) => {
-  const preflight = handlePreflight(req)
-  if (preflight) return preflight
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  json,
+  readJson,
+  serverError,
+  validationErr,
+} from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+import { MAX_ACCOUNTS, limitReached } from '../lib/limits.mts'
+import { ACCOUNT_TYPES, isUuid, parseAccountType } from '../lib/params.mts'
+import {
+  NULLS_ORDERS,
+  QueryBuilder,
+  escapeLike,
+  orderBySql,
+} from '../lib/query.mts'
+import type { NullsOrder } from '../lib/query.mts'
+import { sharedQuery } from '../lib/singleflight.mts'
 
+export default apiHandler(async (req: Request, context: Context) => {
   const session = await getSessionFromRequest(req)
-  if (!session) return withCors(req, err('Unauthorized', 401))
+  if (!session) return err('Unauthorized', 401)
   const userId = session.user.id
 
   const method = req.method
+  const units = requestAmountUnits(req)
 
   try {
     const sql = await getDb()
 
     if (method === 'GET') {
-      const rows =
-        await sql`SELECT id, name, type FROM bank_accounts WHERE user_id = ${userId} ORDER BY name`
-      return withCors(req, json(rows))
+      const url = new URL(req.url)
+      const sort = url.searchParams.get('sort') ?? DEFAULT_ACCOUNT_SORT
+      if (!isAccountSort(sort)) {
+        return err(
+          `sort must be one of ${Object.keys(ACCOUNT_SORTS).join(', ')}`,
+          400,
+        )
+      }
+      const nulls = url.searchParams.get('nulls') ?? 'last'
+      if (!(NULLS_ORDERS as readonly string[]).includes(nulls))
+        return err(`nulls must be one of ${NULLS_ORDERS.join(', ')}`, 400)
+      // Counting is opt-in so the plain listing stays a single-table scan.
+      const count =
+        url.searchParams.get('withCounts') === 'true'
+          ? ', (SELECT COUNT(*) FROM transactions t WHERE t.account_id = a.id)::int AS "transactionCount"'
+          : ''
+      const q = new QueryBuilder()
+      q.where(`a.user_id = ${q.param(userId)}`)
+      // `ids` fetches just those accounts; malformed ids are skipped rather
+      // than failing the whole batch.
+      const rawIds = url.searchParams.get('ids')
+      if (rawIds !== null) {
+        const ids = rawIds
+          .split(',')
+          .map((id) => id.trim())
+          .filter(isUuid)
+        if (ids.length === 0) return json([])
+        q.where(`a.id = ANY(${q.param(ids)}::uuid[])`)
+      }
+      // Case-insensitive name search; % and _ match themselves.
+      const search = url.searchParams.get('q')?.trim()
+      if (search) {
+        q.where(`a.name ILIKE ${q.param(`%${escapeLike(search)}%`)}`)
+      }
+      const rawType = url.searchParams.get('type')
+      if (rawType?.trim()) {
+        const type = parseAccountType(rawType)
+        if (!type)
+          return err(`type must be one of ${ACCOUNT_TYPES.join(', ')}`, 400)
+        q.where(`a.type = ${q.param(type)}`)
+      }
+      const groupId = url.searchParams.get('groupId')
+      if (groupId !== null) {
+        if (!isUuid(groupId)) return err('groupId must be a UUID', 400)
+        q.where(`a.group_id = ${q.param(groupId)}`)
+      }
+      // The ORDER BY comes from the ACCOUNT_SORTS allowlist.
+      const rows = await sharedQuery(
+        sql,
+        `SELECT a.id, a.name, a.type, a.currency, a.sort_order, a.default_transaction_type, a.last_used_at, a.group_id, a.opening_balance::text, a.allow_negative${count}
+         FROM bank_accounts a
+         ${q.whereSql()}
+         ${orderBySql(ACCOUNT_SORTS[sort], nulls as NullsOrder)}`,
+        q.params,
+      )
+      return json(presentAccounts(rows, units))
     }
 
     if (method === 'POST') {
-      let body: { name?: string; type?: string }
+      const read = await readJson<unknown>(req, ACCOUNT_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const validated = validateAccountCreate(read.body, units)
+      if ('fields' in validated)
+        return validationErr(validated.fields, validated.details)
+      const {
+        name,
+        type,
+        currency,
+        defaultTransactionType,
+        groupId,
+        openingBalance,
+        allowNegative,
+      } = validated.value
+      if (MAX_ACCOUNTS !== null) {
+        const [{ count }] =
+          await sql`SELECT COUNT(*)::int AS count FROM bank_accounts`
+        if (limitReached(count, MAX_ACCOUNTS))
+          return err('account limit reached', 403)
+      }
+      if (groupId) {
+        const [group] =
+          await sql`SELECT id FROM account_groups WHERE id = ${groupId} AND user_id = ${userId}`
+        if (!group) return err('group not found', 400)
+      }
       try {
-        body = (await req.json()) as { name?: string; type?: string }
-      } catch {
-        return withCors(req, err('Invalid JSON', 400))
+        const [row] = await sql`
+          INSERT INTO bank_accounts (id, name, type, currency, user_id, sort_order, default_transaction_type, group_id, opening_balance, allow_negative)
+          SELECT ${newId()}, ${name}, ${type}, ${currency}, ${userId}, COALESCE(MAX(sort_order), 0) + 1, ${defaultTransactionType}, ${groupId}, ${openingBalance}, ${allowNegative}
+          FROM bank_accounts
+          WHERE user_id = ${userId}
+          RETURNING id, name, type, currency, sort_order, default_transaction_type, group_id, opening_balance::text, allow_negative
+        `
+        return created(
+          req,
+          `bank_account?id=${encodeURIComponent(row.id)}`,
+          presentAccounts([row], units)[0],
+        )
+      } catch (e) {
+        if (isAccountNameTaken(e)) return accountNameTaken(name, type)
+        throw e
       }
-      const name = typeof body.name === 'string' ? body.name.trim() : ''
-      const type = typeof body.type === 'string' ? body.type.trim() : ''
-      if (!name) return withCors(req, err('name is required', 400))
-      if (!type) return withCors(req, err('type is required', 400))
-      const [row] = await sql`
-        INSERT INTO bank_accounts (id, name, type, user_id)
-        VALUES (gen_random_uuid(), ${name}, ${type}, ${userId})
-        RETURNING id, name, type
-      `
-      return withCors(req, json(row, 201))
     }
 
-    return withCors(req, err('Method not allowed', 405))
+    return err('Method not allowed', 405)
   } catch (e) {
-    console.error(e)
-    return withCors(req, err('Internal server error', 500))
+    return serverError(req, context, e)
   }
-}
+})
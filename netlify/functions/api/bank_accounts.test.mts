@@ -0,0 +1,207 @@
+import { afterEach, beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_accounts.mts'
+
+const { defaults } = vi.hoisted(() => ({
+  defaults: { sort: 'manual' as 'manual' | 'type' },
+}))
+
+vi.mock('../lib/accounts.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/accounts.mts')>()),
+  get DEFAULT_ACCOUNT_SORT() {
+    return defaults.sort
+  },
+}))
+
+const ID_A = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+const ID_B = '5d1c2b3a-6e7f-4a8b-9c0d-e1f2a3b4c5d6'
+
+function list(query = '') {
+  return handler(apiRequest(`bank_accounts?${query}`), context)
+}
+
+describe('GET bank_accounts', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+    sql.query.mockResolvedValue([])
+  })
+
+  it('lists every account of the user by default', async () => {
+    await list()
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).not.toContain('ANY')
+    expect(params).toEqual(['user-1'])
+  })
+
+  it('fetches only the requested ids, skipping malformed ones', async () => {
+    await list(`ids=${ID_A},not-a-uuid, ${ID_B}`)
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('a.id = ANY($2::uuid[])')
+    expect(params).toEqual(['user-1', [ID_A, ID_B]])
+  })
+
+  it('returns an empty list without querying when no id is valid', async () => {
+    const res = await list('ids=nope')
+    expect(await res.json()).toEqual([])
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+
+  it('filters by group', async () => {
+    await list(`groupId=${ID_B}`)
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('a.group_id = $2')
+    expect(params).toEqual(['user-1', ID_B])
+  })
+
+  it('searches names case-insensitively, matching % and _ literally', async () => {
+    await list(`q=${encodeURIComponent('50%_chk')}&type=Bank`)
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('a.name ILIKE $2')
+    expect(text).toContain('a.type = $3')
+    expect(params).toEqual(['user-1', '%50\\%\\_chk%', 'bank'])
+  })
+
+  it('rejects an unknown account type', async () => {
+    const res = await list('type=brokerage')
+    expect(res.status).toBe(400)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+
+  it('rejects a malformed groupId', async () => {
+    const res = await list('groupId=nope')
+    expect(res.status).toBe(400)
+  })
+
+  it('sorts unused accounts last by default', async () => {
+    await list('sort=recent')
+    const [text] = sql.query.mock.calls[0]
+    expect(text).toContain(
+      'ORDER BY a.last_used_at DESC NULLS LAST, a.sort_order, a.name',
+    )
+  })
+
+  it('can put ungrouped accounts first', async () => {
+    await list('sort=group&nulls=first')
+    const [text] = sql.query.mock.calls[0]
+    expect(text).toContain('ORDER BY a.group_id NULLS FIRST, a.sort_order')
+  })
+
+  it('rejects unknown sort orders', async () => {
+    const res = await list('sort=size')
+    expect(res.status).toBe(400)
+  })
+
+  describe('with DEFAULT_ACCOUNT_SORT', () => {
+    beforeEach(() => {
+      defaults.sort = 'type'
+    })
+    afterEach(() => {
+      defaults.sort = 'manual'
+    })
+
+    it('uses it without ?sort=', async () => {
+      await list()
+      const [text] = sql.query.mock.calls[0]
+      expect(text).toContain('ORDER BY a.type, a.name')
+    })
+
+    it('still lets ?sort= override it', async () => {
+      await list('sort=manual')
+      const [text] = sql.query.mock.calls[0]
+      expect(text).toContain('ORDER BY a.sort_order, a.name')
+    })
+  })
+})
+
+describe('POST bank_accounts', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('points Location at the created account', async () => {
+    sql.mockResolvedValueOnce([{ id: ID_A, name: 'Checking', type: 'bank' }])
+    const res = await handler(
+      apiRequest('bank_accounts', {
+        method: 'POST',
+        body: JSON.stringify({ name: 'Checking', type: 'bank' }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(201)
+    expect(res.headers.get('Location')).toBe(
+      `https://example.com/bank_account?id=${ID_A}`,
+    )
+  })
+
+  function createAccount(name: string, type: string) {
+    return handler(
+      apiRequest('bank_accounts', {
+        method: 'POST',
+        body: JSON.stringify({ name, type }),
+      }),
+      context,
+    )
+  }
+
+  it('allows a name already used by an account of another type', async () => {
+    // A "Cash" bank account exists; the index only covers name and type
+    // together, so a "Cash" cash account inserts normally.
+    sql.mockResolvedValueOnce([{ id: ID_B, name: 'Cash', type: 'cash' }])
+    const res = await createAccount('Cash', 'cash')
+    expect(res.status).toBe(201)
+  })
+
+  it('reports a name taken within the same type as a 409', async () => {
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('duplicate key'), {
+        code: '23505',
+        constraint: 'idx_bank_accounts_user_name_type',
+      }),
+    )
+    const res = await createAccount('cash', 'cash')
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: 'an account named "cash" of type cash already exists',
+    })
+  })
+
+  it('rejects a group owned by someone else', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await handler(
+      apiRequest('bank_accounts', {
+        method: 'POST',
+        body: JSON.stringify({
+          name: 'Checking',
+          type: 'bank',
+          group_id: ID_B,
+        }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'group not found' })
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('rejects invalid accounts before touching the database', async () => {
+    const res = await handler(
+      apiRequest('bank_accounts', {
+        method: 'POST',
+        body: JSON.stringify({ name: 'Checking', type: 'brokerage' }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: {
+        code: 'VALIDATION',
+        fields: { type: 'invalid' },
+        details: {
+          type: { received: 'brokerage', allowed: ['bank', 'cash', 'card'] },
+        },
+      },
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,51 @@
+import type { Context } from '@netlify/functions'
+import { parseBackup, restoreBackup } from '../lib/account-backup.mts'
+import { accountNameTaken, isAccountNameTaken } from '../lib/accounts.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, created, err, serverError } from '../lib/http.mts'
+import { MAX_ACCOUNTS, limitReached } from '../lib/limits.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  let body: unknown
+  try {
+    body = await req.json()
+  } catch {
+    return err('Invalid JSON', 400)
+  }
+  const parsed = parseBackup(body)
+  if ('error' in parsed) return err(parsed.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    if (MAX_ACCOUNTS !== null) {
+      const [{ count }] =
+        await sql`SELECT COUNT(*)::int AS count FROM bank_accounts`
+      if (limitReached(count, MAX_ACCOUNTS))
+        return err('account limit reached', 403)
+    }
+
+    const account = await restoreBackup(sql, userId, parsed.backup)
+    return created(
+      req,
+      `bank_account?id=${encodeURIComponent(String(account.id))}`,
+      { ...account, transactionCount: parsed.backup.transactions.length },
+    )
+  } catch (e) {
+    // Restoring next to the account it was exported from.
+    if (isAccountNameTaken(e)) {
+      const { name, type } = parsed.backup.account
+      return accountNameTaken(name, type)
+    }
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,49 @@
+import type { Context } from '@netlify/functions'
+import { presentAccounts } from '../lib/accounts.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+/**
+ * Finds an account by name, ignoring case and surrounding whitespace. Names
+ * are not unique, so rather than guessing, several matches are a 409 that
+ * lists the candidates' ids.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const name = url.searchParams.get('name')?.trim()
+  if (!name) return err('name query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const rows = await sql`
+      SELECT id, name, type, currency, sort_order, default_transaction_type, last_used_at, group_id, opening_balance::text, allow_negative
+      FROM bank_accounts
+      WHERE user_id = ${userId} AND lower(name) = lower(${name})
+      ORDER BY sort_order, id
+    `
+    if (rows.length === 0) return err('Not found', 404)
+    if (rows.length > 1) {
+      return json(
+        {
+          error: `${rows.length} accounts are named ${JSON.stringify(name)}`,
+          ids: rows.map((row) => row.id),
+        },
+        409,
+      )
+    }
+    return json(presentAccounts(rows, requestAmountUnits(req))[0])
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
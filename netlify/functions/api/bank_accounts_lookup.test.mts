@@ -0,0 +1,43 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './bank_accounts_lookup.mts'
+
+function lookup(query: string) {
+  return handler(apiRequest(`bank_accounts_lookup?${query}`), context)
+}
+
+describe('GET bank_accounts_lookup', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('returns the single matching account', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', name: 'Checking' }])
+    const res = await lookup('name=%20checking%20')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ id: 'acc-1', name: 'Checking' })
+    expect(sql.mock.calls[0]).toContain('checking')
+  })
+
+  it('returns 404 when nothing matches', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await lookup('name=Savings')
+    expect(res.status).toBe(404)
+  })
+
+  it('returns 409 with the candidates when the name is ambiguous', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }, { id: 'acc-2' }])
+    const res = await lookup('name=Checking')
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: '2 accounts are named "Checking"',
+      ids: ['acc-1', 'acc-2'],
+    })
+  })
+
+  it('requires a name', async () => {
+    const res = await lookup('name=%20')
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,62 @@
+import type { Context } from '@netlify/functions'
+import { presentAccounts } from '../lib/accounts.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{ ids?: unknown }>(req, { ids: 'array' })
+  if ('error' in read) return err(read.error, 400)
+  const body = read.body
+  const ids = body.ids
+  if (
+    !Array.isArray(ids) ||
+    ids.length === 0 ||
+    !ids.every((id) => typeof id === 'string' && isUuid(id))
+  )
+    return err('ids must be a non-empty array of UUIDs', 400)
+  if (new Set(ids).size !== ids.length)
+    return err('ids must not contain duplicates', 400)
+
+  try {
+    const sql = await getDb()
+
+    // Positions follow the order of ids. The update only applies when every id
+    // belongs to the user, so a bad id leaves the existing order untouched.
+    const updated = await sql`
+      WITH input AS (
+        SELECT id, ord
+        FROM unnest(${ids}::uuid[]) WITH ORDINALITY AS x(id, ord)
+      )
+      UPDATE bank_accounts a
+      SET sort_order = i.ord
+      FROM input i
+      WHERE a.id = i.id
+        AND a.user_id = ${userId}
+        AND (
+          SELECT COUNT(*) FROM bank_accounts b
+          JOIN input j ON b.id = j.id
+          WHERE b.user_id = ${userId}
+        ) = ${ids.length}
+      RETURNING a.id
+    `
+    if (updated.length !== ids.length)
+      return err('ids must reference existing accounts', 400)
+
+    const rows =
+      await sql`SELECT id, name, type, currency, sort_order, default_transaction_type, opening_balance::text, allow_negative FROM bank_accounts WHERE user_id = ${userId} ORDER BY sort_order, name`
+    return json(presentAccounts(rows, requestAmountUnits(req)))
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
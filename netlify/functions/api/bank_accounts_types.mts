@@ -0,0 +1,33 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+/**
+ * Account types the user actually has, with counts, for filter dropdowns.
+ * ACCOUNT_TYPES still decides which types can be created.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const rows = await sql`
+      SELECT type, COUNT(*)::int AS count
+      FROM bank_accounts
+      WHERE user_id = ${userId}
+      GROUP BY type
+      ORDER BY type
+    `
+    return json(rows)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,31 @@
+import type { Context } from '@netlify/functions'
+import { validateAccountCreate } from '../lib/accounts.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json } from '../lib/http.mts'
+
+/**
+ * Runs the account creation checks without persisting anything, for inline
+ * form validation.
+ */
+export default apiHandler(async (req: Request, _context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  let body: unknown
+  try {
+    body = await req.json()
+  } catch {
+    return err('Invalid JSON', 400)
+  }
+
+  const validated = validateAccountCreate(body, requestAmountUnits(req))
+  if ('fields' in validated) {
+    return json({ valid: false, ...validated })
+  }
+  return json({ valid: true })
+})
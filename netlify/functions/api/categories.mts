@@ -0,0 +1,51 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  json,
+  readJson,
+  serverError,
+} from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      const rows = await sql`
+        SELECT id, name FROM categories
+        WHERE user_id = ${userId}
+        ORDER BY name
+      `
+      return json(rows)
+    }
+
+    if (method === 'POST') {
+      const read = await readJson<{ name?: unknown }>(req, { name: 'string' })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const name = typeof body.name === 'string' ? body.name.trim() : ''
+      if (!name) return err('name is required', 400)
+      const [row] = await sql`
+        INSERT INTO categories (id, name, user_id)
+        VALUES (${newId()}, ${name}, ${userId})
+        RETURNING id, name
+      `
+      return created(req, `category?id=${encodeURIComponent(row.id)}`, row)
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
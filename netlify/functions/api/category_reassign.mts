@@ -0,0 +1,70 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { PG_FOREIGN_KEY_VIOLATION, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+/**
+ * Moves every transaction in the category, across all of the user's
+ * accounts, into `toCategoryId`. Unlike deleting the category, the source
+ * stays, along with any rules that assign it, so it can keep being used
+ * after a reorganization.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const read = await readJson<{ toCategoryId?: string }>(req, {
+      toCategoryId: 'string',
+    })
+    if ('error' in read) return err(read.error, 400)
+    const toCategoryId = read.body.toCategoryId?.trim()
+    if (!toCategoryId) return err('toCategoryId is required', 400)
+    if (!isUuid(toCategoryId)) return err('toCategoryId must be a UUID', 400)
+    if (toCategoryId === id)
+      return err('cannot reassign a category to itself', 400)
+
+    const sql = await getDb()
+
+    const categories = await sql`
+      SELECT id FROM categories
+      WHERE id IN (${id}, ${toCategoryId}) AND user_id = ${userId}
+    `
+    const found = new Set(categories.map((c) => c.id))
+    if (!found.has(id)) return err('Not found', 404)
+    if (!found.has(toCategoryId)) return err('target category not found', 400)
+
+    try {
+      // updated_at moves too, so caches and ETags see the change.
+      const [{ reassigned }] = await sql`
+        WITH moved AS (
+          UPDATE transactions t
+          SET category_id = ${toCategoryId}, updated_at = now()
+          FROM bank_accounts a
+          WHERE t.account_id = a.id AND a.user_id = ${userId}
+            AND t.category_id = ${id}
+          RETURNING t.id
+        )
+        SELECT COUNT(*)::int AS reassigned FROM moved
+      `
+      return json({ reassigned })
+    } catch (e) {
+      // The target can be deleted between the check and the update.
+      if (isPgError(e, PG_FOREIGN_KEY_VIOLATION))
+        return err('target category not found', 400)
+      throw e
+    }
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
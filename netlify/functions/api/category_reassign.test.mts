@@ -0,0 +1,61 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './category_reassign.mts'
+
+const FROM = '00000000-0000-4000-8000-000000000001'
+const TO = '00000000-0000-4000-8000-000000000002'
+
+function reassign(toCategoryId: unknown, id = FROM) {
+  return handler(
+    apiRequest(`category_reassign?id=${id}`, {
+      method: 'POST',
+      body: JSON.stringify({ toCategoryId }),
+    }),
+    context,
+  )
+}
+
+describe('POST category_reassign', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('moves the transactions and keeps the source category', async () => {
+    sql.mockResolvedValueOnce([{ id: FROM }, { id: TO }])
+    sql.mockResolvedValueOnce([{ reassigned: 7 }])
+    const res = await reassign(TO)
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ reassigned: 7 })
+    expect(sql).toHaveBeenCalledTimes(2)
+    expect(sql.mock.calls[1][0].join('')).not.toContain('DELETE')
+  })
+
+  it('rejects reassigning a category to itself', async () => {
+    const res = await reassign(FROM)
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'cannot reassign a category to itself',
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 when the source category belongs to someone else', async () => {
+    sql.mockResolvedValueOnce([{ id: TO }])
+    const res = await reassign(TO)
+    expect(res.status).toBe(404)
+  })
+
+  it('rejects a target category that does not exist', async () => {
+    sql.mockResolvedValueOnce([{ id: FROM }])
+    const res = await reassign(TO)
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'target category not found' })
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('requires toCategoryId', async () => {
+    const res = await reassign(undefined)
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'toCategoryId is required' })
+  })
+})
@@ -0,0 +1,48 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import {
+  apiHandler,
+  deleteMissing,
+  err,
+  json,
+  serverError,
+} from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      const [row] = await sql`
+        SELECT id, pattern, match_type, type, category_id, priority
+        FROM category_rules
+        WHERE id = ${id} AND user_id = ${userId}
+      `
+      if (!row) return err('Not found', 404)
+      return json(row)
+    }
+
+    if (method === 'DELETE') {
+      // Categories already assigned by the rule are kept.
+      const [deleted] =
+        await sql`DELETE FROM category_rules WHERE id = ${id} AND user_id = ${userId} RETURNING id`
+      if (!deleted) return deleteMissing(url)
+      return new Response(null, { status: 204 })
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
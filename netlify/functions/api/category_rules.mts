@@ -0,0 +1,89 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { parseCategoryRule } from '../lib/category-rules.mts'
+import { PG_INVALID_REGULAR_EXPRESSION, getDb, isPgError } from '../lib/db.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  json,
+  readJson,
+  serverError,
+} from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+import { isUuid } from '../lib/params.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      // Listed in the order they are applied.
+      const rows = await sql`
+        SELECT id, pattern, match_type, type, category_id, priority
+        FROM category_rules
+        WHERE user_id = ${userId}
+        ORDER BY priority, id
+      `
+      return json(rows)
+    }
+
+    if (method === 'POST') {
+      const read = await readJson<{
+        pattern?: unknown
+        matchType?: unknown
+        type?: unknown
+        categoryId?: unknown
+        priority?: unknown
+      }>(req, {
+        pattern: 'string',
+        matchType: 'string',
+        type: 'string',
+        categoryId: 'string',
+        priority: 'number',
+      })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const parsed = parseCategoryRule(body)
+      if ('error' in parsed) return err(parsed.error, 400)
+      const { pattern, matchType, type } = parsed.rule
+      const categoryId = body.categoryId
+      if (typeof categoryId !== 'string' || !isUuid(categoryId))
+        return err('categoryId must be a UUID', 400)
+      const priority = body.priority ?? 0
+      if (!Number.isSafeInteger(priority))
+        return err('priority must be an integer', 400)
+
+      const [category] =
+        await sql`SELECT id FROM categories WHERE id = ${categoryId} AND user_id = ${userId}`
+      if (!category) return err('category not found', 400)
+      if (matchType === 'regex') {
+        // Compile the pattern now rather than failing every later run.
+        try {
+          await sql`SELECT '' ~* ${pattern}`
+        } catch (e) {
+          if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+            return err('pattern is not a valid regular expression', 400)
+          throw e
+        }
+      }
+
+      const [row] = await sql`
+        INSERT INTO category_rules (id, user_id, pattern, match_type, type, category_id, priority)
+        VALUES (${newId()}, ${userId}, ${pattern}, ${matchType}, ${type}, ${categoryId}, ${priority})
+        RETURNING id, pattern, match_type, type, category_id, priority
+      `
+      return created(req, `category_rule?id=${encodeURIComponent(row.id)}`, row)
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
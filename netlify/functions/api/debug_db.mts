@@ -0,0 +1,35 @@
+import type { Context } from '@netlify/functions'
+import { queryStats } from '../lib/db.mts'
+import { DEBUG_API_KEY, hasDebugKey } from '../lib/debug.mts'
+import { apiHandler, err, json } from '../lib/http.mts'
+
+const startedAt = new Date()
+
+/**
+ * Database driver metrics for this function instance. It only reads
+ * in-memory counters, so it is cheap enough to poll.
+ */
+export default apiHandler(async (req: Request, _context: Context) => {
+  if (!DEBUG_API_KEY) return err('Not found', 404)
+  if (!hasDebugKey(req)) return err('Unauthorized', 401)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const { total, inFlight, failed, totalDurationMs, maxDurationMs } =
+    queryStats
+  const res = json({
+    startedAt: startedAt.toISOString(),
+    uptimeMs: Date.now() - startedAt.getTime(),
+    queries: {
+      total,
+      inFlight,
+      failed,
+      avgDurationMs: total ? Math.round(totalDurationMs / total) : 0,
+      maxDurationMs,
+    },
+  })
+  res.headers.set('Cache-Control', 'no-store')
+  return res
+})
@@ -0,0 +1,25 @@
+import type { Context } from '@netlify/functions'
+import { queryStats } from '../lib/db.mts'
+import { DEBUG_API_KEY, hasDebugKey } from '../lib/debug.mts'
+import { apiHandler, err } from '../lib/http.mts'
+import { formatPrometheus, requestDurations } from '../lib/metrics.mts'
+
+/**
+ * Request duration histograms and query counters for this function
+ * instance, for Prometheus to scrape with the debug bearer key.
+ */
+export default apiHandler(async (req: Request, _context: Context) => {
+  if (!DEBUG_API_KEY) return err('Not found', 404)
+  if (!hasDebugKey(req)) return err('Unauthorized', 401)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  return new Response(formatPrometheus(requestDurations, queryStats), {
+    headers: {
+      'Content-Type': 'text/plain; version=0.0.4',
+      'Cache-Control': 'no-store',
+    },
+  })
+})
@@ -0,0 +1,40 @@
+import type { Context } from '@netlify/functions'
+import { DEBUG_API_KEY, hasDebugKey } from '../lib/debug.mts'
+import { apiHandler, err, json } from '../lib/http.mts'
+import {
+  SLOW_REQUEST_CAPACITY,
+  SLOW_REQUEST_MS,
+  slowRequests,
+} from '../lib/metrics.mts'
+
+const DEFAULT_LIMIT = 20
+
+/**
+ * The slowest recent requests served by this function instance, slowest
+ * first, for a live look at hotspots without searching the logs. Only
+ * requests over SLOW_REQUEST_MS are kept, and only the latest
+ * SLOW_REQUEST_CAPACITY of those.
+ */
+export default apiHandler(async (req: Request, _context: Context) => {
+  if (!DEBUG_API_KEY) return err('Not found', 404)
+  if (!hasDebugKey(req)) return err('Unauthorized', 401)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const raw = new URL(req.url).searchParams.get('limit')
+  const limit = raw ? Number(raw) : DEFAULT_LIMIT
+  if (!Number.isInteger(limit) || limit < 1 || limit > SLOW_REQUEST_CAPACITY)
+    return err(
+      `limit must be an integer between 1 and ${SLOW_REQUEST_CAPACITY}`,
+      400,
+    )
+
+  const res = json({
+    thresholdMs: SLOW_REQUEST_MS,
+    requests: slowRequests.slowest(limit),
+  })
+  res.headers.set('Cache-Control', 'no-store')
+  return res
+})
@@ -0,0 +1,66 @@
+import type { Context } from '@netlify/functions'
+import { BACKUP_VERSION, userBackups } from '../lib/account-backup.mts'
+import { getDb } from '../lib/db.mts'
+import { DEBUG_API_KEY, hasDebugKey } from '../lib/debug.mts'
+import {
+  apiHandler,
+  err,
+  serverError,
+  streamJsonObject,
+} from '../lib/http.mts'
+
+/**
+ * Everything stored for one user as a single JSON document, for data
+ * portability requests. Each entry of `accounts` is the same document
+ * bank_account_export produces, so it can be fed back to
+ * bank_accounts_import one account at a time.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  if (!DEBUG_API_KEY) return err('Not found', 404)
+  if (!hasDebugKey(req)) return err('Unauthorized', 401)
+
+  const url = new URL(req.url)
+  const userId = url.searchParams.get('userId')
+  if (!userId) return err('userId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const [categories, tags] = await Promise.all([
+      sql`SELECT name FROM categories WHERE user_id = ${userId} ORDER BY name`,
+      sql`
+        SELECT DISTINCT unnest(t.tags) AS tag
+        FROM transactions t
+        JOIN bank_accounts a ON a.id = t.account_id
+        WHERE a.user_id = ${userId}
+        ORDER BY tag
+      `,
+    ])
+
+    const res = streamJsonObject(
+      req,
+      context,
+      {
+        version: BACKUP_VERSION,
+        userId,
+        exportedAt: new Date().toISOString(),
+        categories: categories.map((c) => c.name),
+        tags: tags.map((t) => t.tag),
+      },
+      'accounts',
+      userBackups(sql, userId),
+    )
+    res.headers.set('Cache-Control', 'no-store')
+    res.headers.set(
+      'Content-Disposition',
+      `attachment; filename="export-${userId}.json"`,
+    )
+    return res
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
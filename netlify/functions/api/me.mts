@@ -0,0 +1,59 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { MAX_ACCOUNTS, MAX_TRANSACTIONS_PER_ACCOUNT } from '../lib/limits.mts'
+import { READ_ONLY } from '../lib/read-only.mts'
+
+/**
+ * The caller's effective context, so clients can hide what they cannot do
+ * and debug auth. Anonymous callers get a 200 that only says so; user
+ * details and limit usage need a session. Usage is only counted for caps
+ * that are configured.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const session = await getSessionFromRequest(req)
+  if (!session) return json({ authenticated: false, readOnly: READ_ONLY })
+  const { id, email, name } = session.user
+
+  try {
+    const sql = await getDb()
+
+    const [accounts, transactions] = await Promise.all([
+      MAX_ACCOUNTS === null
+        ? null
+        : sql`SELECT COUNT(*)::int AS count FROM bank_accounts`.then(
+            ([row]) => ({ limit: MAX_ACCOUNTS, used: row.count as number }),
+          ),
+      // The cap applies per account, so the fullest account is what counts.
+      MAX_TRANSACTIONS_PER_ACCOUNT === null
+        ? null
+        : sql`
+            SELECT COALESCE(MAX(n), 0)::int AS count
+            FROM (
+              SELECT COUNT(t.id) AS n
+              FROM bank_accounts a
+              LEFT JOIN transactions t ON t.account_id = a.id
+              WHERE a.user_id = ${id}
+              GROUP BY a.id
+            ) s
+          `.then(([row]) => ({
+            limit: MAX_TRANSACTIONS_PER_ACCOUNT,
+            used: row.count as number,
+          })),
+    ])
+
+    return json({
+      authenticated: true,
+      user: { id, email, name },
+      readOnly: READ_ONLY,
+      limits: { accounts, transactionsPerAccount: transactions },
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
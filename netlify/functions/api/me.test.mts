@@ -0,0 +1,33 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import handler from './me.mts'
+
+function get() {
+  return handler(apiRequest('me'), context)
+}
+
+describe('GET me', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('describes the signed-in caller and skips unset caps', async () => {
+    const res = await get()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      authenticated: true,
+      user: { id: 'user-1', email: 'user@example.com', name: 'User' },
+      readOnly: false,
+      limits: { accounts: null, transactionsPerAccount: null },
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('only reports read-only status to anonymous callers', async () => {
+    vi.mocked(getSessionFromRequest).mockResolvedValueOnce(null)
+    const res = await get()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ authenticated: false, readOnly: false })
+  })
+})
@@ -0,0 +1,92 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { ACCOUNT_BALANCE } from '../lib/balance.mts'
+import { presentAmount, presentAmounts } from '../lib/amount.mts'
+import {
+  DEFAULT_CURRENCY,
+  EXCHANGE_RATES,
+  missingRates,
+  parseCurrency,
+} from '../lib/currency.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+/**
+ * Total balance across the user's accounts, optionally as of a date,
+ * converted into `base` (DEFAULT_CURRENCY when omitted). Conversion and the
+ * total are done in NUMERIC so the sum is exact however many accounts
+ * there are.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const rawBase = url.searchParams.get('base')
+  const base = rawBase === null ? DEFAULT_CURRENCY : parseCurrency(rawBase)
+  if (!base) return err('base must be a 3-letter ISO 4217 code', 400)
+  const rawAsOf = url.searchParams.get('asOf')?.trim()
+  const asOf = rawAsOf ? new Date(rawAsOf) : null
+  if (asOf && Number.isNaN(asOf.getTime()))
+    return err('asOf must be a valid date', 400)
+
+  try {
+    const sql = await getDb()
+
+    const q = new QueryBuilder()
+    const dateFilter = asOf ? `AND t.date <= ${q.param(asOf.toISOString())}` : ''
+    q.where(`a.user_id = ${q.param(userId)}`)
+    const baseParam = `${q.param(base)}::text`
+    const rateJson = JSON.stringify(Object.fromEntries(EXCHANGE_RATES))
+    const rates = `${q.param(rateJson)}::jsonb`
+
+    // An account without a rate converts to NULL; it is reported below
+    // before any total is returned.
+    const accounts = await sql.query(
+      `SELECT b.id, b.name, b.currency, b.balance::text AS balance,
+         c.converted::text AS converted,
+         (SUM(c.converted) OVER ())::text AS total
+       FROM (
+         SELECT a.id, a.name, a.currency, a.sort_order, ${ACCOUNT_BALANCE} AS balance
+         FROM bank_accounts a
+         LEFT JOIN transactions t ON t.account_id = a.id ${dateFilter}
+         ${q.whereSql()}
+         GROUP BY a.id
+       ) b
+       CROSS JOIN LATERAL (
+         SELECT CASE WHEN b.currency = ${baseParam} THEN b.balance
+           ELSE ROUND(b.balance * (${rates} ->> b.currency)::numeric / (${rates} ->> ${baseParam})::numeric, 4)
+         END AS converted
+       ) c
+       ORDER BY b.sort_order, b.name`,
+      q.params,
+    )
+
+    const missing = missingRates(
+      accounts.map((a) => String(a.currency)),
+      base,
+    )
+    if (missing.length) {
+      return err(`no exchange rate for ${missing.join(', ')}`, 400)
+    }
+
+    const units = requestAmountUnits(req)
+    const total = String(accounts[0]?.total ?? '0.0000')
+    const rows = accounts.map(({ total: _, ...a }) => a)
+    return json({
+      base,
+      asOf,
+      total: presentAmount(total, units),
+      accounts: presentAmounts(rows, units, ['balance', 'converted']),
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
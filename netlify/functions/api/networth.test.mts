@@ -0,0 +1,77 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './networth.mts'
+
+const { units } = vi.hoisted(() => ({
+  units: { value: 'decimal' as 'decimal' | 'minor' },
+}))
+
+vi.mock('../lib/features.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/features.mts')>()),
+  requestAmountUnits: () => units.value,
+}))
+
+function get(query = '') {
+  return handler(apiRequest(`networth?${query}`), context)
+}
+
+const accounts = [
+  {
+    id: 'acc-1',
+    name: 'Checking',
+    currency: 'USD',
+    balance: '0.1000',
+    converted: '0.1000',
+    total: '0.3000',
+  },
+  {
+    id: 'acc-2',
+    name: 'Savings',
+    currency: 'USD',
+    balance: '0.2000',
+    converted: '0.2000',
+    total: '0.3000',
+  },
+]
+
+describe('GET networth', () => {
+  beforeEach(() => {
+    sql.query.mockReset()
+    units.value = 'decimal'
+  })
+
+  it('returns the total summed in SQL, not in floating point', async () => {
+    sql.query.mockResolvedValueOnce(accounts)
+    const res = await get('base=USD')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      base: 'USD',
+      asOf: null,
+      total: '0.3000',
+      accounts: accounts.map(({ total: _, ...a }) => a),
+    })
+    const [text] = sql.query.mock.calls[0] as [string]
+    expect(text).toContain('SUM(c.converted) OVER ()')
+  })
+
+  it('reports zero when there are no accounts', async () => {
+    sql.query.mockResolvedValueOnce([])
+    const res = await get('base=USD')
+    expect((await res.json()).total).toBe('0.0000')
+  })
+
+  it('presents balances and the total in minor units', async () => {
+    units.value = 'minor'
+    sql.query.mockResolvedValueOnce(accounts)
+    const body = await (await get('base=USD')).json()
+    expect(body.total).toBe(30)
+    expect(body.accounts[0]).toMatchObject({ balance: 10, converted: 10 })
+  })
+
+  it('rejects a base with no exchange rate', async () => {
+    sql.query.mockResolvedValueOnce(accounts)
+    const res = await get('base=EUR')
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'no exchange rate for EUR, USD' })
+  })
+})
@@ -0,0 +1,105 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { IS_ACTIVE } from '../lib/balance.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+const DEFAULT_DEVIATIONS = 2
+const MAX_DEVIATIONS = 10
+/** Fewer transactions of a type than this give no meaningful spread. */
+export const MIN_SAMPLE = 5
+
+/**
+ * Transactions in a period whose amount lies more than `deviations`
+ * standard deviations from the mean of the account's transactions of the
+ * same type, over its whole history. Incomes and expenses are scored
+ * separately, and transfer legs and scheduled transactions are ignored. A
+ * type with fewer than MIN_SAMPLE transactions, or whose amounts are all
+ * equal, flags nothing.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const rawDeviations = url.searchParams.get('deviations')
+  const deviations = rawDeviations ? Number(rawDeviations) : DEFAULT_DEVIATIONS
+  if (
+    !Number.isFinite(deviations) ||
+    deviations <= 0 ||
+    deviations > MAX_DEVIATIONS
+  ) {
+    return err(
+      `deviations must be a number above 0 and at most ${MAX_DEVIATIONS}`,
+      400,
+    )
+  }
+  const units = requestAmountUnits(req)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(
+      sql,
+      accountId,
+      url,
+      to,
+      async () => {
+        const q = new QueryBuilder()
+        const accountParam = q.param(accountId)
+        q.where(`s.samples >= ${q.param(MIN_SAMPLE)}`)
+        q.where('s.stddev > 0')
+        q.where(`ABS(s.amount - s.mean) > ${q.param(deviations)} * s.stddev`)
+        if (from) q.where(`s.date >= ${q.param(from)}`)
+        if (to) q.where(`s.date <= ${q.param(to)}`)
+
+        const rows = await sql.query(
+          `WITH scored AS (
+             SELECT t.id, t.amount, t.date, t.description, t.type, t.category_id,
+               AVG(t.amount) OVER w AS mean,
+               stddev_samp(t.amount) OVER w AS stddev,
+               COUNT(*) OVER w AS samples
+             FROM transactions t
+             WHERE t.account_id = ${accountParam} AND ${IS_ACTIVE}
+               AND t.transfer_group IS NULL
+             WINDOW w AS (PARTITION BY t.type)
+           )
+           SELECT s.id, s.amount::text, s.date, s.description, s.type, s.category_id,
+             ROUND(s.mean, 4)::text AS mean,
+             ROUND((s.amount - s.mean) / s.stddev, 2)::float8 AS "deviations"
+           FROM scored s
+           ${q.whereSql()}
+           ORDER BY ABS(s.amount - s.mean) / s.stddev DESC, s.date DESC`,
+          q.params,
+        )
+        return {
+          deviations,
+          transactions: presentAmounts(rows, units, ['amount', 'mean']),
+        }
+      },
+      units,
+    )
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,57 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler, { MIN_SAMPLE } from './reports_anomalies.mts'
+
+function get(query: string) {
+  return handler(
+    apiRequest(`reports_anomalies?accountId=acc-1&${query}`),
+    context,
+  )
+}
+
+describe('GET reports_anomalies', () => {
+  let fingerprint = 0
+
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+    sql.query.mockResolvedValue([])
+    // Account lookup, then a fresh cache fingerprint so no test sees
+    // another's cached report.
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ fingerprint: String(++fingerprint) }])
+  })
+
+  it('scores each type against its own mean and spread', async () => {
+    const res = await get('')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ deviations: 2, transactions: [] })
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('WINDOW w AS (PARTITION BY t.type)')
+    expect(text).toContain('stddev_samp(t.amount) OVER w')
+    expect(text).toContain('s.samples >= $2')
+    expect(text).toContain('s.stddev > 0')
+    expect(text).toContain('ABS(s.amount - s.mean) > $3 * s.stddev')
+    expect(params).toEqual(['acc-1', MIN_SAMPLE, 2])
+  })
+
+  it('flags within the period but measures against all history', async () => {
+    await get('from=2025-01-01&to=2025-01-31&deviations=2.5')
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('s.date >= $4 AND s.date <= $5')
+    expect(text).not.toContain('t.date >=')
+    expect(params).toEqual([
+      'acc-1',
+      MIN_SAMPLE,
+      2.5,
+      '2025-01-01',
+      '2025-01-31',
+    ])
+  })
+
+  it.each(['0', '-1', '11', 'many'])('rejects deviations=%s', async (raw) => {
+    const res = await get(`deviations=${raw}`)
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,117 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { SIGNED_AMOUNT, countedInBalance } from '../lib/balance.mts'
+import {
+  DEFAULT_CURRENCY,
+  convert,
+  missingRates,
+  parseCurrency,
+} from '../lib/currency.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { ACCOUNT_TYPES, parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+/**
+ * Balance, income and expense across the user's accounts grouped by
+ * account type, converted into `base` (DEFAULT_CURRENCY when omitted).
+ * Every type is listed, with zeros when the user has no such account.
+ * Balances are as of `to`; income and expense cover `from`..`to` and leave
+ * out transfers, which only move money between accounts.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const rawBase = url.searchParams.get('base')
+  const base = rawBase === null ? DEFAULT_CURRENCY : parseCurrency(rawBase)
+  if (!base) return err('base must be a 3-letter ISO 4217 code', 400)
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+
+  try {
+    const sql = await getDb()
+
+    const q = new QueryBuilder()
+    const counted = [countedInBalance()]
+    const inPeriod = ['t.transfer_group IS NULL']
+    if (from) inPeriod.push(`t.date >= ${q.param(from)}`)
+    if (to) {
+      const until = `t.date <= ${q.param(to)}`
+      counted.push(until)
+      inPeriod.push(until)
+    }
+    q.where(`a.user_id = ${q.param(userId)}`)
+    const period = inPeriod.join(' AND ')
+
+    const accounts = await sql.query(
+      `SELECT a.type, a.currency,
+         (a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE ${counted.join(' AND ')}), 0))::text AS balance,
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income' AND ${period}), 0)::text AS income,
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense' AND ${period}), 0)::text AS expense
+       FROM bank_accounts a
+       LEFT JOIN transactions t ON t.account_id = a.id
+       ${q.whereSql()}
+       GROUP BY a.id`,
+      q.params,
+    )
+
+    const missing = missingRates(
+      accounts.map((a) => String(a.currency)),
+      base,
+    )
+    if (missing.length) {
+      return err(`no exchange rate for ${missing.join(', ')}`, 400)
+    }
+
+    const sums = new Map(
+      ACCOUNT_TYPES.map((type) => [
+        type as string,
+        { accounts: 0, balance: 0, income: 0, expense: 0 },
+      ]),
+    )
+    for (const a of accounts) {
+      const sum = sums.get(a.type)
+      if (!sum) continue
+      sum.accounts++
+      sum.balance += Number(convert(a.balance, a.currency, base))
+      sum.income += Number(convert(a.income, a.currency, base))
+      sum.expense += Number(convert(a.expense, a.currency, base))
+    }
+
+    const totals = { balance: 0, income: 0, expense: 0 }
+    const types = [...sums].map(([type, sum]) => {
+      totals.balance += sum.balance
+      totals.income += sum.income
+      totals.expense += sum.expense
+      return {
+        type,
+        accounts: sum.accounts,
+        balance: sum.balance.toFixed(4),
+        income: sum.income.toFixed(4),
+        expense: sum.expense.toFixed(4),
+      }
+    })
+
+    return json({
+      base,
+      from: from ?? null,
+      to: to ?? null,
+      totals: {
+        balance: totals.balance.toFixed(4),
+        income: totals.income.toFixed(4),
+        expense: totals.expense.toFixed(4),
+      },
+      types,
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,104 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './reports_by_account_type.mts'
+
+function get(query = '') {
+  return handler(apiRequest(`reports_by_account_type?${query}`), context)
+}
+
+describe('GET reports_by_account_type', () => {
+  beforeEach(() => {
+    sql.query.mockReset()
+  })
+
+  it('sums accounts per type and lists types without accounts as zero', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        type: 'bank',
+        currency: 'USD',
+        balance: '1000.0000',
+        income: '2000.0000',
+        expense: '500.0000',
+      },
+      {
+        type: 'bank',
+        currency: 'USD',
+        balance: '250.5000',
+        income: '0',
+        expense: '0',
+      },
+      {
+        type: 'card',
+        currency: 'USD',
+        balance: '-120.0000',
+        income: '0',
+        expense: '120.0000',
+      },
+    ])
+    const res = await get('base=USD')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      base: 'USD',
+      from: null,
+      to: null,
+      totals: {
+        balance: '1130.5000',
+        income: '2000.0000',
+        expense: '620.0000',
+      },
+      types: [
+        {
+          type: 'bank',
+          accounts: 2,
+          balance: '1250.5000',
+          income: '2000.0000',
+          expense: '500.0000',
+        },
+        {
+          type: 'cash',
+          accounts: 0,
+          balance: '0.0000',
+          income: '0.0000',
+          expense: '0.0000',
+        },
+        {
+          type: 'card',
+          accounts: 1,
+          balance: '-120.0000',
+          income: '0.0000',
+          expense: '120.0000',
+        },
+      ],
+    })
+  })
+
+  it('scopes the balance to the end of the period', async () => {
+    sql.query.mockResolvedValueOnce([])
+    await get('base=USD&from=2025-01-01&to=2025-01-31')
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain(
+      "FILTER (WHERE NOT t.scheduled AND t.status = 'posted' AND t.date <= $2)",
+    )
+    expect(params).toEqual(['2025-01-01', '2025-01-31', 'user-1'])
+  })
+
+  it('rejects a base without an exchange rate', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        type: 'bank',
+        currency: 'EUR',
+        balance: '1',
+        income: '0',
+        expense: '0',
+      },
+    ])
+    const res = await get('base=USD')
+    expect(res.status).toBe(400)
+  })
+
+  it('rejects an invalid period', async () => {
+    const res = await get('from=yesterday')
+    expect(res.status).toBe(400)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+})
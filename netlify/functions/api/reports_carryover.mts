@@ -0,0 +1,121 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { SIGNED_AMOUNT, countedInBalance } from '../lib/balance.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { carryover, parseRound, roundFigures } from '../lib/reports.mts'
+
+/** A century of months; more is a mistyped year. */
+const MAX_MONTHS = 1200
+
+/**
+ * Month by month income, expense and net for a budgeting table, with the
+ * balance carried from each month into the next. The carryover starts at
+ * the account's balance before the first month and so always ends at its
+ * balance, which is why, like the default balance, it counts posted,
+ * active transactions including transfers. `from` and `to` pick whole
+ * months and default to the first and last with activity; months without
+ * activity are listed with zeros.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const end = to ? new Date(to) : new Date()
+  if (from) {
+    const start = new Date(from)
+    const months =
+      (end.getUTCFullYear() - start.getUTCFullYear()) * 12 +
+      (end.getUTCMonth() - start.getUTCMonth())
+    if (months >= MAX_MONTHS)
+      return err(`range spans more than ${MAX_MONTHS} months`, 400)
+  }
+  // `to` takes in the rest of its month.
+  const periodEnd = to
+    ? new Date(
+        Date.UTC(end.getUTCFullYear(), end.getUTCMonth() + 1, 1),
+      ).toISOString()
+    : undefined
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, periodEnd, async () => {
+      const counted = `t.account_id = $1 AND ${countedInBalance()}`
+      const [months, [{ opening }]] = await Promise.all([
+        sql.query(
+          `WITH bounds AS (
+             SELECT date_trunc('month', $2::timestamptz) AS lo,
+               date_trunc('month', $3::timestamptz) AS hi
+           ),
+           monthly AS (
+             SELECT date_trunc('month', t.date) AS month,
+               COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0) AS income,
+               COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0) AS expense
+             FROM transactions t, bounds r
+             WHERE ${counted}
+               AND (r.lo IS NULL OR t.date >= r.lo)
+               AND (r.hi IS NULL OR t.date < r.hi + interval '1 month')
+             GROUP BY 1
+           ),
+           series AS (
+             SELECT generate_series(
+               COALESCE(MIN(r.lo), MIN(m.month)),
+               COALESCE(MIN(r.hi), MAX(m.month)),
+               interval '1 month'
+             ) AS month
+             FROM bounds r LEFT JOIN monthly m ON true
+           )
+           SELECT to_char(s.month, 'YYYY-MM') AS month,
+             COALESCE(m.income, 0)::text AS income,
+             COALESCE(m.expense, 0)::text AS expense
+           FROM series s
+           LEFT JOIN monthly m ON m.month = s.month
+           ORDER BY s.month`,
+          [accountId, from ?? null, to ?? null],
+        ),
+        // Everything before the first month, on top of the opening
+        // balance. Without `from` the first month is the first activity.
+        sql.query(
+          `SELECT (a.opening_balance + COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (
+               WHERE t.date < date_trunc('month', $2::timestamptz)
+             ), 0))::text AS opening
+           FROM bank_accounts a
+           LEFT JOIN transactions t ON t.account_id = a.id AND ${counted}
+           WHERE a.id = $1
+           GROUP BY a.id`,
+          [accountId, from ?? null],
+        ),
+      ])
+
+      // Carried balances are summed exactly and only rounded for display.
+      return roundFigures(
+        { opening, months: carryover(opening, months) },
+        rounding.round,
+        ['opening', 'income', 'expense', 'net', 'carryover'],
+      )
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,80 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { convert, missingRates, parseCurrency } from '../lib/currency.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const base = parseCurrency(url.searchParams.get('base'))
+  if (!base) return err('base must be a 3-letter ISO 4217 code', 400)
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+
+  try {
+    const sql = await getDb()
+
+    const q = new QueryBuilder()
+    const join = ['t.account_id = a.id', 't.transfer_group IS NULL']
+    if (from) join.push(`t.date >= ${q.param(from)}`)
+    if (to) join.push(`t.date <= ${q.param(to)}`)
+    q.where(`a.user_id = ${q.param(userId)}`)
+
+    // Period filters sit in the join so accounts without activity still
+    // appear with zero totals.
+    const accounts = await sql.query(
+      `SELECT a.id, a.name, a.currency,
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)::text AS income,
+         COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0)::text AS expense
+       FROM bank_accounts a
+       LEFT JOIN transactions t ON ${join.join(' AND ')}
+       ${q.whereSql()}
+       GROUP BY a.id
+       ORDER BY a.sort_order, a.name`,
+      q.params,
+    )
+
+    const missing = missingRates(
+      accounts.map((a) => String(a.currency)),
+      base,
+    )
+    if (missing.length) {
+      return err(`no exchange rate for ${missing.join(', ')}`, 400)
+    }
+
+    let income = 0
+    let expense = 0
+    const rows = accounts.map((a) => {
+      const converted = {
+        income: convert(a.income, a.currency, base),
+        expense: convert(a.expense, a.currency, base),
+      }
+      income += Number(converted.income)
+      expense += Number(converted.expense)
+      return { ...a, converted }
+    })
+
+    return json({
+      base,
+      totals: {
+        income: income.toFixed(4),
+        expense: expense.toFixed(4),
+        net: (income - expense).toFixed(4),
+      },
+      accounts: rows,
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,99 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parseMonth } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  EXCLUDE_TRANSFERS,
+  comparePeriods,
+  parseRound,
+  roundFigures,
+} from '../lib/reports.mts'
+import type { ComparisonRow } from '../lib/reports.mts'
+import { SPLIT_LINES } from '../lib/splits.mts'
+
+/**
+ * Income, expense and net for two months side by side, overall and per
+ * category, for comparison views that need more than percent changes.
+ * With `splits=true`, split transactions count toward their splits'
+ * categories instead of their own.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const rangeA = parseMonth(url.searchParams.get('periodA'))
+  if (!rangeA) return err('periodA must be in YYYY-MM format', 400)
+  const rangeB = parseMonth(url.searchParams.get('periodB'))
+  if (!rangeB) return err('periodB must be in YYYY-MM format', 400)
+  // The report covers both months, so it is settled once the later ends.
+  const periodEnd = rangeA.end > rangeB.end ? rangeA.end : rangeB.end
+  const includeTransfers = url.searchParams.get('includeTransfers') === 'true'
+  const bySplits = url.searchParams.get('splits') === 'true'
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, periodEnd, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      if (!includeTransfers) q.where(EXCLUDE_TRANSFERS)
+      // Joining on the ranges rather than a CASE lets a transaction count
+      // in both periods when they are the same month.
+      const periods = `(VALUES
+        ('a', ${q.param(rangeA.start)}::timestamptz, ${q.param(rangeA.end)}::timestamptz),
+        ('b', ${q.param(rangeB.start)}::timestamptz, ${q.param(rangeB.end)}::timestamptz)
+      ) p(period, start_at, end_at)`
+
+      // Whole-period and per-category totals come from one scan; the
+      // period-only grouping set is told apart from the uncategorized
+      // group by GROUPING().
+      const rows = await sql.query(
+        `SELECT p.period,
+           GROUPING(t.category_id) = 1 AS "isTotal",
+           t.category_id AS "categoryId",
+           c.name AS category,
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)::text AS income,
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0)::text AS expense,
+           (COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)
+             - COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0))::text AS net
+         FROM ${bySplits ? SPLIT_LINES : 'transactions t'}
+         JOIN ${periods} ON t.date >= p.start_at AND t.date < p.end_at
+         LEFT JOIN categories c ON c.id = t.category_id
+         ${q.whereSql()}
+         GROUP BY GROUPING SETS ((p.period), (p.period, t.category_id, c.name))
+         ORDER BY c.name NULLS LAST, t.category_id`,
+        q.params,
+      )
+
+      const compared = comparePeriods(rows as ComparisonRow[])
+      const report = {
+        includeTransfers,
+        splits: bySplits,
+        periodA: { month: rangeA.month, ...compared.periodA },
+        periodB: { month: rangeB.month, ...compared.periodB },
+        categories: compared.categories,
+      }
+      return roundFigures(report, rounding.round, ['income', 'expense', 'net'])
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,125 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  EXCLUDE_TRANSFERS,
+  incomeExpenseRatio,
+  parseGroupBy,
+  parseRound,
+  parseWeekStart,
+  periodStartSql,
+  roundFigures,
+  weekLabelSql,
+} from '../lib/reports.mts'
+
+/** Most periods in one series; ten years of days. */
+const MAX_PERIODS = 3660
+
+/** Thrown from the report loader so an oversized range is never cached. */
+class TooManyPeriods extends Error {}
+
+/**
+ * Expense as a share of income for every period from the first to the
+ * last (or `from` to `to`), so a budgeting view can spot the periods that
+ * spent more than came in (`ratio` above 1). Periods without transactions
+ * are included with zero totals; a period without income has a null
+ * ratio.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const grouping = parseGroupBy(url)
+  if ('error' in grouping) return err(grouping.error, 400)
+  const week = parseWeekStart(url)
+  if ('error' in week) return err(week.error, 400)
+  const { groupBy } = grouping
+  const weekly = groupBy === 'week'
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      q.where(EXCLUDE_TRANSFERS)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+      const startOf = (column: string) =>
+        periodStartSql(groupBy, week.weekStart, column)
+      const lo = from ? startOf(`${q.param(from)}::timestamptz`) : 'NULL'
+      const hi = to ? startOf(`${q.param(to)}::timestamptz`) : 'NULL'
+      const label = weekly
+        ? `${weekLabelSql('s.period', week.weekStart)} AS label, `
+        : ''
+
+      // groupBy and weekStart are allowlisted, so they are safe to inline.
+      const rows = await sql.query(
+        `WITH totals AS (
+           SELECT ${startOf('t.date')} AS period,
+             SUM(t.amount) FILTER (WHERE t.type = 'income') AS income,
+             SUM(t.amount) FILTER (WHERE t.type = 'expense') AS expense
+           FROM transactions t
+           ${q.whereSql()}
+           GROUP BY 1
+         ),
+         bounds AS (
+           SELECT COALESCE(${lo}, MIN(period)) AS lo, COALESCE(${hi}, MAX(period)) AS hi
+           FROM totals
+         ),
+         series AS (
+           SELECT generate_series(lo, hi, interval '1 ${groupBy}') AS period
+           FROM bounds
+           LIMIT ${MAX_PERIODS + 1}
+         )
+         SELECT s.period, ${label}COALESCE(p.income, 0)::text AS income,
+           COALESCE(p.expense, 0)::text AS expense
+         FROM series s
+         LEFT JOIN totals p ON p.period = s.period
+         ORDER BY s.period`,
+        q.params,
+      )
+      if (rows.length > MAX_PERIODS) throw new TooManyPeriods()
+
+      const report = {
+        groupBy,
+        ...(weekly && { weekStart: week.weekStart }),
+        periods: rows.map(({ period, label, income, expense }) => ({
+          period,
+          ...(weekly && { label }),
+          ...incomeExpenseRatio(income, expense),
+        })),
+      }
+      return roundFigures(report, rounding.round, ['income', 'expense'])
+    })
+  } catch (e) {
+    if (e instanceof TooManyPeriods)
+      return err(
+        `range spans more than ${MAX_PERIODS} periods; use a larger groupBy`,
+        400,
+      )
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,83 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import {
+  TRANSACTION_TYPES,
+  parsePeriod,
+  parseTransactionType,
+} from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { EXCLUDE_TRANSFERS } from '../lib/reports.mts'
+
+const DEFAULT_LIMIT = 10
+const MAX_LIMIT = 100
+
+/**
+ * The biggest single transactions of one type in a period, largest first.
+ * Transfer legs are left out as they are neither income nor spend.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const rawLimit = url.searchParams.get('limit')
+  const limit = rawLimit ? Number(rawLimit) : DEFAULT_LIMIT
+  if (!Number.isInteger(limit) || limit < 1 || limit > MAX_LIMIT)
+    return err(`limit must be an integer between 1 and ${MAX_LIMIT}`, 400)
+  const type = parseTransactionType(url.searchParams.get('type') ?? 'expense')
+  if (!type)
+    return err(`type must be one of ${TRANSACTION_TYPES.join(', ')}`, 400)
+  const units = requestAmountUnits(req)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(
+      sql,
+      accountId,
+      url,
+      to,
+      async () => {
+        const q = new QueryBuilder()
+        q.where(`t.account_id = ${q.param(accountId)}`)
+        q.where(`t.type = ${q.param(type)}`)
+        q.where(EXCLUDE_TRANSFERS)
+        if (from) q.where(`t.date >= ${q.param(from)}`)
+        if (to) q.where(`t.date <= ${q.param(to)}`)
+
+        const rows = await sql.query(
+          `SELECT t.id, t.amount::text, t.date, t.description, t.type, t.status, t.category_id, t.tags
+           FROM transactions t
+           ${q.whereSql()}
+           ORDER BY ABS(t.amount) DESC, t.date DESC, t.id
+           LIMIT ${q.param(limit)}`,
+          q.params,
+        )
+        return { type, transactions: presentAmounts(rows, units) }
+      },
+      units,
+    )
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
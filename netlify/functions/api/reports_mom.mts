@@ -0,0 +1,82 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parseMonth } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  EXCLUDE_TRANSFERS,
+  parseRound,
+  percentChange,
+  roundFigures,
+} from '../lib/reports.mts'
+
+/** Income and expense for a month against the month before it. */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const range = parseMonth(url.searchParams.get('month'))
+  if (!range) return err('month must be in YYYY-MM format', 400)
+  const start = new Date(range.start)
+  start.setUTCMonth(start.getUTCMonth() - 1)
+  const prior = parseMonth(start.toISOString().slice(0, 7))!
+  const includeTransfers = url.searchParams.get('includeTransfers') === 'true'
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, range.end, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      q.where(`t.date >= ${q.param(prior.start)}`)
+      q.where(`t.date < ${q.param(range.end)}`)
+      if (!includeTransfers) q.where(EXCLUDE_TRANSFERS)
+      const current = `t.date >= ${q.param(range.start)}`
+
+      // Both months come from one scan, split by FILTER.
+      const [totals] = await sql.query(
+        `SELECT
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income' AND ${current}), 0)::text AS income,
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense' AND ${current}), 0)::text AS expense,
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income' AND NOT ${current}), 0)::text AS "priorIncome",
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense' AND NOT ${current}), 0)::text AS "priorExpense"
+         FROM transactions t
+         ${q.whereSql()}`,
+        q.params,
+      )
+
+      const report = {
+        month: range.month,
+        previousMonth: prior.month,
+        includeTransfers,
+        current: { income: totals.income, expense: totals.expense },
+        previous: { income: totals.priorIncome, expense: totals.priorExpense },
+        change: {
+          income: percentChange(totals.income, totals.priorIncome),
+          expense: percentChange(totals.expense, totals.priorExpense),
+        },
+      }
+      // The change percentages above were taken from the exact totals.
+      return roundFigures(report, rounding.round, ['income', 'expense'])
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,85 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { fillSlots, parseRound, roundFigures } from '../lib/reports.mts'
+
+/**
+ * Expense totals by day of the week (0 = Sunday) and by hour of the day,
+ * in UTC, to show when spending happens. Both arrays always cover every
+ * day and hour.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      q.where(`t.type = 'expense'`)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+
+      // One scan for both breakdowns: each grouping set leaves the other
+      // column null.
+      const dow = "EXTRACT(dow FROM t.date AT TIME ZONE 'UTC')"
+      const hour = "EXTRACT(hour FROM t.date AT TIME ZONE 'UTC')"
+      const rows = await sql.query(
+        `SELECT ${dow}::int AS dow, ${hour}::int AS hour,
+           SUM(t.amount)::text AS total,
+           COUNT(*)::int AS count
+         FROM transactions t
+         ${q.whereSql()}
+         GROUP BY GROUPING SETS ((${dow}), (${hour}))`,
+        q.params,
+      )
+
+      const pick = (key: 'dow' | 'hour') =>
+        rows
+          .filter((row) => row[key] !== null)
+          .map((row) => ({
+            slot: row[key] as number,
+            total: row.total as string,
+            count: row.count as number,
+          }))
+      const report = {
+        byDayOfWeek: fillSlots(pick('dow'), 7).map(({ slot, ...rest }) => ({
+          dayOfWeek: slot,
+          ...rest,
+        })),
+        byHour: fillSlots(pick('hour'), 24).map(({ slot, ...rest }) => ({
+          hour: slot,
+          ...rest,
+        })),
+      }
+      return roundFigures(report, rounding.round, ['total'])
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
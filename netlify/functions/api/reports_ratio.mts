@@ -0,0 +1,55 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { EXCLUDE_TRANSFERS, incomeExpenseRatio } from '../lib/reports.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+      q.where(EXCLUDE_TRANSFERS)
+
+      const [totals] = await sql.query(
+        `SELECT
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0)::text AS income,
+           COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0)::text AS expense
+         FROM transactions t
+         ${q.whereSql()}`,
+        q.params,
+      )
+
+      return incomeExpenseRatio(totals.income, totals.expense)
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,76 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parseMonth } from '../lib/params.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const range = parseMonth(url.searchParams.get('month'))
+  if (!range) return err('month must be in YYYY-MM format', 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, range.end, async () => {
+      // Every month from the first expense through the later of the last
+      // expense and the requested month takes part, with quiet months as
+      // zero, so "higher than 80% of your months" counts them too.
+      const [row] = await sql`
+        WITH monthly AS (
+          SELECT date_trunc('month', t.date) AS month, SUM(t.amount) AS expense
+          FROM transactions t
+          WHERE t.account_id = ${accountId} AND t.type = 'expense'
+            AND t.transfer_group IS NULL
+          GROUP BY 1
+        ),
+        series AS (
+          SELECT generate_series(
+            LEAST(MIN(month), ${range.start}::timestamptz),
+            GREATEST(MAX(month), ${range.start}::timestamptz),
+            interval '1 month'
+          ) AS month
+          FROM monthly
+        ),
+        ranked AS (
+          SELECT s.month, COALESCE(m.expense, 0) AS expense,
+            percent_rank() OVER (ORDER BY COALESCE(m.expense, 0)) AS rank,
+            COUNT(*) OVER () AS months
+          FROM series s
+          LEFT JOIN monthly m ON m.month = s.month
+        )
+        SELECT expense::text, ROUND(rank::numeric, 4)::float8 AS rank,
+          months::int
+        FROM ranked
+        WHERE month = ${range.start}::timestamptz
+      `
+
+      // With nothing to compare against, a rank would be meaningless.
+      const months = row?.months ?? 1
+      return {
+        month: range.month,
+        expense: row?.expense ?? '0',
+        percentile: months > 1 ? row.rank : null,
+        monthsCompared: months,
+      }
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
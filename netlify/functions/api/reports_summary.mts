@@ -0,0 +1,101 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  EXCLUDE_TRANSFERS,
+  parseGroupBy,
+  parseRound,
+  parseTimeZone,
+  parseWeekStart,
+  periodStartSql,
+  roundFigures,
+  weekLabelSql,
+} from '../lib/reports.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const grouping = parseGroupBy(url)
+  if ('error' in grouping) return err(grouping.error, 400)
+  const week = parseWeekStart(url)
+  if ('error' in week) return err(week.error, 400)
+  const zone = parseTimeZone(url)
+  if ('error' in zone) return err(zone.error, 400)
+  const { timeZone } = zone
+  const weekly = grouping.groupBy === 'week'
+  const includeTransfers = url.searchParams.get('includeTransfers') === 'true'
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+      if (!includeTransfers) q.where(EXCLUDE_TRANSFERS)
+
+      const totals = `
+        COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'income'), 0) AS income,
+        COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'expense'), 0) AS expense
+      `
+      const label = weekly
+        ? `${weekLabelSql('period', week.weekStart, timeZone)} AS label, `
+        : ''
+      const [periods, [overall]] = await Promise.all([
+        // groupBy, weekStart and tz are allowlisted, so they are safe to inline.
+        sql.query(
+          `SELECT period, ${label}income::text, expense::text, (income - expense)::text AS net
+           FROM (
+             SELECT ${periodStartSql(grouping.groupBy, week.weekStart, 't.date', timeZone)} AS period, ${totals}
+             FROM transactions t
+             ${q.whereSql()}
+             GROUP BY 1
+           ) s
+           ORDER BY period`,
+          q.params,
+        ),
+        sql.query(
+          `SELECT income::text, expense::text, (income - expense)::text AS net
+           FROM (SELECT ${totals} FROM transactions t ${q.whereSql()}) s`,
+          q.params,
+        ),
+      ])
+
+      const report = {
+        groupBy: grouping.groupBy,
+        ...(weekly && { weekStart: week.weekStart }),
+        timeZone,
+        includeTransfers,
+        totals: overall,
+        periods,
+      }
+      return roundFigures(report, rounding.round, ['income', 'expense', 'net'])
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
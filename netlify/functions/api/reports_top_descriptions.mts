@@ -0,0 +1,70 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { EXCLUDE_TRANSFERS, parseRound, roundFigures } from '../lib/reports.mts'
+
+const DEFAULT_LIMIT = 10
+const MAX_LIMIT = 100
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const rawLimit = url.searchParams.get('limit')
+  const limit = rawLimit ? Number(rawLimit) : DEFAULT_LIMIT
+  if (!Number.isInteger(limit) || limit < 1 || limit > MAX_LIMIT)
+    return err(`limit must be an integer between 1 and ${MAX_LIMIT}`, 400)
+  const type = url.searchParams.get('type') ?? 'expense'
+  if (type !== 'expense' && type !== 'all')
+    return err('type must be expense or all', 400)
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      q.where(EXCLUDE_TRANSFERS)
+      if (type === 'expense') q.where(`t.type = 'expense'`)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+
+      const rows = await sql.query(
+        `SELECT t.description, SUM(t.amount)::text AS total, COUNT(*)::int AS count
+         FROM transactions t
+         ${q.whereSql()}
+         GROUP BY t.description
+         ORDER BY SUM(t.amount) DESC, t.description
+         LIMIT ${q.param(limit)}`,
+        q.params,
+      )
+      return roundFigures({ type, descriptions: rows }, rounding.round, [
+        'total',
+      ])
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,105 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedReport } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, serverError } from '../lib/http.mts'
+import { parsePeriod } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  EXCLUDE_TRANSFERS,
+  localTimeSql,
+  parseRound,
+  parseTimeZone,
+  roundFigures,
+} from '../lib/reports.mts'
+
+const DEFAULT_WINDOW = 3
+const MAX_WINDOW = 24
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const rawWindow = url.searchParams.get('window')
+  const window = rawWindow ? Number(rawWindow) : DEFAULT_WINDOW
+  if (!Number.isInteger(window) || window < 1 || window > MAX_WINDOW)
+    return err(`window must be an integer between 1 and ${MAX_WINDOW}`, 400)
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const { from, to } = parsed.period
+  const zone = parseTimeZone(url)
+  if ('error' in zone) return err(zone.error, 400)
+  const { timeZone } = zone
+  const rounding = parseRound(url)
+  if ('error' in rounding) return err(rounding.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    return await cachedReport(sql, accountId, url, to, async () => {
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      q.where(`t.type = 'expense'`)
+      q.where(EXCLUDE_TRANSFERS)
+      if (from) q.where(`t.date >= ${q.param(from)}`)
+      if (to) q.where(`t.date <= ${q.param(to)}`)
+      // Months are truncated on the local calendar of tz, which is
+      // allowlisted and safe to inline, and turned back into instants last.
+      const local = (column: string) => localTimeSql(column, timeZone)
+      const lo = from
+        ? `date_trunc('month', ${local(`${q.param(from)}::timestamptz`)})`
+        : 'NULL'
+      const hi = to
+        ? `date_trunc('month', ${local(`${q.param(to)}::timestamptz`)})`
+        : 'NULL'
+
+      // Months without expenses are generated as zeros so the rolling
+      // average runs over a continuous series. window is validated above.
+      const rows = await sql.query(
+        `WITH monthly AS (
+           SELECT date_trunc('month', ${local('t.date')}) AS month, SUM(t.amount) AS expense
+           FROM transactions t
+           ${q.whereSql()}
+           GROUP BY 1
+         ),
+         bounds AS (
+           SELECT COALESCE(${lo}, MIN(month)) AS lo, COALESCE(${hi}, MAX(month)) AS hi
+           FROM monthly
+         ),
+         series AS (
+           SELECT generate_series(lo, hi, interval '1 month') AS month FROM bounds
+         )
+         SELECT ${local('s.month')} AS month,
+           COALESCE(m.expense, 0)::text AS expense,
+           ROUND(AVG(COALESCE(m.expense, 0)) OVER (
+             ORDER BY s.month ROWS BETWEEN ${window - 1} PRECEDING AND CURRENT ROW
+           ), 4)::text AS "rollingAverage"
+         FROM series s
+         LEFT JOIN monthly m ON m.month = s.month
+         ORDER BY s.month`,
+        q.params,
+      )
+
+      return roundFigures(
+        { window, timeZone, months: rows },
+        rounding.round,
+        ['expense', 'rollingAverage'],
+      )
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,83 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { GOAL_BODY, parseGoalFields } from '../lib/goals.mts'
+import {
+  apiHandler,
+  deleteMissing,
+  err,
+  json,
+  readJson,
+  saved,
+  serverError,
+} from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  const id = url.searchParams.get('id')
+  if (!accountId || !id)
+    return err('accountId and id query parameters are required', 400)
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    if (method === 'GET') {
+      const [row] = await sql`
+        SELECT id, account_id, name, target_amount::text,
+          target_date::text, created_at
+        FROM savings_goals
+        WHERE id = ${id} AND account_id = ${accountId}
+      `
+      if (!row) return err('Not found', 404)
+      return json(row)
+    }
+
+    if (method === 'PATCH') {
+      const read = await readJson<Record<string, unknown>>(req, GOAL_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const parsed = parseGoalFields(read.body, true)
+      if ('error' in parsed) return err(parsed.error, 400)
+      const goal = parsed.value
+      if (Object.keys(goal).length === 0)
+        return err('No fields to update', 400)
+      // Omitted fields keep their value; target_date may be cleared with an
+      // explicit null.
+      const [updated] = await sql`
+        UPDATE savings_goals SET
+          name = COALESCE(${goal.name ?? null}, name),
+          target_amount = COALESCE(${goal.targetAmount ?? null}::numeric, target_amount),
+          target_date = CASE
+            WHEN ${goal.targetDate !== undefined} THEN ${goal.targetDate ?? null}::date
+            ELSE target_date
+          END
+        WHERE id = ${id} AND account_id = ${accountId}
+        RETURNING id, account_id, name, target_amount::text,
+          target_date::text, created_at
+      `
+      if (!updated) return err('Not found', 404)
+      return saved(req, updated)
+    }
+
+    if (method === 'DELETE') {
+      const [deleted] =
+        await sql`DELETE FROM savings_goals WHERE id = ${id} AND account_id = ${accountId} RETURNING id`
+      if (!deleted) return deleteMissing(url)
+      return new Response(null, { status: 204 })
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,69 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import {
+  ACCOUNT_BALANCE,
+  SIGNED_AMOUNT,
+  countedInBalance,
+} from '../lib/balance.mts'
+import { getDb } from '../lib/db.mts'
+import { PACE_WINDOW_DAYS, goalProgress } from '../lib/goals.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') return err('Method not allowed', 405)
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  const id = url.searchParams.get('id')
+  if (!accountId || !id)
+    return err('accountId and id query parameters are required', 400)
+
+  try {
+    const sql = await getDb()
+
+    // The balance and recent net change come from one snapshot, so the
+    // pace cannot count a transaction the balance missed.
+    const [row] = await sql.query(
+      `SELECT g.id, g.name, g.target_amount::text AS target,
+         g.target_date::text AS target_date,
+         b.balance::text AS balance,
+         GREATEST(g.target_amount - b.balance, 0)::text AS remaining,
+         b.recent_net::text AS recent_net,
+         current_date::text AS today
+       FROM savings_goals g
+       JOIN bank_accounts a ON a.id = g.account_id
+       CROSS JOIN LATERAL (
+         SELECT ${ACCOUNT_BALANCE} AS balance,
+           COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (
+             WHERE ${countedInBalance()}
+               AND t.date >= now() - make_interval(days => $4)
+           ), 0) AS recent_net
+         FROM transactions t
+         WHERE t.account_id = a.id
+       ) b
+       WHERE g.id = $1 AND g.account_id = $2 AND a.user_id = $3`,
+      [id, accountId, userId, PACE_WINDOW_DAYS],
+    )
+    if (!row) return err('Not found', 404)
+
+    return json({
+      goalId: row.id,
+      name: row.name,
+      targetDate: row.target_date,
+      ...goalProgress({
+        balance: row.balance,
+        target: row.target,
+        remaining: row.remaining,
+        recentNet: row.recent_net,
+        targetDate: row.target_date,
+        today: row.today,
+      }),
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,58 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './savings_goal_progress.mts'
+
+function progress() {
+  return handler(
+    apiRequest('savings_goal_progress?accountId=acc-1&id=goal-1'),
+    context,
+  )
+}
+
+describe('GET savings_goal_progress', () => {
+  beforeEach(() => {
+    sql.query.mockReset()
+  })
+
+  it('reports the balance against the target', async () => {
+    sql.query.mockResolvedValueOnce([
+      {
+        id: 'goal-1',
+        name: 'Holiday',
+        target: '1000.0000',
+        target_date: '2026-03-01',
+        balance: '250.0000',
+        remaining: '750.0000',
+        recent_net: '90.0000',
+        today: '2026-01-01',
+      },
+    ])
+    const res = await progress()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      goalId: 'goal-1',
+      name: 'Holiday',
+      targetDate: '2026-03-01',
+      balance: '250.0000',
+      target: '1000.0000',
+      remaining: '750.0000',
+      percent: 25,
+      status: 'behind',
+      daysRemaining: 59,
+      pacePerDay: 1,
+      projectedBalance: 309,
+    })
+    expect(sql.query.mock.calls[0][1]).toEqual([
+      'goal-1',
+      'acc-1',
+      'user-1',
+      90,
+    ])
+  })
+
+  it('returns 404 for a goal the user does not own', async () => {
+    sql.query.mockResolvedValueOnce([])
+    const res = await progress()
+    expect(res.status).toBe(404)
+  })
+})
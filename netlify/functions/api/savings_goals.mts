@@ -0,0 +1,67 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { GOAL_BODY, parseGoalFields } from '../lib/goals.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  json,
+  readJson,
+  serverError,
+} from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    if (method === 'GET') {
+      const rows = await sql`
+        SELECT id, account_id, name, target_amount::text,
+          target_date::text, created_at
+        FROM savings_goals
+        WHERE account_id = ${accountId}
+        ORDER BY target_date NULLS LAST, created_at, id
+      `
+      return json(rows)
+    }
+
+    if (method === 'POST') {
+      const read = await readJson<Record<string, unknown>>(req, GOAL_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const parsed = parseGoalFields(read.body, false)
+      if ('error' in parsed) return err(parsed.error, 400)
+      const goal = parsed.value
+      const [row] = await sql`
+        INSERT INTO savings_goals (id, account_id, name, target_amount, target_date)
+        VALUES (${newId()}, ${accountId}, ${goal.name}, ${goal.targetAmount}, ${goal.targetDate})
+        RETURNING id, account_id, name, target_amount::text,
+          target_date::text, created_at
+      `
+      return created(
+        req,
+        `savings_goal?accountId=${encodeURIComponent(accountId)}&id=${encodeURIComponent(row.id)}`,
+        row,
+      )
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
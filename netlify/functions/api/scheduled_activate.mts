@@ -0,0 +1,60 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { PG_OVERDRAWN, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+/**
+ * Activates the caller's scheduled transactions whose date has arrived, so
+ * they start counting toward the balance; `accountId` limits it to one
+ * account. Meant to be called on a timer or when the app opens. Running it
+ * again before anything else falls due changes nothing.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (accountId !== null && !isUuid(accountId))
+    return err('accountId must be a UUID', 400)
+
+  try {
+    const sql = await getDb()
+
+    if (accountId !== null) {
+      const [account] =
+        await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+      if (!account) return err('Not found', 404)
+    }
+
+    try {
+      const [{ activated }] = await sql`
+        WITH due AS (
+          UPDATE transactions t
+          SET scheduled = false, updated_at = now()
+          FROM bank_accounts a
+          WHERE a.id = t.account_id AND a.user_id = ${userId}
+            AND (${accountId}::uuid IS NULL OR t.account_id = ${accountId})
+            AND t.scheduled AND t.date <= now()
+          RETURNING t.id
+        )
+        SELECT COUNT(*)::int AS activated FROM due
+      `
+      return json({ activated })
+    } catch (e) {
+      // Nothing is activated when a due expense would overdraw an account
+      // with allow_negative off.
+      if (isPgError(e, PG_OVERDRAWN))
+        return err('activating would make a balance negative', 409)
+      throw e
+    }
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,57 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './scheduled_activate.mts'
+
+const accountId = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+
+function activate(query = '') {
+  return handler(
+    apiRequest(`scheduled_activate?${query}`, {
+      method: 'POST',
+    }),
+    context,
+  )
+}
+
+describe('POST scheduled_activate', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('activates every due scheduled transaction of the user', async () => {
+    sql.mockResolvedValueOnce([{ activated: 3 }])
+    const res = await activate()
+    expect(await res.json()).toEqual({ activated: 3 })
+    const [strings, ...values] = sql.mock.calls[0]
+    expect(strings.join('')).toContain('t.scheduled AND t.date <= now()')
+    expect(values).toEqual(['user-1', null, null])
+  })
+
+  it('limits activation to one owned account', async () => {
+    sql.mockResolvedValueOnce([{ id: accountId }])
+    sql.mockResolvedValueOnce([{ activated: 0 }])
+    const res = await activate(`accountId=${accountId}`)
+    expect(await res.json()).toEqual({ activated: 0 })
+    expect(sql.mock.calls[1].slice(1)).toEqual(['user-1', accountId, accountId])
+  })
+
+  it('activates nothing when a due expense would overdraw an account', async () => {
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await activate()
+    expect(res.status).toBe(409)
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await activate(`accountId=${accountId}`)
+    expect(res.status).toBe(404)
+  })
+
+  it('rejects a malformed accountId', async () => {
+    const res = await activate('accountId=abc')
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,78 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedTotal } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { estimateCount, parsePagination } from '../lib/pagination.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { applyTransactionFilters } from '../lib/transaction-filters.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const paging = parsePagination(url)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  const q = new QueryBuilder()
+  q.where(`a.user_id = ${q.param(userId)}`)
+  const units = requestAmountUnits(req)
+  const filterError = applyTransactionFilters(q, url, new Date(), units)
+  if (filterError) return err(filterError, 400)
+
+  try {
+    const sql = await getDb()
+
+    const from = `
+      FROM transactions t
+      JOIN bank_accounts a ON t.account_id = a.id
+      ${q.whereSql()}
+    `
+    // estimate=true trades an exact total for the planner's estimate, for
+    // deployments where counting every match is too slow.
+    const estimate = url.searchParams.get('estimate') === 'true'
+    const countTotal = (): Promise<number> =>
+      estimate
+        ? estimateCount(sql, from, q.params)
+        : sql
+            .query(`SELECT COUNT(*)::int AS total ${from}`, q.params)
+            .then(([row]) => row.total as number)
+    // cacheTotal=true reuses the total while paging through the same
+    // search; the rows are always read fresh.
+    const cacheTotal = url.searchParams.get('cacheTotal') === 'true'
+    const [rows, counted] = await Promise.all([
+      sql.query(
+        `SELECT t.id, t.account_id, a.name AS "accountName", t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared, t.status, t.scheduled, t.currency, t.original_amount::text, t.category_id, t.tags, t.seq
+         ${from}
+         ORDER BY t.date DESC, t.id
+         LIMIT ${pageSize} OFFSET ${offset}`,
+        q.params,
+      ),
+      cacheTotal
+        ? cachedTotal(sql, { userId }, url, countTotal)
+        : countTotal().then((total) => ({ total, hit: false })),
+    ])
+
+    const res = json({
+      data: presentAmounts(rows, units),
+      total: counted.total,
+      totalIsEstimate: estimate,
+      page,
+      pageSize,
+    })
+    if (cacheTotal)
+      res.headers.set('X-Total-Cache', counted.hit ? 'HIT' : 'MISS')
+    return res
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
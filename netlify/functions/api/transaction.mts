@@ -1,39 +1,82 @@
 import type { Context } from '@netlify/functions'
-import { neon } from '@neondatabase/serverless'
+import { allowedTransactionTypes } from '../lib/accounts.mts'
+import { parseAmountIn, presentAmounts } from '../lib/amount.mts'
 import { getSessionFromRequest } from '../lib/auth.mts'
-import { handlePreflight, withCors } from '../lib/cors.mts'
+import { parseCompute, withComputedFields } from '../lib/computed-fields.mts'
+import { parseCurrency } from '../lib/currency.mts'
+import { PG_OVERDRAWN, getDb, isPgError } from '../lib/db.mts'
+import { fitDescription, wantsTruncation } from '../lib/description.mts'
+import {
+  etag,
+  ifMatchFails,
+  ifNoneMatchHits,
+  notModified,
+} from '../lib/etag.mts'
+import { expandTransactions, parseExpand } from '../lib/expand.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import {
+  apiHandler,
+  choiceErr,
+  deleteMissing,
+  err,
+  json,
+  readJson,
+  saved,
+  serverError,
+} from '../lib/http.mts'
+import type { BodySchema } from '../lib/http.mts'
+import {
+  moneyFormatter,
+  requestLocale,
+  wantsFormatted,
+  withFormattedAmounts,
+} from '../lib/money-format.mts'
+import {
+  TRANSACTION_STATUSES,
+  TRANSACTION_TYPES,
+  isUuid,
+  parseTransactionStatus,
+  parseTransactionType,
+} from '../lib/params.mts'
+import { dispatchWebhooks } from '../lib/webhooks.mts'
 
-const DATABASE_URL = process.env.DATABASE_URL
-
-async function getDb() {
-  if (!DATABASE_URL) throw new Response('DATABASE_URL not set', { status: 500 })
-  return neon(DATABASE_URL)
-}
-
-function json<T>(data: T, status = 200) {
-  return new Response(JSON.stringify(data), {
-    status,
-    headers: { 'Content-Type': 'application/json' },
-  })
+/** Field types accepted by PATCH; amounts may be decimal strings. */
+const TRANSACTION_BODY: BodySchema = {
+  amount: ['number', 'string'],
+  date: 'string',
+  description: 'string',
+  type: 'string',
+  status: 'string',
+  scheduled: 'boolean',
+  transfer_group: 'string',
+  category_id: 'string',
+  currency: 'string',
+  original_amount: ['number', 'string'],
 }
 
-function err(message: string, status: number) {
-  return json({ error: message }, status)
+/** A split transaction's amount only changes by splitting it again. */
+function resplitErr(): Response {
+  return err(
+    'transaction is split; delete its splits before changing the amount',
+    409,
+  )
 }
 
-export default async (req: Request, _context: Context) => {
-  const preflight = handlePreflight(req)
-  if (preflight) return preflight
-
+export default apiHandler(async (req: Request, context: Context) => {
   const session = await getSessionFromRequest(req)
-  if (!session) return withCors(req, err('Unauthorized', 401))
+  if (!session) return err('Unauthorized', 401)
   const userId = session.user.id
 
   const url = new URL(req.url)
   const accountId = url.searchParams.get('accountId')
   const id = url.searchParams.get('id')
-  if (!accountId) return withCors(req, err('accountId query parameter is required', 400))
-  if (!id) return withCors(req, err('id query parameter is required', 400))
+  if (!accountId) return err('accountId query parameter is required', 400)
+  // A GET may name the transaction by its number within the account.
+  const rawSeq = url.searchParams.get('seq')
+  const seq = req.method === 'GET' && !id ? rawSeq : null
+  if (!id && seq === null) return err('id query parameter is required', 400)
+  if (seq !== null && !/^[1-9]\d{0,17}$/.test(seq))
+    return err('seq must be a positive integer', 400)
 
   const method = req.method
 
@@ -41,88 +84,254 @@ export default async (req: Request, _context: Context) => {
     const sql = await getDb()
 
     if (method === 'GET') {
-      const [row] = await sql`
-        SELECT t.id, t.account_id, t.amount::text, t.date, t.description, t.type
+      const expansion = parseExpand(url)
+      if ('error' in expansion) return err(expansion.error, 400)
+      const computation = parseCompute(url)
+      if ('error' in computation) return err(computation.error, 400)
+      const [found] = await sql`
+        SELECT t.id, t.account_id, t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared, t.status, t.scheduled, t.currency, t.original_amount::text, t.import_batch_id, t.category_id, t.tags, t.seq,
+          (extract(epoch FROM t.updated_at) * 1000000)::bigint::text AS version,
+          a.currency AS account_currency
         FROM transactions t
         JOIN bank_accounts a ON t.account_id = a.id
-        WHERE t.id = ${id} AND t.account_id = ${accountId} AND a.user_id = ${userId}
+        WHERE (t.id = ${id} OR t.seq = ${seq})
+          AND t.account_id = ${accountId} AND a.user_id = ${userId}
       `
-      if (!row) return withCors(req, err('Not found', 404))
-      return withCors(req, json(row))
+      if (!found) return err('Not found', 404)
+      const { version, account_currency, ...row } = found
+      // Polling clients revalidate with If-None-Match; an unchanged row
+      // skips expansion and the body entirely.
+      const tag = etag(version)
+      if (ifNoneMatchHits(req, tag)) return notModified(tag)
+      const rows = await expandTransactions(sql, [row], expansion.expand)
+      const [expanded] = withComputedFields(
+        presentAmounts(
+          wantsFormatted(url)
+            ? withFormattedAmounts(
+                rows,
+                moneyFormatter(account_currency, requestLocale(req, url)),
+              )
+            : rows,
+          requestAmountUnits(req),
+        ),
+        computation.compute,
+      )
+      const res = json(expanded)
+      res.headers.set('ETag', tag)
+      return res
     }
 
     if (method === 'PATCH') {
-      let body: {
+      const read = await readJson<{
         amount?: number | string
         date?: string
-        description?: string
+        description?: string | null
         type?: string
-      }
-      try {
-        body = (await req.json()) as typeof body
-      } catch {
-        return withCors(req, err('Invalid JSON', 400))
-      }
-      const amount = body.amount != null ? Number(body.amount) : undefined
-      if (amount !== undefined && Number.isNaN(amount))
-        return withCors(req, err('amount must be a number', 400))
+        status?: string
+        scheduled?: boolean
+        transfer_group?: string | null
+        category_id?: string | null
+        currency?: string | null
+        original_amount?: number | string | null
+      }>(req, TRANSACTION_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const units = requestAmountUnits(req)
+      const amount =
+        body.amount != null ? parseAmountIn(body.amount, units) : undefined
+      if (amount === null) return err('amount must be a number', 400)
+      // Same rule as create: zero is only for placeholders that opt in.
+      if (
+        amount !== undefined &&
+        Number(amount) === 0 &&
+        url.searchParams.get('allowZero') !== 'true'
+      )
+        return err('amount cannot be zero', 400)
+      // An omitted date is left unchanged. Every transaction needs a date,
+      // so "" (or null) is rejected rather than treated as clearing it.
       const date =
-        body.date !== undefined ? String(body.date).trim() : undefined
-      const description =
-        body.description !== undefined ? String(body.description) : undefined
-      const type =
-        body.type === 'income' || body.type === 'expense'
-          ? body.type
+        body.date !== undefined ? String(body.date ?? '').trim() : undefined
+      if (date === '') return err('date cannot be empty', 400)
+      if (date !== undefined && Number.isNaN(Date.parse(date)))
+        return err('date must be a valid date', 400)
+      // An omitted description is left unchanged; "" (or null) clears it.
+      let description =
+        body.description !== undefined
+          ? String(body.description ?? '')
           : undefined
+      if (description !== undefined) {
+        const fitted = fitDescription(description, wantsTruncation(url))
+        if ('error' in fitted) return err(fitted.error, 400)
+        description = fitted.value
+      }
+      const type =
+        body.type !== undefined ? parseTransactionType(body.type) : undefined
+      if (type === null)
+        return choiceErr(
+          'type must be income or expense',
+          body.type,
+          TRANSACTION_TYPES,
+        )
+      // A pending transaction is marked posted once the bank settles it.
+      const status =
+        body.status != null ? parseTransactionStatus(body.status) : undefined
+      if (status === null)
+        return choiceErr(
+          'status must be pending or posted',
+          body.status,
+          TRANSACTION_STATUSES,
+        )
+      const scheduled = body.scheduled ?? undefined
+      const transferGroup = body.transfer_group
+      if (
+        transferGroup !== undefined &&
+        transferGroup !== null &&
+        !isUuid(String(transferGroup))
+      )
+        return err('transfer_group must be a UUID', 400)
+      // category_id may be cleared with an explicit null.
+      const categoryId = body.category_id
+      if (categoryId != null && !isUuid(String(categoryId)))
+        return err('category_id must be a UUID', 400)
+      // The foreign currency and its amount are cleared with explicit nulls.
+      const currency =
+        body.currency != null ? parseCurrency(body.currency) : body.currency
+      if (currency === null && body.currency !== null)
+        return err('currency must be a 3-letter ISO 4217 code', 400)
+      const originalAmount =
+        body.original_amount != null
+          ? parseAmountIn(body.original_amount, units)
+          : body.original_amount
+      if (originalAmount === null && body.original_amount !== null)
+        return err('original_amount must be a number', 400)
 
       if (
         amount === undefined &&
         date === undefined &&
         description === undefined &&
-        type === undefined
+        type === undefined &&
+        status === undefined &&
+        scheduled === undefined &&
+        transferGroup === undefined &&
+        categoryId === undefined &&
+        currency === undefined &&
+        originalAmount === undefined
       ) {
-        return withCors(req, err('No fields to update', 400))
+        return err('No fields to update', 400)
+      }
+      if (categoryId) {
+        const [category] =
+          await sql`SELECT id FROM categories WHERE id = ${categoryId} AND user_id = ${userId}`
+        if (!category) return err('category not found', 400)
       }
 
       const [existing] = await sql`
-        SELECT t.id, t.account_id, t.amount, t.date, t.description, t.type
+        SELECT t.id, t.account_id, t.amount, t.date, t.description, t.type, t.status, t.scheduled, t.transfer_group, t.category_id,
+          t.currency, t.original_amount::text, a.type AS account_type,
+          t.amount <> ${amount ?? null}::numeric AS amount_changed,
+          EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = t.id) AS split,
+          (extract(epoch FROM t.updated_at) * 1000000)::bigint::text AS version
         FROM transactions t
         JOIN bank_accounts a ON t.account_id = a.id
         WHERE t.id = ${id} AND t.account_id = ${accountId} AND a.user_id = ${userId}
       `
-      if (!existing) return withCors(req, err('Not found', 404))
+      if (!existing) return err('Not found', 404)
+      if (ifMatchFails(req, etag(existing.version)))
+        return err('Precondition failed', 412)
+      // Splits must add up to the amount, so a new amount needs new splits.
+      if (existing.split && existing.amount_changed) return resplitErr()
+      // Only a changed type is checked, so rows that predate the account
+      // type's TRANSACTION_TYPE_RULES can still be edited otherwise.
+      const allowed = allowedTransactionTypes(existing.account_type)
+      if (type !== undefined && !allowed.includes(type))
+        return choiceErr(
+          `${existing.account_type} accounts only accept ${allowed.join(' or ')} transactions`,
+          type,
+          allowed,
+        )
+      // With If-Match, the write only lands if nobody changed the row since
+      // it was read above.
+      const conditional = req.headers.has('If-Match')
 
-      const newAmount = amount !== undefined ? amount : Number(existing.amount)
+      const newAmount = amount !== undefined ? amount : String(existing.amount)
       const newDate = date !== undefined ? date : String(existing.date)
       const newDescription =
         description !== undefined ? description : String(existing.description)
       const newType = type !== undefined ? type : String(existing.type)
+      const newStatus = status !== undefined ? status : String(existing.status)
+      const newScheduled =
+        scheduled !== undefined ? scheduled : Boolean(existing.scheduled)
+      const newTransferGroup =
+        transferGroup !== undefined ? transferGroup : existing.transfer_group
+      const newCategoryId =
+        categoryId !== undefined ? categoryId : existing.category_id
+      const newCurrency = currency !== undefined ? currency : existing.currency
+      const newOriginalAmount =
+        originalAmount !== undefined ? originalAmount : existing.original_amount
+      if ((newCurrency === null) !== (newOriginalAmount === null))
+        return err('currency and original_amount must be set together', 400)
 
       const [updated] = await sql`
         UPDATE transactions
-        SET amount = ${newAmount}, date = ${newDate}::timestamptz, description = ${newDescription}, type = ${newType}
+        SET amount = ${newAmount}, date = ${newDate}::timestamptz, description = ${newDescription}, type = ${newType}, status = ${newStatus}, scheduled = ${newScheduled}, transfer_group = ${newTransferGroup}, category_id = ${newCategoryId}, currency = ${newCurrency}, original_amount = ${newOriginalAmount}, updated_at = now()
         WHERE id = ${id} AND account_id = ${accountId}
-        RETURNING id, account_id, amount::text, date, description, type
+          AND (${!conditional} OR (extract(epoch FROM updated_at) * 1000000)::bigint = ${existing.version}::bigint)
+          AND (amount = ${newAmount}::numeric OR NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.transaction_id = transactions.id))
+        RETURNING id, account_id, amount::text, date, description, type, transfer_group, cleared, status, scheduled, currency, original_amount::text, category_id, tags, seq,
+          (extract(epoch FROM updated_at) * 1000000)::bigint::text AS version
       `
-      if (!updated) return withCors(req, err('Not found', 404))
-      return withCors(req, json(updated))
+      if (!updated) {
+        // Without If-Match the UPDATE only misses a row that was deleted
+        // or, when the amount changes, split since it was read.
+        if (conditional) return err('Precondition failed', 412)
+        return existing.amount_changed ? resplitErr() : err('Not found', 404)
+      }
+      const { version, ...stored } = updated
+      // As on create, the webhook body ignores the caller's feature flags.
+      const [event] = presentAmounts([stored])
+      dispatchWebhooks(sql, context, userId, 'transaction.updated', event)
+      const res = saved(req, presentAmounts([stored], units)[0])
+      res.headers.set('ETag', etag(version))
+      return res
     }
 
     if (method === 'DELETE') {
       // Verify ownership before deleting
       const [owned] = await sql`
-        SELECT t.id FROM transactions t
+        SELECT t.id, (extract(epoch FROM t.updated_at) * 1000000)::bigint::text AS version
+        FROM transactions t
         JOIN bank_accounts a ON t.account_id = a.id
         WHERE t.id = ${id} AND t.account_id = ${accountId} AND a.user_id = ${userId}
       `
-      if (!owned) return withCors(req, err('Not found', 404))
-      await sql`DELETE FROM transactions WHERE id = ${id} AND account_id = ${accountId}`
-      return withCors(req, new Response(null, { status: 204 }))
+      if (!owned) return deleteMissing(url)
+      if (ifMatchFails(req, etag(owned.version)))
+        return err('Precondition failed', 412)
+      const conditional = req.headers.has('If-Match')
+      const [deleted] = await sql`
+        DELETE FROM transactions
+        WHERE id = ${id} AND account_id = ${accountId}
+          AND (${!conditional} OR (extract(epoch FROM updated_at) * 1000000)::bigint = ${owned.version}::bigint)
+        RETURNING id
+      `
+      if (!deleted && conditional) return err('Precondition failed', 412)
+      if (deleted) {
+        dispatchWebhooks(sql, context, userId, 'transaction.deleted', {
+          id,
+          account_id: accountId,
+        })
+      }
+      return new Response(null, { status: 204 })
     }
 
-    return withCors(req, err('Method not allowed', 405))
+    return err('Method not allowed', 405)
   } catch (e) {
-    console.error(e)
-    return withCors(req, err('Internal server error', 500))
+    // Raising an expense, lowering income or deleting income can take an
+    // account with allow_negative off below zero.
+    if (isPgError(e, PG_OVERDRAWN)) {
+      return method === 'DELETE'
+        ? err('deleting the transaction would make the balance negative', 409)
+        : err('transaction would make the balance negative', 409)
+    }
+    return serverError(req, context, e)
   }
-}
+})
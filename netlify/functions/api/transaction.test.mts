@@ -0,0 +1,278 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transaction.mts'
+
+const existing = {
+  id: 'tx-1',
+  account_id: 'acc-1',
+  amount: '12.5000',
+  date: '2025-02-01T00:00:00.000Z',
+  description: 'Coffee',
+  type: 'expense',
+  transfer_group: null,
+}
+
+function patch(body: unknown) {
+  return handler(
+    apiRequest('transaction?accountId=acc-1&id=tx-1', {
+      method: 'PATCH',
+      body: JSON.stringify(body),
+    }),
+    context,
+  )
+}
+
+/** The value bound to `description = ...` in the UPDATE statement. */
+function updatedDescription() {
+  const [strings, ...values] = sql.mock.calls[1] as [
+    TemplateStringsArray,
+    ...unknown[],
+  ]
+  const index = strings.findIndex((s) => s.endsWith('description = '))
+  return values[index]
+}
+
+describe('PATCH transaction', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([existing])
+    sql.mockResolvedValueOnce([{ ...existing }])
+  })
+
+  it('clears the description when it is an empty string', async () => {
+    const res = await patch({ description: '' })
+    expect(res.status).toBe(200)
+    expect(updatedDescription()).toBe('')
+  })
+
+  it('clears the description when it is null', async () => {
+    await patch({ description: null })
+    expect(updatedDescription()).toBe('')
+  })
+
+  it('keeps the description when it is omitted', async () => {
+    const res = await patch({ amount: '15' })
+    expect(res.status).toBe(200)
+    expect(updatedDescription()).toBe('Coffee')
+  })
+
+  it('keeps the date when it is omitted', async () => {
+    await patch({ description: 'Tea' })
+    const [strings, ...values] = sql.mock.calls[1] as [
+      TemplateStringsArray,
+      ...unknown[],
+    ]
+    const index = strings.findIndex((s) => s.endsWith('date = '))
+    expect(values[index]).toBe(existing.date)
+  })
+
+  it.each(['', '  ', null])(
+    'rejects an explicit empty date (%j)',
+    async (date) => {
+      const res = await patch({ date })
+      expect(res.status).toBe(400)
+      expect(await res.json()).toEqual({ error: 'date cannot be empty' })
+      expect(sql).not.toHaveBeenCalled()
+    },
+  )
+
+  it('rejects an unparseable date separately from an empty one', async () => {
+    const res = await patch({ date: 'next tuesday' })
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'date must be a valid date' })
+  })
+
+  it('rejects a zero amount like create does', async () => {
+    const res = await patch({ amount: '0' })
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'amount cannot be zero' })
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('accepts a zero amount with allowZero=true', async () => {
+    const res = await handler(
+      apiRequest('transaction?accountId=acc-1&id=tx-1&allowZero=true', {
+        method: 'PATCH',
+        body: JSON.stringify({ amount: '0' }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(200)
+  })
+
+  it('rejects an overlong description by default', async () => {
+    const res = await patch({ description: 'x'.repeat(501) })
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('truncates an overlong description with truncate=true', async () => {
+    const res = await handler(
+      apiRequest('transaction?accountId=acc-1&id=tx-1&truncate=true', {
+        method: 'PATCH',
+        body: JSON.stringify({ description: 'x'.repeat(501) }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(200)
+    expect(updatedDescription()).toBe('x'.repeat(500))
+  })
+
+  it('refuses to change the amount of a split transaction', async () => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([
+      { ...existing, split: true, amount_changed: true },
+    ])
+    const res = await patch({ amount: '20' })
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error:
+        'transaction is split; delete its splits before changing the amount',
+    })
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('edits a split transaction when the amount stays the same', async () => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([
+      { ...existing, split: true, amount_changed: false },
+    ])
+    sql.mockResolvedValueOnce([{ ...existing }])
+    const res = await patch({ amount: '12.5', description: 'Tea' })
+    expect(res.status).toBe(200)
+  })
+
+  it('answers 409 when the transaction was split after it was read', async () => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([{ ...existing, amount_changed: true }])
+    sql.mockResolvedValueOnce([])
+    const res = await patch({ amount: '20' })
+    expect(res.status).toBe(409)
+  })
+
+  it('returns 409 when the edit would overdraw a protected account', async () => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([existing])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await patch({ amount: '500' })
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: 'transaction would make the balance negative',
+    })
+  })
+})
+
+describe('If-Match', () => {
+  const versioned = { ...existing, version: '1738368000000000' }
+
+  function send(method: string, ifMatch: string) {
+    return handler(
+      apiRequest('transaction?accountId=acc-1&id=tx-1', {
+        method,
+        headers: { 'If-Match': ifMatch },
+        body: method === 'PATCH' ? JSON.stringify({ amount: '15' }) : null,
+      }),
+      context,
+    )
+  }
+
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('rejects a stale version on PATCH without writing', async () => {
+    sql.mockResolvedValueOnce([versioned])
+    const res = await send('PATCH', '"1700000000000000"')
+    expect(res.status).toBe(412)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('rejects a stale version on DELETE without writing', async () => {
+    sql.mockResolvedValueOnce([versioned])
+    const res = await send('DELETE', '"1700000000000000"')
+    expect(res.status).toBe(412)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('returns 412 when the row changes between read and write', async () => {
+    sql.mockResolvedValueOnce([versioned])
+    sql.mockResolvedValueOnce([])
+    const res = await send('PATCH', '"1738368000000000"')
+    expect(res.status).toBe(412)
+  })
+
+  it('applies a matching version and returns the new ETag', async () => {
+    sql.mockResolvedValueOnce([versioned])
+    sql.mockResolvedValueOnce([{ ...existing, version: '1738368000000001' }])
+    const res = await send('PATCH', '"1738368000000000"')
+    expect(res.status).toBe(200)
+    expect(res.headers.get('ETag')).toBe('"1738368000000001"')
+    expect(await res.json()).not.toHaveProperty('version')
+  })
+})
+
+describe('GET transaction by seq', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  function get(query: string) {
+    return handler(apiRequest(`transaction?accountId=acc-1&${query}`), context)
+  }
+
+  it('finds the transaction by its number in the account', async () => {
+    sql.mockResolvedValueOnce([
+      { ...existing, seq: '7', version: '1', account_currency: 'USD' },
+    ])
+    const res = await get('seq=7')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toMatchObject({ id: 'tx-1', seq: '7' })
+    // No id was given, so only the seq can match.
+    expect(sql.mock.calls[0].slice(1, 3)).toEqual([null, '7'])
+  })
+
+  it('rejects a seq that is not a positive integer', async () => {
+    const res = await get('seq=0')
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
+
+describe('If-None-Match', () => {
+  const found = {
+    ...existing,
+    version: '1738368000000000',
+    account_currency: 'USD',
+  }
+
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  function get(ifNoneMatch: string) {
+    return handler(
+      apiRequest('transaction?accountId=acc-1&id=tx-1', {
+        headers: { 'If-None-Match': ifNoneMatch },
+      }),
+      context,
+    )
+  }
+
+  it('returns 304 without a body while the version is unchanged', async () => {
+    sql.mockResolvedValueOnce([found])
+    const res = await get('"1738368000000000"')
+    expect(res.status).toBe(304)
+    expect(res.headers.get('ETag')).toBe('"1738368000000000"')
+    expect(await res.text()).toBe('')
+  })
+
+  it('returns the transaction and its new ETag once it has changed', async () => {
+    sql.mockResolvedValueOnce([found])
+    const res = await get('"1700000000000000"')
+    expect(res.status).toBe(200)
+    expect(res.headers.get('ETag')).toBe('"1738368000000000"')
+    expect(await res.json()).toMatchObject({ id: 'tx-1' })
+  })
+})
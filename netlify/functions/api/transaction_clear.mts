@@ -0,0 +1,4 @@
+import { setClearedHandler } from '../lib/cleared.mts'
+import { apiHandler } from '../lib/http.mts'
+
+export default apiHandler(setClearedHandler(true))
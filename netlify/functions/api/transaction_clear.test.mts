@@ -0,0 +1,42 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import clear from './transaction_clear.mts'
+import unclear from './transaction_unclear.mts'
+
+function post(query = 'accountId=acc-1&id=tx-1') {
+  return apiRequest(`transaction_clear?${query}`, {
+    method: 'POST',
+  })
+}
+
+describe('transaction_clear / transaction_unclear', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('marks the transaction cleared', async () => {
+    sql.mockResolvedValueOnce([{ id: 'tx-1', cleared: true }])
+    const res = await clear(post(), context)
+    expect(res.status).toBe(200)
+    expect(sql.mock.calls[0]).toContain(true)
+    expect(await res.json()).toEqual({ id: 'tx-1', cleared: true })
+  })
+
+  it('marks the transaction pending again', async () => {
+    sql.mockResolvedValueOnce([{ id: 'tx-1', cleared: false }])
+    await unclear(post(), context)
+    expect(sql.mock.calls[0]).toContain(false)
+  })
+
+  it('returns 404 for a transaction outside the user accounts', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await clear(post(), context)
+    expect(res.status).toBe(404)
+  })
+
+  it('requires both ids', async () => {
+    const res = await clear(post('accountId=acc-1'), context)
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,92 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { parseSplits } from '../lib/splits.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  const id = url.searchParams.get('id')
+  if (!accountId) return err('accountId query parameter is required', 400)
+  if (!id) return err('id query parameter is required', 400)
+
+  const method = req.method
+  const units = requestAmountUnits(req)
+
+  try {
+    const sql = await getDb()
+
+    const [parent] = await sql`
+      SELECT t.id, t.amount::text
+      FROM transactions t
+      JOIN bank_accounts a ON t.account_id = a.id
+      WHERE t.id = ${id} AND t.account_id = ${accountId} AND a.user_id = ${userId}
+    `
+    if (!parent) return err('Not found', 404)
+
+    if (method === 'GET') {
+      const rows = await sql`
+        SELECT id, transaction_id, amount::text, description, category_id
+        FROM transaction_splits
+        WHERE transaction_id = ${id}
+        ORDER BY amount DESC
+      `
+      return json(presentAmounts(rows, units))
+    }
+
+    if (method === 'POST') {
+      const read = await readJson<{ splits?: unknown }>(req, {
+        splits: 'array',
+      })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const parsed = parseSplits(parent.amount, body.splits, units)
+      if ('error' in parsed) return err(parsed.error, 400)
+      const categoryIds = parsed.splits.map((s) => s.categoryId)
+      const named = [...new Set(categoryIds.filter((c) => c !== null))]
+      if (named.length > 0) {
+        const owned = await sql`
+          SELECT id FROM categories
+          WHERE id = ANY(${named}::uuid[]) AND user_id = ${userId}
+        `
+        if (owned.length !== named.length)
+          return err('split category not found', 400)
+      }
+
+      // Replace any existing splits so the set always sums to the parent,
+      // and touch the parent so its ETag and cached reports move on.
+      const amounts = parsed.splits.map((s) => s.amount)
+      const descriptions = parsed.splits.map((s) => s.description)
+      const [, rows] = await sql.transaction([
+        sql`DELETE FROM transaction_splits WHERE transaction_id = ${id}`,
+        sql`
+          INSERT INTO transaction_splits (id, transaction_id, amount, description, category_id)
+          SELECT gen_random_uuid(), ${id}, s.amount, s.description, s.category_id
+          FROM unnest(${amounts}::numeric[], ${descriptions}::text[], ${categoryIds}::uuid[]) AS s(amount, description, category_id)
+          RETURNING id, transaction_id, amount::text, description, category_id
+        `,
+        sql`UPDATE transactions SET updated_at = now() WHERE id = ${id}`,
+      ])
+      return json(presentAmounts(rows, units), 201)
+    }
+
+    if (method === 'DELETE') {
+      await sql.transaction([
+        sql`DELETE FROM transaction_splits WHERE transaction_id = ${id}`,
+        sql`UPDATE transactions SET updated_at = now() WHERE id = ${id}`,
+      ])
+      return new Response(null, { status: 204 })
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
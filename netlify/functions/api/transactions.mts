@@ -1,38 +1,139 @@
 import type { Context } from '@netlify/functions'
-import { neon } from '@neondatabase/serverless'
+import { allowedTransactionTypes } from '../lib/accounts.mts'
+import { parseAmountIn, presentAmounts } from '../lib/amount.mts'
+import type { AmountUnits } from '../lib/amount.mts'
 import { getSessionFromRequest } from '../lib/auth.mts'
-import { handlePreflight, withCors } from '../lib/cors.mts'
+import { parseCompute, withComputedFields } from '../lib/computed-fields.mts'
+import type { ComputedField } from '../lib/computed-fields.mts'
+import { matchCategoryRule } from '../lib/category-rules.mts'
+import { parseCurrency } from '../lib/currency.mts'
+import {
+  PG_FOREIGN_KEY_VIOLATION,
+  PG_INVALID_REGULAR_EXPRESSION,
+  PG_OVERDRAWN,
+  getDb,
+  isPgError,
+} from '../lib/db.mts'
+import type { Sql } from '../lib/db.mts'
+import { fitDescription, wantsTruncation } from '../lib/description.mts'
+import { expandTransactions, parseExpand } from '../lib/expand.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import type { TransactionExpansion } from '../lib/expand.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  invalidChoice,
+  json,
+  readJson,
+  serverError,
+  streamJsonArray,
+  validationErr,
+} from '../lib/http.mts'
+import type {
+  BodySchema,
+  FieldDetails,
+  FieldErrors,
+} from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+import {
+  MAX_RESULT_ROWS,
+  MAX_TRANSACTIONS_PER_ACCOUNT,
+  capRows,
+  limitReached,
+  markTruncated,
+} from '../lib/limits.mts'
+import {
+  moneyFormatter,
+  requestLocale,
+  wantsFormatted,
+  withFormattedAmounts,
+} from '../lib/money-format.mts'
+import { parseLast } from '../lib/pagination.mts'
+import {
+  TRANSACTION_STATUSES,
+  TRANSACTION_TYPES,
+  isUuid,
+  parseTransactionStatus,
+  parseTransactionType,
+} from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { applyTransactionFilters } from '../lib/transaction-filters.mts'
+import { dispatchWebhooks } from '../lib/webhooks.mts'
 
-const DATABASE_URL = process.env.DATABASE_URL
-
-async function getDb() {
-  if (!DATABASE_URL) throw new Response('DATABASE_URL not set', { status: 500 })
-  return neon(DATABASE_URL)
+/** Field types accepted on create. */
+const TRANSACTION_BODY: BodySchema = {
+  account_id: 'string',
+  amount: ['number', 'string'],
+  date: 'string',
+  description: 'string',
+  type: 'string',
+  status: 'string',
+  scheduled: 'boolean',
+  transfer_group: 'string',
+  category_id: 'string',
+  currency: 'string',
+  original_amount: ['number', 'string'],
 }
 
-function json<T>(data: T, status = 200) {
-  return new Response(JSON.stringify(data), {
-    status,
-    headers: { 'Content-Type': 'application/json' },
-  })
-}
+const LIST_COLUMNS =
+  't.id, t.account_id, t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared, t.status, t.scheduled, t.currency, t.original_amount::text, t.import_batch_id, t.category_id, t.tags, t.seq'
 
-function err(message: string, status: number) {
-  return json({ error: message }, status)
-}
+/** Rows read per query in streaming mode. */
+export const STREAM_BATCH_SIZE = 500
 
-export default async (req: Request, _context: Context) => {
-  const preflight = handlePreflight(req)
-  if (preflight) return preflight
+/**
+ * Reads the filtered list newest first in batches of STREAM_BATCH_SIZE,
+ * each starting after the last row of the one before (keyset paging: the
+ * HTTP driver has no server-side cursors). Only one batch is held at a
+ * time. The sort key is read as text so the next batch resumes at the
+ * exact microsecond rather than a millisecond-rounded Date.
+ */
+async function* transactionBatches(
+  sql: Sql,
+  q: QueryBuilder,
+  expand: Set<TransactionExpansion>,
+  format: ((amount: string) => string) | null,
+  compute: readonly ComputedField[],
+  units: AmountUnits,
+): AsyncGenerator<unknown[]> {
+  let after: { date: string; id: string } | null = null
+  for (;;) {
+    const params = [...q.params]
+    const keyset = after
+      ? `AND (t.date, t.id) < ($${params.push(after.date)}::timestamptz, $${params.push(after.id)}::uuid)`
+      : ''
+    const rows = await sql.query(
+      `SELECT ${LIST_COLUMNS}, t.date::text AS sort_date
+       FROM transactions t
+       ${q.whereSql()} ${keyset}
+       ORDER BY t.date DESC, t.id DESC LIMIT ${STREAM_BATCH_SIZE}`,
+      params,
+    )
+    if (rows.length === 0) return
+    const last = rows[rows.length - 1]
+    after = { date: last.sort_date, id: last.id }
+    for (const row of rows) delete row.sort_date
+    const expanded = await expandTransactions(sql, rows, expand)
+    yield withComputedFields(
+      presentAmounts(
+        format ? withFormattedAmounts(expanded, format) : expanded,
+        units,
+      ),
+      compute,
+    )
+    if (rows.length < STREAM_BATCH_SIZE) return
+  }
+}
 
+export default apiHandler(async (req: Request, context: Context) => {
   const session = await getSessionFromRequest(req)
-  if (!session) return withCors(req, err('Unauthorized', 401))
+  if (!session) return err('Unauthorized', 401)
   const userId = session.user.id
 
   const url = new URL(req.url)
   const accountId = url.searchParams.get('accountId')
-  if (!accountId)
-    return withCors(req, err('accountId query parameter is required', 400))
+  if (!accountId) return err('accountId query parameter is required', 400)
 
   const method = req.method
 
@@ -41,60 +142,260 @@ export default async (req: Request, _context: Context) => {
 
     if (method === 'GET') {
       const [account] =
-        await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
-      if (!account) return withCors(req, err('Not found', 404))
-
-      const rows = await sql`
-        SELECT id, account_id, amount::text, date, description, type
-        FROM transactions
-        WHERE account_id = ${accountId}
-        ORDER BY date DESC
-      `
-      return withCors(req, json(rows))
+        await sql`SELECT id, currency FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+      if (!account) return err('Not found', 404)
+
+      const q = new QueryBuilder()
+      q.where(`t.account_id = ${q.param(accountId)}`)
+      const units = requestAmountUnits(req)
+      const filterError = applyTransactionFilters(q, url, new Date(), units)
+      if (filterError) return err(filterError, 400)
+      const expansion = parseExpand(url)
+      if ('error' in expansion) return err(expansion.error, 400)
+      const computation = parseCompute(url)
+      if ('error' in computation) return err(computation.error, 400)
+      const parsedLast = parseLast(url)
+      if ('error' in parsedLast) return err(parsedLast.error, 400)
+      const { last } = parsedLast
+      const format = wantsFormatted(url)
+        ? moneyFormatter(account.currency, requestLocale(req, url))
+        : null
+
+      // `stream=true` writes a bare array as it is read, for clients too
+      // big for MAX_RESULT_ROWS; no row cap applies.
+      if (url.searchParams.get('stream') === 'true') {
+        if (last) return err('stream cannot be combined with last', 400)
+        return streamJsonArray(
+          req,
+          context,
+          transactionBatches(
+            sql,
+            q,
+            expansion.expand,
+            format,
+            computation.compute,
+            units,
+          ),
+        )
+      }
+
+      // The list is newest first. `last=N` returns the N oldest matches,
+      // still newest first (so the oldest is at the end), by reading them
+      // in ascending order and reversing. Without `last` the list stops at
+      // MAX_RESULT_ROWS; `truncated` in the body, and the
+      // X-Result-Truncated header, tell when it did.
+      const fetched = await sql.query(
+        `SELECT ${LIST_COLUMNS}
+         FROM transactions t
+         ${q.whereSql()}
+         ${last ? `ORDER BY t.date, t.id LIMIT ${last}` : `ORDER BY t.date DESC, t.id DESC LIMIT ${MAX_RESULT_ROWS + 1}`}`,
+        q.params,
+      )
+      const { rows, truncated } = capRows(fetched)
+      if (last) rows.reverse()
+      const expanded = await expandTransactions(sql, rows, expansion.expand)
+      const data = withComputedFields(
+        presentAmounts(
+          format ? withFormattedAmounts(expanded, format) : expanded,
+          units,
+        ),
+        computation.compute,
+      )
+      return markTruncated(json({ data, truncated }), truncated)
     }
 
     if (method === 'POST') {
-      let body: {
+      const read = await readJson<{
         account_id?: string
         amount?: number | string
         date?: string
         description?: string
         type?: string
+        status?: string
+        scheduled?: boolean
+        transfer_group?: string | null
+        category_id?: string | null
+        currency?: string | null
+        original_amount?: number | string | null
+      }>(req, TRANSACTION_BODY)
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+
+      const [account] =
+        await sql`SELECT id, type, default_transaction_type FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+      if (!account) return err('Not found', 404)
+
+      // Every field is checked before responding so all problems are
+      // reported together.
+      const fields: FieldErrors = {}
+      if (body.account_id === undefined) fields.account_id = 'required'
+      else if (body.account_id !== accountId) fields.account_id = 'mismatch'
+      const units = requestAmountUnits(req)
+      const amount = parseAmountIn(body.amount, units)
+      if (amount === null)
+        fields.amount = body.amount == null ? 'required' : 'invalid'
+      // A zero amount is almost always a form left blank; placeholder
+      // entries to be filled in later opt in with allowZero.
+      else if (
+        Number(amount) === 0 &&
+        url.searchParams.get('allowZero') !== 'true'
+      )
+        fields.amount = 'zero'
+      // Quick entry may leave out the date and take the server's clock, but
+      // only when asked: other clients rely on a missing date being an error.
+      const date =
+        body.date === undefined &&
+        url.searchParams.get('dateDefaultsNow') === 'true'
+          ? new Date().toISOString()
+          : typeof body.date === 'string'
+            ? body.date.trim()
+            : ''
+      if (!date) fields.date = 'required'
+      const fitted = fitDescription(
+        typeof body.description === 'string' ? body.description : '',
+        wantsTruncation(url),
+      )
+      if ('error' in fitted) fields.description = 'too_long'
+      const description = 'value' in fitted ? fitted.value : ''
+      // Without an explicit type, fall back to the account's default.
+      const type =
+        body.type === undefined
+          ? account.default_transaction_type
+          : parseTransactionType(body.type)
+      const details: FieldDetails = {}
+      if (!type && body.type === undefined) fields.type = 'required'
+      else if (!type) {
+        fields.type = 'invalid'
+        details.type = invalidChoice(body.type, TRANSACTION_TYPES)
+      } else if (!allowedTransactionTypes(account.type).includes(type)) {
+        // The deployment's TRANSACTION_TYPE_RULES refuse this combination.
+        fields.type = 'not_allowed'
+        details.type = invalidChoice(
+          type,
+          allowedTransactionTypes(account.type),
+        )
+      }
+      const status =
+        body.status == null ? 'posted' : parseTransactionStatus(body.status)
+      if (!status) {
+        fields.status = 'invalid'
+        details.status = invalidChoice(body.status, TRANSACTION_STATUSES)
+      }
+      // Scheduled transactions stay out of the balance until activated.
+      const scheduled = body.scheduled ?? false
+      const transferGroup = body.transfer_group ?? null
+      if (transferGroup !== null && !isUuid(String(transferGroup)))
+        fields.transfer_group = 'invalid'
+      let categoryId = body.category_id ?? null
+      if (categoryId !== null && !isUuid(String(categoryId)))
+        fields.category_id = 'invalid'
+      // A foreign-currency purchase keeps what was charged for reference;
+      // `amount` stays in the account currency and is what the balance uses.
+      const currency =
+        body.currency == null ? null : parseCurrency(body.currency)
+      if (body.currency != null && !currency) fields.currency = 'invalid'
+      const originalAmount =
+        body.original_amount == null
+          ? null
+          : parseAmountIn(body.original_amount, units)
+      if (body.original_amount != null && !originalAmount)
+        fields.original_amount = 'invalid'
+      if (body.currency == null && body.original_amount != null)
+        fields.currency = 'required'
+      if (body.original_amount == null && body.currency != null)
+        fields.original_amount = 'required'
+      if (Object.keys(fields).length) return validationErr(fields, details)
+      if (categoryId) {
+        const [category] =
+          await sql`SELECT id FROM categories WHERE id = ${categoryId} AND user_id = ${userId}`
+        if (!category) return validationErr({ category_id: 'not_found' })
+      } else if (url.searchParams.get('autoCategorize') === 'true') {
+        try {
+          categoryId = await matchCategoryRule(sql, userId, description, type)
+        } catch (e) {
+          if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+            return err('a rule has an invalid regular expression', 400)
+          throw e
+        }
       }
+      if (MAX_TRANSACTIONS_PER_ACCOUNT !== null) {
+        const [{ count }] =
+          await sql`SELECT COUNT(*)::int AS count FROM transactions WHERE account_id = ${accountId}`
+        if (limitReached(count, MAX_TRANSACTIONS_PER_ACCOUNT))
+          return err('transaction limit reached', 403)
+      }
+
       try {
-        body = (await req.json()) as typeof body
-      } catch {
-        return withCors(req, err('Invalid JSON', 400))
+        const id = newId()
+        // Touch last_used_at in the same statement so both apply or neither.
+        const [stored] = await sql`
+          WITH inserted AS (
+            INSERT INTO transactions (id, account_id, amount, date, description, type, transfer_group, category_id, status, scheduled, currency, original_amount)
+            VALUES (${id}, ${accountId}, ${amount}, ${date}::timestamptz, ${description}, ${type}, ${transferGroup}, ${categoryId}, ${status}, ${scheduled}, ${currency}, ${originalAmount})
+            RETURNING id, account_id, amount::text, date, description, type, transfer_group, cleared, status, scheduled, currency, original_amount::text, category_id, tags, seq
+          ), touched AS (
+            UPDATE bank_accounts SET last_used_at = now()
+            WHERE id IN (SELECT account_id FROM inserted)
+          )
+          SELECT * FROM inserted
+        `
+        // Webhook bodies keep the deployment's units whatever the caller
+        // opted into.
+        const [event] = presentAmounts([stored])
+        dispatchWebhooks(sql, context, userId, 'transaction.created', event)
+        const [row] = presentAmounts([stored], units)
+        return created(
+          req,
+          `transaction?accountId=${encodeURIComponent(accountId)}&id=${encodeURIComponent(row.id)}`,
+          row,
+        )
+      } catch (e) {
+        // The account can be deleted between the ownership check and the insert.
+        if (isPgError(e, PG_FOREIGN_KEY_VIOLATION))
+          return err('account not found', 404)
+        if (isPgError(e, PG_OVERDRAWN))
+          return err('transaction would make the balance negative', 409)
+        throw e
       }
-      if (body.account_id !== accountId)
-        return withCors(req, err('account_id must match accountId', 400))
+    }
+
+    if (method === 'DELETE') {
+      // importBatch rolls back one import; otherwise the whole account is
+      // cleared, which needs an explicit confirm.
+      const importBatch = url.searchParams.get('importBatch')
+      if (importBatch !== null && !isUuid(importBatch))
+        return err('importBatch must be a UUID', 400)
+      if (importBatch === null && url.searchParams.get('confirm') !== 'true')
+        return err('confirm=true is required to delete all transactions', 400)
 
       const [account] =
         await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
-      if (!account) return withCors(req, err('Not found', 404))
-
-      const amount = body.amount != null ? Number(body.amount) : NaN
-      if (Number.isNaN(amount))
-        return withCors(req, err('amount is required and must be a number', 400))
-      const date = typeof body.date === 'string' ? body.date.trim() : ''
-      if (!date) return withCors(req, err('date is required', 400))
-      const description =
-        typeof body.description === 'string' ? body.description : ''
-      const type =
-        body.type === 'income' || body.type === 'expense' ? body.type : ''
-      if (!type) return withCors(req, err('type must be income or expense', 400))
-
-      const [row] = await sql`
-        INSERT INTO transactions (id, account_id, amount, date, description, type)
-        VALUES (gen_random_uuid(), ${accountId}, ${amount}, ${date}::timestamptz, ${description}, ${type})
-        RETURNING id, account_id, amount::text, date, description, type
-      `
-      return withCors(req, json(row, 201))
+      if (!account) return err('Not found', 404)
+
+      try {
+        const [{ deleted }] = await sql`
+          WITH removed AS (
+            DELETE FROM transactions
+            WHERE account_id = ${accountId}
+              AND (${importBatch}::uuid IS NULL OR import_batch_id = ${importBatch})
+            RETURNING id
+          )
+          SELECT COUNT(*)::int AS deleted FROM removed
+        `
+        return json({ deleted })
+      } catch (e) {
+        // Removing income can take a protected account below zero.
+        if (isPgError(e, PG_OVERDRAWN))
+          return err(
+            'deleting these transactions would make the balance negative',
+            409,
+          )
+        throw e
+      }
     }
 
-    return withCors(req, err('Method not allowed', 405))
+    return err('Method not allowed', 405)
   } catch (e) {
-    console.error(e)
-    return withCors(req, err('Internal server error', 500))
+    return serverError(req, context, e)
   }
-}
+})
@@ -0,0 +1,522 @@
+import { afterEach, beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import { MAX_RESULT_ROWS } from '../lib/limits.mts'
+import handler, { STREAM_BATCH_SIZE } from './transactions.mts'
+
+const { limits, rules } = vi.hoisted(() => ({
+  limits: { maxTransactions: null as number | null },
+  rules: { policy: new Map<string, readonly string[]>() },
+}))
+
+vi.mock('../lib/limits.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/limits.mts')>()),
+  get MAX_TRANSACTIONS_PER_ACCOUNT() {
+    return limits.maxTransactions
+  },
+}))
+
+vi.mock('../lib/accounts.mts', async (importOriginal) => {
+  const actual = await importOriginal<typeof import('../lib/accounts.mts')>()
+  return {
+    ...actual,
+    allowedTransactionTypes: (accountType: 'bank' | 'cash' | 'card') =>
+      actual.allowedTransactionTypes(
+        accountType,
+        rules.policy as import('../lib/accounts.mts').TransactionTypePolicy,
+      ),
+  }
+})
+
+function request(query: string, init?: RequestInit) {
+  return apiRequest(`transactions?${query}`, init)
+}
+
+describe('GET transactions', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+  })
+
+  it('lists newest first', async () => {
+    sql.query.mockResolvedValueOnce([])
+    await handler(request('accountId=acc-1'), context)
+    expect(sql.query.mock.calls[0][0]).toContain(
+      'ORDER BY t.date DESC, t.id DESC',
+    )
+  })
+
+  it('returns the last N rows with the oldest at the end', async () => {
+    sql.query.mockResolvedValueOnce([{ id: 'oldest' }, { id: 'older' }])
+    const res = await handler(request('accountId=acc-1&last=2'), context)
+    expect(sql.query.mock.calls[0][0]).toContain(
+      'ORDER BY t.date, t.id LIMIT 2',
+    )
+    expect(await res.json()).toEqual({
+      data: [{ id: 'older' }, { id: 'oldest' }],
+      truncated: false,
+    })
+  })
+
+  it('reports in the body when the list was cut off', async () => {
+    const fetched = Array.from({ length: MAX_RESULT_ROWS + 1 }, (_, i) => ({
+      id: `tx-${i}`,
+    }))
+    sql.query.mockResolvedValueOnce(fetched)
+    const res = await handler(request('accountId=acc-1'), context)
+    const body = await res.json()
+    expect(body.truncated).toBe(true)
+    expect(body.data).toHaveLength(MAX_RESULT_ROWS)
+    expect(res.headers.get('X-Result-Truncated')).toBe('true')
+  })
+
+  it('streams every batch as one array with stream=true', async () => {
+    const full = Array.from({ length: STREAM_BATCH_SIZE }, (_, i) => ({
+      id: `tx-${i}`,
+      sort_date: `2025-01-01 00:00:00.${i}+00`,
+    }))
+    sql.query.mockResolvedValueOnce(full)
+    sql.query.mockResolvedValueOnce([
+      { id: 'tx-last', sort_date: '2024-12-31 00:00:00+00' },
+    ])
+    const res = await handler(request('accountId=acc-1&stream=true'), context)
+    const body = await res.json()
+    expect(body).toHaveLength(STREAM_BATCH_SIZE + 1)
+    expect(body[STREAM_BATCH_SIZE]).toEqual({ id: 'tx-last' })
+    expect(sql.query).toHaveBeenCalledTimes(2)
+    // The second batch resumes after the last row of the first.
+    expect(sql.query.mock.calls[1][0]).toContain('(t.date, t.id) <')
+    expect(sql.query.mock.calls[1][1]).toEqual([
+      'acc-1',
+      full[STREAM_BATCH_SIZE - 1].sort_date,
+      full[STREAM_BATCH_SIZE - 1].id,
+    ])
+  })
+
+  it('rejects stream combined with last', async () => {
+    const res = await handler(
+      request('accountId=acc-1&stream=true&last=5'),
+      context,
+    )
+    expect(res.status).toBe(400)
+  })
+
+  it('rejects an invalid last', async () => {
+    const res = await handler(request('accountId=acc-1&last=0'), context)
+    expect(res.status).toBe(400)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+})
+
+describe('DELETE transactions', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('requires confirm=true', async () => {
+    const res = await handler(
+      request('accountId=acc-1', { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('deletes every transaction in the account', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ deleted: 3 }])
+    const res = await handler(
+      request('accountId=acc-1&confirm=true', { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ deleted: 3 })
+  })
+
+  it('rolls back a single import batch without confirm', async () => {
+    const batch = '7c9e6679-7425-40de-944b-e07fc1f90ae7'
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ deleted: 2 }])
+    const res = await handler(
+      request(`accountId=acc-1&importBatch=${batch}`, { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ deleted: 2 })
+    expect(sql.mock.calls[1]).toContain(batch)
+  })
+
+  it('returns 409 when removing income would overdraw the account', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await handler(
+      request('accountId=acc-1&confirm=true', { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(409)
+  })
+
+  it('rejects a malformed importBatch', async () => {
+    const res = await handler(
+      request('accountId=acc-1&importBatch=last', { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await handler(
+      request('accountId=acc-2&confirm=true', { method: 'DELETE' }),
+      context,
+    )
+    expect(res.status).toBe(404)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+})
+
+describe('POST transactions', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  function create(accountId: string, type = 'expense') {
+    return handler(
+      request(`accountId=${accountId}`, {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: accountId,
+          amount: '12.50',
+          date: '2025-02-01T00:00:00Z',
+          type,
+        }),
+      }),
+      context,
+    )
+  }
+
+  it('returns 404 when the account does not exist', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await create('missing')
+    expect(res.status).toBe(404)
+  })
+
+  it('maps a foreign-key violation on insert to 404', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('violates foreign key'), { code: '23503' }),
+    )
+    const res = await create('acc-1')
+    expect(res.status).toBe(404)
+    expect(await res.json()).toEqual({ error: 'account not found' })
+  })
+
+  it('accepts mixed-case types and stores them lowercased', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-1', type: 'expense' }])
+    const res = await create('acc-1', ' Expense ')
+    expect(res.status).toBe(201)
+    expect(sql.mock.calls[1]).toContain('expense')
+  })
+
+  it('points Location at the created transaction', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-9', account_id: 'acc-1' }])
+    const res = await create('acc-1')
+    expect(res.status).toBe(201)
+    expect(res.headers.get('Location')).toBe(
+      'https://example.com/transaction?accountId=acc-1&id=tx-9',
+    )
+  })
+
+  it('returns 409 when the insert would overdraw a protected account', async () => {
+    // The refuse_overdraft trigger rejects the insert for accounts with
+    // allow_negative off.
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await create('acc-1')
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: 'transaction would make the balance negative',
+    })
+  })
+
+  it('stores transactions as posted unless marked pending', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-1', status: 'posted' }])
+    await create('acc-1')
+    expect(sql.mock.calls[1]).toContain('posted')
+  })
+
+  it('rejects an unknown status', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: 'acc-1',
+          amount: '12.50',
+          date: '2025-02-01T00:00:00Z',
+          type: 'expense',
+          status: 'settled',
+        }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: {
+        code: 'VALIDATION',
+        fields: { status: 'invalid' },
+        details: {
+          status: { received: 'settled', allowed: ['pending', 'posted'] },
+        },
+      },
+    })
+  })
+
+  it('keeps a foreign currency and original amount alongside the amount', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-1', currency: 'EUR' }])
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: 'acc-1',
+          amount: '10.80',
+          date: '2025-02-01T00:00:00Z',
+          type: 'expense',
+          currency: 'eur',
+          original_amount: '10.00',
+        }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(201)
+    expect(sql.mock.calls[1]).toContain('EUR')
+    expect(sql.mock.calls[1]).toContain('10.00')
+  })
+
+  it('requires currency and original_amount together', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: 'acc-1',
+          amount: '10.80',
+          date: '2025-02-01T00:00:00Z',
+          type: 'expense',
+          currency: 'EUR',
+        }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: { code: 'VALIDATION', fields: { original_amount: 'required' } },
+    })
+  })
+
+  it('rejects unknown types', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await create('acc-1', 'refund')
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: {
+        code: 'VALIDATION',
+        fields: { type: 'invalid' },
+        details: {
+          type: { received: 'refund', allowed: ['income', 'expense'] },
+        },
+      },
+    })
+  })
+
+  it('reports every invalid field together', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({ account_id: 'acc-2', amount: 'lots' }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: {
+        code: 'VALIDATION',
+        fields: {
+          account_id: 'mismatch',
+          amount: 'invalid',
+          date: 'required',
+          type: 'required',
+        },
+      },
+    })
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  describe('with MAX_TRANSACTIONS_PER_ACCOUNT', () => {
+    beforeEach(() => {
+      limits.maxTransactions = 3
+    })
+    afterEach(() => {
+      limits.maxTransactions = null
+    })
+
+    it('allows creating the last transaction under the cap', async () => {
+      sql.mockResolvedValueOnce([
+        { id: 'acc-1', default_transaction_type: null },
+      ])
+      sql.mockResolvedValueOnce([{ count: 2 }])
+      sql.mockResolvedValueOnce([{ id: 'tx-1', account_id: 'acc-1' }])
+      const res = await create('acc-1')
+      expect(res.status).toBe(201)
+    })
+
+    it('returns 403 once the account is at the cap', async () => {
+      sql.mockResolvedValueOnce([
+        { id: 'acc-1', default_transaction_type: null },
+      ])
+      sql.mockResolvedValueOnce([{ count: 3 }])
+      const res = await create('acc-1')
+      expect(res.status).toBe(403)
+      expect(await res.json()).toEqual({ error: 'transaction limit reached' })
+      expect(sql).toHaveBeenCalledTimes(2)
+    })
+  })
+
+  describe('with TRANSACTION_TYPE_RULES', () => {
+    beforeEach(() => {
+      rules.policy = new Map([['card', ['expense']]])
+    })
+    afterEach(() => {
+      rules.policy = new Map()
+    })
+
+    it('rejects a transaction type the account type does not accept', async () => {
+      sql.mockResolvedValueOnce([
+        { id: 'acc-1', type: 'card', default_transaction_type: null },
+      ])
+      const res = await create('acc-1', 'income')
+      expect(res.status).toBe(400)
+      expect(await res.json()).toEqual({
+        error: {
+          code: 'VALIDATION',
+          fields: { type: 'not_allowed' },
+          details: { type: { received: 'income', allowed: ['expense'] } },
+        },
+      })
+      expect(sql).toHaveBeenCalledTimes(1)
+    })
+
+    it('allows the accepted types and leaves other account types alone', async () => {
+      sql.mockResolvedValueOnce([
+        { id: 'acc-1', type: 'card', default_transaction_type: null },
+      ])
+      sql.mockResolvedValueOnce([{ id: 'tx-1', account_id: 'acc-1' }])
+      expect((await create('acc-1', 'expense')).status).toBe(201)
+      sql.mockResolvedValueOnce([
+        { id: 'acc-2', type: 'bank', default_transaction_type: null },
+      ])
+      sql.mockResolvedValueOnce([{ id: 'tx-2', account_id: 'acc-2' }])
+      expect((await create('acc-2', 'income')).status).toBe(201)
+    })
+  })
+
+  it('defaults an omitted date to now with dateDefaultsNow', async () => {
+    vi.useFakeTimers({ now: new Date('2025-03-04T05:06:07Z') })
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-1', account_id: 'acc-1' }])
+    const body = { account_id: 'acc-1', amount: '3.20', type: 'expense' }
+    const res = await handler(
+      request('accountId=acc-1&dateDefaultsNow=true', {
+        method: 'POST',
+        body: JSON.stringify(body),
+      }),
+      context,
+    )
+    vi.useRealTimers()
+    expect(res.status).toBe(201)
+    expect(sql.mock.calls[1]).toContain('2025-03-04T05:06:07.000Z')
+  })
+
+  it('still requires a date without dateDefaultsNow', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: 'acc-1',
+          amount: '3.20',
+          type: 'expense',
+        }),
+      }),
+      context,
+    )
+    expect(await res.json()).toEqual({
+      error: { code: 'VALIDATION', fields: { date: 'required' } },
+    })
+  })
+
+  function createWithAmount(amount: unknown, query = '') {
+    return handler(
+      request(`accountId=acc-1${query}`, {
+        method: 'POST',
+        body: JSON.stringify({
+          account_id: 'acc-1',
+          amount,
+          date: '2025-02-01T00:00:00Z',
+          type: 'expense',
+        }),
+      }),
+      context,
+    )
+  }
+
+  it('rejects a zero amount by default', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await createWithAmount('0.00')
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: { code: 'VALIDATION', fields: { amount: 'zero' } },
+    })
+  })
+
+  it('reports an omitted amount as required, not zero', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    const res = await createWithAmount(undefined)
+    expect(await res.json()).toEqual({
+      error: { code: 'VALIDATION', fields: { amount: 'required' } },
+    })
+  })
+
+  it('accepts a zero placeholder with allowZero', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1', default_transaction_type: null }])
+    sql.mockResolvedValueOnce([{ id: 'tx-1', account_id: 'acc-1' }])
+    const res = await createWithAmount(0, '&allowZero=true')
+    expect(res.status).toBe(201)
+    expect(sql.mock.calls[1]).toContain('0')
+  })
+
+  it('names a field whose JSON type is wrong', async () => {
+    const res = await handler(
+      request('accountId=acc-1', {
+        method: 'POST',
+        body: JSON.stringify({ account_id: 'acc-1', amount: { value: 5 } }),
+      }),
+      context,
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: "field 'amount' must be a number or a string",
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
@@ -0,0 +1,64 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { RULE_MATCHES } from '../lib/category-rules.mts'
+import { PG_INVALID_REGULAR_EXPRESSION, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+/**
+ * Runs the user's category rules over the account's uncategorized
+ * transactions. Each transaction takes the category of the first rule (by
+ * priority) that matches it, in one statement, and the response counts
+ * how many each rule categorized.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const rules = await sql.query(
+      `WITH matched AS (
+         SELECT t.id, (
+           SELECT r.id FROM category_rules r
+           WHERE r.user_id = $1 AND ${RULE_MATCHES}
+           ORDER BY r.priority, r.id
+           LIMIT 1
+         ) AS rule_id
+         FROM transactions t
+         WHERE t.account_id = $2 AND t.category_id IS NULL
+       ), changed AS (
+         UPDATE transactions t
+         SET category_id = r.category_id, updated_at = now()
+         FROM matched m
+         JOIN category_rules r ON r.id = m.rule_id
+         WHERE t.id = m.id
+         RETURNING m.rule_id
+       )
+       SELECT rule_id AS "ruleId", COUNT(*)::int AS categorized
+       FROM changed
+       GROUP BY rule_id
+       ORDER BY categorized DESC, rule_id`,
+      [userId, accountId],
+    )
+    const categorized = rules.reduce((sum, r) => sum + Number(r.categorized), 0)
+    return json({ categorized, rules })
+  } catch (e) {
+    if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+      return err('a rule has an invalid regular expression', 400)
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,56 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_auto_categorize.mts'
+
+function run(accountId: string) {
+  return handler(
+    apiRequest(`transactions_auto_categorize?accountId=${accountId}`, {
+      method: 'POST',
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_auto_categorize', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+  })
+
+  it('totals the transactions categorized by each rule', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.query.mockResolvedValueOnce([
+      { ruleId: 'rule-1', categorized: 3 },
+      { ruleId: 'rule-2', categorized: 1 },
+    ])
+    const res = await run('acc-1')
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      categorized: 4,
+      rules: [
+        { ruleId: 'rule-1', categorized: 3 },
+        { ruleId: 'rule-2', categorized: 1 },
+      ],
+    })
+    expect(sql.query.mock.calls[0][0]).toContain('ORDER BY r.priority, r.id')
+    expect(sql.query.mock.calls[0][1]).toEqual(['user-1', 'acc-1'])
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await run('acc-2')
+    expect(res.status).toBe(404)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+
+  it('reports a stored rule with an invalid regex as 400', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.query.mockRejectedValueOnce(
+      Object.assign(new Error('invalid regular expression'), {
+        code: '2201B',
+      }),
+    )
+    const res = await run('acc-1')
+    expect(res.status).toBe(400)
+  })
+})
@@ -0,0 +1,67 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { PG_OVERDRAWN, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+/**
+ * Deletes the listed transactions from one account and reports which ids
+ * were removed and which were not found there (another account's, or
+ * already gone), so clients can reconcile a partial success.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{ ids?: unknown }>(req, { ids: 'array' })
+  if ('error' in read) return err(read.error, 400)
+  const ids = read.body.ids
+  if (
+    !Array.isArray(ids) ||
+    ids.length === 0 ||
+    !ids.every((id) => typeof id === 'string' && isUuid(id))
+  )
+    return err('ids must be a non-empty array of UUIDs', 400)
+  const requested = [...new Set(ids.map((id: string) => id.toLowerCase()))]
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    let rows
+    try {
+      rows = await sql`
+        DELETE FROM transactions
+        WHERE account_id = ${accountId} AND id = ANY(${requested}::uuid[])
+        RETURNING id
+      `
+    } catch (e) {
+      // Removing income can take a protected account below zero.
+      if (isPgError(e, PG_OVERDRAWN))
+        return err(
+          'deleting these transactions would make the balance negative',
+          409,
+        )
+      throw e
+    }
+    const removed = new Set(rows.map((row) => String(row.id)))
+    return json({
+      deleted: requested.filter((id) => removed.has(id)),
+      notFound: requested.filter((id) => !removed.has(id)),
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
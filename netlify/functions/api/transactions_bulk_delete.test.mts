@@ -0,0 +1,59 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_bulk_delete.mts'
+
+const A = '00000000-0000-4000-8000-00000000000a'
+const B = '00000000-0000-4000-8000-00000000000b'
+const C = '00000000-0000-4000-8000-00000000000c'
+
+function remove(body: unknown) {
+  return handler(
+    apiRequest('transactions_bulk_delete?accountId=acc-1', {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_bulk_delete', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('splits the requested ids into deleted and not found', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ id: C }, { id: A }])
+    const res = await remove({ ids: [A, B, C] })
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ deleted: [A, C], notFound: [B] })
+  })
+
+  it('reports every id as not found when none are in the account', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([])
+    const res = await remove({ ids: [A, B] })
+    expect(await res.json()).toEqual({ deleted: [], notFound: [A, B] })
+  })
+
+  it('lists a repeated or differently cased id once', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ id: A }])
+    const res = await remove({ ids: [A, A.toUpperCase()] })
+    expect(await res.json()).toEqual({ deleted: [A], notFound: [] })
+    expect(sql.mock.calls[1]).toContainEqual([A])
+  })
+
+  it('rejects ids that are not UUIDs before querying', async () => {
+    const res = await remove({ ids: [A, 'tx-1'] })
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await remove({ ids: [A] })
+    expect(res.status).toBe(404)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+})
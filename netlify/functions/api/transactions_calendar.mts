@@ -0,0 +1,61 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parseMonth } from '../lib/params.mts'
+import { parseTimeZone } from '../lib/reports.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const month = parseMonth(url.searchParams.get('month'))
+  if (!month) return err('month must be in YYYY-MM format', 400)
+  const zone = parseTimeZone(url)
+  if ('error' in zone) return err(zone.error, 400)
+  const { timeZone } = zone
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Days are those of tz's calendar. The month bounds are read as local
+    // midnights there: a plain timestamp drops the Z from month.start.
+    const rows = await sql`
+      SELECT to_char(date AT TIME ZONE ${timeZone}, 'YYYY-MM-DD') AS date,
+        COALESCE(SUM(amount) FILTER (WHERE type = 'income'), 0)::text AS income,
+        COALESCE(SUM(amount) FILTER (WHERE type = 'expense'), 0)::text AS expense,
+        COUNT(*)::int AS count
+      FROM transactions
+      WHERE account_id = ${accountId}
+        AND date >= ${month.start}::timestamp AT TIME ZONE ${timeZone}
+        AND date < ${month.end}::timestamp AT TIME ZONE ${timeZone}
+      GROUP BY 1
+    `
+    const byDate = new Map(rows.map((row) => [String(row.date), row]))
+
+    // Days without activity are filled in so the calendar has every day.
+    const days = Array.from({ length: month.days }, (_, i) => {
+      const date = `${month.month}-${String(i + 1).padStart(2, '0')}`
+      return (
+        byDate.get(date) ?? { date, income: '0', expense: '0', count: 0 }
+      )
+    })
+
+    return json(days)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
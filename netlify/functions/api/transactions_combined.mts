@@ -0,0 +1,98 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { cachedTotal } from '../lib/cache.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePagination } from '../lib/pagination.mts'
+import { isUuid } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { applyTransactionFilters } from '../lib/transaction-filters.mts'
+
+/**
+ * One statement across a chosen set of accounts (say cash and checking),
+ * newest first. Unlike `transactions` it is paginated and names each
+ * row's account; unlike `search` it covers only the listed accounts.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const url = new URL(req.url)
+  const accountIds = [
+    ...new Set(
+      (url.searchParams.get('accountIds') ?? '')
+        .split(',')
+        .map((id) => id.trim())
+        .filter(Boolean),
+    ),
+  ]
+  if (accountIds.length === 0)
+    return err('accountIds query parameter is required', 400)
+  const malformed = accountIds.find((id) => !isUuid(id))
+  if (malformed) return err(`accountIds: "${malformed}" is not a UUID`, 400)
+
+  const paging = parsePagination(url)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  const q = new QueryBuilder()
+  q.where(`a.user_id = ${q.param(userId)}`)
+  q.where(`t.account_id = ANY(${q.param(accountIds)}::uuid[])`)
+  const units = requestAmountUnits(req)
+  const filterError = applyTransactionFilters(q, url, new Date(), units)
+  if (filterError) return err(filterError, 400)
+
+  try {
+    const sql = await getDb()
+
+    // Every listed account must be the user's, so a typo is an error
+    // rather than a statement quietly missing an account.
+    const [owned] = await sql`
+      SELECT COUNT(*)::int AS count FROM bank_accounts
+      WHERE id = ANY(${accountIds}::uuid[]) AND user_id = ${userId}
+    `
+    if (owned.count !== accountIds.length) return err('Not found', 404)
+
+    const from = `
+      FROM transactions t
+      JOIN bank_accounts a ON t.account_id = a.id
+      ${q.whereSql()}
+    `
+    const countTotal = (): Promise<number> =>
+      sql
+        .query(`SELECT COUNT(*)::int AS total ${from}`, q.params)
+        .then(([row]) => row.total as number)
+    const cacheTotal = url.searchParams.get('cacheTotal') === 'true'
+    const [rows, counted] = await Promise.all([
+      sql.query(
+        `SELECT t.id, t.account_id, a.name AS "accountName", t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared, t.status, t.scheduled, t.currency, t.original_amount::text, t.category_id, t.tags, t.seq
+         ${from}
+         ORDER BY t.date DESC, t.id DESC
+         LIMIT ${pageSize} OFFSET ${offset}`,
+        q.params,
+      ),
+      cacheTotal
+        ? cachedTotal(sql, { userId, accountIds }, url, countTotal)
+        : countTotal().then((total) => ({ total, hit: false })),
+    ])
+
+    const res = json({
+      data: presentAmounts(rows, units),
+      total: counted.total,
+      page,
+      pageSize,
+    })
+    if (cacheTotal)
+      res.headers.set('X-Total-Cache', counted.hit ? 'HIT' : 'MISS')
+    return res
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,85 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_combined.mts'
+
+const CASH = '7c9e6679-7425-40de-944b-e07fc1f90ae7'
+const CHECKING = '0f8fad5b-d9cb-469f-a165-70867728950e'
+
+function list(query: string) {
+  return handler(apiRequest(`transactions_combined?${query}`), context)
+}
+
+describe('GET transactions_combined', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+  })
+
+  it('pages through the listed accounts with their names', async () => {
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    sql.query.mockResolvedValueOnce([
+      { id: 'tx-1', account_id: CASH, accountName: 'Cash', amount: '5.0000' },
+    ])
+    sql.query.mockResolvedValueOnce([{ total: 41 }])
+    const res = await list(`accountIds=${CASH},${CHECKING}&pageSize=1`)
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      data: [
+        { id: 'tx-1', account_id: CASH, accountName: 'Cash', amount: '5.0000' },
+      ],
+      total: 41,
+      page: 1,
+      pageSize: 1,
+    })
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain('t.account_id = ANY($2::uuid[])')
+    expect(text).toContain('ORDER BY t.date DESC, t.id DESC')
+    expect(params).toEqual(['user-1', [CASH, CHECKING]])
+  })
+
+  it('reuses the total across pages with cacheTotal until a write', async () => {
+    const query = `accountIds=${CASH},${CHECKING}&cacheTotal=true&q=rent`
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    sql.mockResolvedValueOnce([{ fingerprint: '2:10' }])
+    sql.query.mockResolvedValueOnce([{ id: 'tx-1' }])
+    sql.query.mockResolvedValueOnce([{ total: 41 }])
+    let res = await list(`${query}&page=1`)
+    expect(res.headers.get('X-Total-Cache')).toBe('MISS')
+
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    sql.mockResolvedValueOnce([{ fingerprint: '2:10' }])
+    sql.query.mockResolvedValueOnce([{ id: 'tx-2' }])
+    res = await list(`${query}&page=2`)
+    expect(res.headers.get('X-Total-Cache')).toBe('HIT')
+    expect(await res.json()).toMatchObject({
+      data: [{ id: 'tx-2' }],
+      total: 41,
+    })
+    expect(sql.query).toHaveBeenCalledTimes(3)
+
+    // A new audit entry in either account changes the fingerprint.
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    sql.mockResolvedValueOnce([{ fingerprint: '2:11' }])
+    sql.query.mockResolvedValueOnce([{ id: 'tx-2' }])
+    sql.query.mockResolvedValueOnce([{ total: 42 }])
+    res = await list(`${query}&page=2`)
+    expect(res.headers.get('X-Total-Cache')).toBe('MISS')
+    expect(await res.json()).toMatchObject({ total: 42 })
+  })
+
+  it('rejects a malformed id before querying', async () => {
+    const res = await list(`accountIds=${CASH},cash`)
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'accountIds: "cash" is not a UUID',
+    })
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 when any account belongs to someone else', async () => {
+    sql.mockResolvedValueOnce([{ count: 1 }])
+    const res = await list(`accountIds=${CASH},${CHECKING}`)
+    expect(res.status).toBe(404)
+    expect(sql.query).not.toHaveBeenCalled()
+  })
+})
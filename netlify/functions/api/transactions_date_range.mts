@@ -0,0 +1,35 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    // MIN/MAX over no transactions yields nulls for an empty account.
+    const [row] = await sql`
+      SELECT MIN(t.date) AS earliest, MAX(t.date) AS latest
+      FROM bank_accounts a
+      LEFT JOIN transactions t ON t.account_id = a.id
+      WHERE a.id = ${accountId} AND a.user_id = ${userId}
+      GROUP BY a.id
+    `
+    if (!row) return err('Not found', 404)
+    return json(row)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,48 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { MAX_RESULT_ROWS, capRows, markTruncated } from '../lib/limits.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Likely duplicates share amount, type, calendar day and description.
+    // Members are listed oldest first so the first id is the one to keep.
+    const fetched = await sql`
+      SELECT t.amount::text, t.type, t.date::date::text AS day, t.description,
+        COUNT(*)::int AS count,
+        array_agg(t.id ORDER BY t.created_at, t.id) AS ids
+      FROM transactions t
+      WHERE t.account_id = ${accountId}
+      GROUP BY t.amount, t.type, t.date::date, t.description
+      HAVING COUNT(*) > 1
+      ORDER BY day DESC, t.amount DESC
+      LIMIT ${MAX_RESULT_ROWS + 1}
+    `
+    const { rows, truncated } = capRows(fetched)
+    const groups = presentAmounts(rows, requestAmountUnits(req))
+    return markTruncated(json({ groups, truncated }), truncated)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,148 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import {
+  PG_OVERDRAWN,
+  PG_UNIQUE_VIOLATION,
+  getDb,
+  isPgError,
+} from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+import { MAX_TRANSACTIONS_PER_ACCOUNT, limitReached } from '../lib/limits.mts'
+import { parseOfxTransactions } from '../lib/ofx.mts'
+import {
+  DUPLICATE_MODES,
+  IMPORT_FORMATS,
+  SIGN_MODES,
+  findExistingContentHashes,
+  findExistingExternalIds,
+  insertImportRows,
+  parseCsvTransactions,
+  withContentHashes,
+} from '../lib/transaction-import.mts'
+import type {
+  DuplicateMode,
+  SignMode,
+} from '../lib/transaction-import.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const format = url.searchParams.get('format')?.toLowerCase() ?? 'csv'
+  if (!(IMPORT_FORMATS as readonly string[]).includes(format))
+    return err(`format must be one of ${IMPORT_FORMATS.join(', ')}`, 400)
+  const dryRun = url.searchParams.get('dryRun') === 'true'
+  const onDuplicate = url.searchParams.get('onDuplicate') ?? 'skip'
+  if (!(DUPLICATE_MODES as readonly string[]).includes(onDuplicate))
+    return err(`onDuplicate must be one of ${DUPLICATE_MODES.join(', ')}`, 400)
+  const mode = onDuplicate as DuplicateMode
+  const dedupe = url.searchParams.get('dedupe') === 'true'
+  // OFX carries its own transaction types, so only CSV takes a sign mode.
+  const rawSignMode = url.searchParams.get('signMode')
+  if (rawSignMode !== null && format !== 'csv')
+    return err('signMode only applies to csv imports', 400)
+  const signMode = rawSignMode ?? 'typeColumn'
+  if (!(SIGN_MODES as readonly string[]).includes(signMode))
+    return err(`signMode must be one of ${SIGN_MODES.join(', ')}`, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const text = await req.text()
+    const parsed =
+      format === 'csv'
+        ? parseCsvTransactions(text, signMode as SignMode)
+        : parseOfxTransactions(text)
+    const { errors } = parsed
+    // With dedupe, rows without a bank id are matched on their content, and
+    // ones already imported are dropped before anything else sees them.
+    const hashed = dedupe
+      ? withContentHashes(accountId, parsed.rows)
+      : parsed.rows
+    const seen = await findExistingContentHashes(sql, accountId, hashed)
+    const rows = hashed.filter(
+      (row) => !row.contentHash || !seen.has(row.contentHash),
+    )
+    const contentDuplicates = hashed.length - rows.length
+
+    // Checked up front so an error-mode import writes nothing; a dry run
+    // reports the same conflict the real import would.
+    const existing = await findExistingExternalIds(sql, accountId, rows)
+    if (mode === 'error' && existing.length) {
+      return json(
+        {
+          error: 'transactions already imported',
+          fitids: existing,
+        },
+        409,
+      )
+    }
+
+    // Rows matching an existing transaction are skipped or update it, so
+    // only the rest count toward the cap. The whole batch is refused rather
+    // than importing up to the limit.
+    const added = rows.length - existing.length
+    if (MAX_TRANSACTIONS_PER_ACCOUNT !== null && added > 0) {
+      const [{ count }] =
+        await sql`SELECT COUNT(*)::int AS count FROM transactions WHERE account_id = ${accountId}`
+      if (limitReached(count, MAX_TRANSACTIONS_PER_ACCOUNT, added))
+        return err('transaction limit reached', 403)
+    }
+
+    // A dry run shares parsing and validation with a real import but never
+    // writes, so the preview matches what would be imported.
+    if (dryRun) {
+      const duplicates = existing.length
+      return json({
+        batchId: null,
+        imported: 0,
+        wouldImport: rows.length - duplicates,
+        updated: mode === 'update' ? duplicates : 0,
+        skipped: (mode === 'update' ? 0 : duplicates) + contentDuplicates,
+        errors,
+      })
+    }
+
+    // Everything from this run shares a batch id, which the client can pass
+    // to DELETE transactions?importBatch= to undo the import.
+    const batchId = newId()
+    let written
+    try {
+      written = await insertImportRows(sql, accountId, rows, batchId, mode)
+    } catch (e) {
+      // Another import inserted the same content between the check and the
+      // insert.
+      if (isPgError(e, PG_UNIQUE_VIOLATION))
+        return err('a concurrent import added the same rows; retry', 409)
+      // The whole batch is refused, not only the rows past the limit.
+      if (isPgError(e, PG_OVERDRAWN))
+        return err('import would make the balance negative', 409)
+      throw e
+    }
+    const { imported, updated } = written
+    return json({
+      batchId,
+      imported,
+      wouldImport: imported,
+      updated,
+      skipped: hashed.length - imported - updated,
+      errors,
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,200 @@
+import { afterEach, beforeEach, describe, expect, it, vi } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_import.mts'
+
+const { limits } = vi.hoisted(() => ({
+  limits: { maxTransactions: null as number | null },
+}))
+
+vi.mock('../lib/limits.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/limits.mts')>()),
+  get MAX_TRANSACTIONS_PER_ACCOUNT() {
+    return limits.maxTransactions
+  },
+}))
+
+const OFX = [
+  '<STMTTRN><DTPOSTED>20250201<TRNAMT>-5<FITID>A<NAME>Coffee</STMTTRN>',
+  '<STMTTRN><DTPOSTED>20250202<TRNAMT>-9<FITID>B<NAME>Lunch</STMTTRN>',
+].join('\n')
+
+function importOfx(query: string) {
+  return handler(
+    apiRequest(`transactions_import?accountId=acc-1&format=ofx&${query}`, {
+      method: 'POST',
+      body: OFX,
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_import onDuplicate', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ external_id: 'A' }])
+  })
+
+  it('skips rows already imported by default', async () => {
+    sql.mockResolvedValueOnce([{ inserted: true }])
+    const res = await importOfx('')
+    expect(await res.json()).toMatchObject({
+      imported: 1,
+      updated: 0,
+      skipped: 1,
+    })
+  })
+
+  it('counts rows that overwrote an existing transaction', async () => {
+    sql.mockResolvedValueOnce([{ inserted: false }, { inserted: true }])
+    const res = await importOfx('onDuplicate=update')
+    expect(await res.json()).toMatchObject({
+      imported: 1,
+      updated: 1,
+      skipped: 0,
+    })
+    expect(sql.mock.calls[2]).toContain('update')
+  })
+
+  it('rejects the import without writing when duplicates are an error', async () => {
+    const res = await importOfx('onDuplicate=error')
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: 'transactions already imported',
+      fitids: ['A'],
+    })
+    expect(sql).toHaveBeenCalledTimes(2)
+  })
+
+  it('previews updates on a dry run', async () => {
+    const res = await importOfx('onDuplicate=update&dryRun=true')
+    expect(await res.json()).toMatchObject({
+      batchId: null,
+      wouldImport: 1,
+      updated: 1,
+      skipped: 0,
+    })
+  })
+})
+
+describe('POST transactions_import with MAX_TRANSACTIONS_PER_ACCOUNT', () => {
+  beforeEach(() => {
+    limits.maxTransactions = 3
+    sql.mockReset()
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([])
+  })
+  afterEach(() => {
+    limits.maxTransactions = null
+  })
+
+  it('imports when the new rows fit under the cap', async () => {
+    sql.mockResolvedValueOnce([{ count: 1 }])
+    sql.mockResolvedValueOnce([{ inserted: true }, { inserted: true }])
+    const res = await importOfx('')
+    expect(await res.json()).toMatchObject({ imported: 2 })
+  })
+
+  it('refuses an import that would cross the cap', async () => {
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    const res = await importOfx('')
+    expect(res.status).toBe(403)
+    expect(await res.json()).toEqual({ error: 'transaction limit reached' })
+    expect(sql).toHaveBeenCalledTimes(3)
+  })
+
+  it('reports the same refusal on a dry run', async () => {
+    sql.mockResolvedValueOnce([{ count: 2 }])
+    const res = await importOfx('dryRun=true')
+    expect(res.status).toBe(403)
+  })
+})
+
+it('rejects an unknown onDuplicate', async () => {
+  sql.mockReset()
+  const res = await importOfx('onDuplicate=merge')
+  expect(res.status).toBe(400)
+  expect(sql).not.toHaveBeenCalled()
+})
+
+it('rejects an unknown signMode', async () => {
+  sql.mockReset()
+  const res = await handler(
+    apiRequest('transactions_import?accountId=acc-1&signMode=flip', {
+      method: 'POST',
+      body: 'date,amount\n2025-02-01,-4.50',
+    }),
+    context,
+  )
+  expect(res.status).toBe(400)
+  expect(sql).not.toHaveBeenCalled()
+})
+
+it('rejects a signMode on OFX imports', async () => {
+  sql.mockReset()
+  const res = await importOfx('signMode=negativeIsExpense')
+  expect(res.status).toBe(400)
+  expect(await res.json()).toEqual({
+    error: 'signMode only applies to csv imports',
+  })
+})
+
+describe('POST transactions_import dedupe', () => {
+  const csv = [
+    'date,amount,description,type',
+    '2025-02-01,4.50,Coffee,expense',
+    '2025-02-01,4.50,Coffee,expense',
+  ].join('\n')
+
+  function importCsv(query: string) {
+    return handler(
+      apiRequest(`transactions_import?accountId=acc-1&${query}`, {
+        method: 'POST',
+        body: csv,
+      }),
+      context,
+    )
+  }
+
+  beforeEach(() => {
+    sql.mockReset()
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+  })
+
+  it('skips rows whose content was imported before', async () => {
+    // The first coffee is already in the account; the second is new.
+    sql.mockImplementationOnce(async (_strings, _accountId, hashes) => [
+      { content_hash: hashes[0] },
+    ])
+    sql.mockResolvedValueOnce([{ inserted: true }])
+    const res = await importCsv('dedupe=true')
+    expect(await res.json()).toMatchObject({ imported: 1, skipped: 1 })
+  })
+
+  it('reports a concurrent import of the same rows as 409', async () => {
+    sql.mockResolvedValueOnce([])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('duplicate key'), { code: '23505' }),
+    )
+    const res = await importCsv('dedupe=true')
+    expect(res.status).toBe(409)
+  })
+
+  it('refuses the whole batch when it would overdraw the account', async () => {
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await importCsv('')
+    expect(res.status).toBe(409)
+    expect(await res.json()).toEqual({
+      error: 'import would make the balance negative',
+    })
+  })
+
+  it('stores no hashes without dedupe', async () => {
+    sql.mockResolvedValueOnce([{ inserted: true }, { inserted: true }])
+    const res = await importCsv('')
+    expect(await res.json()).toMatchObject({ imported: 2, skipped: 0 })
+    expect(sql).toHaveBeenCalledTimes(2)
+  })
+})
@@ -0,0 +1,44 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { SIGN_MODES, inspectCsv } from '../lib/transaction-import.mts'
+import type { SignMode } from '../lib/transaction-import.mts'
+
+/**
+ * Inspects a CSV before it is imported: its header columns, the column
+ * suggested for each transaction field, and a parsed preview of the first
+ * rows. Nothing is written; the import itself is `transactions_import`.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  // The preview reads amounts the way the import with this mode would.
+  const signMode = url.searchParams.get('signMode') ?? 'typeColumn'
+  if (!(SIGN_MODES as readonly string[]).includes(signMode))
+    return err(`signMode must be one of ${SIGN_MODES.join(', ')}`, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const inspection = inspectCsv(await req.text(), signMode as SignMode)
+    if ('error' in inspection) return err(inspection.error, 400)
+    return json(inspection)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
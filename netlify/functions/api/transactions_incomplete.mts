@@ -0,0 +1,85 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePagination } from '../lib/pagination.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import {
+  COMPLETENESS_CHECKS,
+  COMPLETENESS_FIELDS,
+} from '../lib/transaction-filters.mts'
+
+/**
+ * An account's transactions that fail any of the COMPLETENESS_FIELDS
+ * checks, newest first, so they can be tidied up. Each row lists the
+ * checks it fails in `missing`. A missing category only counts once the
+ * user has created categories to choose from.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const paging = parsePagination(url)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  try {
+    const sql = await getDb()
+
+    const [account] = await sql`
+      SELECT id, EXISTS (SELECT 1 FROM categories WHERE user_id = ${userId}) AS "hasCategories"
+      FROM bank_accounts
+      WHERE id = ${accountId} AND user_id = ${userId}
+    `
+    if (!account) return err('Not found', 404)
+
+    const checks = COMPLETENESS_FIELDS.filter(
+      (check) => check !== 'category' || account.hasCategories,
+    )
+    if (!checks.length) return json({ data: [], total: 0, page, pageSize })
+
+    // The checks are fixed SQL from COMPLETENESS_CHECKS, safe to inline.
+    const conditions = checks.map((check) => COMPLETENESS_CHECKS[check])
+    const missing = checks
+      .map(
+        (check) =>
+          `CASE WHEN ${COMPLETENESS_CHECKS[check]} THEN '${check}' END`,
+      )
+      .join(', ')
+    const q = new QueryBuilder()
+    q.where(`t.account_id = ${q.param(accountId)}`)
+    q.where(`(${conditions.join(' OR ')})`)
+
+    const [rows, [{ total }]] = await Promise.all([
+      sql.query(
+        `SELECT t.id, t.account_id, t.amount::text, t.date, t.description, t.type, t.status, t.scheduled, t.category_id, t.tags,
+           array_remove(ARRAY[${missing}], NULL) AS missing
+         FROM transactions t
+         ${q.whereSql()}
+         ORDER BY t.date DESC, t.id
+         LIMIT ${pageSize} OFFSET ${offset}`,
+        q.params,
+      ),
+      sql.query(
+        `SELECT COUNT(*)::int AS total FROM transactions t ${q.whereSql()}`,
+        q.params,
+      ),
+    ])
+
+    const data = presentAmounts(rows, requestAmountUnits(req))
+    return json({ data, total, page, pageSize })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,77 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { parsePagination } from '../lib/pagination.mts'
+import { isUuid } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+import { compileTransactionQuery } from '../lib/transaction-query.mts'
+
+/**
+ * Searches an account's transactions with a JSON query posted as the body,
+ * combining conditions with `and` / `or` groups where the query-string
+ * filters can only AND them; see compileTransactionQuery. Newest first,
+ * paginated like the transaction list.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  if (!isUuid(accountId)) return err('accountId must be a UUID', 400)
+  const paging = parsePagination(url)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  const read = await readJson<unknown>(req, {})
+  if ('error' in read) return err(read.error, 400)
+
+  const units = requestAmountUnits(req)
+  const q = new QueryBuilder()
+  q.where(`t.account_id = ${q.param(accountId)}`)
+  const compiled = compileTransactionQuery(q, read.body, units)
+  if ('error' in compiled) return err(compiled.error, 400)
+  q.where(compiled.sql)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const [rows, [{ total }]] = await Promise.all([
+      sql.query(
+        `SELECT t.id, t.account_id, t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared, t.status, t.scheduled, t.currency, t.original_amount::text, t.category_id, t.tags
+         FROM transactions t
+         ${q.whereSql()}
+         ORDER BY t.date DESC, t.id
+         LIMIT ${pageSize} OFFSET ${offset}`,
+        q.params,
+      ),
+      sql.query(
+        `SELECT COUNT(*)::int AS total FROM transactions t ${q.whereSql()}`,
+        q.params,
+      ),
+    ])
+
+    return json({
+      data: presentAmounts(rows, units),
+      total,
+      page,
+      pageSize,
+    })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
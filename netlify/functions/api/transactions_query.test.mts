@@ -0,0 +1,85 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_query.mts'
+
+const accountId = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+
+function search(body: unknown, query = `accountId=${accountId}`) {
+  return handler(
+    apiRequest(`transactions_query?${query}`, {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_query', () => {
+  beforeEach(() => {
+    sql.mockReset()
+    sql.query.mockReset()
+  })
+
+  it('runs the compiled query within the account', async () => {
+    sql.mockResolvedValueOnce([{ id: accountId }])
+    sql.query.mockResolvedValueOnce([{ id: 'tx-1', amount: '120.0000' }])
+    sql.query.mockResolvedValueOnce([{ total: 1 }])
+
+    const res = await search({
+      and: [
+        { field: 'amount', op: 'gte', value: 100 },
+        { field: 'description', op: 'contains', value: 'coffee' },
+      ],
+    })
+
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({
+      data: [{ id: 'tx-1', amount: '120.0000' }],
+      total: 1,
+      page: 1,
+      pageSize: 50,
+    })
+    const [text, params] = sql.query.mock.calls[0]
+    expect(text).toContain(
+      'WHERE t.account_id = $1 AND (t.amount >= $2::numeric AND t.description ILIKE $3)',
+    )
+    expect(text).not.toContain('coffee')
+    expect(params).toEqual([accountId, '100', '%coffee%'])
+  })
+
+  it('rejects unknown fields before touching the database', async () => {
+    const res = await search({ or: [{ field: 'id', op: 'eq', value: 'x' }] })
+    expect(res.status).toBe(400)
+    expect((await res.json()).error).toMatch(/^or\[0\]\.field must be one of/)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('rejects a body that is not a JSON object', async () => {
+    const res = await search([{ field: 'amount', op: 'gt', value: 1 }])
+    expect(res.status).toBe(400)
+  })
+
+  it('rejects a malformed accountId before touching the database', async () => {
+    const res = await search(
+      { field: 'amount', op: 'gt', value: 1 },
+      'accountId=acc-1',
+    )
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({ error: 'accountId must be a UUID' })
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('returns 404 for another user’s account', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await search({ field: 'amount', op: 'gt', value: 1 })
+    expect(res.status).toBe(404)
+  })
+
+  it('only accepts POST', async () => {
+    const res = await handler(
+      apiRequest(`transactions_query?accountId=${accountId}`),
+      context,
+    )
+    expect(res.status).toBe(405)
+  })
+})
@@ -0,0 +1,60 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, serverError } from '../lib/http.mts'
+import { parsePagination } from '../lib/pagination.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  const since = url.searchParams.get('since')?.trim()
+  if (!since) return err('since query parameter is required', 400)
+  if (Number.isNaN(Date.parse(since)))
+    return err('since must be a valid date', 400)
+  const paging = parsePagination(url)
+  if ('error' in paging) return err(paging.error, 400)
+  const { page, pageSize, offset } = paging.pagination
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // A row that was never edited still has created_at = updated_at.
+    const [rows, [{ total }]] = await Promise.all([
+      sql`
+        SELECT id, account_id, amount::text, date, description, type, transfer_group, cleared, status, scheduled, currency, original_amount::text,
+          created_at, updated_at,
+          CASE WHEN created_at = updated_at THEN 'created' ELSE 'updated' END AS "changeType"
+        FROM transactions
+        WHERE account_id = ${accountId} AND updated_at >= ${since}::timestamptz
+        ORDER BY updated_at DESC, id
+        LIMIT ${pageSize} OFFSET ${offset}
+      `,
+      sql`
+        SELECT COUNT(*)::int AS total
+        FROM transactions
+        WHERE account_id = ${accountId} AND updated_at >= ${since}::timestamptz
+      `,
+    ])
+
+    const data = presentAmounts(rows, requestAmountUnits(req))
+    return json({ data, total, page, pageSize })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,78 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { PG_INVALID_REGULAR_EXPRESSION, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{
+    find?: unknown
+    replaceWith?: unknown
+    regex?: unknown
+  }>(req, {
+    find: 'string',
+    replaceWith: 'string',
+    regex: 'boolean',
+  })
+  if ('error' in read) return err(read.error, 400)
+  const body = read.body
+  const find = typeof body.find === 'string' ? body.find : ''
+  if (!find) return err('find is required', 400)
+  if (body.replaceWith !== undefined && typeof body.replaceWith !== 'string')
+    return err('replaceWith must be a string', 400)
+  const replaceWith = body.replaceWith ?? ''
+  const regex = body.regex === true
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    if (!regex) {
+      // strpos/replace are literal, so `*` or `%` in find need no escaping.
+      const [{ updated }] = await sql`
+        WITH changed AS (
+          UPDATE transactions
+          SET description = replace(description, ${find}, ${replaceWith}), updated_at = now()
+          WHERE account_id = ${accountId} AND strpos(description, ${find}) > 0
+          RETURNING id
+        )
+        SELECT COUNT(*)::int AS updated FROM changed
+      `
+      return json({ updated })
+    }
+
+    try {
+      const [{ updated }] = await sql`
+        WITH changed AS (
+          UPDATE transactions
+          SET description = regexp_replace(description, ${find}, ${replaceWith}, 'g'), updated_at = now()
+          WHERE account_id = ${accountId} AND description ~ ${find}
+          RETURNING id
+        )
+        SELECT COUNT(*)::int AS updated FROM changed
+      `
+      return json({ updated })
+    } catch (e) {
+      // Postgres validates the pattern; its POSIX dialect is what matters.
+      if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+        return err('find is not a valid regular expression', 400)
+      throw e
+    }
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,59 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_replace_description.mts'
+
+function replace(body: unknown) {
+  return handler(
+    apiRequest('transactions_replace_description?accountId=acc-1', {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_replace_description', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('requires find', async () => {
+    const res = await replace({ find: '', replaceWith: 'x' })
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('does a literal replace by default', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ updated: 4 }])
+    const res = await replace({ find: 'SQ *', replaceWith: '' })
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ updated: 4 })
+    const [strings] = sql.mock.calls[1] as [TemplateStringsArray]
+    expect(strings.join('?')).toContain('replace(description')
+    expect(strings.join('?')).not.toContain('regexp_replace')
+  })
+
+  it('uses regexp_replace when regex is true', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ updated: 2 }])
+    const res = await replace({ find: '^SQ \\*', replaceWith: '', regex: true })
+    expect(await res.json()).toEqual({ updated: 2 })
+    const [strings] = sql.mock.calls[1] as [TemplateStringsArray]
+    expect(strings.join('?')).toContain('regexp_replace')
+  })
+
+  it('rejects a pattern Postgres cannot compile', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('invalid regular expression'), {
+        code: '2201B',
+      }),
+    )
+    const res = await replace({ find: '(', regex: true })
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'find is not a valid regular expression',
+    })
+  })
+})
@@ -0,0 +1,74 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { applyCategoryRule, parseCategoryRule } from '../lib/category-rules.mts'
+import { PG_INVALID_REGULAR_EXPRESSION, getDb, isPgError } from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+/**
+ * Assigns `categoryId` to the uncategorized transactions a rule matches;
+ * transactions_rule_preview shows the same set beforehand.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{
+    pattern?: unknown
+    matchType?: unknown
+    type?: unknown
+    categoryId?: unknown
+  }>(req, {
+    pattern: 'string',
+    matchType: 'string',
+    type: 'string',
+    categoryId: 'string',
+  })
+  if ('error' in read) return err(read.error, 400)
+  const parsed = parseCategoryRule(read.body)
+  if ('error' in parsed) return err(parsed.error, 400)
+  const categoryId = read.body.categoryId
+  if (typeof categoryId !== 'string' || !isUuid(categoryId))
+    return err('categoryId must be a UUID', 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+    const [category] =
+      await sql`SELECT id FROM categories WHERE id = ${categoryId} AND user_id = ${userId}`
+    if (!category) return err('category not found', 400)
+
+    const q = new QueryBuilder()
+    const categoryParam = q.param(categoryId)
+    applyCategoryRule(q, accountId, parsed.rule)
+    const [{ updated }] = await sql.query(
+      `WITH changed AS (
+         UPDATE transactions t
+         SET category_id = ${categoryParam}, updated_at = now()
+         ${q.whereSql()}
+         RETURNING t.id
+       )
+       SELECT COUNT(*)::int AS updated FROM changed`,
+      q.params,
+    )
+    return json({ updated })
+  } catch (e) {
+    // Postgres validates regex patterns; its POSIX dialect is what matters.
+    if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+      return err('pattern is not a valid regular expression', 400)
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,72 @@
+import type { Context } from '@netlify/functions'
+import { presentAmounts } from '../lib/amount.mts'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { applyCategoryRule, parseCategoryRule } from '../lib/category-rules.mts'
+import { PG_INVALID_REGULAR_EXPRESSION, getDb, isPgError } from '../lib/db.mts'
+import { requestAmountUnits } from '../lib/features.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { QueryBuilder } from '../lib/query.mts'
+
+/** Matches listed in a preview; `count` still covers every match. */
+const SAMPLE_SIZE = 10
+
+/**
+ * Shows which uncategorized transactions a proposed rule would categorize,
+ * without changing anything, so rules can be tuned before applying.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{
+    pattern?: unknown
+    matchType?: unknown
+    type?: unknown
+  }>(req, { pattern: 'string', matchType: 'string', type: 'string' })
+  if ('error' in read) return err(read.error, 400)
+  const parsed = parseCategoryRule(read.body)
+  if ('error' in parsed) return err(parsed.error, 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    const q = new QueryBuilder()
+    applyCategoryRule(q, accountId, parsed.rule)
+    const [sample, [{ count }]] = await Promise.all([
+      sql.query(
+        `SELECT t.id, t.account_id, t.amount::text, t.date, t.description, t.type
+         FROM transactions t
+         ${q.whereSql()}
+         ORDER BY t.date DESC, t.id DESC
+         LIMIT ${SAMPLE_SIZE}`,
+        q.params,
+      ),
+      sql.query(
+        `SELECT COUNT(*)::int AS count FROM transactions t ${q.whereSql()}`,
+        q.params,
+      ),
+    ])
+    return json({
+      count,
+      sample: presentAmounts(sample, requestAmountUnits(req)),
+    })
+  } catch (e) {
+    // Postgres validates regex patterns; its POSIX dialect is what matters.
+    if (isPgError(e, PG_INVALID_REGULAR_EXPRESSION))
+      return err('pattern is not a valid regular expression', 400)
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,74 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { parseDuration } from '../lib/duration.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+/** Largest shift accepted either way: a week covers any timezone slip. */
+const MAX_SHIFT_MS = 7 * 24 * 3_600_000
+
+/**
+ * Moves the dates of an account's transactions, or just `ids`, by a Go
+ * style duration such as `"-5h"`, to repair an import read in the wrong
+ * timezone. Every row moves in one UPDATE, so a failure changes nothing.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{ offset?: string; ids?: unknown[] }>(req, {
+    offset: 'string',
+    ids: 'array',
+  })
+  if ('error' in read) return err(read.error, 400)
+  const body = read.body
+  if (body.offset == null) return err('offset is required', 400)
+  const offsetMs = parseDuration(body.offset)
+  if (offsetMs === null)
+    return err('offset must be a duration such as "-5h" or "1h30m"', 400)
+  if (offsetMs === 0) return err('offset must not be zero', 400)
+  if (Math.abs(offsetMs) > MAX_SHIFT_MS)
+    return err('offset must be at most 168h either way', 400)
+  const ids = body.ids ?? null
+  if (
+    ids !== null &&
+    (ids.length === 0 ||
+      !ids.every((id) => typeof id === 'string' && isUuid(id)))
+  )
+    return err('ids must be a non-empty array of UUIDs', 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Without ids the whole account shifts. Ids from other accounts are
+    // ignored, as in the other bulk endpoints.
+    const [{ updated }] = await sql`
+      WITH shifted AS (
+        UPDATE transactions
+        SET date = date + make_interval(secs => ${offsetMs / 1000}),
+          updated_at = now()
+        WHERE account_id = ${accountId}
+          AND (${ids}::uuid[] IS NULL OR id = ANY(${ids}::uuid[]))
+        RETURNING id
+      )
+      SELECT COUNT(*)::int AS updated FROM shifted
+    `
+    return json({ updated })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -0,0 +1,74 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+import { parseTags } from '../lib/tags.mts'
+
+/**
+ * Adds and removes tags across many transactions in one account. A tag in
+ * both lists ends up removed. Each row's tags stay sorted and distinct.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{
+    ids?: unknown
+    addTags?: unknown
+    removeTags?: unknown
+  }>(req, { ids: 'array', addTags: 'array', removeTags: 'array' })
+  if ('error' in read) return err(read.error, 400)
+  const body = read.body
+  const ids = body.ids
+  if (
+    !Array.isArray(ids) ||
+    ids.length === 0 ||
+    !ids.every((id) => typeof id === 'string' && isUuid(id))
+  )
+    return err('ids must be a non-empty array of UUIDs', 400)
+  const addTags = parseTags(body.addTags ?? [])
+  if (!addTags) return err('addTags must be an array of tags', 400)
+  const removeTags = parseTags(body.removeTags ?? [])
+  if (!removeTags) return err('removeTags must be an array of tags', 400)
+  if (addTags.length === 0 && removeTags.length === 0)
+    return err('addTags or removeTags is required', 400)
+
+  try {
+    const sql = await getDb()
+
+    const [account] =
+      await sql`SELECT id FROM bank_accounts WHERE id = ${accountId} AND user_id = ${userId}`
+    if (!account) return err('Not found', 404)
+
+    // Ids from other accounts are ignored rather than rejected; `updated`
+    // counts only the rows that were in this one.
+    const [{ updated }] = await sql`
+      WITH changed AS (
+        UPDATE transactions t
+        SET tags = ARRAY(
+            SELECT DISTINCT tag
+            FROM unnest(t.tags || ${addTags}::text[]) AS tag
+            WHERE tag <> ALL(${removeTags}::text[])
+            ORDER BY tag
+          ),
+          updated_at = now()
+        WHERE t.account_id = ${accountId} AND t.id = ANY(${ids}::uuid[])
+        RETURNING t.id
+      )
+      SELECT COUNT(*)::int AS updated FROM changed
+    `
+    return json({ updated })
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
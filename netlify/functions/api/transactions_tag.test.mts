@@ -0,0 +1,65 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_tag.mts'
+
+const ID = '0b8a1f3e-5c1d-4e2a-9f00-6d7c8b9a0e1f'
+
+function tag(body: unknown) {
+  return handler(
+    apiRequest('transactions_tag?accountId=acc-1', {
+      method: 'POST',
+      body: JSON.stringify(body),
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_tag', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('adds and removes tags and reports the rows updated', async () => {
+    sql.mockResolvedValueOnce([{ id: 'acc-1' }])
+    sql.mockResolvedValueOnce([{ updated: 1 }])
+    const res = await tag({
+      ids: [ID],
+      addTags: [' trip ', 'trip'],
+      removeTags: ['food'],
+    })
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ updated: 1 })
+    const [strings, ...values] = sql.mock.calls[1] as [
+      TemplateStringsArray,
+      ...unknown[],
+    ]
+    expect(strings.join('?')).toContain('t.account_id = ?')
+    expect(values).toEqual([['trip'], ['food'], 'acc-1', [ID]])
+  })
+
+  it('requires at least one tag change', async () => {
+    const res = await tag({ ids: [ID], addTags: [] })
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+
+  it('rejects ids that are not UUIDs', async () => {
+    const res = await tag({ ids: ['tx-1'], addTags: ['trip'] })
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'ids must be a non-empty array of UUIDs',
+    })
+  })
+
+  it('rejects blank tags', async () => {
+    const res = await tag({ ids: [ID], addTags: [''] })
+    expect(res.status).toBe(400)
+  })
+
+  it('returns 404 for an account the user does not own', async () => {
+    sql.mockResolvedValueOnce([])
+    const res = await tag({ ids: [ID], addTags: ['trip'] })
+    expect(res.status).toBe(404)
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+})
@@ -0,0 +1,79 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import {
+  PG_OVERDRAWN,
+  PG_UNIQUE_VIOLATION,
+  getDb,
+  isPgError,
+} from '../lib/db.mts'
+import { apiHandler, err, json, readJson, serverError } from '../lib/http.mts'
+import { isUuid } from '../lib/params.mts'
+
+/**
+ * Moves every transaction of an account into another one, e.g. before
+ * deleting a redundant account.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const accountId = url.searchParams.get('accountId')
+  if (!accountId) return err('accountId query parameter is required', 400)
+
+  if (req.method !== 'POST') {
+    return err('Method not allowed', 405)
+  }
+
+  const read = await readJson<{ toAccountId?: unknown }>(req, {
+    toAccountId: 'string',
+  })
+  if ('error' in read) return err(read.error, 400)
+  const body = read.body
+  const toAccountId = body.toAccountId
+  if (typeof toAccountId !== 'string' || !isUuid(toAccountId))
+    return err('toAccountId must be a UUID', 400)
+  if (toAccountId === accountId)
+    return err('toAccountId must differ from accountId', 400)
+
+  try {
+    const sql = await getDb()
+
+    const accounts = await sql`
+      SELECT id, currency FROM bank_accounts
+      WHERE id IN (${accountId}, ${toAccountId}) AND user_id = ${userId}
+    `
+    const source = accounts.find((a) => a.id === accountId)
+    const target = accounts.find((a) => a.id === toAccountId)
+    if (!source) return err('Not found', 404)
+    if (!target) return err('destination account not found', 404)
+    if (source.currency !== target.currency)
+      return err('accounts must use the same currency', 400)
+
+    try {
+      const [{ moved }] = await sql`
+        WITH moved AS (
+          UPDATE transactions SET account_id = ${toAccountId}, updated_at = now()
+          WHERE account_id = ${accountId}
+          RETURNING id
+        ), touched AS (
+          UPDATE bank_accounts SET last_used_at = now()
+          WHERE id = ${toAccountId} AND EXISTS (SELECT 1 FROM moved)
+        )
+        SELECT COUNT(*)::int AS moved FROM moved
+      `
+      return json({ moved })
+    } catch (e) {
+      // Both accounts hold a transaction imported with the same bank id.
+      if (isPgError(e, PG_UNIQUE_VIOLATION))
+        return err('both accounts contain the same imported transaction', 409)
+      // Moving income out of the source, or expenses into the destination.
+      if (isPgError(e, PG_OVERDRAWN))
+        return err('transfer would make a balance negative', 409)
+      throw e
+    }
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
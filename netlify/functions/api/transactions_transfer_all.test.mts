@@ -0,0 +1,61 @@
+import { beforeEach, describe, expect, it } from 'vitest'
+import { apiRequest, context, sql } from '../test/handler.mts'
+import handler from './transactions_transfer_all.mts'
+
+const FROM = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+const TO = '5d1c2b3a-6e7f-4a8b-9c0d-e1f2a3b4c5d6'
+
+function transferAll(toAccountId: unknown) {
+  return handler(
+    apiRequest(`transactions_transfer_all?accountId=${FROM}`, {
+      method: 'POST',
+      body: JSON.stringify({ toAccountId }),
+    }),
+    context,
+  )
+}
+
+describe('POST transactions_transfer_all', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('moves every transaction and reports the count', async () => {
+    sql.mockResolvedValueOnce([
+      { id: FROM, currency: 'USD' },
+      { id: TO, currency: 'USD' },
+    ])
+    sql.mockResolvedValueOnce([{ moved: 12 }])
+    const res = await transferAll(TO)
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ moved: 12 })
+  })
+
+  it('returns 404 when the destination is missing or not owned', async () => {
+    sql.mockResolvedValueOnce([{ id: FROM, currency: 'USD' }])
+    const res = await transferAll(TO)
+    expect(res.status).toBe(404)
+    expect(await res.json()).toEqual({
+      error: 'destination account not found',
+    })
+    expect(sql).toHaveBeenCalledTimes(1)
+  })
+
+  it('returns 409 when the move would overdraw either account', async () => {
+    sql.mockResolvedValueOnce([
+      { id: FROM, currency: 'USD' },
+      { id: TO, currency: 'USD' },
+    ])
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('overdrawn'), { code: 'EL001' }),
+    )
+    const res = await transferAll(TO)
+    expect(res.status).toBe(409)
+  })
+
+  it('rejects moving an account into itself', async () => {
+    const res = await transferAll(FROM)
+    expect(res.status).toBe(400)
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
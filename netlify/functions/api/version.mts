@@ -0,0 +1,51 @@
+import type { Context } from '@netlify/functions'
+import { PG_UNDEFINED_TABLE, getDb, isPgError } from '../lib/db.mts'
+import {
+  API_VERSION,
+  apiHandler,
+  err,
+  json,
+  serverError,
+} from '../lib/http.mts'
+
+/**
+ * What this deployment is running: the API version, the deploy and commit
+ * it came from, and the newest migration applied to its database, so ops
+ * can confirm a schema change has landed. Public; none of it is sensitive.
+ */
+export default apiHandler(async (req: Request, context: Context) => {
+  if (req.method !== 'GET') {
+    return err('Method not allowed', 405)
+  }
+
+  try {
+    const sql = await getDb()
+
+    // schema_migrations is created by the first migration run; before that
+    // nothing has been applied.
+    let migration = null
+    try {
+      const [latest] = await sql`
+        SELECT filename AS latest, applied_at AS "appliedAt",
+          (SELECT COUNT(*)::int FROM schema_migrations) AS applied
+        FROM schema_migrations
+        ORDER BY filename DESC
+        LIMIT 1
+      `
+      migration = latest ?? null
+    } catch (e) {
+      if (!isPgError(e, PG_UNDEFINED_TABLE)) throw e
+    }
+
+    const res = json({
+      apiVersion: API_VERSION,
+      deployId: context.deploy?.id ?? null,
+      commit: process.env.COMMIT_REF || null,
+      migration,
+    })
+    res.headers.set('Cache-Control', 'no-store')
+    return res
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
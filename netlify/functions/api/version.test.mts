@@ -0,0 +1,45 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import type { Context } from '@netlify/functions'
+import handler from './version.mts'
+
+const { sql } = vi.hoisted(() => ({ sql: vi.fn() }))
+
+vi.mock('../lib/db.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/db.mts')>()),
+  getDb: async () => sql,
+}))
+
+const context = {
+  ip: '127.0.0.1',
+  deploy: { id: 'deploy-1' },
+} as unknown as Context
+
+function get() {
+  return handler(new Request('https://example.com/version'), context)
+}
+
+describe('GET version', () => {
+  beforeEach(() => {
+    sql.mockReset()
+  })
+
+  it('reports the newest applied migration', async () => {
+    const migration = {
+      latest: '20261017_17_add_opening_balance_to_bank_accounts.sql',
+      appliedAt: '2026-10-17T09:00:00.000Z',
+      applied: 24,
+    }
+    sql.mockResolvedValueOnce([migration])
+    const res = await get()
+    expect(await res.json()).toMatchObject({ deployId: 'deploy-1', migration })
+  })
+
+  it('reports no migration before the first migration run', async () => {
+    sql.mockRejectedValueOnce(
+      Object.assign(new Error('relation does not exist'), { code: '42P01' }),
+    )
+    const res = await get()
+    expect(res.status).toBe(200)
+    expect(await res.json()).toMatchObject({ migration: null })
+  })
+})
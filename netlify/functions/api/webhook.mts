@@ -0,0 +1,80 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import {
+  apiHandler,
+  deleteMissing,
+  err,
+  json,
+  readJson,
+  saved,
+  serverError,
+  validationErr,
+} from '../lib/http.mts'
+import type { FieldErrors } from '../lib/http.mts'
+import { parseWebhookEvents, parseWebhookUrl } from '../lib/webhooks.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const url = new URL(req.url)
+  const id = url.searchParams.get('id')
+  if (!id) return err('id query parameter is required', 400)
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      const [row] = await sql`
+        SELECT id, url, events, created_at FROM webhooks
+        WHERE id = ${id} AND user_id = ${userId}
+      `
+      if (!row) return err('Not found', 404)
+      return json(row)
+    }
+
+    if (method === 'PATCH') {
+      const read = await readJson<{ url?: unknown; events?: unknown }>(req, {
+        url: 'string',
+        events: 'array',
+      })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const fields: FieldErrors = {}
+      const target =
+        body.url === undefined ? undefined : parseWebhookUrl(body.url)
+      if (target === null) fields.url = 'invalid'
+      const events =
+        body.events === undefined ? undefined : parseWebhookEvents(body.events)
+      if (events === null) fields.events = 'invalid'
+      if (Object.keys(fields).length) return validationErr(fields)
+      if (target === undefined && events === undefined)
+        return err('No fields to update', 400)
+
+      const [updated] = await sql`
+        UPDATE webhooks
+        SET url = COALESCE(${target ?? null}, url),
+          events = COALESCE(${events ?? null}::text[], events)
+        WHERE id = ${id} AND user_id = ${userId}
+        RETURNING id, url, events, created_at
+      `
+      if (!updated) return err('Not found', 404)
+      return saved(req, updated)
+    }
+
+    if (method === 'DELETE') {
+      const [deleted] =
+        await sql`DELETE FROM webhooks WHERE id = ${id} AND user_id = ${userId} RETURNING id`
+      if (!deleted) return deleteMissing(url)
+      return new Response(null, { status: 204 })
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
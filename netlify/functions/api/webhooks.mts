@@ -0,0 +1,67 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from '../lib/auth.mts'
+import { getDb } from '../lib/db.mts'
+import {
+  apiHandler,
+  created,
+  err,
+  json,
+  readJson,
+  serverError,
+  validationErr,
+} from '../lib/http.mts'
+import type { FieldErrors } from '../lib/http.mts'
+import { newId } from '../lib/ids.mts'
+import {
+  newWebhookSecret,
+  parseWebhookEvents,
+  parseWebhookUrl,
+} from '../lib/webhooks.mts'
+
+export default apiHandler(async (req: Request, context: Context) => {
+  const session = await getSessionFromRequest(req)
+  if (!session) return err('Unauthorized', 401)
+  const userId = session.user.id
+
+  const method = req.method
+
+  try {
+    const sql = await getDb()
+
+    if (method === 'GET') {
+      // Secrets are only ever returned once, on creation.
+      const rows = await sql`
+        SELECT id, url, events, created_at FROM webhooks
+        WHERE user_id = ${userId}
+        ORDER BY created_at
+      `
+      return json(rows)
+    }
+
+    if (method === 'POST') {
+      const read = await readJson<{ url?: unknown; events?: unknown }>(req, {
+        url: 'string',
+        events: 'array',
+      })
+      if ('error' in read) return err(read.error, 400)
+      const body = read.body
+      const fields: FieldErrors = {}
+      const url = parseWebhookUrl(body.url)
+      if (!url) fields.url = body.url == null ? 'required' : 'invalid'
+      const events = parseWebhookEvents(body.events)
+      if (!events) fields.events = body.events == null ? 'required' : 'invalid'
+      if (!url || !events) return validationErr(fields)
+
+      const [row] = await sql`
+        INSERT INTO webhooks (id, user_id, url, events, secret)
+        VALUES (${newId()}, ${userId}, ${url}, ${events}, ${newWebhookSecret()})
+        RETURNING id, url, events, secret, created_at
+      `
+      return created(req, `webhook?id=${encodeURIComponent(row.id)}`, row)
+    }
+
+    return err('Method not allowed', 405)
+  } catch (e) {
+    return serverError(req, context, e)
+  }
+})
@@ -1,8 +1,10 @@
 import type { Config, Context } from '@netlify/functions'
 import { auth } from '@/lib/auth'
+import { withApiVersion } from './lib/http.mts'
+import { withSecureHeaders } from './lib/secure-headers.mts'
 
 export default async (req: Request, _context: Context) => {
-  return auth.handler(req)
+  return withApiVersion(withSecureHeaders(req, await auth.handler(req)))
 }
 
 export const config: Config = {
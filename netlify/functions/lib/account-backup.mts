@@ -0,0 +1,303 @@
+import { parseAmount } from './amount.mts'
+import { DEFAULT_CURRENCY, parseCurrency } from './currency.mts'
+import type { Sql } from './db.mts'
+import { newId } from './ids.mts'
+import {
+  ACCOUNT_TYPES,
+  parseAccountType,
+  parseTransactionType,
+} from './params.mts'
+import type { TransactionType } from './params.mts'
+import { parseSplits } from './splits.mts'
+
+/** Bumped whenever the document shape changes incompatibly. */
+export const BACKUP_VERSION = 1
+
+export interface BackupSplit {
+  amount: string
+  description: string
+}
+
+export interface BackupTransaction {
+  amount: string
+  date: string
+  description: string
+  type: TransactionType
+  splits: BackupSplit[]
+}
+
+export interface AccountBackup {
+  version: typeof BACKUP_VERSION
+  account: {
+    name: string
+    type: string
+    currency: string
+    default_transaction_type: TransactionType | null
+    opening_balance: string
+  }
+  transactions: BackupTransaction[]
+}
+
+export interface AccountRow {
+  name: string
+  type: string
+  currency: string
+  default_transaction_type: TransactionType | null
+  opening_balance: string | number
+}
+
+export interface TransactionRow {
+  id: string
+  amount: string | number
+  date: string | Date
+  description: string | null
+  type: TransactionType
+}
+
+export interface SplitRow {
+  transaction_id: string
+  amount: string | number
+  description: string | null
+}
+
+/**
+ * Builds a self-contained backup document. Ids are left out so the
+ * document can be re-imported as a new account; transfer links are dropped
+ * because the other leg lives in a different account.
+ */
+export function buildBackup(
+  account: AccountRow,
+  transactions: TransactionRow[],
+  splits: SplitRow[],
+): AccountBackup {
+  const splitsByTransaction = new Map<string, BackupSplit[]>()
+  for (const split of splits) {
+    const list = splitsByTransaction.get(split.transaction_id) ?? []
+    list.push({
+      amount: String(split.amount),
+      description: split.description ?? '',
+    })
+    splitsByTransaction.set(split.transaction_id, list)
+  }
+  return {
+    version: BACKUP_VERSION,
+    account: {
+      name: account.name,
+      type: account.type,
+      currency: account.currency,
+      default_transaction_type: account.default_transaction_type ?? null,
+      opening_balance: String(account.opening_balance),
+    },
+    transactions: transactions.map((t) => ({
+      amount: String(t.amount),
+      date: new Date(t.date).toISOString(),
+      description: t.description ?? '',
+      type: t.type,
+      splits: splitsByTransaction.get(t.id) ?? [],
+    })),
+  }
+}
+
+/**
+ * Loads the transactions and splits of `account` and builds its backup.
+ * The caller has already checked that the account belongs to the user.
+ */
+export async function loadBackup(
+  sql: Sql,
+  account: AccountRow & { id: string },
+): Promise<AccountBackup> {
+  const [transactions, splits] = await Promise.all([
+    sql`
+      SELECT id, amount::text, date, description, type
+      FROM transactions
+      WHERE account_id = ${account.id}
+      ORDER BY date, created_at
+    `,
+    sql`
+      SELECT s.transaction_id, s.amount::text, s.description
+      FROM transaction_splits s
+      JOIN transactions t ON s.transaction_id = t.id
+      WHERE t.account_id = ${account.id}
+      ORDER BY s.amount DESC
+    `,
+  ])
+  return buildBackup(
+    account,
+    transactions as TransactionRow[],
+    splits as SplitRow[],
+  )
+}
+
+/**
+ * Backups of every account `userId` owns, yielded one account at a time.
+ * The HTTP driver has no cursors, so this is what bounds memory for a full
+ * export: only one account's transactions are loaded at once.
+ */
+export async function* userBackups(
+  sql: Sql,
+  userId: string,
+): AsyncGenerator<AccountBackup[]> {
+  const accounts = await sql`
+    SELECT id, name, type, currency, default_transaction_type, opening_balance::text
+    FROM bank_accounts
+    WHERE user_id = ${userId}
+    ORDER BY sort_order, name
+  `
+  for (const account of accounts) {
+    yield [await loadBackup(sql, account as AccountRow & { id: string })]
+  }
+}
+
+/**
+ * Validates an uploaded backup document. The first problem found is
+ * reported, with the transaction index when it is specific to one.
+ */
+export function parseBackup(
+  raw: unknown,
+): { backup: AccountBackup } | { error: string } {
+  if (typeof raw !== 'object' || raw === null) {
+    return { error: 'backup must be a JSON object' }
+  }
+  const doc = raw as Record<string, unknown>
+  if (doc.version !== BACKUP_VERSION) {
+    return {
+      error: `unsupported backup version (expected ${BACKUP_VERSION})`,
+    }
+  }
+
+  const account = (doc.account ?? {}) as Record<string, unknown>
+  const name = typeof account.name === 'string' ? account.name.trim() : ''
+  const type = parseAccountType(account.type)
+  // Documents from before accounts had a currency use the default.
+  const currency =
+    account.currency === undefined
+      ? DEFAULT_CURRENCY
+      : parseCurrency(account.currency)
+  // Likewise, older documents start from zero.
+  const openingBalance =
+    account.opening_balance === undefined
+      ? '0'
+      : parseAmount(account.opening_balance)
+  const rawDefaultType = account.default_transaction_type ?? null
+  const defaultType =
+    rawDefaultType === null ? null : parseTransactionType(rawDefaultType)
+  if (!name) return { error: 'account.name is required' }
+  if (!type) {
+    return {
+      error: `account.type must be one of ${ACCOUNT_TYPES.join(', ')}`,
+    }
+  }
+  if (!currency) {
+    return { error: 'account.currency must be a 3-letter ISO 4217 code' }
+  }
+  if (rawDefaultType !== null && !defaultType) {
+    return {
+      error: 'account.default_transaction_type must be income or expense',
+    }
+  }
+  if (openingBalance === null) {
+    return { error: 'account.opening_balance must be a number' }
+  }
+
+  if (!Array.isArray(doc.transactions)) {
+    return { error: 'transactions must be an array' }
+  }
+  const transactions: BackupTransaction[] = []
+  for (const [i, item] of (
+    doc.transactions as Array<Record<string, unknown>>
+  ).entries()) {
+    const at = `transactions[${i}]`
+    const amount = parseAmount(item?.amount)
+    if (amount === null) return { error: `${at}.amount must be a number` }
+    const date = new Date(String(item.date ?? ''))
+    if (!item.date || Number.isNaN(date.getTime())) {
+      return { error: `${at}.date must be a valid date` }
+    }
+    const type = parseTransactionType(item.type)
+    if (!type) return { error: `${at}.type must be income or expense` }
+    let splits: BackupSplit[] = []
+    const rawSplits = item.splits
+    if (Array.isArray(rawSplits) && rawSplits.length > 0) {
+      // Backups are always written in decimal, whatever AMOUNT_UNITS says.
+      const parsed = parseSplits(amount, rawSplits, 'decimal')
+      if ('error' in parsed) return { error: `${at}: ${parsed.error}` }
+      // Category ids belong to the exporting user, so splits restore
+      // uncategorized like their transactions.
+      splits = parsed.splits.map(({ amount, description }) => ({
+        amount,
+        description,
+      }))
+    }
+    transactions.push({
+      amount,
+      date: date.toISOString(),
+      description:
+        typeof item.description === 'string' ? item.description : '',
+      type,
+      splits,
+    })
+  }
+
+  return {
+    backup: {
+      version: BACKUP_VERSION,
+      account: {
+        name,
+        type,
+        currency,
+        default_transaction_type: defaultType,
+        opening_balance: openingBalance,
+      },
+      transactions,
+    },
+  }
+}
+
+/**
+ * Recreates a backup as a new account owned by `userId`, with fresh ids,
+ * in a single database transaction. Returns the new account row.
+ */
+export async function restoreBackup(
+  sql: Sql,
+  userId: string,
+  backup: AccountBackup,
+): Promise<Record<string, unknown>> {
+  const accountId = newId()
+  const transactionIds = backup.transactions.map(() => newId())
+  const splits = backup.transactions.flatMap((t, i) =>
+    t.splits.map((s) => ({ ...s, transactionId: transactionIds[i] })),
+  )
+  const { name, type, currency, default_transaction_type, opening_balance } =
+    backup.account
+
+  const [[account]] = await sql.transaction([
+    sql`
+      INSERT INTO bank_accounts (id, name, type, currency, user_id, sort_order, default_transaction_type, opening_balance)
+      SELECT ${accountId}, ${name}, ${type}, ${currency}, ${userId}, COALESCE(MAX(sort_order), 0) + 1, ${default_transaction_type}, ${opening_balance}
+      FROM bank_accounts
+      WHERE user_id = ${userId}
+      RETURNING id, name, type, currency, sort_order, default_transaction_type, opening_balance::text
+    `,
+    sql`
+      INSERT INTO transactions (id, account_id, amount, date, description, type)
+      SELECT r.id, ${accountId}, r.amount, r.date, r.description, r.type
+      FROM unnest(
+        ${transactionIds}::uuid[],
+        ${backup.transactions.map((t) => t.amount)}::numeric[],
+        ${backup.transactions.map((t) => t.date)}::timestamptz[],
+        ${backup.transactions.map((t) => t.description)}::text[],
+        ${backup.transactions.map((t) => t.type)}::text[]
+      ) AS r(id, amount, date, description, type)
+    `,
+    sql`
+      INSERT INTO transaction_splits (id, transaction_id, amount, description)
+      SELECT gen_random_uuid(), s.transaction_id, s.amount, s.description
+      FROM unnest(
+        ${splits.map((s) => s.transactionId)}::uuid[],
+        ${splits.map((s) => s.amount)}::numeric[],
+        ${splits.map((s) => s.description)}::text[]
+      ) AS s(transaction_id, amount, description)
+    `,
+  ])
+  return account
+}
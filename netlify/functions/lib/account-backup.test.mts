@@ -0,0 +1,175 @@
+import { describe, expect, it, vi } from 'vitest'
+import {
+  BACKUP_VERSION,
+  buildBackup,
+  parseBackup,
+  restoreBackup,
+  userBackups,
+} from './account-backup.mts'
+import type { Sql } from './db.mts'
+
+const account = {
+  name: 'Checking',
+  type: 'bank',
+  currency: 'EUR',
+  default_transaction_type: 'expense' as const,
+  opening_balance: '250.0000',
+}
+const transactions = [
+  {
+    id: 'tx-1',
+    amount: '120.5000',
+    date: new Date('2025-02-01T00:00:00Z'),
+    description: 'Groceries',
+    type: 'expense' as const,
+  },
+  {
+    id: 'tx-2',
+    amount: '2000.0000',
+    date: '2025-02-03T09:30:00.000Z',
+    description: null,
+    type: 'income' as const,
+  },
+]
+const splits = [
+  { transaction_id: 'tx-1', amount: '100.0000', description: 'Food' },
+  { transaction_id: 'tx-1', amount: '20.5000', description: 'Household' },
+]
+
+describe('buildBackup', () => {
+  it('nests splits under their transactions and drops ids', () => {
+    const backup = buildBackup(account, transactions, splits)
+    expect(backup).toEqual({
+      version: BACKUP_VERSION,
+      account,
+      transactions: [
+        {
+          amount: '120.5000',
+          date: '2025-02-01T00:00:00.000Z',
+          description: 'Groceries',
+          type: 'expense',
+          splits: [
+            { amount: '100.0000', description: 'Food' },
+            { amount: '20.5000', description: 'Household' },
+          ],
+        },
+        {
+          amount: '2000.0000',
+          date: '2025-02-03T09:30:00.000Z',
+          description: '',
+          type: 'income',
+          splits: [],
+        },
+      ],
+    })
+    expect(JSON.stringify(backup)).not.toContain('tx-1')
+  })
+})
+
+describe('parseBackup', () => {
+  it('round-trips an exported document', () => {
+    const backup = buildBackup(account, transactions, splits)
+    const parsed = parseBackup(JSON.parse(JSON.stringify(backup)))
+    expect(parsed).toEqual({ backup })
+  })
+
+  it('defaults the currency and opening balance of older documents', () => {
+    const { currency: _, opening_balance: __, ...legacy } = account
+    const parsed = parseBackup({
+      version: BACKUP_VERSION,
+      account: legacy,
+      transactions: [],
+    })
+    expect(parsed).toMatchObject({
+      backup: { account: { currency: 'USD', opening_balance: '0' } },
+    })
+  })
+
+  it('rejects unknown versions and invalid transactions', () => {
+    expect(parseBackup({ version: 2 })).toHaveProperty('error')
+    expect(
+      parseBackup({
+        version: BACKUP_VERSION,
+        account,
+        transactions: [{ amount: 'x', date: '2025-01-01', type: 'income' }],
+      }),
+    ).toEqual({ error: 'transactions[0].amount must be a number' })
+    expect(
+      parseBackup({
+        version: BACKUP_VERSION,
+        account,
+        transactions: [
+          {
+            amount: '10',
+            date: '2025-01-01',
+            type: 'expense',
+            splits: [{ amount: '4' }],
+          },
+        ],
+      }),
+    ).toHaveProperty('error')
+  })
+})
+
+describe('restoreBackup', () => {
+  it('inserts the account, transactions and splits atomically', async () => {
+    const sql = Object.assign(
+      vi.fn((strings: TemplateStringsArray, ...values: unknown[]) => ({
+        text: strings.join('?'),
+        values,
+      })),
+      {
+        transaction: vi.fn(async () => [[{ id: 'new', name: 'Checking' }]]),
+      },
+    )
+    const backup = buildBackup(account, transactions, splits)
+    const created = await restoreBackup(sql as unknown as Sql, 'user-1', backup)
+
+    expect(created).toEqual({ id: 'new', name: 'Checking' })
+    expect(sql.transaction).toHaveBeenCalledTimes(1)
+    const [queries] = sql.transaction.mock.calls[0] as unknown as [
+      Array<{ values: unknown[] }>,
+    ]
+    expect(queries).toHaveLength(3)
+    const [transactionIds] = queries[1].values as string[][]
+    const [splitParents] = queries[2].values as string[][]
+    expect(transactionIds).toHaveLength(2)
+    expect(transactionIds).not.toContain('tx-1')
+    expect(splitParents).toEqual([transactionIds[0], transactionIds[0]])
+  })
+})
+
+describe('userBackups', () => {
+  it('yields one account at a time in an importable shape', async () => {
+    const accounts = [
+      { id: 'acc-1', ...account },
+      { id: 'acc-2', ...account, name: 'Savings', opening_balance: '0' },
+    ]
+    const sql = vi.fn(
+      async (strings: TemplateStringsArray, ...values: unknown[]) => {
+        const text = strings.join('?')
+        if (text.includes('FROM bank_accounts')) return accounts
+        if (values[0] !== 'acc-1') return []
+        return text.includes('transaction_splits') ? splits : transactions
+      },
+    )
+
+    const exported = []
+    for await (const batch of userBackups(sql as unknown as Sql, 'user-1')) {
+      expect(batch).toHaveLength(1)
+      exported.push(...batch)
+    }
+
+    expect(sql.mock.calls[0][1]).toBe('user-1')
+    expect(exported.map((b) => b.account.name)).toEqual([
+      'Checking',
+      'Savings',
+    ])
+    // What an import would see after the document went over the wire.
+    for (const backup of JSON.parse(JSON.stringify(exported))) {
+      expect(parseBackup(backup)).toEqual({ backup })
+    }
+    expect(exported[0]).toEqual(buildBackup(account, transactions, splits))
+    expect(exported[1].transactions).toEqual([])
+  })
+})
@@ -0,0 +1,243 @@
+import { AMOUNT_UNITS, parseAmountIn, presentAmounts } from './amount.mts'
+import type { AmountUnits } from './amount.mts'
+import { DEFAULT_CURRENCY, parseCurrency } from './currency.mts'
+import { PG_UNIQUE_VIOLATION, isPgError } from './db.mts'
+import { err, invalidChoice } from './http.mts'
+import type { BodySchema, FieldDetails, FieldErrors } from './http.mts'
+import {
+  ACCOUNT_TYPES,
+  TRANSACTION_TYPES,
+  isUuid,
+  parseAccountType,
+  parseTransactionType,
+} from './params.mts'
+import type { AccountType, TransactionType } from './params.mts'
+import type { OrderTerm } from './query.mts'
+
+export interface AccountInput {
+  name: string
+  type: AccountType
+  currency: string
+  defaultTransactionType: TransactionType | null
+  groupId: string | null
+  /** Decimal string, whatever units it was sent in; balances start from it. */
+  openingBalance: string
+  /** When false, transactions that would overdraw the account are refused. */
+  allowNegative: boolean
+}
+
+/**
+ * Whether `e` is the unique index on the user, lowercased name and type
+ * refusing a second account. Names are only unique within a type, so a
+ * "Cash" bank account and a "Cash" cash account can coexist.
+ */
+export function isAccountNameTaken(e: unknown): boolean {
+  return isPgError(e, PG_UNIQUE_VIOLATION)
+}
+
+/** The 409 for a name another account of the same type already has. */
+export function accountNameTaken(name: string, type: string): Response {
+  return err(`an account named "${name}" of type ${type} already exists`, 409)
+}
+
+/**
+ * Transaction types each account type accepts. Account types missing from
+ * the map accept every transaction type.
+ */
+export type TransactionTypePolicy = ReadonlyMap<
+  AccountType,
+  readonly TransactionType[]
+>
+
+/**
+ * Parses TRANSACTION_TYPE_RULES, a JSON object from account type to the
+ * transaction types it accepts, e.g. `{"card":["expense"]}` to refuse
+ * income on cards. Unset allows every combination. Anything malformed
+ * throws, so a typo stops the deployment at startup rather than quietly
+ * enforcing nothing.
+ */
+export function parseTransactionTypePolicy(
+  raw: string | undefined,
+): TransactionTypePolicy {
+  const policy = new Map<AccountType, readonly TransactionType[]>()
+  if (!raw?.trim()) return policy
+  const fail = (): never => {
+    throw new Error(
+      `TRANSACTION_TYPE_RULES must map account types (${ACCOUNT_TYPES.join(', ')}) to non-empty lists of ${TRANSACTION_TYPES.join(', ')}, got ${JSON.stringify(raw)}`,
+    )
+  }
+  let parsed: unknown
+  try {
+    parsed = JSON.parse(raw)
+  } catch {
+    return fail()
+  }
+  if (typeof parsed !== 'object' || parsed === null || Array.isArray(parsed))
+    return fail()
+  for (const [key, value] of Object.entries(parsed)) {
+    const accountType = parseAccountType(key)
+    if (!accountType || !Array.isArray(value) || !value.length) return fail()
+    const types = value.map(parseTransactionType)
+    if (types.includes(null)) return fail()
+    policy.set(accountType, [...new Set(types as TransactionType[])])
+  }
+  return policy
+}
+
+export const TRANSACTION_TYPE_POLICY = parseTransactionTypePolicy(
+  process.env.TRANSACTION_TYPE_RULES,
+)
+
+/** The transaction types an account of `accountType` accepts. */
+export function allowedTransactionTypes(
+  accountType: AccountType,
+  policy: TransactionTypePolicy = TRANSACTION_TYPE_POLICY,
+): readonly TransactionType[] {
+  return policy.get(accountType) ?? TRANSACTION_TYPES
+}
+
+const BY_POSITION: OrderTerm[] = [
+  { column: 'a.sort_order' },
+  { column: 'a.name' },
+]
+
+/**
+ * Orderings accepted by `?sort=`; `manual` follows the user's drag order.
+ * Accounts never used, or not in a group, sort last unless `?nulls=first`.
+ */
+export const ACCOUNT_SORTS = {
+  manual: BY_POSITION,
+  recent: [
+    { column: 'a.last_used_at', desc: true, nullable: true },
+    ...BY_POSITION,
+  ],
+  group: [{ column: 'a.group_id', nullable: true }, ...BY_POSITION],
+  type: [{ column: 'a.type' }, { column: 'a.name' }],
+} satisfies Record<string, OrderTerm[]>
+
+export type AccountSort = keyof typeof ACCOUNT_SORTS
+
+export function isAccountSort(value: string): value is AccountSort {
+  return Object.hasOwn(ACCOUNT_SORTS, value)
+}
+
+/** Reads `DEFAULT_ACCOUNT_SORT`; unset means `manual`. */
+export function parseDefaultAccountSort(raw: string | undefined): AccountSort {
+  const value = raw?.trim()
+  if (!value) return 'manual'
+  if (!isAccountSort(value)) {
+    throw new Error(
+      `DEFAULT_ACCOUNT_SORT must be one of ${Object.keys(ACCOUNT_SORTS).join(', ')}, got ${JSON.stringify(raw)}`,
+    )
+  }
+  return value
+}
+
+/** The account list order when no `?sort=` is given. */
+export const DEFAULT_ACCOUNT_SORT = parseDefaultAccountSort(
+  process.env.DEFAULT_ACCOUNT_SORT,
+)
+
+/** JSON types of the account fields accepted on create and update. */
+export const ACCOUNT_BODY: BodySchema = {
+  name: 'string',
+  type: 'string',
+  currency: 'string',
+  default_transaction_type: 'string',
+  group_id: 'string',
+  opening_balance: ['number', 'string'],
+  allow_negative: 'boolean',
+}
+
+/**
+ * Validates a new account's fields. Shared by account creation and the
+ * validate endpoint so the two cannot drift apart; every invalid field is
+ * reported as `required` (missing) or `invalid` (present but unusable).
+ * Invalid types also get `details` with the value received. The opening
+ * balance is read in `units`, like transaction amounts.
+ */
+export function validateAccountCreate(
+  raw: unknown,
+  units: AmountUnits = AMOUNT_UNITS,
+):
+  | { value: AccountInput }
+  | { fields: FieldErrors; details?: FieldDetails } {
+  const body = (typeof raw === 'object' && raw !== null ? raw : {}) as Record<
+    string,
+    unknown
+  >
+  const fields: FieldErrors = {}
+  const details: FieldDetails = {}
+
+  const name = typeof body.name === 'string' ? body.name.trim() : ''
+  if (!name) fields.name = 'required'
+
+  let type: AccountType | null = null
+  if (typeof body.type !== 'string' || !body.type.trim()) {
+    fields.type = 'required'
+  } else {
+    type = parseAccountType(body.type)
+    if (!type) {
+      fields.type = 'invalid'
+      details.type = invalidChoice(body.type, ACCOUNT_TYPES)
+    }
+  }
+
+  const currency =
+    body.currency === undefined
+      ? DEFAULT_CURRENCY
+      : parseCurrency(body.currency)
+  if (!currency) fields.currency = 'invalid'
+
+  const defaultTransactionType =
+    body.default_transaction_type == null
+      ? null
+      : parseTransactionType(body.default_transaction_type)
+  if (body.default_transaction_type != null && !defaultTransactionType) {
+    fields.default_transaction_type = 'invalid'
+    details.default_transaction_type = invalidChoice(
+      body.default_transaction_type,
+      TRANSACTION_TYPES,
+    )
+  }
+
+  const groupId = body.group_id ?? null
+  if (groupId !== null && (typeof groupId !== 'string' || !isUuid(groupId)))
+    fields.group_id = 'invalid'
+
+  const openingBalance =
+    body.opening_balance == null
+      ? '0'
+      : parseAmountIn(body.opening_balance, units)
+  if (openingBalance === null) fields.opening_balance = 'invalid'
+
+  const allowNegative = body.allow_negative ?? true
+  if (typeof allowNegative !== 'boolean') fields.allow_negative = 'invalid'
+
+  if (
+    Object.keys(fields).length ||
+    !type ||
+    !currency ||
+    openingBalance === null
+  )
+    return Object.keys(details).length ? { fields, details } : { fields }
+  return {
+    value: {
+      name,
+      type,
+      currency,
+      defaultTransactionType,
+      groupId: groupId as string | null,
+      openingBalance,
+      allowNegative: allowNegative as boolean,
+    },
+  }
+}
+
+/** Rewrites accounts' opening_balance in `units` for a response. */
+export function presentAccounts<T extends Record<string, unknown>>(
+  rows: T[],
+  units: AmountUnits,
+): T[] {
+  return presentAmounts(rows, units, ['opening_balance'])
+}
@@ -0,0 +1,150 @@
+import { describe, expect, it } from 'vitest'
+import {
+  allowedTransactionTypes,
+  parseDefaultAccountSort,
+  parseTransactionTypePolicy,
+  presentAccounts,
+  validateAccountCreate,
+} from './accounts.mts'
+
+describe('presentAccounts', () => {
+  it('rewrites the opening balance in minor units', () => {
+    const rows = [{ id: 'acc-1', opening_balance: '-250.0000' }]
+    expect(presentAccounts(rows, 'decimal')).toBe(rows)
+    expect(presentAccounts(rows, 'minor')).toEqual([
+      { id: 'acc-1', opening_balance: -25000 },
+    ])
+  })
+})
+
+describe('validateAccountCreate', () => {
+  it('normalizes a valid account', () => {
+    expect(
+      validateAccountCreate({
+        name: ' Checking ',
+        type: 'Bank',
+        currency: 'eur',
+        default_transaction_type: 'EXPENSE',
+        opening_balance: '-250.00',
+        allow_negative: false,
+      }),
+    ).toEqual({
+      value: {
+        name: 'Checking',
+        type: 'bank',
+        currency: 'EUR',
+        defaultTransactionType: 'expense',
+        groupId: null,
+        openingBalance: '-250.00',
+        allowNegative: false,
+      },
+    })
+  })
+
+  it('defaults the currency and transaction type', () => {
+    expect(validateAccountCreate({ name: 'Wallet', type: 'cash' })).toEqual({
+      value: {
+        name: 'Wallet',
+        type: 'cash',
+        currency: 'USD',
+        defaultTransactionType: null,
+        groupId: null,
+        openingBalance: '0',
+        allowNegative: true,
+      },
+    })
+  })
+
+  it('reads the opening balance in minor units', () => {
+    const minor = validateAccountCreate(
+      { name: 'Card', type: 'card', opening_balance: -25000 },
+      'minor',
+    )
+    expect(minor).toMatchObject({ value: { openingBalance: '-250.00' } })
+    expect(
+      validateAccountCreate(
+        { name: 'Card', type: 'card', opening_balance: 12.5 },
+        'minor',
+      ),
+    ).toEqual({ fields: { opening_balance: 'invalid' } })
+  })
+
+  it('reports every problem', () => {
+    expect(
+      validateAccountCreate({
+        name: '  ',
+        type: 'brokerage',
+        currency: 'euro',
+        default_transaction_type: 'refund',
+        group_id: 'inbox',
+        opening_balance: 'lots',
+      }),
+    ).toEqual({
+      fields: {
+        name: 'required',
+        type: 'invalid',
+        currency: 'invalid',
+        default_transaction_type: 'invalid',
+        group_id: 'invalid',
+        opening_balance: 'invalid',
+      },
+      details: {
+        type: { received: 'brokerage', allowed: ['bank', 'cash', 'card'] },
+        default_transaction_type: {
+          received: 'refund',
+          allowed: ['income', 'expense'],
+        },
+      },
+    })
+    expect(validateAccountCreate({})).toEqual({
+      fields: { name: 'required', type: 'required' },
+    })
+  })
+})
+
+describe('parseTransactionTypePolicy', () => {
+  it('allows everything when unset', () => {
+    const policy = parseTransactionTypePolicy(undefined)
+    expect(allowedTransactionTypes('card', policy)).toEqual([
+      'income',
+      'expense',
+    ])
+  })
+
+  it('limits the listed account types only', () => {
+    const policy = parseTransactionTypePolicy('{"Card":["EXPENSE"]}')
+    expect(allowedTransactionTypes('card', policy)).toEqual(['expense'])
+    expect(allowedTransactionTypes('bank', policy)).toEqual([
+      'income',
+      'expense',
+    ])
+  })
+
+  it('throws on malformed rules instead of enforcing nothing', () => {
+    for (const raw of [
+      'card=expense',
+      '["expense"]',
+      '{"credit":["expense"]}',
+      '{"card":[]}',
+      '{"card":["refund"]}',
+    ]) {
+      expect(() => parseTransactionTypePolicy(raw)).toThrow(
+        /TRANSACTION_TYPE_RULES/,
+      )
+    }
+  })
+})
+
+describe('parseDefaultAccountSort', () => {
+  it('defaults to the manual order', () => {
+    expect(parseDefaultAccountSort(undefined)).toBe('manual')
+    expect(parseDefaultAccountSort(' ')).toBe('manual')
+  })
+
+  it('accepts any ?sort= value and rejects the rest', () => {
+    expect(parseDefaultAccountSort('type')).toBe('type')
+    expect(() => parseDefaultAccountSort('size')).toThrow(
+      /DEFAULT_ACCOUNT_SORT/,
+    )
+  })
+})
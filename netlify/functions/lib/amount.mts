@@ -0,0 +1,123 @@
+const DECIMAL_RE = /^[+-]?(\d+)(\.\d+)?$/
+
+/** NUMERIC(18,4) leaves room for 14 integer digits. */
+const MAX_INTEGER_DIGITS = 14
+
+/**
+ * Parses a money amount sent either as a JSON number or as a decimal string
+ * (e.g. `"25.50"`). Returns a canonical decimal string to hand to Postgres
+ * unchanged, so string input never passes through a float. Returns null for
+ * anything that is not a plain finite decimal.
+ */
+export function parseAmount(value: unknown): string | null {
+  let text: string
+  if (typeof value === 'number') {
+    if (!Number.isFinite(value)) return null
+    text = String(value)
+    // Very large or small numbers stringify in exponent form.
+    if (text.includes('e')) text = value.toFixed(4)
+  } else if (typeof value === 'string') {
+    text = value.trim()
+  } else {
+    return null
+  }
+
+  const match = DECIMAL_RE.exec(text)
+  if (!match) return null
+  if (match[1].replace(/^0+(?=\d)/, '').length > MAX_INTEGER_DIGITS) {
+    return null
+  }
+  return text.startsWith('+') ? text.slice(1) : text
+}
+
+export const AMOUNT_UNITS_OPTIONS = ['decimal', 'minor'] as const
+
+export type AmountUnits = (typeof AMOUNT_UNITS_OPTIONS)[number]
+
+/** Parses AMOUNT_UNITS; anything other than `minor` keeps decimal amounts. */
+export function parseAmountUnits(raw: string | undefined): AmountUnits {
+  return raw?.trim().toLowerCase() === 'minor' ? 'minor' : 'decimal'
+}
+
+/**
+ * How transaction amounts cross the API: `decimal` strings (the default)
+ * or `minor` integer cents. Storage stays NUMERIC either way.
+ */
+export const AMOUNT_UNITS = parseAmountUnits(process.env.AMOUNT_UNITS)
+
+/**
+ * Parses an amount in minor units (an integer number of cents, as a JSON
+ * integer or integer string) into the decimal string that is stored, e.g.
+ * `1250` → `"12.50"`. Returns null for fractions or out-of-range values.
+ */
+export function parseMinorAmount(value: unknown): string | null {
+  let text: string
+  if (typeof value === 'number') {
+    if (!Number.isSafeInteger(value)) return null
+    text = String(value)
+  } else if (typeof value === 'string') {
+    text = value.trim()
+  } else {
+    return null
+  }
+  const match = /^([+-]?)(\d+)$/.exec(text)
+  if (!match) return null
+  const digits = match[2].padStart(3, '0')
+  const sign = match[1] === '-' ? '-' : ''
+  return parseAmount(`${sign}${digits.slice(0, -2)}.${digits.slice(-2)}`)
+}
+
+/** Parses a request amount in the deployment's AMOUNT_UNITS. */
+export function parseAmountIn(
+  value: unknown,
+  units: AmountUnits = AMOUNT_UNITS,
+): string | null {
+  return units === 'minor' ? parseMinorAmount(value) : parseAmount(value)
+}
+
+/**
+ * Converts a stored decimal amount to integer minor units, rounding any
+ * sub-cent digits half away from zero (`"12.3450"` → 1235).
+ */
+export function toMinorAmount(decimal: string): number {
+  const [, sign, whole, fraction = ''] = /^(-?)(\d+)(?:\.(\d+))?$/.exec(
+    decimal,
+  )!
+  let cents = BigInt(whole) * 100n + BigInt(fraction.padEnd(2, '0').slice(0, 2))
+  if (fraction[2] >= '5') cents += 1n
+  return Number(sign ? -cents : cents)
+}
+
+/** Row fields presentAmounts converts unless told otherwise. */
+export const AMOUNT_FIELDS = ['amount', 'original_amount'] as const
+
+/** A stored decimal amount in `units` for a response. */
+export function presentAmount(
+  decimal: string,
+  units: AmountUnits = AMOUNT_UNITS,
+): string | number {
+  return units === 'minor' ? toMinorAmount(decimal) : decimal
+}
+
+/**
+ * Rewrites each row's decimal `amount`, and `original_amount` when set, in
+ * the deployment's units for a response; `fields` names other money
+ * columns instead, such as a balance. Decimal rows are returned
+ * untouched.
+ */
+export function presentAmounts<T extends Record<string, unknown>>(
+  rows: T[],
+  units: AmountUnits = AMOUNT_UNITS,
+  fields: readonly string[] = AMOUNT_FIELDS,
+): T[] {
+  if (units === 'decimal') return rows
+  return rows.map((row) => {
+    const presented: Record<string, unknown> = { ...row }
+    for (const field of fields) {
+      if (row[field] != null) {
+        presented[field] = toMinorAmount(String(row[field]))
+      }
+    }
+    return presented as T
+  })
+}
@@ -0,0 +1,131 @@
+import { describe, expect, it } from 'vitest'
+import {
+  parseAmount,
+  parseAmountIn,
+  parseAmountUnits,
+  parseMinorAmount,
+  presentAmount,
+  presentAmounts,
+  toMinorAmount,
+} from './amount.mts'
+
+describe('parseAmount', () => {
+  it('accepts JSON numbers', () => {
+    expect(parseAmount(25.5)).toBe('25.5')
+    expect(parseAmount(-3)).toBe('-3')
+    expect(parseAmount(0)).toBe('0')
+  })
+
+  it('accepts decimal strings without converting through a float', () => {
+    expect(parseAmount('25.50')).toBe('25.50')
+    expect(parseAmount(' 0.1 ')).toBe('0.1')
+    expect(parseAmount('+7.25')).toBe('7.25')
+    expect(parseAmount('12345678901234.5678')).toBe('12345678901234.5678')
+  })
+
+  it('rejects non-numeric strings', () => {
+    expect(parseAmount('abc')).toBeNull()
+    expect(parseAmount('')).toBeNull()
+    expect(parseAmount('  ')).toBeNull()
+    expect(parseAmount('1e3')).toBeNull()
+    expect(parseAmount('0x10')).toBeNull()
+    expect(parseAmount('1,000.00')).toBeNull()
+    expect(parseAmount('Infinity')).toBeNull()
+    expect(parseAmount('.5')).toBeNull()
+  })
+
+  it('rejects values that do not fit NUMERIC(18,4)', () => {
+    expect(parseAmount('123456789012345')).toBeNull()
+    expect(parseAmount(1e20)).toBeNull()
+  })
+
+  it('rejects non-finite numbers and other types', () => {
+    expect(parseAmount(Number.NaN)).toBeNull()
+    expect(parseAmount(Number.POSITIVE_INFINITY)).toBeNull()
+    expect(parseAmount(null)).toBeNull()
+    expect(parseAmount(undefined)).toBeNull()
+    expect(parseAmount(true)).toBeNull()
+  })
+})
+
+describe('parseAmountUnits', () => {
+  it('only switches to minor units when asked', () => {
+    expect(parseAmountUnits('minor')).toBe('minor')
+    expect(parseAmountUnits(' MINOR ')).toBe('minor')
+    expect(parseAmountUnits(undefined)).toBe('decimal')
+    expect(parseAmountUnits('cents')).toBe('decimal')
+  })
+})
+
+describe('parseMinorAmount', () => {
+  it('converts integer cents to a decimal string', () => {
+    expect(parseMinorAmount(1250)).toBe('12.50')
+    expect(parseMinorAmount(5)).toBe('0.05')
+    expect(parseMinorAmount('-99')).toBe('-0.99')
+    expect(parseMinorAmount(0)).toBe('0.00')
+  })
+
+  it('rejects fractional and non-numeric cents', () => {
+    expect(parseMinorAmount(12.5)).toBeNull()
+    expect(parseMinorAmount('12.50')).toBeNull()
+    expect(parseMinorAmount('abc')).toBeNull()
+    expect(parseMinorAmount(null)).toBeNull()
+  })
+})
+
+describe('parseAmountIn', () => {
+  it('parses in the given units', () => {
+    expect(parseAmountIn('12.50', 'decimal')).toBe('12.50')
+    expect(parseAmountIn(1250, 'minor')).toBe('12.50')
+    expect(parseAmountIn('12.50', 'minor')).toBeNull()
+  })
+})
+
+describe('toMinorAmount', () => {
+  it('converts stored decimals to cents', () => {
+    expect(toMinorAmount('12.5000')).toBe(1250)
+    expect(toMinorAmount('7')).toBe(700)
+    expect(toMinorAmount('-0.0500')).toBe(-5)
+  })
+
+  it('rounds sub-cent digits half away from zero', () => {
+    expect(toMinorAmount('12.3450')).toBe(1235)
+    expect(toMinorAmount('12.3449')).toBe(1234)
+    expect(toMinorAmount('-12.3450')).toBe(-1235)
+  })
+})
+
+describe('presentAmounts', () => {
+  it('only rewrites amounts in minor units', () => {
+    const rows = [{ id: 'tx-1', amount: '12.5000' }]
+    expect(presentAmounts(rows, 'decimal')).toBe(rows)
+    expect(presentAmounts(rows, 'minor')).toEqual([
+      { id: 'tx-1', amount: 1250 },
+    ])
+  })
+
+  it('rewrites a foreign original_amount alongside the amount', () => {
+    const rows = [
+      { id: 'tx-1', amount: '10.8000', original_amount: '10.0000' },
+      { id: 'tx-2', amount: '4.0000', original_amount: null },
+    ]
+    expect(presentAmounts(rows, 'minor')).toEqual([
+      { id: 'tx-1', amount: 1080, original_amount: 1000 },
+      { id: 'tx-2', amount: 400, original_amount: null },
+    ])
+  })
+
+  it('rewrites the named fields instead when given', () => {
+    const rows = [{ amount: '1.0000', balance: '-3.2500', note: 'x' }]
+    expect(presentAmounts(rows, 'minor', ['amount', 'balance'])).toEqual([
+      { amount: 100, balance: -325, note: 'x' },
+    ])
+  })
+})
+
+describe('presentAmount', () => {
+  it('converts one amount only in minor units', () => {
+    expect(presentAmount('12.5000', 'decimal')).toBe('12.5000')
+    expect(presentAmount('12.5000', 'minor')).toBe(1250)
+  })
+})
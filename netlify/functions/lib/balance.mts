@@ -0,0 +1,53 @@
+/**
+ * SQL expression for a transaction's effect on the balance of its account,
+ * over a `transactions t` alias. Income adds, expense subtracts.
+ */
+export const SIGNED_AMOUNT =
+  "CASE WHEN t.type = 'income' THEN t.amount ELSE -t.amount END"
+
+/**
+ * SQL condition for a transaction `t` that counts toward the balance: a
+ * scheduled one only does once scheduled_activate has made it active.
+ */
+export const IS_ACTIVE = 'NOT t.scheduled'
+
+/**
+ * SQL condition for a transaction `t` the bank has posted. Pending ones may
+ * still change or drop off, so balances leave them out unless asked to
+ * include them.
+ */
+export const IS_POSTED = "t.status = 'posted'"
+
+/**
+ * SQL condition for the transactions `t` a balance counts: active and
+ * posted, or with `includePending` active in either status.
+ */
+export function countedInBalance(includePending = false): string {
+  return includePending ? IS_ACTIVE : `${IS_ACTIVE} AND ${IS_POSTED}`
+}
+
+/**
+ * SQL expression summing SIGNED_AMOUNT over the transactions
+ * countedInBalance, zero when there are none.
+ */
+export function balanceSum(includePending = false): string {
+  return `COALESCE(SUM(${SIGNED_AMOUNT}) FILTER (WHERE ${countedInBalance(includePending)}), 0)`
+}
+
+/**
+ * SQL expression for the balance of a `bank_accounts a` row grouped with
+ * its transactions: the opening balance plus balanceSum.
+ */
+export function accountBalance(includePending = false): string {
+  return `a.opening_balance + ${balanceSum(includePending)}`
+}
+
+/** balanceSum over posted transactions, what every balance shows by default. */
+export const BALANCE_SUM = balanceSum()
+
+/**
+ * accountBalance over posted transactions. The refuse_overdraft trigger in
+ * db/migrations/20261018_01_refuse_overdrafts.sql repeats this rule in
+ * plpgsql; keep the two in step.
+ */
+export const ACCOUNT_BALANCE = accountBalance()
@@ -0,0 +1,20 @@
+import { describe, expect, it } from 'vitest'
+import {
+  ACCOUNT_BALANCE,
+  BALANCE_SUM,
+  accountBalance,
+  countedInBalance,
+} from './balance.mts'
+
+describe('countedInBalance', () => {
+  it('counts only active, posted transactions by default', () => {
+    expect(countedInBalance()).toBe("NOT t.scheduled AND t.status = 'posted'")
+    expect(BALANCE_SUM).toContain("t.status = 'posted'")
+    expect(ACCOUNT_BALANCE).toContain("t.status = 'posted'")
+  })
+
+  it('adds pending transactions only when asked', () => {
+    expect(countedInBalance(true)).toBe('NOT t.scheduled')
+    expect(accountBalance(true)).not.toContain('t.status')
+  })
+})
@@ -0,0 +1,190 @@
+import type { AmountUnits } from './amount.mts'
+import type { Sql } from './db.mts'
+import { json } from './http.mts'
+
+interface Entry<T> {
+  expiresAt: number
+  value: Promise<T>
+}
+
+/**
+ * A small in-memory TTL cache. Loads for the same key share one in-flight
+ * promise, so concurrent requests never compute a value twice, and a failed
+ * load is evicted instead of being cached.
+ */
+export class TtlCache<T> {
+  private readonly entries = new Map<string, Entry<T>>()
+  hits = 0
+  misses = 0
+
+  constructor(
+    readonly ttlMs: number,
+    readonly maxEntries = 500,
+    private readonly now: () => number = Date.now,
+  ) {}
+
+  async getOrLoad(
+    key: string,
+    load: () => Promise<T>,
+  ): Promise<{ value: T; hit: boolean }> {
+    const entry = this.entries.get(key)
+    if (entry && entry.expiresAt > this.now()) {
+      this.hits++
+      return { value: await entry.value, hit: true }
+    }
+
+    this.misses++
+    const value = load()
+    if (this.ttlMs > 0) {
+      // Map iteration order is insertion order, so this evicts the oldest.
+      if (this.entries.size >= this.maxEntries) {
+        const [oldest] = this.entries.keys()
+        this.entries.delete(oldest)
+      }
+      this.entries.set(key, { expiresAt: this.now() + this.ttlMs, value })
+      value.catch(() => {
+        if (this.entries.get(key)?.value === value) this.entries.delete(key)
+      })
+    }
+    return { value: await value, hit: false }
+  }
+
+  clear(): void {
+    this.entries.clear()
+  }
+}
+
+/**
+ * Parses a cache lifetime such as REPORT_CACHE_TTL_MS. Unset or invalid
+ * values fall back to `fallback`, one minute unless given; `0` disables
+ * caching.
+ */
+export function parseCacheTtl(
+  raw: string | undefined,
+  fallback = 60_000,
+): number {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value < 0) return fallback
+  return value
+}
+
+export const REPORT_CACHE_TTL_MS = parseCacheTtl(
+  process.env.REPORT_CACHE_TTL_MS,
+)
+
+const reportCache = new TtlCache<unknown>(REPORT_CACHE_TTL_MS)
+
+/** Seconds a client may keep a report whose period has ended. */
+export const CLOSED_REPORT_MAX_AGE_S = 86_400
+
+/**
+ * HTTP caching headers for a report covering data up to `periodEnd`. A
+ * period that ended before `now` gets no new transactions by date, so
+ * its report may be kept for a day; editing an old transaction shows up
+ * once that lapses. Open-ended and current periods are revalidated every
+ * time. Reports belong to one user, so shared caches must not store them.
+ */
+export function reportCacheHeaders(
+  periodEnd: string | undefined,
+  now: Date = new Date(),
+): Record<string, string> {
+  const end = periodEnd ? Date.parse(periodEnd) : NaN
+  if (Number.isNaN(end) || end > now.getTime()) {
+    return { 'Cache-Control': 'private, no-cache' }
+  }
+  const expires = new Date(now.getTime() + CLOSED_REPORT_MAX_AGE_S * 1000)
+  return {
+    'Cache-Control': `private, max-age=${CLOSED_REPORT_MAX_AGE_S}`,
+    Expires: expires.toUTCString(),
+  }
+}
+
+/**
+ * Serves a report from the cache. Each function instance has its own
+ * memory, so entries are keyed on a cheap fingerprint of the account's
+ * transactions (row count and latest change) and of the account itself
+ * (its updated_at, for opening balance edits): any such write changes the
+ * key, which invalidates the report everywhere without cross-instance
+ * messaging. Reports that present amounts pass the request's `units`,
+ * which are part of the key too. Responses carry `X-Cache: HIT` or
+ * `MISS`, and the caching headers of reportCacheHeaders for `periodEnd`,
+ * the end of the range the report reads.
+ */
+export async function cachedReport(
+  sql: Sql,
+  accountId: string,
+  url: URL,
+  periodEnd: string | undefined,
+  load: () => Promise<unknown>,
+  units?: AmountUnits,
+): Promise<Response> {
+  const [{ fingerprint }] = await sql`
+    SELECT (
+      SELECT COUNT(*) || ':' || COALESCE(MAX(updated_at)::text, '')
+      FROM transactions
+      WHERE account_id = ${accountId}
+    ) || ':' || COALESCE((
+      SELECT updated_at::text FROM bank_accounts WHERE id = ${accountId}
+    ), '') AS fingerprint
+  `
+  const params = new URLSearchParams(url.searchParams)
+  params.sort()
+  const key = `${url.pathname}?${params}#${units ?? ''}#${fingerprint}`
+  const { value, hit } = await reportCache.getOrLoad(key, load)
+  const res = json(value)
+  res.headers.set('X-Cache', hit ? 'HIT' : 'MISS')
+  for (const [name, value] of Object.entries(reportCacheHeaders(periodEnd))) {
+    res.headers.set(name, value)
+  }
+  return res
+}
+
+export const COUNT_CACHE_TTL_MS = parseCacheTtl(
+  process.env.COUNT_CACHE_TTL_MS,
+  5_000,
+)
+
+const countCache = new TtlCache<number>(COUNT_CACHE_TTL_MS)
+
+/** Whose transactions a total counts: all the user's, or `accountIds`. */
+export interface CountScope {
+  userId: string
+  accountIds?: string[]
+}
+
+/**
+ * The total for a paginated list, reused across pages for a few seconds
+ * so "page 5 of 50" does not count every match again. The key is the
+ * query without `page` and `pageSize`, plus the newest audit entry in
+ * scope and the number of accounts: the audit trigger logs every create,
+ * update and delete, so any write to those accounts, on any instance,
+ * changes the key. That lookup reads one index entry per account,
+ * however many transactions match. `hit` tells whether the count was
+ * reused.
+ */
+export async function cachedTotal(
+  sql: Sql,
+  scope: CountScope,
+  url: URL,
+  count: () => Promise<number>,
+): Promise<{ total: number; hit: boolean }> {
+  const accountIds = scope.accountIds ?? null
+  const [{ fingerprint }] = await sql`
+    SELECT COUNT(*) || ':' || COALESCE(MAX(latest.id)::text, '') AS fingerprint
+    FROM bank_accounts a
+    LEFT JOIN LATERAL (
+      SELECT au.id FROM transaction_audit au
+      WHERE au.account_id = a.id
+      ORDER BY au.id DESC LIMIT 1
+    ) latest ON true
+    WHERE a.user_id = ${scope.userId}
+      AND (${accountIds}::uuid[] IS NULL OR a.id = ANY(${accountIds}::uuid[]))
+  `
+  const params = new URLSearchParams(url.searchParams)
+  params.delete('page')
+  params.delete('pageSize')
+  params.sort()
+  const key = `${url.pathname}?${params}#${scope.userId}#${fingerprint}`
+  const { value, hit } = await countCache.getOrLoad(key, count)
+  return { total: value, hit }
+}
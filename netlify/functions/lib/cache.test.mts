@@ -0,0 +1,92 @@
+import { describe, expect, it, vi } from 'vitest'
+import {
+  CLOSED_REPORT_MAX_AGE_S,
+  TtlCache,
+  parseCacheTtl,
+  reportCacheHeaders,
+} from './cache.mts'
+
+describe('TtlCache', () => {
+  it('serves repeated keys from the cache until they expire', async () => {
+    let now = 0
+    const cache = new TtlCache<number>(1000, 10, () => now)
+    const load = vi.fn(async () => 42)
+
+    expect(await cache.getOrLoad('a', load)).toEqual({ value: 42, hit: false })
+    expect(await cache.getOrLoad('a', load)).toEqual({ value: 42, hit: true })
+    now = 1000
+    expect(await cache.getOrLoad('a', load)).toEqual({ value: 42, hit: false })
+    expect(load).toHaveBeenCalledTimes(2)
+    expect([cache.hits, cache.misses]).toEqual([1, 2])
+  })
+
+  it('shares one load between concurrent callers', async () => {
+    const cache = new TtlCache<string>(1000)
+    let resolve!: (value: string) => void
+    const load = vi.fn(() => new Promise<string>((r) => (resolve = r)))
+
+    const first = cache.getOrLoad('k', load)
+    const second = cache.getOrLoad('k', load)
+    resolve('report')
+    expect(await first).toEqual({ value: 'report', hit: false })
+    expect(await second).toEqual({ value: 'report', hit: true })
+    expect(load).toHaveBeenCalledTimes(1)
+  })
+
+  it('does not cache failed loads', async () => {
+    const cache = new TtlCache<number>(1000)
+    await expect(
+      cache.getOrLoad('k', async () => {
+        throw new Error('boom')
+      }),
+    ).rejects.toThrow('boom')
+    expect(await cache.getOrLoad('k', async () => 1)).toEqual({
+      value: 1,
+      hit: false,
+    })
+  })
+
+  it('evicts the oldest entry when full', async () => {
+    const cache = new TtlCache<string>(1000, 2)
+    await cache.getOrLoad('a', async () => 'a')
+    await cache.getOrLoad('b', async () => 'b')
+    await cache.getOrLoad('c', async () => 'c')
+    expect((await cache.getOrLoad('a', async () => 'a2')).hit).toBe(false)
+    expect((await cache.getOrLoad('c', async () => 'c2')).hit).toBe(true)
+  })
+
+  it('never stores entries when the TTL is 0', async () => {
+    const cache = new TtlCache<number>(0)
+    await cache.getOrLoad('k', async () => 1)
+    expect((await cache.getOrLoad('k', async () => 2)).value).toBe(2)
+  })
+})
+
+describe('parseCacheTtl', () => {
+  it('defaults to a minute and accepts 0 to disable', () => {
+    expect(parseCacheTtl(undefined)).toBe(60_000)
+    expect(parseCacheTtl('later')).toBe(60_000)
+    expect(parseCacheTtl('5000')).toBe(5000)
+    expect(parseCacheTtl('0')).toBe(0)
+    expect(parseCacheTtl(undefined, 5_000)).toBe(5_000)
+  })
+})
+
+describe('reportCacheHeaders', () => {
+  const now = new Date('2025-03-15T12:00:00Z')
+
+  it('lets clients keep reports of periods that have ended', () => {
+    expect(reportCacheHeaders('2025-03-01T00:00:00.000Z', now)).toEqual({
+      'Cache-Control': `private, max-age=${CLOSED_REPORT_MAX_AGE_S}`,
+      Expires: 'Sun, 16 Mar 2025 12:00:00 GMT',
+    })
+  })
+
+  it('revalidates open and current periods', () => {
+    const revalidate = { 'Cache-Control': 'private, no-cache' }
+    expect(reportCacheHeaders(undefined, now)).toEqual(revalidate)
+    expect(reportCacheHeaders('2025-04-01T00:00:00.000Z', now)).toEqual(
+      revalidate,
+    )
+  })
+})
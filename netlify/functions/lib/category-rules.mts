@@ -0,0 +1,92 @@
+import type { Sql } from './db.mts'
+import { parseTransactionType } from './params.mts'
+import type { TransactionType } from './params.mts'
+import type { QueryBuilder } from './query.mts'
+
+export const MATCH_TYPES = ['literal', 'regex'] as const
+
+export type MatchType = (typeof MATCH_TYPES)[number]
+
+/**
+ * An auto-categorization rule: transactions whose description contains
+ * `pattern` (ignoring case), or matches it as a case-insensitive POSIX
+ * regex, optionally only of one `type`.
+ */
+export interface CategoryRule {
+  pattern: string
+  matchType: MatchType
+  type: TransactionType | null
+}
+
+/** Validates a rule from a request body. */
+export function parseCategoryRule(body: {
+  pattern?: unknown
+  matchType?: unknown
+  type?: unknown
+}): { rule: CategoryRule } | { error: string } {
+  const pattern = typeof body.pattern === 'string' ? body.pattern.trim() : ''
+  if (!pattern) return { error: 'pattern is required' }
+  const matchType = body.matchType ?? 'literal'
+  if (!(MATCH_TYPES as readonly unknown[]).includes(matchType))
+    return { error: `matchType must be one of ${MATCH_TYPES.join(', ')}` }
+  const type = body.type == null ? null : parseTransactionType(body.type)
+  if (body.type != null && !type)
+    return { error: 'type must be income or expense' }
+  return { rule: { pattern, matchType: matchType as MatchType, type } }
+}
+
+/**
+ * SQL testing whether a transaction (alias `t`) matches a rule whose
+ * pattern, match type and type are the given SQL expressions. The one
+ * definition of a match, shared by previews, bulk runs and stored rules.
+ */
+function matchSql(pattern: string, matchType: string, type: string): string {
+  return `(CASE WHEN ${matchType} = 'regex' THEN t.description ~* ${pattern}
+      ELSE strpos(lower(t.description), lower(${pattern})) > 0 END)
+    AND (${type} IS NULL OR t.type = ${type})`
+}
+
+/** Whether transaction `t` matches stored rule `r` (a category_rules row). */
+export const RULE_MATCHES = matchSql('r.pattern', 'r.match_type', 'r.type')
+
+/**
+ * Restricts a query over `transactions t` to the account's uncategorized
+ * transactions that the rule matches. Preview and apply both go through
+ * here, so a preview always shows exactly what applying would change.
+ */
+export function applyCategoryRule(
+  q: QueryBuilder,
+  accountId: string,
+  rule: CategoryRule,
+): void {
+  q.where(`t.account_id = ${q.param(accountId)}`)
+  q.where('t.category_id IS NULL')
+  q.where(
+    matchSql(
+      `${q.param(rule.pattern)}::text`,
+      `${q.param(rule.matchType)}::text`,
+      `${q.param(rule.type)}::text`,
+    ),
+  )
+}
+
+/**
+ * The category of the user's first rule (by priority) matching a
+ * transaction not yet stored, or null when none matches.
+ */
+export async function matchCategoryRule(
+  sql: Sql,
+  userId: string,
+  description: string,
+  type: string,
+): Promise<string | null> {
+  const [rule] = await sql.query(
+    `SELECT r.category_id
+     FROM (SELECT $2::text AS description, $3::text AS type) t
+     JOIN category_rules r ON r.user_id = $1 AND ${RULE_MATCHES}
+     ORDER BY r.priority, r.id
+     LIMIT 1`,
+    [userId, description, type],
+  )
+  return rule ? rule.category_id : null
+}
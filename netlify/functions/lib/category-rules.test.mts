@@ -0,0 +1,40 @@
+import { describe, expect, it } from 'vitest'
+import { applyCategoryRule, parseCategoryRule } from './category-rules.mts'
+import { QueryBuilder } from './query.mts'
+
+describe('parseCategoryRule', () => {
+  it('trims the pattern and normalizes the type', () => {
+    expect(parseCategoryRule({ pattern: ' Uber ', type: 'Expense' })).toEqual({
+      rule: { pattern: 'Uber', matchType: 'literal', type: 'expense' },
+    })
+    expect(parseCategoryRule({ pattern: '^UBER', matchType: 'regex' })).toEqual(
+      { rule: { pattern: '^UBER', matchType: 'regex', type: null } },
+    )
+  })
+
+  it('rejects a missing pattern, unknown match type or unknown type', () => {
+    expect(parseCategoryRule({ pattern: ' ' })).toEqual({
+      error: 'pattern is required',
+    })
+    expect(parseCategoryRule({ pattern: 'Uber', matchType: 'glob' })).toEqual({
+      error: 'matchType must be one of literal, regex',
+    })
+    expect(parseCategoryRule({ pattern: 'Uber', type: 'refund' })).toEqual({
+      error: 'type must be income or expense',
+    })
+  })
+})
+
+describe('applyCategoryRule', () => {
+  it('binds the rule as parameters over uncategorized rows', () => {
+    const q = new QueryBuilder()
+    applyCategoryRule(q, 'acc-1', {
+      pattern: '50%_off',
+      matchType: 'literal',
+      type: null,
+    })
+    expect(q.whereSql()).toContain('t.category_id IS NULL')
+    expect(q.whereSql()).toContain('strpos(lower(t.description), lower($2')
+    expect(q.params).toEqual(['acc-1', '50%_off', 'literal', null])
+  })
+})
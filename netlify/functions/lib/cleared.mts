@@ -0,0 +1,43 @@
+import type { Context } from '@netlify/functions'
+import { getSessionFromRequest } from './auth.mts'
+import { getDb } from './db.mts'
+import { err, json, serverError } from './http.mts'
+
+/**
+ * Handler for the `transaction_clear` / `transaction_unclear` endpoints,
+ * which mark one transaction as cleared or pending during reconciliation.
+ */
+export function setClearedHandler(cleared: boolean) {
+  return async (req: Request, context: Context) => {
+    const session = await getSessionFromRequest(req)
+    if (!session) return err('Unauthorized', 401)
+    const userId = session.user.id
+
+    const url = new URL(req.url)
+    const accountId = url.searchParams.get('accountId')
+    const id = url.searchParams.get('id')
+    if (!accountId) return err('accountId query parameter is required', 400)
+    if (!id) return err('id query parameter is required', 400)
+
+    if (req.method !== 'POST') {
+      return err('Method not allowed', 405)
+    }
+
+    try {
+      const sql = await getDb()
+
+      const [updated] = await sql`
+        UPDATE transactions t
+        SET cleared = ${cleared}, updated_at = now()
+        FROM bank_accounts a
+        WHERE t.id = ${id} AND t.account_id = ${accountId}
+          AND a.id = t.account_id AND a.user_id = ${userId}
+        RETURNING t.id, t.account_id, t.amount::text, t.date, t.description, t.type, t.transfer_group, t.cleared
+      `
+      if (!updated) return err('Not found', 404)
+      return json(updated)
+    } catch (e) {
+      return serverError(req, context, e)
+    }
+  }
+}
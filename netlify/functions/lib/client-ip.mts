@@ -0,0 +1,131 @@
+interface ParsedIp {
+  family: 4 | 6
+  value: bigint
+}
+
+export interface Cidr extends ParsedIp {
+  bits: number
+}
+
+function parseIpv4(ip: string): bigint | null {
+  const parts = ip.split('.')
+  if (parts.length !== 4) return null
+  let value = 0n
+  for (const part of parts) {
+    if (!/^\d{1,3}$/.test(part)) return null
+    const octet = Number(part)
+    if (octet > 255) return null
+    value = (value << 8n) | BigInt(octet)
+  }
+  return value
+}
+
+function parseIpv6(ip: string): bigint | null {
+  let head = ip
+  let tail: bigint | null = null
+  let tailGroups = 0
+  // An embedded IPv4 suffix (e.g. ::ffff:10.0.0.1) takes the last 32 bits.
+  const lastColon = ip.lastIndexOf(':')
+  if (ip.includes('.', lastColon)) {
+    tail = parseIpv4(ip.slice(lastColon + 1))
+    if (tail === null) return null
+    head = ip.slice(0, lastColon + 1)
+    if (head.endsWith(':') && !head.endsWith('::')) head = head.slice(0, -1)
+    tailGroups = 2
+  }
+
+  const halves = head.split('::')
+  if (halves.length > 2) return null
+  const toGroups = (s: string) => (s === '' ? [] : s.split(':'))
+  const left = toGroups(halves[0])
+  const right = halves.length === 2 ? toGroups(halves[1]) : []
+  const missing = 8 - tailGroups - left.length - right.length
+  if (halves.length === 1 ? missing !== 0 : missing < 1) return null
+
+  let value = 0n
+  for (const group of [...left, ...Array(missing).fill('0'), ...right]) {
+    if (!/^[0-9a-f]{1,4}$/i.test(group)) return null
+    value = (value << 16n) | BigInt(parseInt(group, 16))
+  }
+  return tail === null ? value : (value << 32n) | tail
+}
+
+/**
+ * Parses an IPv4 or IPv6 address. IPv4-mapped IPv6 addresses are reported as
+ * IPv4 so they match IPv4 CIDRs. Returns null for anything unparseable.
+ */
+export function parseIp(raw: string): ParsedIp | null {
+  let ip = raw.trim()
+  if (ip.startsWith('[')) ip = ip.slice(1, ip.indexOf(']'))
+  ip = ip.split('%')[0]
+
+  const v4 = parseIpv4(ip)
+  if (v4 !== null) return { family: 4, value: v4 }
+
+  const v6 = parseIpv6(ip)
+  if (v6 === null) return null
+  if (v6 >> 32n === 0xffffn) return { family: 4, value: v6 & 0xffffffffn }
+  return { family: 6, value: v6 }
+}
+
+/**
+ * Parses a comma-separated list of CIDRs (or bare addresses). Invalid entries
+ * are ignored.
+ */
+export function parseCidrList(value: string): Cidr[] {
+  const cidrs: Cidr[] = []
+  for (const entry of value.split(',')) {
+    const trimmed = entry.trim()
+    if (!trimmed) continue
+    const [addr, bitsRaw] = trimmed.split('/')
+    const ip = parseIp(addr)
+    if (!ip) continue
+    const maxBits = ip.family === 4 ? 32 : 128
+    const bits = bitsRaw === undefined ? maxBits : Number(bitsRaw)
+    if (!Number.isInteger(bits) || bits < 0 || bits > maxBits) continue
+    cidrs.push({ ...ip, bits })
+  }
+  return cidrs
+}
+
+export function isTrusted(ip: string, cidrs: Cidr[]): boolean {
+  const parsed = parseIp(ip)
+  if (!parsed) return false
+  return cidrs.some((cidr) => {
+    if (cidr.family !== parsed.family) return false
+    const shift = BigInt((cidr.family === 4 ? 32 : 128) - cidr.bits)
+    return parsed.value >> shift === cidr.value >> shift
+  })
+}
+
+const TRUSTED_PROXIES = parseCidrList(process.env.TRUSTED_PROXIES ?? '')
+
+/**
+ * Returns the originating client IP. Forwarding headers are only honoured when
+ * the immediate peer is a trusted proxy; the X-Forwarded-For chain is walked
+ * right to left and the first untrusted hop wins. Falls back to the peer.
+ */
+export function clientIp(
+  req: Request,
+  peer: string,
+  trusted: Cidr[] = TRUSTED_PROXIES,
+): string {
+  if (!isTrusted(peer, trusted)) return peer
+
+  const forwarded = req.headers.get('x-forwarded-for')
+  if (forwarded) {
+    const hops = forwarded
+      .split(',')
+      .map((hop) => hop.trim())
+      .filter((hop) => parseIp(hop))
+    for (let i = hops.length - 1; i >= 0; i--) {
+      if (!isTrusted(hops[i], trusted)) return hops[i]
+    }
+    if (hops.length > 0) return hops[0]
+  }
+
+  const realIp = req.headers.get('x-real-ip')?.trim()
+  if (realIp && parseIp(realIp)) return realIp
+
+  return peer
+}
@@ -0,0 +1,67 @@
+import { describe, expect, it } from 'vitest'
+import { clientIp, isTrusted, parseCidrList, parseIp } from './client-ip.mts'
+
+function request(headers: Record<string, string>) {
+  return new Request('https://example.com/api', { headers })
+}
+
+describe('parseIp', () => {
+  it('parses IPv4, IPv6, and IPv4-mapped addresses', () => {
+    expect(parseIp('10.0.0.1')).toEqual({ family: 4, value: 0x0a000001n })
+    expect(parseIp('::1')).toEqual({ family: 6, value: 1n })
+    expect(parseIp('[2001:db8::1]')?.family).toBe(6)
+    expect(parseIp('::ffff:10.0.0.1')).toEqual({
+      family: 4,
+      value: 0x0a000001n,
+    })
+  })
+
+  it('rejects malformed addresses', () => {
+    expect(parseIp('')).toBeNull()
+    expect(parseIp('256.0.0.1')).toBeNull()
+    expect(parseIp('1.2.3')).toBeNull()
+    expect(parseIp('1::2::3')).toBeNull()
+    expect(parseIp('unknown')).toBeNull()
+  })
+})
+
+describe('isTrusted', () => {
+  const cidrs = parseCidrList('10.0.0.0/8, 192.168.1.1, fd00::/8, bogus')
+
+  it('matches addresses inside configured ranges', () => {
+    expect(isTrusted('10.20.30.40', cidrs)).toBe(true)
+    expect(isTrusted('192.168.1.1', cidrs)).toBe(true)
+    expect(isTrusted('fd12::5', cidrs)).toBe(true)
+  })
+
+  it('does not match addresses outside configured ranges', () => {
+    expect(isTrusted('11.0.0.1', cidrs)).toBe(false)
+    expect(isTrusted('192.168.1.2', cidrs)).toBe(false)
+    expect(isTrusted('2001:db8::1', cidrs)).toBe(false)
+  })
+})
+
+describe('clientIp', () => {
+  const trusted = parseCidrList('10.0.0.0/8')
+
+  it('ignores forwarding headers from untrusted peers', () => {
+    const req = request({ 'x-forwarded-for': '1.1.1.1' })
+    expect(clientIp(req, '203.0.113.7', trusted)).toBe('203.0.113.7')
+  })
+
+  it('returns the first untrusted hop from the right', () => {
+    const req = request({ 'x-forwarded-for': '6.6.6.6, 1.1.1.1, 10.0.0.2' })
+    expect(clientIp(req, '10.0.0.1', trusted)).toBe('1.1.1.1')
+  })
+
+  it('falls back to X-Real-IP and then the peer', () => {
+    const req = request({ 'x-real-ip': '1.1.1.1' })
+    expect(clientIp(req, '10.0.0.1', trusted)).toBe('1.1.1.1')
+    expect(clientIp(request({}), '10.0.0.1', trusted)).toBe('10.0.0.1')
+  })
+
+  it('trusts nothing by default', () => {
+    const req = request({ 'x-forwarded-for': '1.1.1.1' })
+    expect(clientIp(req, '10.0.0.1', [])).toBe('10.0.0.1')
+  })
+})
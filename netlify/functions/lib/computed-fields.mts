@@ -0,0 +1,64 @@
+type Row = Record<string, unknown>
+
+/**
+ * Negates an amount as presented: a decimal string or, with minor units,
+ * a number. Zero stays as it is rather than becoming `-0`.
+ */
+function negate(amount: unknown): unknown {
+  if (typeof amount === 'number') return amount === 0 ? 0 : -amount
+  const text = String(amount)
+  if (Number(text) === 0) return text
+  return text.startsWith('-') ? text.slice(1) : `-${text}`
+}
+
+function isNegative(amount: unknown): boolean {
+  if (typeof amount === 'number') return amount < 0
+  return String(amount).startsWith('-')
+}
+
+/**
+ * Derived transaction fields a client may ask for with `?compute=`. Each
+ * reads the row as sent, after presentAmounts, so amounts keep the
+ * deployment's units.
+ */
+export const COMPUTED_FIELDS = {
+  absoluteAmount: (row: Row) =>
+    isNegative(row.amount) ? negate(row.amount) : row.amount,
+  /** Negative for expenses, as the transaction moves the balance. */
+  signedAmount: (row: Row) =>
+    row.type === 'expense' ? negate(row.amount) : row.amount,
+  isExpense: (row: Row) => row.type === 'expense',
+} satisfies Record<string, (row: Row) => unknown>
+
+export type ComputedField = keyof typeof COMPUTED_FIELDS
+
+/** Reads the comma-separated `compute` parameter, rejecting unknown keys. */
+export function parseCompute(
+  url: URL,
+): { compute: ComputedField[] } | { error: string } {
+  const compute = new Set<ComputedField>()
+  for (const part of url.searchParams.get('compute')?.split(',') ?? []) {
+    const value = part.trim()
+    if (!value) continue
+    if (!Object.hasOwn(COMPUTED_FIELDS, value)) {
+      return {
+        error: `unknown compute value "${value}"; allowed: ${Object.keys(COMPUTED_FIELDS).join(', ')}`,
+      }
+    }
+    compute.add(value as ComputedField)
+  }
+  return { compute: [...compute] }
+}
+
+/** Adds the requested computed fields to each row. */
+export function withComputedFields<T extends Row>(
+  rows: T[],
+  fields: readonly ComputedField[],
+): T[] {
+  if (!fields.length) return rows
+  return rows.map((row) => {
+    const computed: Row = {}
+    for (const field of fields) computed[field] = COMPUTED_FIELDS[field](row)
+    return { ...row, ...computed }
+  })
+}
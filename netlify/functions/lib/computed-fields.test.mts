@@ -0,0 +1,54 @@
+import { describe, expect, it } from 'vitest'
+import { parseCompute, withComputedFields } from './computed-fields.mts'
+
+function url(query: string) {
+  return new URL(`https://example.com/transactions?${query}`)
+}
+
+describe('parseCompute', () => {
+  it('reads known keys once each', () => {
+    expect(
+      parseCompute(url('compute=signedAmount, isExpense,isExpense')),
+    ).toEqual({ compute: ['signedAmount', 'isExpense'] })
+    expect(parseCompute(url(''))).toEqual({ compute: [] })
+  })
+
+  it('rejects unknown keys', () => {
+    expect(parseCompute(url('compute=signedAmount,payee'))).toEqual({
+      error:
+        'unknown compute value "payee"; allowed: absoluteAmount, signedAmount, isExpense',
+    })
+  })
+})
+
+describe('withComputedFields', () => {
+  it('signs expenses negative and leaves income as is', () => {
+    const rows = [
+      { amount: '12.5000', type: 'expense' },
+      { amount: '40.0000', type: 'income' },
+      { amount: '0.0000', type: 'expense' },
+    ]
+    expect(
+      withComputedFields(rows, ['signedAmount']).map((r) => r.signedAmount),
+    ).toEqual(['-12.5000', '40.0000', '0.0000'])
+  })
+
+  it('works on amounts presented in minor units', () => {
+    const [row] = withComputedFields(
+      [{ amount: -1250, type: 'expense' }],
+      ['absoluteAmount', 'signedAmount', 'isExpense'],
+    )
+    expect(row).toEqual({
+      amount: -1250,
+      type: 'expense',
+      absoluteAmount: 1250,
+      signedAmount: 1250,
+      isExpense: true,
+    })
+  })
+
+  it('returns the rows untouched without fields', () => {
+    const rows = [{ amount: '1.0000', type: 'income' }]
+    expect(withComputedFields(rows, [])).toBe(rows)
+  })
+})
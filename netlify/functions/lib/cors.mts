@@ -1,15 +1,60 @@
 /**
- * Returns CORS headers that reflect the request's Origin, enabling
- * credentialed cross-origin requests from any allowed origin.
+ * Parses a comma-separated origin allowlist. `*` (or an unset value) allows
+ * every origin.
  */
-export function corsHeaders(req: Request): Record<string, string> {
+export function parseAllowedOrigins(value: string | undefined): string[] {
+  const origins = (value ?? '')
+    .split(',')
+    .map((origin) => origin.trim().replace(/\/$/, ''))
+    .filter(Boolean)
+  return origins.length ? origins : ['*']
+}
+
+const ALLOWED_ORIGINS = parseAllowedOrigins(process.env.CORS_ALLOWED_ORIGINS)
+
+/** Request headers cross-origin clients may send; preflight refuses others. */
+const ALLOWED_HEADERS = [
+  'Content-Type',
+  'Authorization',
+  'Prefer',
+  'X-Feature-Flags',
+  'If-Match',
+  'If-None-Match',
+]
+
+/**
+ * Response headers cross-origin scripts may read. Without ETag listed a
+ * client could never revalidate with If-None-Match.
+ */
+const EXPOSED_HEADERS = [
+  'ETag',
+  'X-Result-Truncated',
+  'X-Feature-Flags-Applied',
+]
+
+/**
+ * Returns CORS headers for the request. The request's Origin is echoed back
+ * only when it is allowed, which credentialed requests require in place of a
+ * blanket `*`.
+ */
+export function corsHeaders(
+  req: Request,
+  allowedOrigins: string[] = ALLOWED_ORIGINS,
+): Record<string, string> {
   const origin = req.headers.get('origin') ?? ''
-  return {
-    'Access-Control-Allow-Origin': origin || '*',
+  const headers: Record<string, string> = {
     'Access-Control-Allow-Credentials': 'true',
     'Access-Control-Allow-Methods': 'GET, POST, PATCH, DELETE, OPTIONS',
-    'Access-Control-Allow-Headers': 'Content-Type, Authorization',
+    'Access-Control-Allow-Headers': ALLOWED_HEADERS.join(', '),
+    'Access-Control-Expose-Headers': EXPOSED_HEADERS.join(', '),
+    Vary: 'Origin',
+  }
+  if (!origin) {
+    if (allowedOrigins.includes('*')) headers['Access-Control-Allow-Origin'] = '*'
+  } else if (allowedOrigins.includes('*') || allowedOrigins.includes(origin)) {
+    headers['Access-Control-Allow-Origin'] = origin
   }
+  return headers
 }
 
 /** Handles OPTIONS preflight requests. */
@@ -0,0 +1,73 @@
+import { describe, expect, it } from 'vitest'
+import { corsHeaders, parseAllowedOrigins } from './cors.mts'
+
+function request(origin?: string) {
+  return new Request('https://api.example.com/bank_accounts', {
+    headers: origin ? { origin } : {},
+  })
+}
+
+describe('parseAllowedOrigins', () => {
+  it('splits and trims the list', () => {
+    expect(
+      parseAllowedOrigins(' https://a.example.com, https://b.example.com/ '),
+    ).toEqual(['https://a.example.com', 'https://b.example.com'])
+  })
+
+  it('allows everything when unset', () => {
+    expect(parseAllowedOrigins(undefined)).toEqual(['*'])
+    expect(parseAllowedOrigins('')).toEqual(['*'])
+  })
+})
+
+describe('corsHeaders', () => {
+  const allowed = ['https://app.example.com']
+
+  it('echoes an allowed origin', () => {
+    const headers = corsHeaders(request('https://app.example.com'), allowed)
+    expect(headers['Access-Control-Allow-Origin']).toBe(
+      'https://app.example.com',
+    )
+    expect(headers.Vary).toBe('Origin')
+  })
+
+  it('omits the allow-origin header for a disallowed origin', () => {
+    const headers = corsHeaders(request('https://evil.example.com'), allowed)
+    expect(headers['Access-Control-Allow-Origin']).toBeUndefined()
+    expect(headers.Vary).toBe('Origin')
+  })
+
+  it('echoes any origin for a wildcard list', () => {
+    const headers = corsHeaders(request('https://evil.example.com'), ['*'])
+    expect(headers['Access-Control-Allow-Origin']).toBe(
+      'https://evil.example.com',
+    )
+  })
+
+  it('falls back to * without an Origin header when wildcarded', () => {
+    expect(corsHeaders(request(), ['*'])['Access-Control-Allow-Origin']).toBe(
+      '*',
+    )
+    expect(
+      corsHeaders(request(), allowed)['Access-Control-Allow-Origin'],
+    ).toBeUndefined()
+  })
+
+  it('lets clients send If-Match for conditional writes', () => {
+    const allow =
+      corsHeaders(request(), allowed)['Access-Control-Allow-Headers']
+    expect(allow.split(', ')).toContain('If-Match')
+  })
+
+  it('lets clients revalidate with If-None-Match and read the ETag', () => {
+    const headers = corsHeaders(request(), allowed)
+    expect(headers['Access-Control-Allow-Headers'].split(', ')).toContain(
+      'If-None-Match',
+    )
+    expect(headers['Access-Control-Expose-Headers'].split(', ')).toEqual([
+      'ETag',
+      'X-Result-Truncated',
+      'X-Feature-Flags-Applied',
+    ])
+  })
+})
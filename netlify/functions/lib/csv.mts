@@ -0,0 +1,45 @@
+/**
+ * Parses RFC 4180 CSV text into rows of fields. Handles quoted fields,
+ * escaped quotes (`""`), embedded newlines, and CRLF line endings. Blank
+ * lines are skipped.
+ */
+export function parseCsv(text: string): string[][] {
+  const rows: string[][] = []
+  let row: string[] = []
+  let field = ''
+  let quoted = false
+
+  const endRow = () => {
+    row.push(field)
+    if (row.length > 1 || row[0] !== '') rows.push(row)
+    row = []
+    field = ''
+  }
+
+  for (let i = 0; i < text.length; i++) {
+    const c = text[i]
+    if (quoted) {
+      if (c === '"' && text[i + 1] === '"') {
+        field += '"'
+        i++
+      } else if (c === '"') {
+        quoted = false
+      } else {
+        field += c
+      }
+    } else if (c === '"' && field === '') {
+      quoted = true
+    } else if (c === ',') {
+      row.push(field)
+      field = ''
+    } else if (c === '\n' || c === '\r') {
+      if (c === '\r' && text[i + 1] === '\n') i++
+      endRow()
+    } else {
+      field += c
+    }
+  }
+  if (field !== '' || row.length > 0) endRow()
+
+  return rows
+}
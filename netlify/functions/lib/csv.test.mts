@@ -0,0 +1,28 @@
+import { describe, expect, it } from 'vitest'
+import { parseCsv } from './csv.mts'
+
+describe('parseCsv', () => {
+  it('splits rows and fields', () => {
+    expect(parseCsv('a,b\n1,2\n')).toEqual([
+      ['a', 'b'],
+      ['1', '2'],
+    ])
+  })
+
+  it('handles quotes, escaped quotes, and embedded newlines', () => {
+    expect(parseCsv('"a, b","say ""hi""","line\nbreak"')).toEqual([
+      ['a, b', 'say "hi"', 'line\nbreak'],
+    ])
+  })
+
+  it('handles CRLF line endings and skips blank lines', () => {
+    expect(parseCsv('a,b\r\n\r\n1,2\r\n')).toEqual([
+      ['a', 'b'],
+      ['1', '2'],
+    ])
+  })
+
+  it('keeps empty trailing fields', () => {
+    expect(parseCsv('a,,\n')).toEqual([['a', '', '']])
+  })
+})
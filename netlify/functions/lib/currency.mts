@@ -0,0 +1,92 @@
+/** Normalizes an ISO 4217 code (`"eur"` → `"EUR"`), or null if malformed. */
+export function parseCurrency(value: unknown): string | null {
+  if (typeof value !== 'string') return null
+  const code = value.trim().toUpperCase()
+  return /^[A-Z]{3}$/.test(code) ? code : null
+}
+
+/**
+ * Parses DEFAULT_CURRENCY, falling back to USD when unset. A value that is
+ * not a currency the runtime knows throws, so a typo stops the deployment
+ * at startup instead of being stamped onto every new account.
+ */
+export function parseDefaultCurrency(raw: string | undefined): string {
+  if (!raw?.trim()) return 'USD'
+  const code = parseCurrency(raw)
+  if (!code || !Intl.supportedValuesOf('currency').includes(code)) {
+    throw new Error(
+      `DEFAULT_CURRENCY must be an ISO 4217 currency code, got ${JSON.stringify(raw)}`,
+    )
+  }
+  return code
+}
+
+/** Currency assigned to accounts created without one. */
+export const DEFAULT_CURRENCY = parseDefaultCurrency(
+  process.env.DEFAULT_CURRENCY,
+)
+
+/**
+ * Exchange rates as the value of one unit of each currency in a common
+ * reference unit, e.g. `{"USD":1,"EUR":1.08}`.
+ */
+export type Rates = ReadonlyMap<string, number>
+
+/**
+ * Parses EXCHANGE_RATES. Entries with malformed codes or non-positive rates
+ * are dropped; an unset or invalid value yields no rates.
+ */
+export function parseRates(raw: string | undefined): Rates {
+  const rates = new Map<string, number>()
+  if (!raw?.trim()) return rates
+  let parsed: unknown
+  try {
+    parsed = JSON.parse(raw)
+  } catch {
+    console.error('EXCHANGE_RATES is not valid JSON; ignoring it')
+    return rates
+  }
+  if (typeof parsed !== 'object' || parsed === null) return rates
+  for (const [key, value] of Object.entries(parsed)) {
+    const code = parseCurrency(key)
+    if (code && typeof value === 'number' && value > 0) rates.set(code, value)
+  }
+  return rates
+}
+
+export const EXCHANGE_RATES = parseRates(process.env.EXCHANGE_RATES)
+
+/** Currencies in `currencies` that cannot be converted into `base`. */
+export function missingRates(
+  currencies: Iterable<string>,
+  base: string,
+  rates: Rates = EXCHANGE_RATES,
+): string[] {
+  const missing = new Set<string>()
+  for (const currency of currencies) {
+    if (currency === base) continue
+    if (!rates.has(currency)) missing.add(currency)
+    if (!rates.has(base)) missing.add(base)
+  }
+  return [...missing].sort()
+}
+
+/**
+ * Converts a decimal amount between currencies and returns it with four
+ * decimal places. Callers check `missingRates` first; converting without a
+ * rate throws rather than silently assuming 1:1.
+ */
+export function convert(
+  amount: string | number,
+  from: string,
+  to: string,
+  rates: Rates = EXCHANGE_RATES,
+): string {
+  if (from === to) return Number(amount).toFixed(4)
+  const fromRate = rates.get(from)
+  const toRate = rates.get(to)
+  if (fromRate === undefined || toRate === undefined) {
+    throw new Error(`no exchange rate between ${from} and ${to}`)
+  }
+  return ((Number(amount) * fromRate) / toRate).toFixed(4)
+}
@@ -0,0 +1,75 @@
+import { describe, expect, it } from 'vitest'
+import {
+  convert,
+  missingRates,
+  parseCurrency,
+  parseDefaultCurrency,
+  parseRates,
+} from './currency.mts'
+
+const rates = parseRates('{"USD":1,"EUR":1.08,"gbp":1.27,"BAD":0,"XX":2}')
+
+describe('parseCurrency', () => {
+  it('normalizes ISO 4217 codes', () => {
+    expect(parseCurrency(' eur ')).toBe('EUR')
+    expect(parseCurrency('EURO')).toBeNull()
+    expect(parseCurrency(978)).toBeNull()
+  })
+})
+
+describe('parseDefaultCurrency', () => {
+  it('falls back to USD when unset', () => {
+    expect(parseDefaultCurrency(undefined)).toBe('USD')
+    expect(parseDefaultCurrency(' ')).toBe('USD')
+  })
+
+  it('normalizes a configured code', () => {
+    expect(parseDefaultCurrency(' eur ')).toBe('EUR')
+  })
+
+  it('throws on codes that are not real currencies', () => {
+    expect(() => parseDefaultCurrency('euro')).toThrow(/DEFAULT_CURRENCY/)
+    expect(() => parseDefaultCurrency('ABC')).toThrow(/DEFAULT_CURRENCY/)
+  })
+})
+
+describe('parseRates', () => {
+  it('keeps valid entries only', () => {
+    expect([...rates]).toEqual([
+      ['USD', 1],
+      ['EUR', 1.08],
+      ['GBP', 1.27],
+    ])
+  })
+
+  it('yields no rates for unset or malformed values', () => {
+    expect(parseRates(undefined).size).toBe(0)
+    expect(parseRates('[1,2]').size).toBe(0)
+  })
+})
+
+describe('missingRates', () => {
+  it('lists currencies that cannot reach the base', () => {
+    expect(missingRates(['USD', 'EUR'], 'USD', rates)).toEqual([])
+    expect(missingRates(['USD', 'JPY', 'CHF', 'JPY'], 'USD', rates)).toEqual([
+      'CHF',
+      'JPY',
+    ])
+    expect(missingRates(['USD'], 'SEK', rates)).toEqual(['SEK'])
+    expect(missingRates(['SEK'], 'SEK', rates)).toEqual([])
+  })
+})
+
+describe('convert', () => {
+  it('converts through the reference rates', () => {
+    expect(convert('100', 'EUR', 'USD', rates)).toBe('108.0000')
+    expect(convert('127', 'GBP', 'EUR', rates)).toBe('149.3426')
+    expect(convert('12.5', 'JPY', 'JPY', rates)).toBe('12.5000')
+  })
+
+  it('refuses to guess a missing rate', () => {
+    expect(() => convert('1', 'JPY', 'USD', rates)).toThrow(
+      'no exchange rate between JPY and USD',
+    )
+  })
+})
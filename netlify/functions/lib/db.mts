@@ -0,0 +1,235 @@
+import { neon, neonConfig } from '@neondatabase/serverless'
+
+const DATABASE_URL = process.env.DATABASE_URL
+
+export const DEFAULT_STATEMENT_TIMEOUT_MS = 10_000
+
+/**
+ * Parses DB_STATEMENT_TIMEOUT_MS. Unset or invalid values fall back to the
+ * default; `0` disables the timeout.
+ */
+export function parseStatementTimeout(raw: string | undefined): number {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value < 0) {
+    return DEFAULT_STATEMENT_TIMEOUT_MS
+  }
+  return value
+}
+
+/** Server-side cap on how long any single statement may run. */
+export const STATEMENT_TIMEOUT_MS = parseStatementTimeout(
+  process.env.DB_STATEMENT_TIMEOUT_MS,
+)
+
+/**
+ * Adds `statement_timeout` to the connection's startup options so Postgres
+ * cancels runaway queries itself, even if the function has already given up
+ * on the response.
+ */
+export function withStatementTimeout(url: string, timeoutMs: number): string {
+  if (timeoutMs <= 0) return url
+  const parsed = new URL(url)
+  const setting = `-c statement_timeout=${timeoutMs}`
+  const existing = parsed.searchParams.get('options')
+  const options = existing ? `${existing} ${setting}` : setting
+  parsed.searchParams.delete('options')
+  const rest = parsed.searchParams.toString()
+  // Encode spaces as %20; libpq-style parsers do not decode `+`.
+  parsed.search = `${rest ? `${rest}&` : ''}options=${encodeURIComponent(options)}`
+  return parsed.toString()
+}
+
+export const DEFAULT_QUERY_TIMEOUT_MS = 15_000
+
+/**
+ * Parses DB_QUERY_TIMEOUT_MS. Unset or invalid values fall back to the
+ * default, which leaves the statement timeout room to fire first; `0`
+ * disables the timeout.
+ */
+export function parseQueryTimeout(raw: string | undefined): number {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value < 0) {
+    return DEFAULT_QUERY_TIMEOUT_MS
+  }
+  return value
+}
+
+/**
+ * Client-side cap on how long a query request may wait for the database,
+ * e.g. while a suspended compute wakes up or the endpoint is saturated.
+ */
+export const QUERY_TIMEOUT_MS = parseQueryTimeout(
+  process.env.DB_QUERY_TIMEOUT_MS,
+)
+
+/** The database did not answer a query within QUERY_TIMEOUT_MS. */
+export class DbTimeoutError extends Error {
+  constructor(timeoutMs: number) {
+    super(`database did not respond within ${timeoutMs}ms`)
+    this.name = 'DbTimeoutError'
+  }
+}
+
+/**
+ * Whether `e` is a query that timed out waiting for the database. The
+ * driver wraps fetch failures, keeping the original as `sourceError`.
+ */
+export function isDbTimeout(e: unknown): boolean {
+  if (e instanceof DbTimeoutError) return true
+  if (typeof e !== 'object' || e === null) return false
+  const source = (e as { sourceError?: unknown }).sourceError
+  return (
+    source instanceof DbTimeoutError ||
+    (source instanceof Error && source.name === 'TimeoutError')
+  )
+}
+
+/**
+ * Wraps fetch so a query request is aborted after `timeoutMs` and fails
+ * with DbTimeoutError instead of holding the function open.
+ */
+export function fetchWithTimeout(
+  timeoutMs: number,
+  fetchImpl: typeof fetch = fetch,
+): typeof fetch {
+  if (timeoutMs <= 0) return fetchImpl
+  return async (input, init) => {
+    const timeout = AbortSignal.timeout(timeoutMs)
+    const signal = init?.signal
+      ? AbortSignal.any([init.signal, timeout])
+      : timeout
+    try {
+      return await fetchImpl(input, { ...init, signal })
+    } catch (e) {
+      if (timeout.aborted) throw new DbTimeoutError(timeoutMs)
+      throw e
+    }
+  }
+}
+
+/**
+ * Parses SLOW_QUERY_MS. Unset or invalid values fall back to one second,
+ * which stays quiet in normal operation; `0` disables the log.
+ */
+export function parseSlowQueryThreshold(raw: string | undefined): number {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value < 0) return 1000
+  return value
+}
+
+export const SLOW_QUERY_MS = parseSlowQueryThreshold(process.env.SLOW_QUERY_MS)
+
+/** Summarizes an HTTP query payload as its first 120 characters of SQL. */
+export function describeQuery(body: unknown): string {
+  const summarize = (query: unknown) =>
+    String(query ?? '')
+      .replace(/\s+/g, ' ')
+      .trim()
+      .slice(0, 120)
+  try {
+    const payload = JSON.parse(String(body)) as {
+      query?: string
+      queries?: Array<{ query?: string }>
+    }
+    if (payload.queries) {
+      return `transaction(${payload.queries.length}): ${summarize(payload.queries[0]?.query)}`
+    }
+    return summarize(payload.query)
+  } catch {
+    return 'unknown query'
+  }
+}
+
+/**
+ * Counters for the queries this function instance has sent. The HTTP driver
+ * has no connection pool: each query is one request, so in-flight requests
+ * and their durations are what show database saturation.
+ */
+export interface QueryStats {
+  total: number
+  inFlight: number
+  failed: number
+  totalDurationMs: number
+  maxDurationMs: number
+}
+
+export function emptyQueryStats(): QueryStats {
+  return {
+    total: 0,
+    inFlight: 0,
+    failed: 0,
+    totalDurationMs: 0,
+    maxDurationMs: 0,
+  }
+}
+
+export const queryStats = emptyQueryStats()
+
+/**
+ * Wraps fetch so every HTTP query made by the Neon driver is timed and
+ * counted in `stats`, and any slower than `thresholdMs` is logged with its
+ * SQL and duration.
+ */
+export function timedFetch(
+  thresholdMs: number,
+  log: (message: string) => void = console.warn,
+  fetchImpl: typeof fetch = fetch,
+  now: () => number = performance.now.bind(performance),
+  stats: QueryStats = queryStats,
+): typeof fetch {
+  return async (input, init) => {
+    const start = now()
+    stats.total++
+    stats.inFlight++
+    let ok = false
+    try {
+      const res = await fetchImpl(input, init)
+      ok = res.ok
+      return res
+    } finally {
+      const duration = Math.round(now() - start)
+      stats.inFlight--
+      if (!ok) stats.failed++
+      stats.totalDurationMs += duration
+      stats.maxDurationMs = Math.max(stats.maxDurationMs, duration)
+      if (thresholdMs > 0 && duration >= thresholdMs) {
+        log(`[slow query] ${duration}ms ${describeQuery(init?.body)}`)
+      }
+    }
+  }
+}
+
+// The driver sends every query through this fetch, so timing and bounding
+// it covers all queries without touching individual call sites.
+neonConfig.fetchFunction = timedFetch(
+  SLOW_QUERY_MS,
+  console.warn,
+  fetchWithTimeout(QUERY_TIMEOUT_MS),
+)
+
+export async function getDb() {
+  if (!DATABASE_URL) throw new Response('DATABASE_URL not set', { status: 500 })
+  return neon(withStatementTimeout(DATABASE_URL, STATEMENT_TIMEOUT_MS))
+}
+
+/** SQLSTATE codes the API maps to client errors. */
+export const PG_FOREIGN_KEY_VIOLATION = '23503'
+export const PG_UNIQUE_VIOLATION = '23505'
+export const PG_INVALID_REGULAR_EXPRESSION = '2201B'
+export const PG_UNDEFINED_TABLE = '42P01'
+/**
+ * Raised by the refuse_overdraft triggers when a write would take an
+ * account with allow_negative off below zero.
+ */
+export const PG_OVERDRAWN = 'EL001'
+
+/** Whether `e` is a Postgres error with the given SQLSTATE code. */
+export function isPgError(e: unknown, code: string): boolean {
+  return (
+    typeof e === 'object' &&
+    e !== null &&
+    (e as { code?: unknown }).code === code
+  )
+}
+
+export type Sql = Awaited<ReturnType<typeof getDb>>
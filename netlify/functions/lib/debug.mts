@@ -0,0 +1,20 @@
+import { timingSafeEqual } from 'node:crypto'
+
+/** Bearer key for the `debug_*` endpoints; unset disables them. */
+export const DEBUG_API_KEY = process.env.DEBUG_API_KEY?.trim() || undefined
+
+/**
+ * Whether the request carries `Authorization: Bearer <key>` for the given
+ * key. Always false when no key is configured.
+ */
+export function hasDebugKey(
+  req: Request,
+  key: string | undefined = DEBUG_API_KEY,
+): boolean {
+  if (!key) return false
+  const match = /^Bearer\s+(.+)$/i.exec(req.headers.get('Authorization') ?? '')
+  if (!match) return false
+  const given = Buffer.from(match[1].trim())
+  const expected = Buffer.from(key)
+  return given.length === expected.length && timingSafeEqual(given, expected)
+}
@@ -0,0 +1,25 @@
+import { describe, expect, it } from 'vitest'
+import { hasDebugKey } from './debug.mts'
+
+function withAuthorization(value?: string) {
+  return new Request('https://example.com/', {
+    headers: value === undefined ? {} : { Authorization: value },
+  })
+}
+
+describe('hasDebugKey', () => {
+  it('accepts the configured bearer key', () => {
+    expect(hasDebugKey(withAuthorization('Bearer s3cret'), 's3cret')).toBe(true)
+  })
+
+  it('rejects missing, malformed or wrong keys', () => {
+    expect(hasDebugKey(withAuthorization(), 's3cret')).toBe(false)
+    expect(hasDebugKey(withAuthorization('s3cret'), 's3cret')).toBe(false)
+    expect(hasDebugKey(withAuthorization('Bearer nope'), 's3cret')).toBe(false)
+  })
+
+  it('is disabled without a configured key', () => {
+    expect(hasDebugKey(withAuthorization('Bearer '), undefined)).toBe(false)
+    expect(hasDebugKey(withAuthorization('Bearer x'), undefined)).toBe(false)
+  })
+})
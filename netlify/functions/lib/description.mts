@@ -0,0 +1,26 @@
+/** Longest transaction description accepted, in characters. */
+export const MAX_DESCRIPTION_LENGTH = 500
+
+/**
+ * Enforces MAX_DESCRIPTION_LENGTH. Too-long descriptions are an error
+ * unless the caller opted into `truncate`, which cuts them at a character
+ * (code point) boundary so no emoji or surrogate pair is split.
+ */
+export function fitDescription(
+  value: string,
+  truncate: boolean,
+): { value: string } | { error: string } {
+  const chars = Array.from(value)
+  if (chars.length <= MAX_DESCRIPTION_LENGTH) return { value }
+  if (!truncate) {
+    return {
+      error: `description must be at most ${MAX_DESCRIPTION_LENGTH} characters`,
+    }
+  }
+  return { value: chars.slice(0, MAX_DESCRIPTION_LENGTH).join('') }
+}
+
+/** Reads the `?truncate=true` opt-in for lossy description truncation. */
+export function wantsTruncation(url: URL): boolean {
+  return url.searchParams.get('truncate') === 'true'
+}
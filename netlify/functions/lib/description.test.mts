@@ -0,0 +1,30 @@
+import { describe, expect, it } from 'vitest'
+import { MAX_DESCRIPTION_LENGTH, fitDescription } from './description.mts'
+
+describe('fitDescription', () => {
+  const long = 'a'.repeat(MAX_DESCRIPTION_LENGTH + 1)
+
+  it('keeps descriptions within the limit', () => {
+    const exact = 'a'.repeat(MAX_DESCRIPTION_LENGTH)
+    expect(fitDescription(exact, false)).toEqual({ value: exact })
+  })
+
+  it('rejects long descriptions by default', () => {
+    expect(fitDescription(long, false)).toEqual({
+      error: `description must be at most ${MAX_DESCRIPTION_LENGTH} characters`,
+    })
+  })
+
+  it('truncates long descriptions when asked', () => {
+    expect(fitDescription(long, true)).toEqual({
+      value: 'a'.repeat(MAX_DESCRIPTION_LENGTH),
+    })
+  })
+
+  it('counts and cuts by character, not UTF-16 unit', () => {
+    const emoji = '💸'.repeat(MAX_DESCRIPTION_LENGTH)
+    expect(fitDescription(emoji, false)).toEqual({ value: emoji })
+    const cut = fitDescription(`${emoji}💸`, true)
+    expect(cut).toEqual({ value: emoji })
+  })
+})
@@ -0,0 +1,36 @@
+const UNIT_MS: Record<string, number> = {
+  ns: 1e-6,
+  us: 1e-3,
+  µs: 1e-3,
+  μs: 1e-3,
+  ms: 1,
+  s: 1000,
+  m: 60_000,
+  h: 3_600_000,
+}
+
+const DURATION_RE = /^[-+]?(?:\d+(?:\.\d*)?|\.\d+)[a-zµμ]+/
+const PART_RE = /^(\d+(?:\.\d*)?|\.\d+)([a-zµμ]+)/
+
+/**
+ * Parses a duration in Go's `time.ParseDuration` syntax (`"-5h"`,
+ * `"1h30m"`, `"90s"`, `"1.5h"`) into milliseconds. Returns null for
+ * anything else, including units longer than hours such as days.
+ */
+export function parseDuration(raw: string): number | null {
+  const text = raw.trim()
+  if (text === '0' || text === '+0' || text === '-0') return 0
+  if (!DURATION_RE.test(text)) return null
+  const sign = text.startsWith('-') ? -1 : 1
+  let rest = text.replace(/^[-+]/, '')
+  let total = 0
+  while (rest) {
+    const match = PART_RE.exec(rest)
+    if (!match) return null
+    const unit = UNIT_MS[match[2]]
+    if (unit === undefined) return null
+    total += Number(match[1]) * unit
+    rest = rest.slice(match[0].length)
+  }
+  return sign * total
+}
@@ -0,0 +1,20 @@
+import { describe, expect, it } from 'vitest'
+import { parseDuration } from './duration.mts'
+
+describe('parseDuration', () => {
+  it('reads Go duration strings as milliseconds', () => {
+    expect(parseDuration('-5h')).toBe(-5 * 3_600_000)
+    expect(parseDuration('1h30m')).toBe(90 * 60_000)
+    expect(parseDuration('+1.5h')).toBe(90 * 60_000)
+    expect(parseDuration('250ms')).toBe(250)
+    expect(parseDuration('0')).toBe(0)
+  })
+
+  it('rejects other formats', () => {
+    expect(parseDuration('')).toBeNull()
+    expect(parseDuration('5')).toBeNull()
+    expect(parseDuration('2d')).toBeNull()
+    expect(parseDuration('5 h')).toBeNull()
+    expect(parseDuration('PT5H')).toBeNull()
+  })
+})
@@ -0,0 +1,39 @@
+/**
+ * Formats a row version as a strong ETag. Versions are `updated_at` in whole
+ * microseconds, selected as text so they round-trip exactly (a JS Date would
+ * drop precision).
+ */
+export function etag(version: string | number): string {
+  return `"${version}"`
+}
+
+/**
+ * Whether a request's If-Match precondition fails against the current
+ * ETag. Without the header there is no precondition; `*` matches any
+ * existing resource. Weak tags never match, as RFC 9110 requires strong
+ * comparison for If-Match.
+ */
+export function ifMatchFails(req: Request, current: string): boolean {
+  const header = req.headers.get('If-Match')
+  if (header === null) return false
+  const tags = header.split(',').map((tag) => tag.trim())
+  if (tags.includes('*')) return false
+  return !tags.includes(current)
+}
+
+/**
+ * Whether a conditional GET's If-None-Match names the current ETag, so the
+ * client's copy is still good. Unlike If-Match this uses weak comparison
+ * (a `W/` prefix is ignored), and `*` matches any existing resource.
+ */
+export function ifNoneMatchHits(req: Request, current: string): boolean {
+  const header = req.headers.get('If-None-Match')
+  if (header === null) return false
+  const tags = header.split(',').map((tag) => tag.trim().replace(/^W\//, ''))
+  return tags.includes('*') || tags.includes(current.replace(/^W\//, ''))
+}
+
+/** A bodiless 304 carrying the ETag the client already holds. */
+export function notModified(tag: string): Response {
+  return new Response(null, { status: 304, headers: { ETag: tag } })
+}
@@ -0,0 +1,58 @@
+import { describe, expect, it } from 'vitest'
+import { etag, ifMatchFails, ifNoneMatchHits, notModified } from './etag.mts'
+
+function withIfMatch(value?: string) {
+  return new Request('https://example.com/', {
+    headers: value === undefined ? {} : { 'If-Match': value },
+  })
+}
+
+describe('ifMatchFails', () => {
+  const current = etag('42')
+
+  it('passes without a precondition', () => {
+    expect(ifMatchFails(withIfMatch(), current)).toBe(false)
+  })
+
+  it('passes on a matching tag, a list containing it, or *', () => {
+    expect(ifMatchFails(withIfMatch('"42"'), current)).toBe(false)
+    expect(ifMatchFails(withIfMatch('"1", "42"'), current)).toBe(false)
+    expect(ifMatchFails(withIfMatch('*'), current)).toBe(false)
+  })
+
+  it('fails on a stale or weak tag', () => {
+    expect(ifMatchFails(withIfMatch('"41"'), current)).toBe(true)
+    expect(ifMatchFails(withIfMatch('W/"42"'), current)).toBe(true)
+  })
+})
+
+function withIfNoneMatch(value?: string) {
+  return new Request('https://example.com/', {
+    headers: value === undefined ? {} : { 'If-None-Match': value },
+  })
+}
+
+describe('ifNoneMatchHits', () => {
+  const current = etag('42')
+
+  it('misses without the header or on a stale tag', () => {
+    expect(ifNoneMatchHits(withIfNoneMatch(), current)).toBe(false)
+    expect(ifNoneMatchHits(withIfNoneMatch('"41"'), current)).toBe(false)
+  })
+
+  it('hits on a matching tag, weak or strong, a list containing it, or *', () => {
+    expect(ifNoneMatchHits(withIfNoneMatch('"42"'), current)).toBe(true)
+    expect(ifNoneMatchHits(withIfNoneMatch('W/"42"'), current)).toBe(true)
+    expect(ifNoneMatchHits(withIfNoneMatch('"1", "42"'), current)).toBe(true)
+    expect(ifNoneMatchHits(withIfNoneMatch('*'), current)).toBe(true)
+  })
+})
+
+describe('notModified', () => {
+  it('is an empty 304 with the ETag', async () => {
+    const res = notModified(etag('42'))
+    expect(res.status).toBe(304)
+    expect(res.headers.get('ETag')).toBe('"42"')
+    expect(await res.text()).toBe('')
+  })
+})
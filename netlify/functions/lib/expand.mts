@@ -0,0 +1,88 @@
+import type { Sql } from './db.mts'
+
+export const TRANSACTION_EXPANSIONS = ['account', 'splits'] as const
+
+export type TransactionExpansion = (typeof TRANSACTION_EXPANSIONS)[number]
+
+/** Reads the comma-separated `expand` parameter, rejecting unknown values. */
+export function parseExpand(
+  url: URL,
+): { expand: Set<TransactionExpansion> } | { error: string } {
+  const expand = new Set<TransactionExpansion>()
+  const raw = url.searchParams.get('expand')
+  if (!raw) return { expand }
+  for (const part of raw.split(',')) {
+    const value = part.trim()
+    if (!value) continue
+    if (!(TRANSACTION_EXPANSIONS as readonly string[]).includes(value)) {
+      return {
+        error: `unknown expand value "${value}"; allowed: ${TRANSACTION_EXPANSIONS.join(', ')}`,
+      }
+    }
+    expand.add(value as TransactionExpansion)
+  }
+  return { expand }
+}
+
+/** Loads the accounts with the given ids in one query, keyed by id. */
+export async function accountsByIds(
+  sql: Sql,
+  ids: string[],
+): Promise<Map<string, Record<string, unknown>>> {
+  if (ids.length === 0) return new Map()
+  const accounts = await sql`
+    SELECT id, name, type FROM bank_accounts WHERE id = ANY(${ids}::uuid[])
+  `
+  return new Map(accounts.map((a) => [String(a.id), a]))
+}
+
+/**
+ * Loads the splits of the given transactions in one query, keyed by
+ * transaction id. Transactions without splits have no entry.
+ */
+export async function splitsByTransactionIds(
+  sql: Sql,
+  ids: string[],
+): Promise<Map<string, Record<string, unknown>[]>> {
+  const byTransaction = new Map<string, Record<string, unknown>[]>()
+  if (ids.length === 0) return byTransaction
+  const splits = await sql`
+    SELECT id, transaction_id, amount::text, description
+    FROM transaction_splits
+    WHERE transaction_id = ANY(${ids}::uuid[])
+    ORDER BY amount DESC
+  `
+  for (const split of splits) {
+    const key = String(split.transaction_id)
+    byTransaction.set(key, [...(byTransaction.get(key) ?? []), split])
+  }
+  return byTransaction
+}
+
+/**
+ * Embeds the requested related resources into transaction rows. Each
+ * expansion is loaded with one batched query, regardless of row count.
+ */
+export async function expandTransactions(
+  sql: Sql,
+  rows: Record<string, unknown>[],
+  expand: Set<TransactionExpansion>,
+): Promise<Record<string, unknown>[]> {
+  if (rows.length === 0 || expand.size === 0) return rows
+
+  if (expand.has('account')) {
+    const accountIds = [...new Set(rows.map((r) => String(r.account_id)))]
+    const byId = await accountsByIds(sql, accountIds)
+    for (const row of rows) row.account = byId.get(String(row.account_id)) ?? null
+  }
+
+  if (expand.has('splits')) {
+    const byTransaction = await splitsByTransactionIds(
+      sql,
+      rows.map((r) => String(r.id)),
+    )
+    for (const row of rows) row.splits = byTransaction.get(String(row.id)) ?? []
+  }
+
+  return rows
+}
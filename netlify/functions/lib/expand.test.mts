@@ -0,0 +1,99 @@
+import { describe, expect, it, vi } from 'vitest'
+import type { Sql } from './db.mts'
+import {
+  accountsByIds,
+  expandTransactions,
+  parseExpand,
+  splitsByTransactionIds,
+} from './expand.mts'
+
+function url(query: string) {
+  return new URL(`https://example.com/transactions?${query}`)
+}
+
+describe('parseExpand', () => {
+  it('accepts known values', () => {
+    const result = parseExpand(url('expand=account, splits'))
+    expect(result).toEqual({ expand: new Set(['account', 'splits']) })
+  })
+
+  it('returns an empty set without the parameter', () => {
+    expect(parseExpand(url(''))).toEqual({ expand: new Set() })
+  })
+
+  it('rejects unknown values', () => {
+    expect(parseExpand(url('expand=account,attachments'))).toHaveProperty(
+      'error',
+    )
+  })
+})
+
+describe('expandTransactions', () => {
+  it('loads each expansion with a single query', async () => {
+    const sql = vi.fn()
+    sql.mockResolvedValueOnce([{ id: 'acc-1', name: 'Checking', type: 'bank' }])
+    sql.mockResolvedValueOnce([
+      { id: 's-1', transaction_id: 'tx-1', amount: '5', description: '' },
+    ])
+    const rows = [
+      { id: 'tx-1', account_id: 'acc-1' },
+      { id: 'tx-2', account_id: 'acc-1' },
+    ]
+
+    const result = await expandTransactions(
+      sql as unknown as Sql,
+      rows,
+      new Set(['account', 'splits']),
+    )
+
+    expect(sql).toHaveBeenCalledTimes(2)
+    expect(result[0].account).toEqual({
+      id: 'acc-1',
+      name: 'Checking',
+      type: 'bank',
+    })
+    expect(result[0].splits).toHaveLength(1)
+    expect(result[1].splits).toEqual([])
+  })
+})
+
+describe('accountsByIds', () => {
+  it('keys the accounts by id', async () => {
+    const sql = vi.fn().mockResolvedValueOnce([
+      { id: 'acc-1', name: 'Checking', type: 'bank' },
+      { id: 'acc-2', name: 'Wallet', type: 'cash' },
+    ])
+
+    const byId = await accountsByIds(sql as unknown as Sql, ['acc-1', 'acc-2'])
+
+    expect(sql).toHaveBeenCalledTimes(1)
+    expect(byId.get('acc-2')).toEqual({
+      id: 'acc-2',
+      name: 'Wallet',
+      type: 'cash',
+    })
+  })
+
+  it('skips the query without ids', async () => {
+    const sql = vi.fn()
+    expect(await accountsByIds(sql as unknown as Sql, [])).toEqual(new Map())
+    expect(sql).not.toHaveBeenCalled()
+  })
+})
+
+describe('splitsByTransactionIds', () => {
+  it('groups the splits by transaction', async () => {
+    const sql = vi.fn().mockResolvedValueOnce([
+      { id: 's-1', transaction_id: 'tx-1', amount: '7', description: '' },
+      { id: 's-2', transaction_id: 'tx-1', amount: '3', description: '' },
+    ])
+
+    const byTransaction = await splitsByTransactionIds(
+      sql as unknown as Sql,
+      ['tx-1', 'tx-2'],
+    )
+
+    expect(byTransaction.get('tx-1')?.map((s) => s.id)).toEqual(['s-1', 's-2'])
+    expect(byTransaction.has('tx-2')).toBe(false)
+  })
+})
@@ -0,0 +1,45 @@
+import { AMOUNT_UNITS } from './amount.mts'
+import type { AmountUnits } from './amount.mts'
+
+/**
+ * Behaviours a client can switch on for a single request by listing them
+ * in `X-Feature-Flags`, to try them out before they change for everyone.
+ * - `decimal`: amounts read and written as decimal strings even where
+ *   AMOUNT_UNITS is `minor`.
+ */
+export const FEATURE_FLAGS = ['decimal'] as const
+
+export type FeatureFlag = (typeof FEATURE_FLAGS)[number]
+
+/**
+ * The known flags named in the request's `X-Feature-Flags` header.
+ * Unknown names are ignored, so a client still sending a retired flag
+ * keeps working.
+ */
+export function featureFlags(req: Request): Set<FeatureFlag> {
+  const flags = new Set<FeatureFlag>()
+  for (const part of (req.headers.get('X-Feature-Flags') ?? '').split(',')) {
+    const name = part.trim().toLowerCase()
+    if ((FEATURE_FLAGS as readonly string[]).includes(name)) {
+      flags.add(name as FeatureFlag)
+    }
+  }
+  return flags
+}
+
+/**
+ * Reports the flags a response honoured in `X-Feature-Flags-Applied`, so
+ * a client can tell a flag from one this deployment does not know.
+ */
+export function withFeatureFlags(req: Request, res: Response): Response {
+  const flags = featureFlags(req)
+  if (!flags.size) return res
+  const headers = new Headers(res.headers)
+  headers.set('X-Feature-Flags-Applied', [...flags].join(','))
+  return new Response(res.body, { status: res.status, headers })
+}
+
+/** The amount units of this request's body and response. */
+export function requestAmountUnits(req: Request): AmountUnits {
+  return featureFlags(req).has('decimal') ? 'decimal' : AMOUNT_UNITS
+}
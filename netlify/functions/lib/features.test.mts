@@ -0,0 +1,29 @@
+import { describe, expect, it } from 'vitest'
+import { featureFlags, withFeatureFlags } from './features.mts'
+
+function request(flags?: string) {
+  return new Request('https://example.com/api/transactions', {
+    headers: flags === undefined ? {} : { 'X-Feature-Flags': flags },
+  })
+}
+
+describe('featureFlags', () => {
+  it('reads known flags and ignores the rest', () => {
+    expect(featureFlags(request(' Decimal, new-errors,'))).toEqual(
+      new Set(['decimal']),
+    )
+    expect(featureFlags(request())).toEqual(new Set())
+  })
+})
+
+describe('withFeatureFlags', () => {
+  it('names the flags the response honoured', () => {
+    const res = withFeatureFlags(request('decimal'), new Response('{}'))
+    expect(res.headers.get('X-Feature-Flags-Applied')).toBe('decimal')
+  })
+
+  it('adds nothing without known flags', () => {
+    const original = new Response('{}')
+    expect(withFeatureFlags(request('new-errors'), original)).toBe(original)
+  })
+})
@@ -0,0 +1,138 @@
+import { parseAmount } from './amount.mts'
+import type { BodySchema } from './http.mts'
+
+/** Days of recent activity used to estimate how fast a goal is filling. */
+export const PACE_WINDOW_DAYS = 90
+
+/** Accepted JSON types of savings goal body fields. */
+export const GOAL_BODY: BodySchema = {
+  name: 'string',
+  target_amount: ['number', 'string'],
+  target_date: 'string',
+}
+
+export interface GoalFields {
+  name?: string
+  targetAmount?: string
+  /** `YYYY-MM-DD`; null for a goal without a deadline. */
+  targetDate?: string | null
+}
+
+const DAY_MS = 86_400_000
+
+function isCalendarDate(value: string): boolean {
+  if (!/^\d{4}-\d{2}-\d{2}$/.test(value)) return false
+  const date = new Date(`${value}T00:00:00Z`)
+  return (
+    !Number.isNaN(date.getTime()) && date.toISOString().startsWith(value)
+  )
+}
+
+/**
+ * Validates goal fields from a request body. On create (`partial` false)
+ * name and target_amount are required; on update only the given fields
+ * are checked, and target_date may be cleared with null.
+ */
+export function parseGoalFields(
+  body: Record<string, unknown>,
+  partial: boolean,
+): { value: GoalFields } | { error: string } {
+  const value: GoalFields = {}
+  if (body.name !== undefined || !partial) {
+    const name = typeof body.name === 'string' ? body.name.trim() : ''
+    if (!name) return { error: 'name is required' }
+    value.name = name
+  }
+  if (body.target_amount !== undefined || !partial) {
+    const target = parseAmount(body.target_amount)
+    if (target === null || Number(target) <= 0)
+      return { error: 'target_amount must be a positive number' }
+    value.targetAmount = target
+  }
+  if (body.target_date !== undefined) {
+    const date = body.target_date
+    if (date !== null && (typeof date !== 'string' || !isCalendarDate(date)))
+      return { error: 'target_date must be a date (YYYY-MM-DD)' }
+    value.targetDate = date
+  } else if (!partial) {
+    value.targetDate = null
+  }
+  return { value }
+}
+
+export type GoalStatus =
+  | 'met'
+  | 'past_due'
+  | 'on_track'
+  | 'behind'
+  | 'no_deadline'
+
+export interface GoalProgress {
+  balance: string
+  target: string
+  /** Zero once the goal is met. */
+  remaining: string
+  /** Share of the target reached, 0–100. */
+  percent: number
+  status: GoalStatus
+  /** Null without a deadline; negative once it has passed. */
+  daysRemaining: number | null
+  /** Average daily net change over the last PACE_WINDOW_DAYS. */
+  pacePerDay: number
+  /** Balance on the target date at the current pace; null without one. */
+  projectedBalance: number | null
+}
+
+/**
+ * Compares an account's balance with a goal. A goal is `met` as soon as
+ * the balance reaches the target, whatever the date; otherwise one whose
+ * date has passed is `past_due`, and an open one is `on_track` when the
+ * current pace reaches the target by the date.
+ */
+export function goalProgress(input: {
+  balance: string
+  target: string
+  remaining: string
+  recentNet: string
+  targetDate: string | null
+  today: string
+}): GoalProgress {
+  const balance = Number(input.balance)
+  const target = Number(input.target)
+  const pacePerDay =
+    Math.round((Number(input.recentNet) / PACE_WINDOW_DAYS) * 100) / 100
+  const percent = Math.min(
+    100,
+    Math.max(0, Math.round((balance / target) * 10_000) / 100),
+  )
+  const daysRemaining =
+    input.targetDate === null
+      ? null
+      : Math.round(
+          (Date.parse(`${input.targetDate}T00:00:00Z`) -
+            Date.parse(`${input.today}T00:00:00Z`)) /
+            DAY_MS,
+        )
+  const projectedBalance =
+    daysRemaining === null
+      ? null
+      : Math.round((balance + pacePerDay * Math.max(daysRemaining, 0)) * 100) /
+        100
+
+  let status: GoalStatus
+  if (balance >= target) status = 'met'
+  else if (daysRemaining === null) status = 'no_deadline'
+  else if (daysRemaining < 0) status = 'past_due'
+  else status = projectedBalance! >= target ? 'on_track' : 'behind'
+
+  return {
+    balance: input.balance,
+    target: input.target,
+    remaining: input.remaining,
+    percent,
+    status,
+    daysRemaining,
+    pacePerDay,
+    projectedBalance,
+  }
+}
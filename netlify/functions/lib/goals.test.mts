@@ -0,0 +1,94 @@
+import { describe, expect, it } from 'vitest'
+import { goalProgress, parseGoalFields } from './goals.mts'
+
+describe('parseGoalFields', () => {
+  it('requires a name and a positive target on create', () => {
+    expect(
+      parseGoalFields({ name: ' Holiday ', target_amount: '1500' }, false),
+    ).toEqual({
+      value: { name: 'Holiday', targetAmount: '1500', targetDate: null },
+    })
+    expect(parseGoalFields({ target_amount: 10 }, false)).toEqual({
+      error: 'name is required',
+    })
+    expect(parseGoalFields({ name: 'x', target_amount: '-5' }, false)).toEqual(
+      { error: 'target_amount must be a positive number' },
+    )
+  })
+
+  it('checks only the given fields on update', () => {
+    expect(parseGoalFields({ target_date: null }, true)).toEqual({
+      value: { targetDate: null },
+    })
+    expect(parseGoalFields({ target_date: '2026-02-30' }, true)).toEqual({
+      error: 'target_date must be a date (YYYY-MM-DD)',
+    })
+  })
+})
+
+describe('goalProgress', () => {
+  const base = {
+    balance: '600.0000',
+    target: '1000.0000',
+    remaining: '400.0000',
+    today: '2026-01-01',
+  }
+
+  it('is on track when the current pace reaches the target in time', () => {
+    // 900 over 90 days is 10 a day; 600 + 10 * 59 = 1190.
+    const progress = goalProgress({
+      ...base,
+      recentNet: '900',
+      targetDate: '2026-03-01',
+    })
+    expect(progress).toMatchObject({
+      percent: 60,
+      status: 'on_track',
+      daysRemaining: 59,
+      pacePerDay: 10,
+      projectedBalance: 1190,
+    })
+  })
+
+  it('is behind when the pace falls short', () => {
+    const progress = goalProgress({
+      ...base,
+      recentNet: '90',
+      targetDate: '2026-03-01',
+    })
+    expect(progress.status).toBe('behind')
+  })
+
+  it('reports met goals regardless of the date', () => {
+    const progress = goalProgress({
+      ...base,
+      balance: '1200',
+      remaining: '0',
+      recentNet: '0',
+      targetDate: '2025-06-01',
+    })
+    expect(progress).toMatchObject({ status: 'met', percent: 100 })
+  })
+
+  it('reports goals whose date has passed as past due', () => {
+    const progress = goalProgress({
+      ...base,
+      recentNet: '5000',
+      targetDate: '2025-12-01',
+    })
+    expect(progress).toMatchObject({ status: 'past_due', daysRemaining: -31 })
+  })
+
+  it('has no projection without a deadline', () => {
+    const progress = goalProgress({
+      ...base,
+      recentNet: '900',
+      targetDate: null,
+    })
+    expect(progress).toMatchObject({
+      status: 'no_deadline',
+      daysRemaining: null,
+      projectedBalance: null,
+    })
+  })
+})
@@ -0,0 +1,356 @@
+import type { Context } from '@netlify/functions'
+import { clientIp } from './client-ip.mts'
+import { handlePreflight, withCors } from './cors.mts'
+import { isDbTimeout } from './db.mts'
+import { withFeatureFlags } from './features.mts'
+import { jsonReplacer } from './json-fields.mts'
+import { observeDuration, observeSlowRequest, routeOf } from './metrics.mts'
+import { isWriteBlocked } from './read-only.mts'
+import { withSecureHeaders } from './secure-headers.mts'
+import { checkUrlLimits } from './url-limits.mts'
+
+/** API contract version advertised on every response. */
+export const API_VERSION = process.env.API_VERSION || '1'
+
+const replacer = jsonReplacer()
+
+/**
+ * A JSON response. Timestamps are formatted per JSON_TIME_PRECISION and
+ * empty optional fields follow JSON_EMPTY_FIELDS.
+ */
+export function json<T>(data: T, status = 200) {
+  return new Response(JSON.stringify(data, replacer), {
+    status,
+    headers: { 'Content-Type': 'application/json' },
+  })
+}
+
+/**
+ * A JSON array response written element by element as `batches` yields
+ * rows, so a huge list never has to be held in memory or serialized in one
+ * piece. Once the 200 is sent a failure can no longer change the status:
+ * it is logged and the body is cut off, which clients see as invalid JSON
+ * rather than a silently short list.
+ */
+export function streamJsonArray(
+  req: Request,
+  context: Context,
+  batches: AsyncIterable<unknown[]>,
+): Response {
+  return streamJson(req, context, '[', batches, ']')
+}
+
+/**
+ * Like streamJsonArray, but the array is the `key` member of an object
+ * whose other members, `head`, are written before it.
+ */
+export function streamJsonObject(
+  req: Request,
+  context: Context,
+  head: Record<string, unknown>,
+  key: string,
+  batches: AsyncIterable<unknown[]>,
+): Response {
+  const members = JSON.stringify(head, replacer).slice(1, -1)
+  const open = `{${members}${members ? ',' : ''}${JSON.stringify(key)}:[`
+  return streamJson(req, context, open, batches, ']}')
+}
+
+function streamJson(
+  req: Request,
+  context: Context,
+  open: string,
+  batches: AsyncIterable<unknown[]>,
+  close: string,
+): Response {
+  const encoder = new TextEncoder()
+  const iterator = batches[Symbol.asyncIterator]()
+  let first = true
+  const body = new ReadableStream<Uint8Array>({
+    start(controller) {
+      controller.enqueue(encoder.encode(open))
+    },
+    async pull(controller) {
+      try {
+        const next = await iterator.next()
+        if (next.done) {
+          controller.enqueue(encoder.encode(close))
+          controller.close()
+          return
+        }
+        let chunk = ''
+        for (const row of next.value) {
+          chunk += (first ? '' : ',') + JSON.stringify(row, replacer)
+          first = false
+        }
+        if (chunk) controller.enqueue(encoder.encode(chunk))
+      } catch (e) {
+        console.error(`[${clientIp(req, context.ip)}]`, e)
+        controller.error(e)
+      }
+    },
+    async cancel() {
+      await iterator.return?.()
+    },
+  })
+  return new Response(body, {
+    headers: { 'Content-Type': 'application/json' },
+  })
+}
+
+/**
+ * Whether the client sent `Prefer: return=minimal` (RFC 7240), asking for
+ * only the id of a created or updated resource instead of all of it.
+ */
+export function prefersMinimal(req: Request): boolean {
+  const prefs = (req.headers.get('Prefer') ?? '').split(',')
+  return prefs.some((pref) => {
+    const [token] = pref.split(';')
+    return token.replace(/[\s"]/g, '').toLowerCase() === 'return=minimal'
+  })
+}
+
+/**
+ * The body of a create or update: the resource itself, or just its id
+ * and a Location to fetch it from when the client prefers minimal.
+ */
+function representation(
+  req: Request,
+  location: string,
+  data: { id: unknown },
+  status: number,
+) {
+  const minimal = prefersMinimal(req)
+  const res = json(minimal ? { id: data.id } : data, status)
+  if (minimal) {
+    res.headers.set('Location', location)
+    res.headers.set('Preference-Applied', 'return=minimal')
+  }
+  return res
+}
+
+/**
+ * A 201 response whose Location points at the new resource. `path` is
+ * resolved against the request URL, so `bank_account?id=…` names the sibling
+ * function wherever the API is mounted. With `Prefer: return=minimal` the
+ * body is only the id.
+ */
+export function created<T extends { id: unknown }>(
+  req: Request,
+  path: string,
+  data: T,
+) {
+  const location = new URL(path, req.url).toString()
+  const res = representation(req, location, data, 201)
+  res.headers.set('Location', location)
+  return res
+}
+
+/**
+ * A 200 response to an update. With `Prefer: return=minimal` the body is
+ * only the id, and Location is the URL the update was sent to.
+ */
+export function saved<T extends { id: unknown }>(req: Request, data: T) {
+  return representation(req, req.url, data, 200)
+}
+
+export function err(message: string, status: number) {
+  return json({ error: message }, status)
+}
+
+/**
+ * The response to a DELETE whose target does not exist. By default a 404;
+ * with `?idempotent=true` the same empty 204 a successful delete gets, so
+ * a client retrying a delete that already went through sees success.
+ */
+export function deleteMissing(url: URL) {
+  if (url.searchParams.get('idempotent') === 'true')
+    return new Response(null, { status: 204 })
+  return err('Not found', 404)
+}
+
+/**
+ * The response for an error a handler did not expect, logged with the
+ * caller's address. A database that did not answer in time is a 503, so
+ * clients know to retry rather than report a bug.
+ */
+export function serverError(req: Request, context: Context, e: unknown) {
+  console.error(`[${clientIp(req, context.ip)}]`, e)
+  if (isDbTimeout(e)) return err('database unavailable', 503)
+  return err('Internal server error', 500)
+}
+
+/**
+ * Problems with request fields, keyed by field name, e.g.
+ * `{ name: 'required', type: 'invalid' }`.
+ */
+export type FieldErrors = Record<string, string>
+
+/** Longest string echoed back in an error, in characters. */
+export const MAX_ECHO_LENGTH = 64
+
+/**
+ * A client value made safe to repeat in an error response: long strings
+ * are cut short and objects and arrays are only named, so a huge or
+ * nested body is never reflected back whole.
+ */
+export function echoValue(value: unknown): unknown {
+  if (typeof value === 'string') {
+    const chars = [...value]
+    return chars.length > MAX_ECHO_LENGTH
+      ? `${chars.slice(0, MAX_ECHO_LENGTH).join('')}…`
+      : value
+  }
+  if (value === null || ['number', 'boolean'].includes(typeof value))
+    return value
+  return `(${jsonType(value)})`
+}
+
+/** A value outside a fixed set, as received, with the values allowed. */
+export interface InvalidChoice {
+  received: unknown
+  allowed: readonly string[]
+}
+
+export function invalidChoice(
+  received: unknown,
+  allowed: readonly string[],
+): InvalidChoice {
+  return { received: echoValue(received), allowed }
+}
+
+/** InvalidChoice details for fields reported as `invalid`, by field name. */
+export type FieldDetails = Record<string, InvalidChoice>
+
+/**
+ * A 400 reporting every invalid field at once, so form UIs can flag them
+ * together instead of one round trip per mistake. `details` shows client
+ * developers what was received for fields limited to a fixed set.
+ */
+export function validationErr(fields: FieldErrors, details?: FieldDetails) {
+  const error =
+    details && Object.keys(details).length
+      ? { code: 'VALIDATION', fields, details }
+      : { code: 'VALIDATION', fields }
+  return json({ error }, 400)
+}
+
+/**
+ * A 400 for a single value outside a fixed set, e.g. `{"error": "type
+ * must be income or expense", "received": "transfer", "allowed":
+ * ["income", "expense"]}`.
+ */
+export function choiceErr(
+  message: string,
+  received: unknown,
+  allowed: readonly string[],
+) {
+  return json({ error: message, ...invalidChoice(received, allowed) }, 400)
+}
+
+export type JsonType = 'string' | 'number' | 'boolean' | 'array' | 'object'
+
+/** Accepted JSON types per body field. */
+export type BodySchema = Record<string, JsonType | readonly JsonType[]>
+
+const TYPE_NAMES: Record<JsonType, string> = {
+  string: 'a string',
+  number: 'a number',
+  boolean: 'a boolean',
+  array: 'an array',
+  object: 'an object',
+}
+
+function jsonType(value: unknown): JsonType {
+  if (Array.isArray(value)) return 'array'
+  return typeof value as JsonType
+}
+
+/**
+ * Reads a JSON object body and checks the type of each field in `schema`,
+ * so a well-formed body with a wrong type is reported by field (`field
+ * 'amount' must be a number`) rather than as invalid JSON. Absent and null
+ * fields pass; handlers decide whether they are required or clearable.
+ * Fields outside the schema are left to the handler.
+ */
+export async function readJson<T>(
+  req: Request,
+  schema: BodySchema,
+): Promise<{ body: T } | { error: string }> {
+  let body: unknown
+  try {
+    body = await req.json()
+  } catch {
+    return { error: 'Invalid JSON' }
+  }
+  if (jsonType(body) !== 'object' || body === null)
+    return { error: 'request body must be a JSON object' }
+  for (const [field, accepted] of Object.entries(schema)) {
+    const value = (body as Record<string, unknown>)[field]
+    if (value == null) continue
+    const types = typeof accepted === 'string' ? [accepted] : accepted
+    if (!types.includes(jsonType(value))) {
+      const expected = types.map((t) => TYPE_NAMES[t]).join(' or ')
+      return { error: `field '${field}' must be ${expected}` }
+    }
+  }
+  return { body: body as T }
+}
+
+/** Adds the X-API-Version header to a response. */
+export function withApiVersion(res: Response): Response {
+  const headers = new Headers(res.headers)
+  headers.set('X-API-Version', API_VERSION)
+  return new Response(res.body, { status: res.status, headers })
+}
+
+type Handler = (req: Request, context: Context) => Promise<Response>
+
+/**
+ * Drops the body of a GET response for a HEAD request, keeping the headers
+ * and reporting the length the body would have had.
+ */
+export async function withoutBody(res: Response): Promise<Response> {
+  const { byteLength } = await res.arrayBuffer()
+  const headers = new Headers(res.headers)
+  headers.set('Content-Length', String(byteLength))
+  return new Response(null, { status: res.status, headers })
+}
+
+/**
+ * Wraps an API function with the behaviour shared by every endpoint: URL
+ * length limits, CORS preflight handling, read-only mode, HEAD support, CORS and security
+ * headers, the API version and applied feature flag headers, request
+ * duration metrics and the slow request log. HEAD requests are served by the GET branch of the handler,
+ * so endpoints only need to check for GET.
+ */
+export function apiHandler(handler: Handler): Handler {
+  return async (req, context) => {
+    const start = performance.now()
+    const head = req.method === 'HEAD'
+    const request = head ? new Request(req, { method: 'GET' }) : req
+    const tooLong = checkUrlLimits(request)
+    const res = tooLong
+      ? err(tooLong.error, tooLong.status)
+      : (handlePreflight(request) ??
+        (isWriteBlocked(request)
+          ? err('read-only mode', 403)
+          : await handler(request, context)))
+    const out = withFeatureFlags(
+      req,
+      withApiVersion(withSecureHeaders(req, withCors(req, res))),
+    )
+    const final = head ? await withoutBody(out) : out
+    const durationMs = performance.now() - start
+    const route = routeOf(req.url)
+    observeDuration(route, durationMs)
+    observeSlowRequest({
+      route,
+      method: req.method,
+      status: final.status,
+      durationMs: Math.round(durationMs),
+      at: new Date().toISOString(),
+    })
+    return final
+  }
+}
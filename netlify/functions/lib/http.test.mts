@@ -0,0 +1,290 @@
+import { describe, expect, it, vi } from 'vitest'
+import type { Context } from '@netlify/functions'
+import { DbTimeoutError } from './db.mts'
+import {
+  API_VERSION,
+  MAX_ECHO_LENGTH,
+  apiHandler,
+  choiceErr,
+  created,
+  echoValue,
+  err,
+  json,
+  readJson,
+  saved,
+  serverError,
+  streamJsonArray,
+  streamJsonObject,
+} from './http.mts'
+
+const context = {} as Context
+
+describe('apiHandler', () => {
+  const handler = apiHandler(async () => err('Not found', 404))
+
+  it('adds the API version and CORS headers to handler responses', async () => {
+    const res = await handler(
+      new Request('https://example.com/api', {
+        headers: { origin: 'https://app.example.com' },
+      }),
+      context,
+    )
+    expect(res.status).toBe(404)
+    expect(res.headers.get('X-API-Version')).toBe(API_VERSION)
+    expect(res.headers.get('Access-Control-Allow-Origin')).toBe(
+      'https://app.example.com',
+    )
+    expect(await res.json()).toEqual({ error: 'Not found' })
+  })
+
+  it('answers preflight requests without calling the handler', async () => {
+    const res = await handler(
+      new Request('https://example.com/api', { method: 'OPTIONS' }),
+      context,
+    )
+    expect(res.status).toBe(204)
+    expect(res.headers.get('X-API-Version')).toBe(API_VERSION)
+  })
+
+  it('rejects an over-long URL before calling the handler', async () => {
+    const inner = vi.fn(async () => json([]))
+    const ids = 'a1b2c3d4,'.repeat(1000)
+    const res = await apiHandler(inner)(
+      new Request(`https://example.com/api/bank_accounts?ids=${ids}`),
+      context,
+    )
+    expect(res.status).toBe(414)
+    expect(res.headers.get('X-API-Version')).toBe(API_VERSION)
+    expect(inner).not.toHaveBeenCalled()
+  })
+})
+
+describe('HEAD requests', () => {
+  const handler = apiHandler(async (req) =>
+    req.method === 'GET'
+      ? json([{ id: 'acc-1', name: 'Checking' }])
+      : err('Method not allowed', 405),
+  )
+
+  it('are served by the GET branch without a body', async () => {
+    const res = await handler(
+      new Request('https://example.com/api', { method: 'HEAD' }),
+      context,
+    )
+    expect(res.status).toBe(200)
+    expect(await res.text()).toBe('')
+    expect(res.headers.get('Content-Type')).toBe('application/json')
+    expect(res.headers.get('X-API-Version')).toBe(API_VERSION)
+    expect(res.headers.get('Content-Length')).toBe(
+      String(JSON.stringify([{ id: 'acc-1', name: 'Checking' }]).length),
+    )
+  })
+
+  it('keep the status of error responses', async () => {
+    const notFound = apiHandler(async () => err('Not found', 404))
+    const res = await notFound(
+      new Request('https://example.com/api', { method: 'HEAD' }),
+      context,
+    )
+    expect(res.status).toBe(404)
+    expect(await res.text()).toBe('')
+  })
+})
+
+describe('readJson', () => {
+  const post = (body: string) =>
+    new Request('https://example.com/api', { method: 'POST', body })
+
+  it('returns the parsed body when field types match', async () => {
+    expect(
+      await readJson(post('{"name":"Cash","amount":"1.50","extra":1}'), {
+        name: 'string',
+        amount: ['number', 'string'],
+      }),
+    ).toEqual({ body: { name: 'Cash', amount: '1.50', extra: 1 } })
+  })
+
+  it('lets absent and null fields through', async () => {
+    expect(
+      await readJson(post('{"group_id":null}'), {
+        name: 'string',
+        group_id: 'string',
+      }),
+    ).toEqual({ body: { group_id: null } })
+  })
+
+  it('names the field with the wrong type', async () => {
+    expect(
+      await readJson(post('{"amount":"abc"}'), { amount: 'number' }),
+    ).toEqual({ error: "field 'amount' must be a number" })
+    expect(await readJson(post('{"ids":{}}'), { ids: 'array' })).toEqual({
+      error: "field 'ids' must be an array",
+    })
+    expect(
+      await readJson(post('{"amount":true}'), { amount: ['number', 'string'] }),
+    ).toEqual({ error: "field 'amount' must be a number or a string" })
+  })
+
+  it('distinguishes malformed JSON from a non-object body', async () => {
+    expect(await readJson(post('{'), {})).toEqual({ error: 'Invalid JSON' })
+    expect(await readJson(post('[1]'), {})).toEqual({
+      error: 'request body must be a JSON object',
+    })
+    expect(await readJson(post('null'), {})).toEqual({
+      error: 'request body must be a JSON object',
+    })
+  })
+})
+
+describe('serverError', () => {
+  const context = { ip: '127.0.0.1' } as Context
+  const req = new Request('https://example.com/api/bank_accounts')
+
+  it('is a 503 when the database timed out', async () => {
+    const res = serverError(req, context, new DbTimeoutError(15000))
+    expect(res.status).toBe(503)
+    expect(await res.json()).toEqual({ error: 'database unavailable' })
+  })
+
+  it('is a 500 otherwise', () => {
+    expect(serverError(req, context, new Error('boom')).status).toBe(500)
+  })
+})
+
+describe('streamJsonArray', () => {
+  const context = { ip: '127.0.0.1' } as Context
+  const req = new Request('https://example.com/api/transactions')
+
+  async function* batches(...chunks: unknown[][]) {
+    yield* chunks
+  }
+
+  it('joins every batch into one JSON array', async () => {
+    const res = streamJsonArray(
+      req,
+      context,
+      batches([{ id: 1 }, { id: 2 }], [], [{ id: 3 }]),
+    )
+    expect(res.headers.get('Content-Type')).toBe('application/json')
+    expect(await res.json()).toEqual([{ id: 1 }, { id: 2 }, { id: 3 }])
+  })
+
+  it('writes an empty array when there are no rows', async () => {
+    const res = streamJsonArray(req, context, batches())
+    expect(await res.text()).toBe('[]')
+  })
+
+  it('cuts the body off when a batch fails', async () => {
+    const error = vi.spyOn(console, 'error').mockImplementation(() => {})
+    async function* failing() {
+      yield [{ id: 1 }]
+      throw new Error('connection lost')
+    }
+    const res = streamJsonArray(req, context, failing())
+    await expect(res.text()).rejects.toThrow('connection lost')
+    expect(error).toHaveBeenCalled()
+    error.mockRestore()
+  })
+})
+
+describe('streamJsonObject', () => {
+  const context = { ip: '127.0.0.1' } as Context
+  const req = new Request('https://example.com/api/export_all')
+
+  async function* batches(...chunks: unknown[][]) {
+    yield* chunks
+  }
+
+  it('writes the head members before the streamed array', async () => {
+    const res = streamJsonObject(
+      req,
+      context,
+      { version: 1, tags: ['a'] },
+      'accounts',
+      batches([{ id: 1 }], [{ id: 2 }]),
+    )
+    expect(await res.json()).toEqual({
+      version: 1,
+      tags: ['a'],
+      accounts: [{ id: 1 }, { id: 2 }],
+    })
+  })
+
+  it('handles an empty head and no rows', async () => {
+    const res = streamJsonObject(req, context, {}, 'accounts', batches())
+    expect(await res.text()).toBe('{"accounts":[]}')
+  })
+})
+
+describe('echoValue', () => {
+  it('repeats short strings and scalars', () => {
+    expect(echoValue('transfer')).toBe('transfer')
+    expect(echoValue(42)).toBe(42)
+    expect(echoValue(null)).toBeNull()
+  })
+
+  it('cuts long strings and only names objects and arrays', () => {
+    const echoed = echoValue('x'.repeat(10_000)) as string
+    expect(echoed).toBe(`${'x'.repeat(MAX_ECHO_LENGTH)}…`)
+    expect(echoValue({ nested: 'x'.repeat(10_000) })).toBe('(object)')
+    expect(echoValue(['income'])).toBe('(array)')
+  })
+})
+
+describe('choiceErr', () => {
+  it('reports the received value beside the allowed ones', async () => {
+    const res = choiceErr('type must be income or expense', 'transfer', [
+      'income',
+      'expense',
+    ])
+    expect(res.status).toBe(400)
+    expect(await res.json()).toEqual({
+      error: 'type must be income or expense',
+      received: 'transfer',
+      allowed: ['income', 'expense'],
+    })
+  })
+})
+
+describe('Prefer: return=minimal', () => {
+  const row = { id: 'acc-1', name: 'Checking' }
+
+  function request(prefer?: string) {
+    return new Request('https://example.com/api/bank_account?id=acc-1', {
+      method: 'PATCH',
+      headers: prefer ? { Prefer: prefer } : {},
+    })
+  }
+
+  it('returns the full resource by default', async () => {
+    for (const prefer of [undefined, 'return=representation']) {
+      const res = saved(request(prefer), row)
+      expect(await res.json()).toEqual(row)
+      expect(res.headers.get('Location')).toBeNull()
+      expect(res.headers.get('Preference-Applied')).toBeNull()
+    }
+  })
+
+  it('returns only the id and where to find the resource', async () => {
+    const res = saved(request('respond-async, return=minimal'), row)
+    expect(res.status).toBe(200)
+    expect(await res.json()).toEqual({ id: 'acc-1' })
+    expect(res.headers.get('Location')).toBe(
+      'https://example.com/api/bank_account?id=acc-1',
+    )
+    expect(res.headers.get('Preference-Applied')).toBe('return=minimal')
+  })
+
+  it('keeps the 201 and Location of a create', async () => {
+    const req = new Request('https://example.com/api/bank_accounts', {
+      method: 'POST',
+      headers: { Prefer: 'return=minimal' },
+    })
+    const res = created(req, 'bank_account?id=acc-1', row)
+    expect(res.status).toBe(201)
+    expect(await res.json()).toEqual({ id: 'acc-1' })
+    expect(res.headers.get('Location')).toBe(
+      'https://example.com/api/bank_account?id=acc-1',
+    )
+  })
+})
@@ -0,0 +1,34 @@
+export const ID_FORMATS = ['uuidv4', 'uuidv7'] as const
+export type IdFormat = (typeof ID_FORMATS)[number]
+
+/** Parses ID_FORMAT; anything other than `uuidv7` keeps random v4 ids. */
+export function parseIdFormat(raw: string | undefined): IdFormat {
+  return raw?.trim().toLowerCase() === 'uuidv7' ? 'uuidv7' : 'uuidv4'
+}
+
+/** Deployment-wide id format for new accounts and transactions. */
+export const ID_FORMAT = parseIdFormat(process.env.ID_FORMAT)
+
+/**
+ * Builds a UUIDv7 (RFC 9562): a 48-bit millisecond timestamp followed by
+ * random bits, so ids sort by creation time and index inserts stay local.
+ */
+export function uuidv7(now = Date.now()): string {
+  const bytes = crypto.getRandomValues(new Uint8Array(16))
+  let ts = now
+  for (let i = 5; i >= 0; i--) {
+    bytes[i] = ts % 256
+    ts = Math.floor(ts / 256)
+  }
+  bytes[6] = (bytes[6] & 0x0f) | 0x70
+  bytes[8] = (bytes[8] & 0x3f) | 0x80
+  const hex = Array.from(bytes, (b) => b.toString(16).padStart(2, '0')).join(
+    '',
+  )
+  return `${hex.slice(0, 8)}-${hex.slice(8, 12)}-${hex.slice(12, 16)}-${hex.slice(16, 20)}-${hex.slice(20)}`
+}
+
+/** Generates a new primary key in the configured format. */
+export function newId(format: IdFormat = ID_FORMAT): string {
+  return format === 'uuidv7' ? uuidv7() : crypto.randomUUID()
+}
@@ -0,0 +1,35 @@
+import { describe, expect, it } from 'vitest'
+import { newId, parseIdFormat, uuidv7 } from './ids.mts'
+import { isUuid } from './params.mts'
+
+describe('parseIdFormat', () => {
+  it('defaults to uuidv4', () => {
+    expect(parseIdFormat(undefined)).toBe('uuidv4')
+    expect(parseIdFormat('')).toBe('uuidv4')
+    expect(parseIdFormat('ulid')).toBe('uuidv4')
+    expect(parseIdFormat(' UUIDv7 ')).toBe('uuidv7')
+  })
+})
+
+describe('uuidv7', () => {
+  it('produces valid version 7 UUIDs', () => {
+    const id = uuidv7()
+    expect(isUuid(id)).toBe(true)
+    expect(id[14]).toBe('7')
+    expect(['8', '9', 'a', 'b']).toContain(id[19])
+  })
+
+  it('sorts by creation time', () => {
+    const earlier = uuidv7(1_700_000_000_000)
+    const later = uuidv7(1_700_000_000_001)
+    expect(earlier < later).toBe(true)
+    expect(earlier.slice(0, 13)).toBe('018bcfe5-6800')
+  })
+})
+
+describe('newId', () => {
+  it('generates ids in the requested format', () => {
+    expect(newId('uuidv4')[14]).toBe('4')
+    expect(newId('uuidv7')[14]).toBe('7')
+  })
+})
@@ -0,0 +1,72 @@
+import { timeReplacer } from './time-format.mts'
+import type { TimePrecision } from './time-format.mts'
+
+export const EMPTY_FIELD_POLICIES = ['omit', 'null'] as const
+
+export type EmptyFieldPolicy = (typeof EMPTY_FIELD_POLICIES)[number]
+
+/**
+ * Parses JSON_EMPTY_FIELDS. Unset or unknown values fall back to `omit`;
+ * `null` keeps empty optional fields in responses as `null` (or `[]`).
+ */
+export function parseEmptyFieldPolicy(
+  raw: string | undefined,
+): EmptyFieldPolicy {
+  const value = raw?.trim().toLowerCase()
+  return (EMPTY_FIELD_POLICIES as readonly string[]).includes(value ?? '')
+    ? (value as EmptyFieldPolicy)
+    : 'omit'
+}
+
+export const JSON_EMPTY_FIELDS = parseEmptyFieldPolicy(
+  process.env.JSON_EMPTY_FIELDS,
+)
+
+/**
+ * Model fields that may be left unset, and so may be omitted from
+ * responses when empty. Everything else is a core field and always sent,
+ * null or not: ids, amounts, dates, descriptions, types, flags, and values
+ * such as `asOf` or a report's `categoryId` whose null carries meaning.
+ */
+export const OPTIONAL_FIELDS: ReadonlySet<string> = new Set([
+  // transactions
+  'category_id',
+  'currency',
+  'import_batch_id',
+  'original_amount',
+  'tags',
+  'transfer_group',
+  // accounts
+  'default_transaction_type',
+  'group_id',
+  'last_used_at',
+  // savings goals
+  'target_date',
+])
+
+function isEmpty(value: unknown): boolean {
+  return value == null || (Array.isArray(value) && value.length === 0)
+}
+
+/**
+ * JSON.stringify replacer for every API and webhook body: formats
+ * timestamps per JSON_TIME_PRECISION and, under the `omit` policy, drops
+ * OPTIONAL_FIELDS that are null or an empty array. Only object properties
+ * are dropped; array elements are never touched.
+ */
+export function jsonReplacer(
+  policy: EmptyFieldPolicy = JSON_EMPTY_FIELDS,
+  precision?: TimePrecision,
+) {
+  const times = timeReplacer(precision)
+  return function (this: Record<string, unknown>, key: string, value: unknown) {
+    if (
+      policy === 'omit' &&
+      !Array.isArray(this) &&
+      OPTIONAL_FIELDS.has(key) &&
+      isEmpty(value)
+    )
+      return undefined
+    return times.call(this, key, value)
+  }
+}
@@ -0,0 +1,124 @@
+import { describe, expect, it } from 'vitest'
+import { jsonReplacer, parseEmptyFieldPolicy } from './json-fields.mts'
+
+const minimalTransaction = {
+  id: 'tx-1',
+  account_id: 'acc-1',
+  seq: '1',
+  amount: '12.5000',
+  date: new Date('2025-02-01T09:30:00Z'),
+  description: '',
+  type: 'expense',
+  transfer_group: null,
+  cleared: false,
+  status: 'posted',
+  scheduled: false,
+  import_batch_id: null,
+  category_id: null,
+  tags: [],
+}
+
+const fullTransaction = {
+  ...minimalTransaction,
+  description: 'Coffee',
+  transfer_group: '7d9c8e3a-1f2b-4c5d-8e9f-0a1b2c3d4e5f',
+  import_batch_id: '1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d',
+  category_id: '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60',
+  tags: ['work'],
+}
+
+const minimalAccount = {
+  id: 'acc-1',
+  name: 'Wallet',
+  type: 'cash',
+  currency: 'USD',
+  sort_order: 0,
+  default_transaction_type: null,
+  last_used_at: null,
+  group_id: null,
+  opening_balance: '0.0000',
+  allow_negative: true,
+}
+
+function keys(value: unknown, policy: 'omit' | 'null') {
+  return Object.keys(JSON.parse(JSON.stringify(value, jsonReplacer(policy))))
+}
+
+describe('jsonReplacer', () => {
+  it('omits empty optional transaction fields but keeps core ones', () => {
+    expect(keys(minimalTransaction, 'omit')).toEqual([
+      'id',
+      'account_id',
+      'seq',
+      'amount',
+      'date',
+      'description',
+      'type',
+      'cleared',
+      'status',
+      'scheduled',
+    ])
+  })
+
+  it('sends every field of a fully populated transaction', () => {
+    expect(keys(fullTransaction, 'omit')).toEqual(Object.keys(fullTransaction))
+  })
+
+  it('omits empty optional account fields', () => {
+    expect(keys(minimalAccount, 'omit')).toEqual([
+      'id',
+      'name',
+      'type',
+      'currency',
+      'sort_order',
+      'opening_balance',
+      'allow_negative',
+    ])
+    const full = {
+      ...minimalAccount,
+      default_transaction_type: 'expense',
+      last_used_at: new Date('2025-02-01T09:30:00Z'),
+      group_id: '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60',
+    }
+    expect(keys(full, 'omit')).toEqual(Object.keys(full))
+  })
+
+  it('keeps empty optional fields as null with the null policy', () => {
+    expect(
+      JSON.parse(JSON.stringify(minimalTransaction, jsonReplacer('null'))),
+    ).toMatchObject({
+      transfer_group: null,
+      import_batch_id: null,
+      category_id: null,
+      tags: [],
+    })
+  })
+
+  it('leaves nulls in core fields, nested objects and arrays alone', () => {
+    const body = JSON.stringify(
+      {
+        asOf: null,
+        data: [null, { ...minimalTransaction, category_id: null }],
+      },
+      jsonReplacer('omit'),
+    )
+    const parsed = JSON.parse(body)
+    expect(parsed.asOf).toBeNull()
+    expect(parsed.data[0]).toBeNull()
+    expect(parsed.data[1]).not.toHaveProperty('category_id')
+  })
+
+  it('still formats timestamps', () => {
+    expect(
+      JSON.parse(JSON.stringify(minimalTransaction, jsonReplacer('omit'))).date,
+    ).toBe('2025-02-01T09:30:00Z')
+  })
+})
+
+describe('parseEmptyFieldPolicy', () => {
+  it('defaults to omit', () => {
+    expect(parseEmptyFieldPolicy(undefined)).toBe('omit')
+    expect(parseEmptyFieldPolicy('always')).toBe('omit')
+    expect(parseEmptyFieldPolicy(' NULL ')).toBe('null')
+  })
+})
@@ -0,0 +1,53 @@
+/**
+ * Parses an optional resource cap from the environment. Unset, empty, zero,
+ * or non-numeric values mean "no limit".
+ */
+export function parseLimit(raw: string | undefined): number | null {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value <= 0) return null
+  return value
+}
+
+/**
+ * Whether creating `adding` more resources, one by default, would exceed
+ * the cap.
+ */
+export function limitReached(
+  count: number,
+  limit: number | null,
+  adding = 1,
+): boolean {
+  return limit !== null && count + adding > limit
+}
+
+/**
+ * Absolute cap on the rows a list without pagination returns, so a
+ * pathological account cannot produce an unbounded response.
+ */
+export const MAX_RESULT_ROWS = parseLimit(process.env.MAX_RESULT_ROWS) ?? 10_000
+
+/**
+ * Trims rows fetched with `LIMIT max + 1` back to `max`; the extra row only
+ * shows whether the cap cut anything off.
+ */
+export function capRows<T>(
+  rows: T[],
+  max: number = MAX_RESULT_ROWS,
+): { rows: T[]; truncated: boolean } {
+  if (rows.length <= max) return { rows, truncated: false }
+  return { rows: rows.slice(0, max), truncated: true }
+}
+
+/** Marks a response whose rows were cut off at MAX_RESULT_ROWS. */
+export function markTruncated(res: Response, truncated: boolean): Response {
+  if (truncated) res.headers.set('X-Result-Truncated', 'true')
+  return res
+}
+
+/** Deployment-wide cap on the number of bank accounts. */
+export const MAX_ACCOUNTS = parseLimit(process.env.MAX_ACCOUNTS)
+
+/** Cap on the transactions in any one account, checked on create and import. */
+export const MAX_TRANSACTIONS_PER_ACCOUNT = parseLimit(
+  process.env.MAX_TRANSACTIONS_PER_ACCOUNT,
+)
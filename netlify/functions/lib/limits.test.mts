@@ -0,0 +1,57 @@
+import { describe, expect, it } from 'vitest'
+import { capRows, limitReached, parseLimit } from './limits.mts'
+
+describe('parseLimit', () => {
+  it('parses positive integers', () => {
+    expect(parseLimit('10')).toBe(10)
+    expect(parseLimit(' 3 ')).toBe(3)
+  })
+
+  it('treats unset, zero, and invalid values as no limit', () => {
+    expect(parseLimit(undefined)).toBeNull()
+    expect(parseLimit('')).toBeNull()
+    expect(parseLimit('0')).toBeNull()
+    expect(parseLimit('-5')).toBeNull()
+    expect(parseLimit('1.5')).toBeNull()
+    expect(parseLimit('many')).toBeNull()
+  })
+})
+
+describe('limitReached', () => {
+  it('allows creation below the limit', () => {
+    expect(limitReached(4, 5)).toBe(false)
+  })
+
+  it('blocks creation exactly at the limit', () => {
+    expect(limitReached(5, 5)).toBe(true)
+  })
+
+  it('blocks creation above the limit', () => {
+    expect(limitReached(6, 5)).toBe(true)
+  })
+
+  it('never blocks without a limit', () => {
+    expect(limitReached(1_000_000, null)).toBe(false)
+  })
+
+  it('checks a batch against the room left', () => {
+    expect(limitReached(3, 5, 2)).toBe(false)
+    expect(limitReached(3, 5, 3)).toBe(true)
+  })
+})
+
+describe('capRows', () => {
+  it('keeps rows at or under the cap', () => {
+    expect(capRows([1, 2, 3], 3)).toEqual({
+      rows: [1, 2, 3],
+      truncated: false,
+    })
+  })
+
+  it('drops the probe row past the cap and flags truncation', () => {
+    expect(capRows([1, 2, 3, 4], 3)).toEqual({
+      rows: [1, 2, 3],
+      truncated: true,
+    })
+  })
+})
@@ -0,0 +1,160 @@
+import { parseSlowQueryThreshold } from './db.mts'
+import type { QueryStats } from './db.mts'
+
+/** Upper bounds of the request duration histogram buckets, in ms. */
+export const DURATION_BUCKETS_MS = [
+  5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000,
+] as const
+
+/**
+ * Routes tracked separately; anything beyond is counted as `other` so a
+ * stream of odd paths cannot grow the metrics without bound.
+ */
+export const MAX_ROUTES = 100
+
+export interface DurationHistogram {
+  /** Cumulative counts per DURATION_BUCKETS_MS bound. */
+  buckets: number[]
+  sum: number
+  count: number
+}
+
+/** Request duration histograms for this function instance, by route. */
+export const requestDurations = new Map<string, DurationHistogram>()
+
+/**
+ * The route of a request: the function name, i.e. the last path segment.
+ * Ids travel in the query string, so this is already a template.
+ */
+export function routeOf(url: string): string {
+  return new URL(url).pathname.split('/').filter(Boolean).at(-1) ?? '/'
+}
+
+export function observeDuration(
+  route: string,
+  durationMs: number,
+  histograms: Map<string, DurationHistogram> = requestDurations,
+): void {
+  const key =
+    histograms.has(route) || histograms.size < MAX_ROUTES ? route : 'other'
+  let histogram = histograms.get(key)
+  if (!histogram) {
+    histogram = {
+      buckets: DURATION_BUCKETS_MS.map(() => 0),
+      sum: 0,
+      count: 0,
+    }
+    histograms.set(key, histogram)
+  }
+  DURATION_BUCKETS_MS.forEach((bound, i) => {
+    if (durationMs <= bound) histogram.buckets[i]++
+  })
+  histogram.sum += durationMs
+  histogram.count++
+}
+
+/**
+ * Requests at or above this many ms are kept for `debug_slow`; set with
+ * SLOW_REQUEST_MS, parsed like SLOW_QUERY_MS (default 1000, `0` disables).
+ */
+export const SLOW_REQUEST_MS = parseSlowQueryThreshold(
+  process.env.SLOW_REQUEST_MS,
+)
+
+/** Slow requests remembered per function instance. */
+export const SLOW_REQUEST_CAPACITY = 100
+
+export interface SlowRequest {
+  route: string
+  method: string
+  status: number
+  durationMs: number
+  /** When the request finished, as an ISO timestamp. */
+  at: string
+}
+
+/**
+ * A fixed-size ring buffer of recent slow requests: once full, each new
+ * entry overwrites the oldest, so memory stays bounded however many slow
+ * requests arrive.
+ */
+export class SlowRequestLog {
+  private readonly entries: Array<SlowRequest | undefined>
+  private next = 0
+
+  constructor(readonly capacity = SLOW_REQUEST_CAPACITY) {
+    this.entries = new Array(capacity).fill(undefined)
+  }
+
+  record(entry: SlowRequest): void {
+    this.entries[this.next] = entry
+    this.next = (this.next + 1) % this.capacity
+  }
+
+  /** Up to `limit` of the buffered requests, slowest first. */
+  slowest(limit = this.capacity): SlowRequest[] {
+    return this.entries
+      .filter((entry): entry is SlowRequest => entry !== undefined)
+      .sort((a, b) => b.durationMs - a.durationMs || b.at.localeCompare(a.at))
+      .slice(0, limit)
+  }
+}
+
+/** Recent slow requests for this function instance. */
+export const slowRequests = new SlowRequestLog()
+
+export function observeSlowRequest(
+  entry: SlowRequest,
+  thresholdMs: number = SLOW_REQUEST_MS,
+  log: SlowRequestLog = slowRequests,
+): void {
+  if (thresholdMs > 0 && entry.durationMs >= thresholdMs) log.record(entry)
+}
+
+function label(value: string): string {
+  return value
+    .replace(/\\/g, '\\\\')
+    .replace(/"/g, '\\"')
+    .replace(/\n/g, '\\n')
+}
+
+/**
+ * Renders request durations and database query counters in the Prometheus
+ * text exposition format.
+ */
+export function formatPrometheus(
+  histograms: Map<string, DurationHistogram>,
+  queries: QueryStats,
+): string {
+  const lines = [
+    '# HELP http_request_duration_ms API request duration in milliseconds.',
+    '# TYPE http_request_duration_ms histogram',
+  ]
+  for (const [route, histogram] of [...histograms].sort(([a], [b]) =>
+    a.localeCompare(b),
+  )) {
+    const r = `route="${label(route)}"`
+    DURATION_BUCKETS_MS.forEach((bound, i) => {
+      lines.push(
+        `http_request_duration_ms_bucket{${r},le="${bound}"} ${histogram.buckets[i]}`,
+      )
+    })
+    lines.push(
+      `http_request_duration_ms_bucket{${r},le="+Inf"} ${histogram.count}`,
+      `http_request_duration_ms_sum{${r}} ${Math.round(histogram.sum)}`,
+      `http_request_duration_ms_count{${r}} ${histogram.count}`,
+    )
+  }
+  lines.push(
+    '# HELP db_queries_total Database queries sent.',
+    '# TYPE db_queries_total counter',
+    `db_queries_total ${queries.total}`,
+    '# HELP db_queries_failed_total Database queries that failed.',
+    '# TYPE db_queries_failed_total counter',
+    `db_queries_failed_total ${queries.failed}`,
+    '# HELP db_queries_in_flight Database queries awaiting a response.',
+    '# TYPE db_queries_in_flight gauge',
+    `db_queries_in_flight ${queries.inFlight}`,
+  )
+  return `${lines.join('\n')}\n`
+}
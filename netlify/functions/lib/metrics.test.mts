@@ -0,0 +1,116 @@
+import { describe, expect, it } from 'vitest'
+import { emptyQueryStats } from './db.mts'
+import type { DurationHistogram, SlowRequest } from './metrics.mts'
+import {
+  MAX_ROUTES,
+  SlowRequestLog,
+  formatPrometheus,
+  observeDuration,
+  observeSlowRequest,
+  routeOf,
+} from './metrics.mts'
+
+describe('routeOf', () => {
+  it('names the function, ignoring the query string', () => {
+    expect(routeOf('https://example.com/api/bank_account?id=acc-1')).toBe(
+      'bank_account',
+    )
+    expect(
+      routeOf('https://example.com/.netlify/functions/transactions/'),
+    ).toBe('transactions')
+  })
+})
+
+describe('observeDuration', () => {
+  it('counts each request in every bucket at or above its duration', () => {
+    const histograms = new Map<string, DurationHistogram>()
+    observeDuration('transactions', 40, histograms)
+    observeDuration('transactions', 3000, histograms)
+    const histogram = histograms.get('transactions')!
+    expect(histogram.count).toBe(2)
+    expect(histogram.sum).toBe(3040)
+    // Bounds: 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000.
+    expect(histogram.buckets).toEqual([0, 0, 0, 1, 1, 1, 1, 1, 1, 2, 2])
+  })
+
+  it('folds routes past the cap into other', () => {
+    const histograms = new Map<string, DurationHistogram>()
+    for (let i = 0; i < MAX_ROUTES; i++) {
+      observeDuration(`r${i}`, 1, histograms)
+    }
+    observeDuration('r0', 1, histograms)
+    observeDuration('extra', 1, histograms)
+    expect(histograms.size).toBe(MAX_ROUTES + 1)
+    expect(histograms.get('r0')!.count).toBe(2)
+    expect(histograms.get('other')!.count).toBe(1)
+  })
+})
+
+function slow(route: string, durationMs: number, second = 0): SlowRequest {
+  return {
+    route,
+    method: 'GET',
+    status: 200,
+    durationMs,
+    at: new Date(Date.UTC(2026, 0, 1, 0, 0, second)).toISOString(),
+  }
+}
+
+describe('SlowRequestLog', () => {
+  it('lists requests slowest first, up to the limit', () => {
+    const log = new SlowRequestLog(10)
+    log.record(slow('transactions', 1500))
+    log.record(slow('reports_summary', 4000))
+    log.record(slow('search', 2200))
+    expect(log.slowest(2).map((r) => r.route)).toEqual([
+      'reports_summary',
+      'search',
+    ])
+  })
+
+  it('overwrites the oldest entry once full', () => {
+    const log = new SlowRequestLog(2)
+    log.record(slow('a', 9000, 1))
+    log.record(slow('b', 1000, 2))
+    log.record(slow('c', 2000, 3))
+    expect(log.slowest().map((r) => r.route)).toEqual(['c', 'b'])
+  })
+})
+
+describe('observeSlowRequest', () => {
+  it('keeps only requests at or above the threshold', () => {
+    const log = new SlowRequestLog(10)
+    observeSlowRequest(slow('fast', 999), 1000, log)
+    observeSlowRequest(slow('slow', 1000), 1000, log)
+    expect(log.slowest().map((r) => r.route)).toEqual(['slow'])
+  })
+
+  it('keeps nothing with a zero threshold', () => {
+    const log = new SlowRequestLog(10)
+    observeSlowRequest(slow('slow', 60_000), 0, log)
+    expect(log.slowest()).toEqual([])
+  })
+})
+
+describe('formatPrometheus', () => {
+  it('renders cumulative buckets, sum and count per route', () => {
+    const histograms = new Map<string, DurationHistogram>()
+    observeDuration('reports_summary', 120, histograms)
+    const text = formatPrometheus(histograms, emptyQueryStats())
+    expect(text).toContain('# TYPE http_request_duration_ms histogram')
+    expect(text).toContain(
+      'http_request_duration_ms_bucket{route="reports_summary",le="100"} 0',
+    )
+    expect(text).toContain(
+      'http_request_duration_ms_bucket{route="reports_summary",le="250"} 1',
+    )
+    expect(text).toContain(
+      'http_request_duration_ms_bucket{route="reports_summary",le="+Inf"} 1',
+    )
+    expect(text).toContain(
+      'http_request_duration_ms_sum{route="reports_summary"} 120',
+    )
+    expect(text).toContain('db_queries_total 0')
+    expect(text.endsWith('\n')).toBe(true)
+  })
+})
@@ -0,0 +1,86 @@
+/** Locale used when the request names none the runtime supports. */
+export const FALLBACK_LOCALE = 'en-US'
+
+/** Reads the `?formatted=true` opt-in for display strings beside amounts. */
+export function wantsFormatted(url: URL): boolean {
+  return url.searchParams.get('formatted') === 'true'
+}
+
+function supportedLocale(tag: string): string | null {
+  try {
+    return Intl.NumberFormat.supportedLocalesOf(tag)[0] ?? null
+  } catch {
+    // Malformed tags throw rather than being reported unsupported.
+    return null
+  }
+}
+
+/**
+ * The locale to format amounts in: `?locale=` when supported, else the
+ * first supported language in Accept-Language by preference, else
+ * FALLBACK_LOCALE.
+ */
+export function requestLocale(req: Request, url: URL): string {
+  const explicit = url.searchParams.get('locale')?.trim()
+  if (explicit) {
+    const locale = supportedLocale(explicit)
+    if (locale) return locale
+  }
+  const ranked = (req.headers.get('accept-language') ?? '')
+    .split(',')
+    .map((part, index) => {
+      const [tag, ...params] = part.trim().split(';')
+      const q = params.find((p) => p.trim().startsWith('q='))
+      const weight = q ? Number(q.trim().slice(2)) : 1
+      return {
+        tag: tag.trim(),
+        weight: Number.isNaN(weight) ? 0 : weight,
+        index,
+      }
+    })
+    .filter(({ tag, weight }) => tag && tag !== '*' && weight > 0)
+    .sort((a, b) => b.weight - a.weight || a.index - b.index)
+  for (const { tag } of ranked) {
+    const locale = supportedLocale(tag)
+    if (locale) return locale
+  }
+  return FALLBACK_LOCALE
+}
+
+/**
+ * A formatter for decimal amounts in one currency, e.g. `"25.5000"` →
+ * `"$25.50"` in en-US or `"25,50 €"` in de-DE. A code the runtime does not
+ * know as a currency gets a plain number followed by the code
+ * (`"25.50 XTS"`) rather than a guessed symbol.
+ */
+export function moneyFormatter(
+  currency: string,
+  locale: string,
+): (amount: string | number) => string {
+  if (Intl.supportedValuesOf('currency').includes(currency)) {
+    const format = new Intl.NumberFormat(locale, {
+      style: 'currency',
+      currency,
+    })
+    return (amount) => format.format(Number(amount))
+  }
+  const format = new Intl.NumberFormat(locale, {
+    minimumFractionDigits: 2,
+    maximumFractionDigits: 2,
+  })
+  return (amount) => `${format.format(Number(amount))} ${currency}`
+}
+
+/**
+ * Adds `amountFormatted` to each row. Call before presentAmounts, while
+ * `amount` is still a decimal string.
+ */
+export function withFormattedAmounts<T extends Record<string, unknown>>(
+  rows: T[],
+  format: (amount: string) => string,
+): Array<T & { amountFormatted: string }> {
+  return rows.map((row) => ({
+    ...row,
+    amountFormatted: format(String(row.amount)),
+  }))
+}
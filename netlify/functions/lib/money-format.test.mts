@@ -0,0 +1,55 @@
+import { describe, expect, it } from 'vitest'
+import {
+  FALLBACK_LOCALE,
+  moneyFormatter,
+  requestLocale,
+  withFormattedAmounts,
+} from './money-format.mts'
+
+function request(query = '', acceptLanguage?: string) {
+  return new Request(`https://example.com/api/transactions?${query}`, {
+    headers: acceptLanguage ? { 'accept-language': acceptLanguage } : {},
+  })
+}
+
+function localeOf(query: string, acceptLanguage?: string) {
+  const req = request(query, acceptLanguage)
+  return requestLocale(req, new URL(req.url))
+}
+
+describe('requestLocale', () => {
+  it('prefers the locale query parameter', () => {
+    expect(localeOf('locale=de-DE', 'fr-FR')).toBe('de-DE')
+  })
+
+  it('takes the most preferred Accept-Language tag', () => {
+    expect(localeOf('', 'fr-FR;q=0.5, de-DE;q=0.9, *;q=0.1')).toBe('de-DE')
+    expect(localeOf('', 'en-GB,en;q=0.8')).toBe('en-GB')
+  })
+
+  it('falls back for malformed or missing locales', () => {
+    expect(localeOf('locale=not_a_locale!')).toBe(FALLBACK_LOCALE)
+    expect(localeOf('')).toBe(FALLBACK_LOCALE)
+  })
+})
+
+describe('moneyFormatter', () => {
+  it('formats in the currency and locale', () => {
+    expect(moneyFormatter('USD', 'en-US')('25.5000')).toBe('$25.50')
+    // Intl separates the amount and symbol with a no-break space.
+    expect(moneyFormatter('EUR', 'de-DE')('25.5000')).toBe('25,50 €')
+    expect(moneyFormatter('JPY', 'en-US')('1200.0000')).toBe('¥1,200')
+  })
+
+  it('uses a neutral format for unknown currencies', () => {
+    expect(moneyFormatter('QQQ', 'en-US')('1234.5')).toBe('1,234.50 QQQ')
+  })
+})
+
+describe('withFormattedAmounts', () => {
+  it('keeps the raw amount beside the formatted one', () => {
+    expect(
+      withFormattedAmounts([{ id: 't1', amount: '-3.2000' }], (a) => `<${a}>`),
+    ).toEqual([{ id: 't1', amount: '-3.2000', amountFormatted: '<-3.2000>' }])
+  })
+})
@@ -0,0 +1,118 @@
+import { parseAmount } from './amount.mts'
+import type { ImportError, ImportRow } from './transaction-import.mts'
+
+const STMTTRN_RE = /<STMTTRN>([\s\S]*?)<\/STMTTRN>/gi
+
+/** `YYYYMMDD[HHMMSS[.XXX]][[offset[:TZ]]]`, e.g. `20250201120000[-5:EST]`. */
+const OFX_DATE_RE =
+  /^(\d{4})(\d{2})(\d{2})(?:(\d{2})(\d{2})(\d{2})?(?:\.\d+)?)?(?:\[([+-]?\d+(?:\.\d+)?)(?::\w+)?\])?$/
+
+const ENTITIES: Record<string, string> = {
+  '&amp;': '&',
+  '&lt;': '<',
+  '&gt;': '>',
+  '&quot;': '"',
+  '&apos;': "'",
+}
+
+/**
+ * Reads an element value from an OFX aggregate. Works for both OFX 1.x SGML,
+ * where elements are not closed, and OFX 2.x XML.
+ */
+function field(block: string, tag: string): string {
+  const match = new RegExp(`<${tag}>([^<\\r\\n]*)`, 'i').exec(block)
+  return (match?.[1] ?? '')
+    .trim()
+    .replace(/&(amp|lt|gt|quot|apos);/g, (entity) => ENTITIES[entity])
+}
+
+/** Parses an OFX date-time into an ISO-8601 UTC string. */
+export function parseOfxDate(value: string): string | null {
+  const match = OFX_DATE_RE.exec(value.trim())
+  if (!match) return null
+  const [, year, month, day, hour = '0', minute = '0', second = '0', offset] =
+    match
+  const utc = Date.UTC(
+    Number(year),
+    Number(month) - 1,
+    Number(day),
+    Number(hour),
+    Number(minute),
+    Number(second),
+  )
+  // Times without an offset are GMT per the OFX spec.
+  const date = new Date(utc - Number(offset ?? 0) * 3_600_000)
+  if (
+    Number.isNaN(date.getTime()) ||
+    Number(month) < 1 ||
+    Number(month) > 12 ||
+    Number(day) < 1 ||
+    Number(day) > 31
+  ) {
+    return null
+  }
+  return date.toISOString()
+}
+
+/**
+ * Parses the `STMTTRN` entries of an OFX/QFX statement. Positive amounts
+ * become income and negative amounts expense; FITID is kept as the external
+ * id so re-imports can skip or update earlier rows. A FITID repeated within
+ * the file is reported as an error after its first occurrence.
+ */
+export function parseOfxTransactions(text: string): {
+  rows: ImportRow[]
+  errors: ImportError[]
+} {
+  const rows: ImportRow[] = []
+  const errors: ImportError[] = []
+  const seen = new Set<string>()
+
+  const matches = [...text.matchAll(STMTTRN_RE)]
+  if (matches.length === 0) {
+    return {
+      rows,
+      errors: [{ row: 1, error: 'no STMTTRN entries found' }],
+    }
+  }
+
+  for (const match of matches) {
+    const line = text.slice(0, match.index).split('\n').length
+    const block = match[1]
+
+    const externalId = field(block, 'FITID')
+    if (!externalId) {
+      errors.push({ row: line, error: 'FITID is required' })
+      continue
+    }
+    if (seen.has(externalId)) {
+      errors.push({ row: line, error: `duplicate FITID ${externalId}` })
+      continue
+    }
+    const date = parseOfxDate(field(block, 'DTPOSTED'))
+    if (!date) {
+      errors.push({ row: line, error: 'DTPOSTED must be a valid date' })
+      continue
+    }
+    // Some banks write the decimal separator as a comma.
+    const amount = parseAmount(field(block, 'TRNAMT').replace(',', '.'))
+    if (amount === null) {
+      errors.push({ row: line, error: 'TRNAMT must be a number' })
+      continue
+    }
+
+    const name = field(block, 'NAME')
+    const memo = field(block, 'MEMO')
+    seen.add(externalId)
+    rows.push({
+      date,
+      amount: amount.replace(/^-/, ''),
+      description:
+        name && memo && name !== memo ? `${name} - ${memo}` : name || memo,
+      type: amount.startsWith('-') ? 'expense' : 'income',
+      externalId,
+    })
+  }
+
+  return { rows, errors }
+}
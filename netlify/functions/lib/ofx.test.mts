@@ -0,0 +1,119 @@
+import { describe, expect, it } from 'vitest'
+import { parseOfxDate, parseOfxTransactions } from './ofx.mts'
+
+const SGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<BANKTRANLIST>
+<DTSTART>20250201
+<DTEND>20250228
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250203120000[-5:EST]
+<TRNAMT>-12.50
+<FITID>2025020301
+<NAME>SQ *COFFEE
+<MEMO>Card purchase
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20250205
+<TRNAMT>2000.00
+<FITID>2025020501
+<NAME>ACME PAYROLL
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+describe('parseOfxDate', () => {
+  it('parses dates with and without time and offset', () => {
+    expect(parseOfxDate('20250205')).toBe('2025-02-05T00:00:00.000Z')
+    expect(parseOfxDate('20250203120000')).toBe('2025-02-03T12:00:00.000Z')
+    expect(parseOfxDate('20250203120000.000[-5:EST]')).toBe(
+      '2025-02-03T17:00:00.000Z',
+    )
+    expect(parseOfxDate('20250203120000[+5.5:IST]')).toBe(
+      '2025-02-03T06:30:00.000Z',
+    )
+  })
+
+  it('rejects malformed dates', () => {
+    expect(parseOfxDate('')).toBeNull()
+    expect(parseOfxDate('2025-02-03')).toBeNull()
+    expect(parseOfxDate('20251303')).toBeNull()
+  })
+})
+
+describe('parseOfxTransactions', () => {
+  it('parses SGML statements', () => {
+    expect(parseOfxTransactions(SGML)).toEqual({
+      rows: [
+        {
+          date: '2025-02-03T17:00:00.000Z',
+          amount: '12.50',
+          description: 'SQ *COFFEE - Card purchase',
+          type: 'expense',
+          externalId: '2025020301',
+        },
+        {
+          date: '2025-02-05T00:00:00.000Z',
+          amount: '2000.00',
+          description: 'ACME PAYROLL',
+          type: 'income',
+          externalId: '2025020501',
+        },
+      ],
+      errors: [],
+    })
+  })
+
+  it('parses XML statements and decodes entities', () => {
+    const xml = `<?xml version="1.0"?>
+<OFX><BANKTRANLIST>
+<STMTTRN><DTPOSTED>20250210</DTPOSTED><TRNAMT>-5</TRNAMT><FITID>X1</FITID><NAME>B&amp;Q</NAME></STMTTRN>
+</BANKTRANLIST></OFX>`
+    expect(parseOfxTransactions(xml).rows).toEqual([
+      {
+        date: '2025-02-10T00:00:00.000Z',
+        amount: '5',
+        description: 'B&Q',
+        type: 'expense',
+        externalId: 'X1',
+      },
+    ])
+  })
+
+  it('reports invalid and repeated entries by line', () => {
+    const text = [
+      '<STMTTRN><DTPOSTED>20250201<TRNAMT>1<FITID>A</STMTTRN>',
+      '<STMTTRN><DTPOSTED>20250201<TRNAMT>1<FITID>A</STMTTRN>',
+      '<STMTTRN><DTPOSTED>yesterday<TRNAMT>1<FITID>B</STMTTRN>',
+      '<STMTTRN><DTPOSTED>20250201<TRNAMT>lots<FITID>C</STMTTRN>',
+      '<STMTTRN><DTPOSTED>20250201<TRNAMT>1</STMTTRN>',
+    ].join('\n')
+    const { rows, errors } = parseOfxTransactions(text)
+    expect(rows).toHaveLength(1)
+    expect(errors).toEqual([
+      { row: 2, error: 'duplicate FITID A' },
+      { row: 3, error: 'DTPOSTED must be a valid date' },
+      { row: 4, error: 'TRNAMT must be a number' },
+      { row: 5, error: 'FITID is required' },
+    ])
+  })
+
+  it('reports a file without transactions', () => {
+    expect(parseOfxTransactions('<OFX></OFX>')).toEqual({
+      rows: [],
+      errors: [{ row: 1, error: 'no STMTTRN entries found' }],
+    })
+  })
+})
@@ -0,0 +1,82 @@
+import type { Sql } from './db.mts'
+
+export const DEFAULT_PAGE_SIZE = 50
+/** Default page size cap; endpoints may pass their own to the parsers. */
+export const MAX_PAGE_SIZE = 100
+
+export interface Pagination {
+  page: number
+  pageSize: number
+  offset: number
+}
+
+/**
+ * Reads `last`, the number of rows to take from the end of a list instead
+ * of paging from the start. Null when absent.
+ */
+export function parseLast(
+  url: URL,
+  maxPageSize = MAX_PAGE_SIZE,
+): { last: number | null } | { error: string } {
+  const raw = url.searchParams.get('last')
+  if (raw === null) return { last: null }
+  const last = Number(raw)
+  if (
+    !raw.trim() ||
+    !Number.isInteger(last) ||
+    last < 1 ||
+    last > maxPageSize
+  ) {
+    return { error: `last must be between 1 and ${maxPageSize}` }
+  }
+  return { last }
+}
+
+/**
+ * Reads 1-based `page` and `pageSize` query parameters. `maxPageSize` caps
+ * the page size; bulk consumers such as sync feeds may allow more than the
+ * interactive lists.
+ */
+export function parsePagination(
+  url: URL,
+  maxPageSize = MAX_PAGE_SIZE,
+): { pagination: Pagination } | { error: string } {
+  const rawPage = url.searchParams.get('page')
+  const rawSize = url.searchParams.get('pageSize')
+  const page = rawPage ? Number(rawPage) : 1
+  const pageSize = rawSize ? Number(rawSize) : DEFAULT_PAGE_SIZE
+  if (!Number.isInteger(page) || page < 1) {
+    return { error: 'page must be a positive integer' }
+  }
+  if (!Number.isInteger(pageSize) || pageSize < 1 || pageSize > maxPageSize) {
+    return { error: `pageSize must be between 1 and ${maxPageSize}` }
+  }
+  return { pagination: { page, pageSize, offset: (page - 1) * pageSize } }
+}
+
+/**
+ * Approximates `SELECT COUNT(*) <from>` from the planner's row estimate
+ * instead of scanning every matching row. `pg_class.reltuples` alone would
+ * only size the whole table; EXPLAIN applies the same filters and
+ * parameters, so per-user and filtered counts stay in the right ballpark.
+ * Statistics lag behind writes, so the figure is approximate.
+ */
+export async function estimateCount(
+  sql: Sql,
+  from: string,
+  params: unknown[],
+): Promise<number> {
+  const [row] = await sql.query(
+    `EXPLAIN (FORMAT JSON) SELECT 1 ${from}`,
+    params,
+  )
+  return planRows(row?.['QUERY PLAN'])
+}
+
+/** Reads the top-level `Plan Rows` from EXPLAIN (FORMAT JSON) output. */
+export function planRows(plan: unknown): number {
+  const parsed = typeof plan === 'string' ? JSON.parse(plan) : plan
+  const rows = (parsed as Array<{ Plan?: { 'Plan Rows'?: unknown } }>)?.[0]
+    ?.Plan?.['Plan Rows']
+  return typeof rows === 'number' && rows > 0 ? Math.round(rows) : 0
+}
@@ -0,0 +1,46 @@
+import { describe, expect, it } from 'vitest'
+import { parseLast, parsePagination, planRows } from './pagination.mts'
+
+const url = (q: string) => new URL(`https://example.com/api?${q}`)
+
+describe('parsePagination', () => {
+  it('defaults to the first page and computes the offset', () => {
+    expect(parsePagination(url(''))).toEqual({
+      pagination: { page: 1, pageSize: 50, offset: 0 },
+    })
+    expect(parsePagination(url('page=3&pageSize=20'))).toEqual({
+      pagination: { page: 3, pageSize: 20, offset: 40 },
+    })
+  })
+
+  it('caps the page size per endpoint', () => {
+    expect(parsePagination(url('pageSize=500'))).toEqual({
+      error: 'pageSize must be between 1 and 100',
+    })
+    expect(parsePagination(url('pageSize=500'), 1000)).toEqual({
+      pagination: { page: 1, pageSize: 500, offset: 0 },
+    })
+  })
+})
+
+describe('parseLast', () => {
+  it('is absent unless given and bounded like pageSize', () => {
+    expect(parseLast(url(''))).toEqual({ last: null })
+    expect(parseLast(url('last=5'))).toEqual({ last: 5 })
+    expect(parseLast(url('last=0'))).toHaveProperty('error')
+    expect(parseLast(url('last=101'))).toHaveProperty('error')
+  })
+})
+
+describe('planRows', () => {
+  it('reads the estimate from EXPLAIN JSON output', () => {
+    const plan = [{ Plan: { 'Node Type': 'Hash Join', 'Plan Rows': 1234 } }]
+    expect(planRows(plan)).toBe(1234)
+    expect(planRows(JSON.stringify(plan))).toBe(1234)
+  })
+
+  it('falls back to zero for unexpected output', () => {
+    expect(planRows(undefined)).toBe(0)
+    expect(planRows([{}])).toBe(0)
+  })
+})
@@ -0,0 +1,162 @@
+const UUID_RE =
+  /^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/i
+
+export function isUuid(value: string): boolean {
+  return UUID_RE.test(value)
+}
+
+export interface Period {
+  from?: string
+  to?: string
+}
+
+/**
+ * Reads the optional `from`/`to` query parameters, or another pair named
+ * by `names` (e.g. `createdFrom`/`createdTo`). Both must be parseable
+ * dates; the start may not be after the end.
+ */
+export function parsePeriod(
+  url: URL,
+  names: readonly [from: string, to: string] = ['from', 'to'],
+): { period: Period } | { error: string } {
+  const period: Period = {}
+  const [fromName, toName] = names
+  for (const [key, name] of [
+    ['from', fromName],
+    ['to', toName],
+  ] as const) {
+    const raw = url.searchParams.get(name)?.trim()
+    if (!raw) continue
+    if (Number.isNaN(Date.parse(raw))) {
+      return { error: `${name} must be a valid date` }
+    }
+    period[key] = raw
+  }
+  if (
+    period.from &&
+    period.to &&
+    Date.parse(period.from) > Date.parse(period.to)
+  ) {
+    return { error: `${fromName} must not be after ${toName}` }
+  }
+  return { period }
+}
+
+const ROLLING_PERIOD_RE = /^([1-9]\d{0,3})([dwmy])$/
+
+/**
+ * Turns a period relative to `now` into a range ending at `now`: `30d`,
+ * `2w`, `6m` or `1y` reach back that many days, weeks, calendar months or
+ * years (a month back from 31 March is 28 or 29 February); `mtd` and `ytd`
+ * start at midnight on the first day of the month or year. "Now" is the
+ * server clock and every boundary is in UTC, so the range does not depend
+ * on the client's timezone. Returns null for an unknown token.
+ */
+export function parseRollingPeriod(
+  raw: string,
+  now: Date = new Date(),
+): Required<Period> | null {
+  const token = raw.trim().toLowerCase()
+  const year = now.getUTCFullYear()
+  const month = now.getUTCMonth()
+  let from: Date
+  if (token === 'mtd') {
+    from = new Date(Date.UTC(year, month, 1))
+  } else if (token === 'ytd') {
+    from = new Date(Date.UTC(year, 0, 1))
+  } else {
+    const match = ROLLING_PERIOD_RE.exec(token)
+    if (!match) return null
+    const count = Number(match[1])
+    const unit = match[2]
+    if (unit === 'd' || unit === 'w') {
+      const days = unit === 'w' ? count * 7 : count
+      from = new Date(now.getTime() - days * 86_400_000)
+    } else {
+      const months = unit === 'y' ? count * 12 : count
+      const target = month - months
+      // Clamp to the last day of a shorter target month.
+      const lastDay = new Date(Date.UTC(year, target + 1, 0)).getUTCDate()
+      from = new Date(now)
+      from.setUTCFullYear(year, target, Math.min(now.getUTCDate(), lastDay))
+    }
+  }
+  return { from: from.toISOString(), to: now.toISOString() }
+}
+
+export const TRANSACTION_TYPES = ['income', 'expense'] as const
+
+export type TransactionType = (typeof TRANSACTION_TYPES)[number]
+
+export function isTransactionType(value: unknown): value is TransactionType {
+  return (TRANSACTION_TYPES as readonly unknown[]).includes(value)
+}
+
+export const ACCOUNT_TYPES = ['bank', 'cash', 'card'] as const
+
+export type AccountType = (typeof ACCOUNT_TYPES)[number]
+
+/** Trims and lowercases client input so `"Bank "` and `"BANK"` match. */
+function normalizeType(value: unknown): string | null {
+  return typeof value === 'string' ? value.trim().toLowerCase() : null
+}
+
+/** Returns the canonical transaction type, or null when it is not valid. */
+export function parseTransactionType(value: unknown): TransactionType | null {
+  const type = normalizeType(value)
+  return isTransactionType(type) ? type : null
+}
+
+/** Returns the canonical account type, or null when it is not valid. */
+export function parseAccountType(value: unknown): AccountType | null {
+  const type = normalizeType(value)
+  return (ACCOUNT_TYPES as readonly unknown[]).includes(type)
+    ? (type as AccountType)
+    : null
+}
+
+/**
+ * Whether the bank has posted a transaction or it is still pending (an
+ * authorization hold, say). Separate from `cleared`, which records the
+ * user's own reconciliation.
+ */
+export const TRANSACTION_STATUSES = ['pending', 'posted'] as const
+
+export type TransactionStatus = (typeof TRANSACTION_STATUSES)[number]
+
+/** Returns the canonical transaction status, or null when it is not valid. */
+export function parseTransactionStatus(
+  value: unknown,
+): TransactionStatus | null {
+  const status = normalizeType(value)
+  return (TRANSACTION_STATUSES as readonly unknown[]).includes(status)
+    ? (status as TransactionStatus)
+    : null
+}
+
+export interface MonthRange {
+  /** The month as `YYYY-MM`. */
+  month: string
+  /** First instant of the month (inclusive), ISO-8601 UTC. */
+  start: string
+  /** First instant of the following month (exclusive), ISO-8601 UTC. */
+  end: string
+  days: number
+}
+
+/** Parses a `YYYY-MM` month into its UTC date range. */
+export function parseMonth(raw: string | null | undefined): MonthRange | null {
+  const match = /^(\d{4})-(\d{2})$/.exec(raw?.trim() ?? '')
+  if (!match) return null
+  const year = Number(match[1])
+  const month = Number(match[2])
+  if (month < 1 || month > 12) return null
+  const start = new Date(Date.UTC(year, month - 1, 1))
+  const end = new Date(Date.UTC(year, month, 1))
+  return {
+    month: `${match[1]}-${match[2]}`,
+    start: start.toISOString(),
+    end: end.toISOString(),
+    days: Math.round((end.getTime() - start.getTime()) / 86_400_000),
+  }
+}
@@ -0,0 +1,118 @@
+import { describe, expect, it } from 'vitest'
+import {
+  parseAccountType,
+  parseMonth,
+  parsePeriod,
+  parseRollingPeriod,
+  parseTransactionStatus,
+  parseTransactionType,
+} from './params.mts'
+
+function url(query: string) {
+  return new URL(`https://example.com/api?${query}`)
+}
+
+describe('parsePeriod', () => {
+  it('reads from and to', () => {
+    expect(parsePeriod(url('from=2025-01-01&to=2025-02-01'))).toEqual({
+      period: { from: '2025-01-01', to: '2025-02-01' },
+    })
+  })
+
+  it('rejects invalid dates and reversed ranges', () => {
+    expect(parsePeriod(url('from=yesterday'))).toHaveProperty('error')
+    expect(parsePeriod(url('from=2025-02-01&to=2025-01-01'))).toHaveProperty(
+      'error',
+    )
+  })
+})
+
+describe('parseRollingPeriod', () => {
+  const now = new Date('2025-03-31T10:00:00Z')
+
+  it('reaches back a number of days or weeks from now', () => {
+    expect(parseRollingPeriod('30d', now)).toEqual({
+      from: '2025-03-01T10:00:00.000Z',
+      to: '2025-03-31T10:00:00.000Z',
+    })
+    expect(parseRollingPeriod('2w', now)?.from).toBe('2025-03-17T10:00:00.000Z')
+  })
+
+  it('counts months and years in calendar units, clamping the day', () => {
+    expect(parseRollingPeriod('1m', now)?.from).toBe('2025-02-28T10:00:00.000Z')
+    expect(parseRollingPeriod('1y', now)?.from).toBe('2024-03-31T10:00:00.000Z')
+    expect(
+      parseRollingPeriod('1y', new Date('2024-02-29T00:00:00Z'))?.from,
+    ).toBe('2023-02-28T00:00:00.000Z')
+  })
+
+  it('starts month- and year-to-date at midnight UTC', () => {
+    expect(parseRollingPeriod('mtd', now)?.from).toBe(
+      '2025-03-01T00:00:00.000Z',
+    )
+    expect(parseRollingPeriod('YTD', now)?.from).toBe(
+      '2025-01-01T00:00:00.000Z',
+    )
+  })
+
+  it('rejects unknown tokens', () => {
+    for (const raw of ['0d', '30', 'd', '1q', 'qtd', '-7d', '30 d'])
+      expect(parseRollingPeriod(raw, now)).toBeNull()
+  })
+})
+
+describe('parseTransactionStatus', () => {
+  it('normalizes known statuses and rejects others', () => {
+    expect(parseTransactionStatus(' POSTED ')).toBe('posted')
+    expect(parseTransactionStatus('pending')).toBe('pending')
+    expect(parseTransactionStatus('cleared')).toBeNull()
+    expect(parseTransactionStatus(1)).toBeNull()
+  })
+})
+
+describe('parseMonth', () => {
+  it('returns the UTC range of the month', () => {
+    expect(parseMonth('2025-02')).toEqual({
+      month: '2025-02',
+      start: '2025-02-01T00:00:00.000Z',
+      end: '2025-03-01T00:00:00.000Z',
+      days: 28,
+    })
+    expect(parseMonth('2024-02')?.days).toBe(29)
+    expect(parseMonth('2025-12')?.end).toBe('2026-01-01T00:00:00.000Z')
+  })
+
+  it('rejects malformed months', () => {
+    expect(parseMonth('2025-13')).toBeNull()
+    expect(parseMonth('2025-2')).toBeNull()
+    expect(parseMonth('Feb 2025')).toBeNull()
+    expect(parseMonth(null)).toBeNull()
+  })
+})
+
+describe('parseTransactionType', () => {
+  it('accepts case and whitespace variations', () => {
+    expect(parseTransactionType('income')).toBe('income')
+    expect(parseTransactionType(' EXPENSE ')).toBe('expense')
+    expect(parseTransactionType('Income')).toBe('income')
+  })
+
+  it('rejects unknown types', () => {
+    expect(parseTransactionType('transfer')).toBeNull()
+    expect(parseTransactionType('')).toBeNull()
+    expect(parseTransactionType(1)).toBeNull()
+  })
+})
+
+describe('parseAccountType', () => {
+  it('accepts case and whitespace variations', () => {
+    expect(parseAccountType('Bank')).toBe('bank')
+    expect(parseAccountType('BANK')).toBe('bank')
+    expect(parseAccountType(' card')).toBe('card')
+  })
+
+  it('rejects unknown types', () => {
+    expect(parseAccountType('brokerage')).toBeNull()
+    expect(parseAccountType(null)).toBeNull()
+  })
+})
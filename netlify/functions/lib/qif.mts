@@ -0,0 +1,54 @@
+/** QIF account headers for each account type. */
+const QIF_TYPES: Record<string, string> = {
+  bank: 'Bank',
+  cash: 'Cash',
+  card: 'CCard',
+}
+
+export interface QifTransaction {
+  amount: string
+  date: Date | string
+  description: string
+  type: string
+  category: string | null
+}
+
+/** `MM/DD/YYYY` in UTC, the date form Quicken and GnuCash both accept. */
+function qifDate(value: Date | string): string {
+  const d = new Date(value)
+  const mm = String(d.getUTCMonth() + 1).padStart(2, '0')
+  const dd = String(d.getUTCDate()).padStart(2, '0')
+  return `${mm}/${dd}/${d.getUTCFullYear()}`
+}
+
+/**
+ * Signed amount, trimming stored zeros past the cents (`12.5000` →
+ * `12.50`) while keeping any real sub-cent digits.
+ */
+function qifAmount(amount: string, type: string): string {
+  const [whole, fraction = ''] = amount.split('.')
+  const digits = fraction.replace(/0+$/, '').padEnd(2, '0')
+  return `${type === 'expense' ? '-' : ''}${whole}.${digits}`
+}
+
+/** QIF fields are one line each. */
+function qifText(value: string): string {
+  return value.replace(/[\r\n]+/g, ' ').trim()
+}
+
+/**
+ * Formats transactions as a QIF file: one `D`/`T`/`P`/`L` record per
+ * transaction, each ended by `^`. Income is positive, expense negative.
+ */
+export function toQif(accountType: string, rows: QifTransaction[]): string {
+  const lines = [`!Type:${QIF_TYPES[accountType] ?? 'Bank'}`]
+  for (const row of rows) {
+    lines.push(`D${qifDate(row.date)}`)
+    lines.push(`T${qifAmount(row.amount, row.type)}`)
+    const payee = qifText(row.description)
+    if (payee) lines.push(`P${payee}`)
+    if (row.category) lines.push(`L${qifText(row.category)}`)
+    lines.push('^')
+  }
+  return `${lines.join('\n')}\n`
+}
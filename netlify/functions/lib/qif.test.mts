@@ -0,0 +1,57 @@
+import { describe, expect, it } from 'vitest'
+import { toQif } from './qif.mts'
+
+describe('toQif', () => {
+  it('writes one record per transaction', () => {
+    expect(
+      toQif('bank', [
+        {
+          amount: '2500.0000',
+          date: new Date('2025-02-01T00:00:00Z'),
+          description: 'Salary',
+          type: 'income',
+          category: null,
+        },
+        {
+          amount: '12.3450',
+          date: '2025-02-14T18:30:00Z',
+          description: 'Coffee\nbeans',
+          type: 'expense',
+          category: 'Groceries',
+        },
+      ]),
+    ).toBe(
+      [
+        '!Type:Bank',
+        'D02/01/2025',
+        'T2500.00',
+        'PSalary',
+        '^',
+        'D02/14/2025',
+        'T-12.345',
+        'PCoffee beans',
+        'LGroceries',
+        '^',
+        '',
+      ].join('\n'),
+    )
+  })
+
+  it('maps account types to QIF headers', () => {
+    expect(toQif('card', [])).toBe('!Type:CCard\n')
+    expect(toQif('cash', [])).toBe('!Type:Cash\n')
+  })
+
+  it('omits the payee line for blank descriptions', () => {
+    const qif = toQif('bank', [
+      {
+        amount: '5',
+        date: '2025-03-01T00:00:00Z',
+        description: '',
+        type: 'expense',
+        category: null,
+      },
+    ])
+    expect(qif).toBe('!Type:Bank\nD03/01/2025\nT-5.00\n^\n')
+  })
+})
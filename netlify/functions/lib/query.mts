@@ -0,0 +1,57 @@
+/**
+ * Accumulates WHERE clauses and their positional parameters for queries that
+ * are assembled at runtime and run with `sql.query(text, params)`.
+ */
+export class QueryBuilder {
+  readonly params: unknown[] = []
+  private readonly clauses: string[] = []
+
+  /** Registers a parameter and returns its `$n` placeholder. */
+  param(value: unknown): string {
+    this.params.push(value)
+    return `$${this.params.length}`
+  }
+
+  where(clause: string): this {
+    this.clauses.push(clause)
+    return this
+  }
+
+  whereSql(): string {
+    return this.clauses.length ? `WHERE ${this.clauses.join(' AND ')}` : ''
+  }
+}
+
+export const NULLS_ORDERS = ['first', 'last'] as const
+
+export type NullsOrder = (typeof NULLS_ORDERS)[number]
+
+export interface OrderTerm {
+  /** Trusted SQL expression; never user input. */
+  column: string
+  desc?: boolean
+  /** Whether the column can be NULL, which makes the NULLS placement matter. */
+  nullable?: boolean
+}
+
+/**
+ * Renders an ORDER BY list. Postgres puts NULLs last ascending but first
+ * descending, so nullable terms always get an explicit `NULLS` clause
+ * instead of relying on the direction.
+ */
+export function orderBySql(
+  terms: readonly OrderTerm[],
+  nulls: NullsOrder = 'last',
+): string {
+  const list = terms.map(({ column, desc, nullable }) => {
+    const direction = desc ? ' DESC' : ''
+    const placement = nullable ? ` NULLS ${nulls.toUpperCase()}` : ''
+    return `${column}${direction}${placement}`
+  })
+  return `ORDER BY ${list.join(', ')}`
+}
+
+/** Escapes LIKE/ILIKE wildcards so user input matches literally. */
+export function escapeLike(value: string): string {
+  return value.replace(/[\\%_]/g, (c) => `\\${c}`)
+}
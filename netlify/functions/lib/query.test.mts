@@ -0,0 +1,38 @@
+import { describe, expect, it } from 'vitest'
+import { QueryBuilder, escapeLike, orderBySql } from './query.mts'
+
+describe('QueryBuilder', () => {
+  it('numbers parameters in order and joins clauses', () => {
+    const q = new QueryBuilder()
+    q.where(`a = ${q.param(1)}`).where(`b = ${q.param('x')}`)
+    expect(q.whereSql()).toBe('WHERE a = $1 AND b = $2')
+    expect(q.params).toEqual([1, 'x'])
+  })
+})
+
+describe('orderBySql', () => {
+  it('makes NULL placement explicit for nullable columns only', () => {
+    const terms = [
+      { column: 'a.last_used_at', desc: true, nullable: true },
+      { column: 'a.name' },
+    ]
+    expect(orderBySql(terms)).toBe(
+      'ORDER BY a.last_used_at DESC NULLS LAST, a.name',
+    )
+    expect(orderBySql(terms, 'first')).toBe(
+      'ORDER BY a.last_used_at DESC NULLS FIRST, a.name',
+    )
+  })
+
+  it('keeps NULLS LAST on ascending columns too', () => {
+    expect(orderBySql([{ column: 'a.group_id', nullable: true }])).toBe(
+      'ORDER BY a.group_id NULLS LAST',
+    )
+  })
+})
+
+describe('escapeLike', () => {
+  it('escapes wildcards and backslashes', () => {
+    expect(escapeLike('50%_off\\')).toBe('50\\%\\_off\\\\')
+  })
+})
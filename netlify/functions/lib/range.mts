@@ -0,0 +1,69 @@
+import { createHash } from 'node:crypto'
+import { etag } from './etag.mts'
+
+export interface ByteRange {
+  start: number
+  /** Inclusive, as in Content-Range. */
+  end: number
+}
+
+/**
+ * Parses a single-range `Range: bytes=…` header against a body of `size`
+ * bytes. Returns null when the header should be ignored (absent, not bytes,
+ * malformed, or several ranges), in which case the full body is sent, and
+ * `'unsatisfiable'` when the range lies wholly past the end.
+ */
+export function parseRange(
+  header: string | null,
+  size: number,
+): ByteRange | 'unsatisfiable' | null {
+  const match = header?.trim().match(/^bytes=(\d*)-(\d*)$/)
+  if (!match) return null
+  const [, first, last] = match
+  if (!first && !last) return null
+  if (!first) {
+    // Suffix range: the final N bytes.
+    const length = Number(last)
+    if (length === 0) return 'unsatisfiable'
+    return { start: Math.max(size - length, 0), end: size - 1 }
+  }
+  const start = Number(first)
+  const end = last ? Number(last) : size - 1
+  if (end < start) return null
+  if (start >= size) return 'unsatisfiable'
+  return { start, end: Math.min(end, size - 1) }
+}
+
+/**
+ * Buffers a 200 response so it can be served in part, for resuming large
+ * downloads. Adds `Accept-Ranges` and a strong ETag of the content; a
+ * satisfiable single range becomes a 206. An `If-Range` that no longer
+ * matches the ETag means the content changed, so the full body is sent.
+ */
+export async function withRanges(req: Request, res: Response) {
+  if (res.status !== 200) return res
+  const body = new Uint8Array(await res.arrayBuffer())
+  const headers = new Headers(res.headers)
+  const tag = etag(createHash('sha256').update(body).digest('base64url'))
+  headers.set('Accept-Ranges', 'bytes')
+  headers.set('ETag', tag)
+
+  const ifRange = req.headers.get('If-Range')
+  const range =
+    ifRange === null || ifRange.trim() === tag
+      ? parseRange(req.headers.get('Range'), body.byteLength)
+      : null
+  if (range === null) return new Response(body, { status: 200, headers })
+  if (range === 'unsatisfiable') {
+    headers.set('Content-Range', `bytes */${body.byteLength}`)
+    return new Response(null, { status: 416, headers })
+  }
+  headers.set(
+    'Content-Range',
+    `bytes ${range.start}-${range.end}/${body.byteLength}`,
+  )
+  return new Response(body.subarray(range.start, range.end + 1), {
+    status: 206,
+    headers,
+  })
+}
@@ -0,0 +1,80 @@
+import { describe, expect, it } from 'vitest'
+import { parseRange, withRanges } from './range.mts'
+
+describe('parseRange', () => {
+  it('parses bounded, open-ended, and suffix ranges', () => {
+    expect(parseRange('bytes=0-9', 100)).toEqual({ start: 0, end: 9 })
+    expect(parseRange('bytes=90-', 100)).toEqual({ start: 90, end: 99 })
+    expect(parseRange('bytes=-10', 100)).toEqual({ start: 90, end: 99 })
+  })
+
+  it('clamps ranges that run past the end', () => {
+    expect(parseRange('bytes=50-500', 100)).toEqual({ start: 50, end: 99 })
+    expect(parseRange('bytes=-500', 100)).toEqual({ start: 0, end: 99 })
+  })
+
+  it('ignores absent, malformed, and multi-range headers', () => {
+    expect(parseRange(null, 100)).toBeNull()
+    expect(parseRange('bytes=-', 100)).toBeNull()
+    expect(parseRange('bytes=9-0', 100)).toBeNull()
+    expect(parseRange('items=0-9', 100)).toBeNull()
+    expect(parseRange('bytes=0-9,20-29', 100)).toBeNull()
+  })
+
+  it('flags ranges that start past the end', () => {
+    expect(parseRange('bytes=100-', 100)).toBe('unsatisfiable')
+    expect(parseRange('bytes=-0', 100)).toBe('unsatisfiable')
+  })
+})
+
+describe('withRanges', () => {
+  const full = () => new Response('0123456789', { status: 200 })
+  const get = (headers: Record<string, string> = {}) =>
+    new Request('https://example.com/export', { headers })
+
+  it('advertises byte ranges on a full response', async () => {
+    const res = await withRanges(get(), full())
+    expect(res.status).toBe(200)
+    expect(res.headers.get('Accept-Ranges')).toBe('bytes')
+    expect(res.headers.get('ETag')).toMatch(/^".+"$/)
+    expect(await res.text()).toBe('0123456789')
+  })
+
+  it('serves the requested range as 206', async () => {
+    const res = await withRanges(get({ Range: 'bytes=4-' }), full())
+    expect(res.status).toBe(206)
+    expect(res.headers.get('Content-Range')).toBe('bytes 4-9/10')
+    expect(await res.text()).toBe('456789')
+  })
+
+  it('answers 416 for a range past the end', async () => {
+    const res = await withRanges(get({ Range: 'bytes=10-' }), full())
+    expect(res.status).toBe(416)
+    expect(res.headers.get('Content-Range')).toBe('bytes */10')
+  })
+
+  it('sends the full body when If-Range no longer matches', async () => {
+    const { headers } = await withRanges(get(), full())
+    const tag = headers.get('ETag')!
+    const resumed = await withRanges(
+      get({ Range: 'bytes=4-', 'If-Range': tag }),
+      full(),
+    )
+    expect(resumed.status).toBe(206)
+    const changed = await withRanges(
+      get({ Range: 'bytes=4-', 'If-Range': '"stale"' }),
+      full(),
+    )
+    expect(changed.status).toBe(200)
+    expect(await changed.text()).toBe('0123456789')
+  })
+
+  it('leaves error responses alone', async () => {
+    const res = await withRanges(
+      get({ Range: 'bytes=0-1' }),
+      new Response('nope', { status: 404 }),
+    )
+    expect(res.status).toBe(404)
+    expect(res.headers.has('Accept-Ranges')).toBe(false)
+  })
+})
@@ -0,0 +1,19 @@
+/** Methods that never modify data and stay available in read-only mode. */
+const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS']
+
+/** Parses a boolean env flag; `1` and `true` (any case) enable it. */
+export function parseFlag(raw: string | undefined): boolean {
+  const value = raw?.trim().toLowerCase()
+  return value === '1' || value === 'true'
+}
+
+/** When set, the API serves reads but rejects every write (e.g. a demo). */
+export const READ_ONLY = parseFlag(process.env.READ_ONLY)
+
+/** Whether the request is a write that read-only mode must reject. */
+export function isWriteBlocked(
+  req: Request,
+  readOnly: boolean = READ_ONLY,
+): boolean {
+  return readOnly && !SAFE_METHODS.includes(req.method)
+}
@@ -0,0 +1,36 @@
+import { describe, expect, it } from 'vitest'
+import { isWriteBlocked, parseFlag } from './read-only.mts'
+
+function request(method: string) {
+  return new Request('https://example.com/api', { method })
+}
+
+describe('parseFlag', () => {
+  it('accepts 1 and true', () => {
+    expect(parseFlag('1')).toBe(true)
+    expect(parseFlag(' TRUE ')).toBe(true)
+    expect(parseFlag('0')).toBe(false)
+    expect(parseFlag('')).toBe(false)
+    expect(parseFlag(undefined)).toBe(false)
+  })
+})
+
+describe('isWriteBlocked', () => {
+  it('allows every method when read-only mode is off', () => {
+    for (const method of ['GET', 'POST', 'PATCH', 'PUT', 'DELETE']) {
+      expect(isWriteBlocked(request(method), false)).toBe(false)
+    }
+  })
+
+  it('allows safe methods in read-only mode', () => {
+    for (const method of ['GET', 'HEAD', 'OPTIONS']) {
+      expect(isWriteBlocked(request(method), true)).toBe(false)
+    }
+  })
+
+  it('blocks writes in read-only mode', () => {
+    for (const method of ['POST', 'PATCH', 'PUT', 'DELETE']) {
+      expect(isWriteBlocked(request(method), true)).toBe(true)
+    }
+  })
+})
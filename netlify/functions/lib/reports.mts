@@ -0,0 +1,398 @@
+import { TRANSACTION_TYPES } from './params.mts'
+import type { TransactionType } from './params.mts'
+
+/**
+ * SQL condition leaving out transfer legs, over a `transactions t` alias.
+ * A transfer moves money between the user's own accounts, so it is neither
+ * income nor spend; reports of either exclude it unless asked not to.
+ */
+export const EXCLUDE_TRANSFERS = 't.transfer_group IS NULL'
+
+export const GROUP_BY_UNITS = ['day', 'week', 'month', 'year'] as const
+
+export type GroupBy = (typeof GROUP_BY_UNITS)[number]
+
+function parseUnit(
+  url: URL,
+  name: string,
+  fallback: GroupBy,
+): { unit: GroupBy } | { error: string } {
+  const raw = url.searchParams.get(name)?.trim() || fallback
+  if (!(GROUP_BY_UNITS as readonly string[]).includes(raw)) {
+    return { error: `${name} must be one of ${GROUP_BY_UNITS.join(', ')}` }
+  }
+  return { unit: raw as GroupBy }
+}
+
+/** Reads `groupBy`, defaulting to month. Values map onto `date_trunc` units. */
+export function parseGroupBy(
+  url: URL,
+): { groupBy: GroupBy } | { error: string } {
+  const parsed = parseUnit(url, 'groupBy', 'month')
+  return 'error' in parsed ? parsed : { groupBy: parsed.unit }
+}
+
+/** Reads a time series `interval`, defaulting to day. Same units as groupBy. */
+export function parseInterval(
+  url: URL,
+): { interval: GroupBy } | { error: string } {
+  const parsed = parseUnit(url, 'interval', 'day')
+  return 'error' in parsed ? parsed : { interval: parsed.unit }
+}
+
+export const WEEK_STARTS = ['monday', 'sunday'] as const
+
+export type WeekStart = (typeof WEEK_STARTS)[number]
+
+/** Reads `weekStart`, defaulting to monday (ISO weeks, as `date_trunc`). */
+export function parseWeekStart(
+  url: URL,
+): { weekStart: WeekStart } | { error: string } {
+  const raw = url.searchParams.get('weekStart')?.trim().toLowerCase()
+  if (!raw) return { weekStart: 'monday' }
+  if (!(WEEK_STARTS as readonly string[]).includes(raw)) {
+    return { error: `weekStart must be one of ${WEEK_STARTS.join(', ')}` }
+  }
+  return { weekStart: raw as WeekStart }
+}
+
+/** IANA zone names the runtime knows, keyed by lowercased name. */
+const TIME_ZONES = new Map(
+  // Node lists UTC only under its Etc aliases, so it is added by name.
+  [...Intl.supportedValuesOf('timeZone'), 'UTC'].map((zone) => [
+    zone.toLowerCase(),
+    zone,
+  ]),
+)
+
+/**
+ * Reads `tz`, the IANA time zone whose local days and months a report
+ * groups by (`America/New_York`), defaulting to UTC. Only names on the
+ * runtime's list pass, returned in their canonical spelling, so the value
+ * is safe to inline in SQL.
+ */
+export function parseTimeZone(
+  url: URL,
+): { timeZone: string } | { error: string } {
+  const raw = url.searchParams.get('tz')?.trim()
+  if (!raw) return { timeZone: 'UTC' }
+  const timeZone = TIME_ZONES.get(raw.toLowerCase())
+  if (!timeZone) {
+    return { error: 'tz must be an IANA time zone name, e.g. Europe/Berlin' }
+  }
+  return { timeZone }
+}
+
+/**
+ * SQL for the local wall-clock time of timestamptz `column` in `timeZone`,
+ * a value from parseTimeZone.
+ */
+export function localTimeSql(column: string, timeZone: string): string {
+  return `(${column} AT TIME ZONE '${timeZone}')`
+}
+
+/**
+ * SQL for the start of the period containing `column`. `date_trunc` weeks
+ * begin on Monday, so Sunday weeks are truncated a day late and shifted
+ * back. `unit` must already be allowlisted. With `timeZone` the period is
+ * truncated on the zone's local calendar and returned as the instant it
+ * starts there, so a transaction late on the 31st in New York stays in
+ * that month.
+ */
+export function periodStartSql(
+  unit: GroupBy,
+  weekStart: WeekStart,
+  column = 't.date',
+  timeZone?: string,
+): string {
+  if (timeZone) {
+    const local = periodStartSql(
+      unit,
+      weekStart,
+      localTimeSql(column, timeZone),
+    )
+    return localTimeSql(local, timeZone)
+  }
+  if (unit === 'week' && weekStart === 'sunday') {
+    return `(date_trunc('week', ${column} + interval '1 day') - interval '1 day')`
+  }
+  return `date_trunc('${unit}', ${column})`
+}
+
+/**
+ * SQL labelling a week period as ISO `YYYY-Www`. A Sunday week takes the
+ * label of the ISO week its Monday through Saturday fall in. Pass the
+ * `timeZone` the period was computed in.
+ */
+export function weekLabelSql(
+  period: string,
+  weekStart: WeekStart,
+  timeZone?: string,
+): string {
+  const local = timeZone ? localTimeSql(period, timeZone) : period
+  const monday = weekStart === 'sunday' ? `${local} + interval '1 day'` : local
+  return `to_char(${monday}, 'IYYY-"W"IW')`
+}
+
+export interface IncomeExpenseRatio {
+  income: string
+  expense: string
+  /** expense / income; null when there was no income. */
+  ratio: number | null
+  /** (income - expense) / income; null when there was no income. */
+  savingsRate: number | null
+}
+
+/** Rounds report ratios to four decimal places. */
+function roundRatio(value: number): number {
+  return Math.round(value * 10_000) / 10_000
+}
+
+/** Derives the spend ratio and savings rate from period totals. */
+export function incomeExpenseRatio(
+  income: string,
+  expense: string,
+): IncomeExpenseRatio {
+  const incomeValue = Number(income)
+  const expenseValue = Number(expense)
+  if (incomeValue === 0) {
+    return { income, expense, ratio: null, savingsRate: null }
+  }
+  return {
+    income,
+    expense,
+    ratio: roundRatio(expenseValue / incomeValue),
+    savingsRate: roundRatio((incomeValue - expenseValue) / incomeValue),
+  }
+}
+
+/**
+ * Percentage change from `previous` to `current`, to two decimal places;
+ * null when `previous` is zero, as any change from nothing is unbounded.
+ */
+export function percentChange(
+  current: string,
+  previous: string,
+): number | null {
+  const base = Number(previous)
+  if (base === 0) return null
+  return Math.round(((Number(current) - base) / base) * 10_000) / 100
+}
+
+export interface AmountStats {
+  count: number
+  total: string
+  /** null when there are no transactions. */
+  average: string | null
+  min: string | null
+  max: string | null
+}
+
+/**
+ * Keys per-type aggregate rows by transaction type. Types without any rows
+ * get a zero count and total, and null average/min/max.
+ */
+export function statsByType(
+  rows: Array<AmountStats & { type: string }>,
+): Record<TransactionType, AmountStats> {
+  const byType = new Map(rows.map(({ type, ...stats }) => [type, stats]))
+  return Object.fromEntries(
+    TRANSACTION_TYPES.map((type) => [
+      type,
+      byType.get(type) ?? {
+        count: 0,
+        total: '0',
+        average: null,
+        min: null,
+        max: null,
+      },
+    ]),
+  ) as Record<TransactionType, AmountStats>
+}
+
+/**
+ * Spreads grouped totals over every slot `0..size-1` (days of the week,
+ * hours of the day), filling slots without rows with zero.
+ */
+export function fillSlots(
+  rows: Array<{ slot: number; total: string; count: number }>,
+  size: number,
+): Array<{ slot: number; total: string; count: number }> {
+  const bySlot = new Map(rows.map((row) => [Number(row.slot), row]))
+  return Array.from({ length: size }, (_, slot) => {
+    const row = bySlot.get(slot)
+    return { slot, total: row?.total ?? '0', count: row?.count ?? 0 }
+  })
+}
+
+export interface PeriodTotals {
+  income: string
+  expense: string
+  net: string
+}
+
+const NO_ACTIVITY: PeriodTotals = { income: '0', expense: '0', net: '0' }
+
+/** One grouped row of a two-period comparison query. */
+export interface ComparisonRow extends PeriodTotals {
+  period: 'a' | 'b'
+  /** The whole-period row rather than one category's. */
+  isTotal: boolean
+  categoryId: string | null
+  category: string | null
+}
+
+export interface CategoryComparison {
+  /** null for uncategorized transactions. */
+  categoryId: string | null
+  name: string | null
+  periodA: PeriodTotals
+  periodB: PeriodTotals
+}
+
+/**
+ * Pairs up the totals of two periods, overall and per category, in the
+ * order categories first appear in `rows`. A period or category without
+ * transactions gets zeros, so an empty month compares like any other.
+ */
+export function comparePeriods(rows: ComparisonRow[]): {
+  periodA: PeriodTotals
+  periodB: PeriodTotals
+  categories: CategoryComparison[]
+} {
+  const totals = { a: NO_ACTIVITY, b: NO_ACTIVITY }
+  const categories = new Map<string | null, CategoryComparison>()
+  for (const { period, isTotal, categoryId, category, ...amounts } of rows) {
+    if (isTotal) {
+      totals[period] = amounts
+      continue
+    }
+    let entry = categories.get(categoryId)
+    if (!entry) {
+      entry = {
+        categoryId,
+        name: category,
+        periodA: NO_ACTIVITY,
+        periodB: NO_ACTIVITY,
+      }
+      categories.set(categoryId, entry)
+    }
+    entry[period === 'a' ? 'periodA' : 'periodB'] = amounts
+  }
+  return {
+    periodA: totals.a,
+    periodB: totals.b,
+    categories: [...categories.values()],
+  }
+}
+
+/** Decimal places of stored amounts, NUMERIC(18,4). */
+const AMOUNT_SCALE = 4
+
+/** A decimal string as an integer count of 1/10^AMOUNT_SCALE units. */
+function toScaled(decimal: string): bigint {
+  const [, sign, whole, fraction = ''] = /^(-?)(\d+)(?:\.(\d+))?$/.exec(
+    decimal,
+  )!
+  const units = BigInt(
+    whole + fraction.padEnd(AMOUNT_SCALE, '0').slice(0, AMOUNT_SCALE),
+  )
+  return sign ? -units : units
+}
+
+function fromScaled(units: bigint): string {
+  const digits = (units < 0n ? -units : units)
+    .toString()
+    .padStart(AMOUNT_SCALE + 1, '0')
+  const sign = units < 0n ? '-' : ''
+  return `${sign}${digits.slice(0, -AMOUNT_SCALE)}.${digits.slice(-AMOUNT_SCALE)}`
+}
+
+export interface MonthTotals {
+  month: string
+  income: string
+  expense: string
+}
+
+export interface CarryoverMonth extends MonthTotals {
+  net: string
+  /** Balance carried into the next month: the previous one plus `net`. */
+  carryover: string
+}
+
+/**
+ * Adds each month's net and the running carryover, starting from
+ * `opening`, the balance brought into the first month. Sums are exact:
+ * amounts are added as scaled integers, never as floats.
+ */
+export function carryover(
+  opening: string,
+  months: MonthTotals[],
+): CarryoverMonth[] {
+  let running = toScaled(opening)
+  return months.map((month) => {
+    const net = toScaled(month.income) - toScaled(month.expense)
+    running += net
+    return { ...month, net: fromScaled(net), carryover: fromScaled(running) }
+  })
+}
+
+/** Decimal places aggregated report figures are rounded to by default. */
+export const DEFAULT_ROUND = 2
+
+/**
+ * Reads `round`, the decimal places a report's aggregated figures are
+ * returned with: 0 to AMOUNT_SCALE, defaulting to DEFAULT_ROUND.
+ */
+export function parseRound(url: URL): { round: number } | { error: string } {
+  const raw = url.searchParams.get('round')
+  if (raw === null) return { round: DEFAULT_ROUND }
+  if (!/^\d$/.test(raw) || Number(raw) > AMOUNT_SCALE) {
+    return { error: `round must be an integer from 0 to ${AMOUNT_SCALE}` }
+  }
+  return { round: Number(raw) }
+}
+
+/**
+ * Rounds a decimal string to `places`, half away from zero like Postgres
+ * ROUND, and pads it to exactly that many decimals (`"2.0050"`, 2 →
+ * `"2.01"`). Anything that is not a plain decimal is returned unchanged.
+ */
+export function roundAmount(decimal: string, places: number): string {
+  const match = /^(-?)(\d+)(?:\.(\d+))?$/.exec(decimal)
+  if (!match) return decimal
+  const [, sign, whole, fraction = ''] = match
+  let units = BigInt(whole + fraction.padEnd(places, '0').slice(0, places))
+  if (fraction.charAt(places) >= '5') units += 1n
+  const digits = units.toString().padStart(places + 1, '0')
+  const rounded = places
+    ? `${digits.slice(0, -places)}.${digits.slice(-places)}`
+    : digits
+  return sign && units !== 0n ? `-${rounded}` : rounded
+}
+
+/**
+ * Rounds every decimal string stored under one of `keys`, at any depth of
+ * a report body, with roundAmount. Derived values such as ratios are
+ * computed from the exact figures before this runs.
+ */
+export function roundFigures<T>(
+  value: T,
+  places: number,
+  keys: readonly string[],
+): T {
+  if (Array.isArray(value)) {
+    return value.map((item) => roundFigures(item, places, keys)) as T
+  }
+  if (value === null || typeof value !== 'object' || value instanceof Date) {
+    return value
+  }
+  return Object.fromEntries(
+    Object.entries(value).map(([key, item]) => [
+      key,
+      keys.includes(key) && typeof item === 'string'
+        ? roundAmount(item, places)
+        : roundFigures(item, places, keys),
+    ]),
+  ) as T
+}
@@ -0,0 +1,318 @@
+import { describe, expect, it } from 'vitest'
+import {
+  carryover,
+  comparePeriods,
+  fillSlots,
+  incomeExpenseRatio,
+  parseGroupBy,
+  parseInterval,
+  parseRound,
+  parseTimeZone,
+  parseWeekStart,
+  periodStartSql,
+  percentChange,
+  roundAmount,
+  roundFigures,
+  statsByType,
+  weekLabelSql,
+} from './reports.mts'
+
+describe('parseGroupBy', () => {
+  it('defaults to month and rejects unknown units', () => {
+    const url = (q: string) => new URL(`https://example.com/api?${q}`)
+    expect(parseGroupBy(url(''))).toEqual({ groupBy: 'month' })
+    expect(parseGroupBy(url('groupBy=week'))).toEqual({ groupBy: 'week' })
+    expect(parseGroupBy(url('groupBy=quarter'))).toHaveProperty('error')
+  })
+})
+
+describe('parseInterval', () => {
+  it('defaults to day and rejects unknown units', () => {
+    const url = (q: string) => new URL(`https://example.com/api?${q}`)
+    expect(parseInterval(url(''))).toEqual({ interval: 'day' })
+    expect(parseInterval(url('interval=month'))).toEqual({ interval: 'month' })
+    expect(parseInterval(url('interval=hour'))).toEqual({
+      error: 'interval must be one of day, week, month, year',
+    })
+  })
+})
+
+describe('incomeExpenseRatio', () => {
+  it('computes the ratio and savings rate', () => {
+    expect(incomeExpenseRatio('4000.0000', '3000.0000')).toEqual({
+      income: '4000.0000',
+      expense: '3000.0000',
+      ratio: 0.75,
+      savingsRate: 0.25,
+    })
+  })
+
+  it('allows a negative savings rate when spending exceeds income', () => {
+    expect(incomeExpenseRatio('1000', '1500')).toMatchObject({
+      ratio: 1.5,
+      savingsRate: -0.5,
+    })
+  })
+
+  it('returns null ratios when there is no income', () => {
+    expect(incomeExpenseRatio('0', '250.0000')).toEqual({
+      income: '0',
+      expense: '250.0000',
+      ratio: null,
+      savingsRate: null,
+    })
+    expect(incomeExpenseRatio('0', '0')).toMatchObject({ ratio: null })
+  })
+
+  it('rounds to four decimal places', () => {
+    expect(incomeExpenseRatio('3', '1').ratio).toBe(0.3333)
+  })
+})
+
+describe('statsByType', () => {
+  it('fills types without transactions with null aggregates', () => {
+    const expense = {
+      count: 2,
+      total: '30.0000',
+      average: '15.0000',
+      min: '10.0000',
+      max: '20.0000',
+    }
+    expect(statsByType([{ type: 'expense', ...expense }])).toEqual({
+      income: { count: 0, total: '0', average: null, min: null, max: null },
+      expense,
+    })
+  })
+})
+
+describe('percentChange', () => {
+  it('returns the change as a percentage of the previous value', () => {
+    expect(percentChange('150.0000', '100.0000')).toBe(50)
+    expect(percentChange('80', '120')).toBe(-33.33)
+    expect(percentChange('0', '40')).toBe(-100)
+  })
+
+  it('is null when the previous value is zero', () => {
+    expect(percentChange('250', '0.0000')).toBeNull()
+    expect(percentChange('0', '0')).toBeNull()
+  })
+})
+
+describe('parseWeekStart', () => {
+  it('defaults to monday and rejects other days', () => {
+    const url = (q: string) => new URL(`https://example.com/api?${q}`)
+    expect(parseWeekStart(url(''))).toEqual({ weekStart: 'monday' })
+    expect(parseWeekStart(url('weekStart=Sunday'))).toEqual({
+      weekStart: 'sunday',
+    })
+    expect(parseWeekStart(url('weekStart=friday'))).toEqual({
+      error: 'weekStart must be one of monday, sunday',
+    })
+  })
+})
+
+describe('periodStartSql', () => {
+  it('uses date_trunc directly except for Sunday weeks', () => {
+    expect(periodStartSql('month', 'sunday')).toBe(
+      "date_trunc('month', t.date)",
+    )
+    expect(periodStartSql('week', 'monday')).toBe("date_trunc('week', t.date)")
+    expect(periodStartSql('week', 'sunday')).toBe(
+      "(date_trunc('week', t.date + interval '1 day') - interval '1 day')",
+    )
+  })
+})
+
+describe('parseTimeZone', () => {
+  const url = (q: string) => new URL(`https://example.com/api?${q}`)
+
+  it('defaults to UTC', () => {
+    expect(parseTimeZone(url(''))).toEqual({ timeZone: 'UTC' })
+    expect(parseTimeZone(url('tz=utc'))).toEqual({ timeZone: 'UTC' })
+  })
+
+  it('returns the canonical spelling of known zones', () => {
+    expect(parseTimeZone(url('tz=america/new_york'))).toEqual({
+      timeZone: 'America/New_York',
+    })
+  })
+
+  it('rejects unknown names and anything else', () => {
+    const error = 'tz must be an IANA time zone name, e.g. Europe/Berlin'
+    expect(parseTimeZone(url('tz=Mars/Olympus'))).toEqual({ error })
+    expect(parseTimeZone(url("tz=UTC'; DROP TABLE x"))).toEqual({ error })
+  })
+})
+
+describe('time zone periods', () => {
+  it('truncates on the local calendar and returns the local instant', () => {
+    expect(periodStartSql('month', 'monday', 't.date', 'Asia/Tokyo')).toBe(
+      "(date_trunc('month', (t.date AT TIME ZONE 'Asia/Tokyo')) AT TIME ZONE 'Asia/Tokyo')",
+    )
+  })
+
+  it('labels weeks by their local date', () => {
+    expect(weekLabelSql('period', 'monday', 'Asia/Tokyo')).toBe(
+      `to_char((period AT TIME ZONE 'Asia/Tokyo'), 'IYYY-"W"IW')`,
+    )
+  })
+})
+
+describe('fillSlots', () => {
+  it('covers every slot with zeros where absent', () => {
+    const filled = fillSlots([{ slot: 6, total: '42.50', count: 3 }], 7)
+    expect(filled).toHaveLength(7)
+    expect(filled[0]).toEqual({ slot: 0, total: '0', count: 0 })
+    expect(filled[6]).toEqual({ slot: 6, total: '42.50', count: 3 })
+  })
+})
+
+describe('comparePeriods', () => {
+  const totals = (income: string, expense: string, net: string) => ({
+    income,
+    expense,
+    net,
+  })
+
+  it('pairs period and category totals, zero-filling gaps', () => {
+    const result = comparePeriods([
+      {
+        period: 'a',
+        isTotal: true,
+        categoryId: null,
+        category: null,
+        ...totals('100', '40', '60'),
+      },
+      {
+        period: 'a',
+        isTotal: false,
+        categoryId: 'cat-food',
+        category: 'Food',
+        ...totals('0', '40', '-40'),
+      },
+      {
+        period: 'a',
+        isTotal: false,
+        categoryId: null,
+        category: null,
+        ...totals('100', '0', '100'),
+      },
+    ])
+    expect(result.periodA).toEqual(totals('100', '40', '60'))
+    // Nothing happened in period B.
+    expect(result.periodB).toEqual(totals('0', '0', '0'))
+    expect(result.categories).toEqual([
+      {
+        categoryId: 'cat-food',
+        name: 'Food',
+        periodA: totals('0', '40', '-40'),
+        periodB: totals('0', '0', '0'),
+      },
+      {
+        categoryId: null,
+        name: null,
+        periodA: totals('100', '0', '100'),
+        periodB: totals('0', '0', '0'),
+      },
+    ])
+  })
+})
+
+describe('carryover', () => {
+  it('carries each month\'s net into the next', () => {
+    expect(
+      carryover('100.0000', [
+        { month: '2025-01', income: '2000.0000', expense: '1500.2500' },
+        { month: '2025-02', income: '0', expense: '0' },
+        { month: '2025-03', income: '300.0000', expense: '1200.0000' },
+      ]),
+    ).toEqual([
+      {
+        month: '2025-01',
+        income: '2000.0000',
+        expense: '1500.2500',
+        net: '499.7500',
+        carryover: '599.7500',
+      },
+      {
+        month: '2025-02',
+        income: '0',
+        expense: '0',
+        net: '0.0000',
+        carryover: '599.7500',
+      },
+      {
+        month: '2025-03',
+        income: '300.0000',
+        expense: '1200.0000',
+        net: '-900.0000',
+        carryover: '-300.2500',
+      },
+    ])
+  })
+
+  it('adds exactly where floats would drift', () => {
+    const months = Array.from({ length: 10 }, (_, i) => ({
+      month: `2025-${String(i + 1).padStart(2, '0')}`,
+      income: '0.1',
+      expense: '0',
+    }))
+    expect(carryover('0', months).at(-1)?.carryover).toBe('1.0000')
+  })
+})
+
+describe('parseRound', () => {
+  const url = (q: string) => new URL(`https://example.com/api?${q}`)
+
+  it('defaults to two places and accepts 0 to 4', () => {
+    expect(parseRound(url(''))).toEqual({ round: 2 })
+    expect(parseRound(url('round=0'))).toEqual({ round: 0 })
+    expect(parseRound(url('round=4'))).toEqual({ round: 4 })
+  })
+
+  it('rejects anything outside 0 to 4', () => {
+    const error = 'round must be an integer from 0 to 4'
+    for (const raw of ['5', '-1', '1.5', '', '02', 'two']) {
+      expect(parseRound(url(`round=${raw}`))).toEqual({ error })
+    }
+  })
+})
+
+describe('roundAmount', () => {
+  it('rounds half away from zero at the boundary digit', () => {
+    expect(roundAmount('2.0049', 2)).toBe('2.00')
+    expect(roundAmount('2.0050', 2)).toBe('2.01')
+    expect(roundAmount('-2.0050', 2)).toBe('-2.01')
+    expect(roundAmount('99.9950', 2)).toBe('100.00')
+    expect(roundAmount('0.5000', 0)).toBe('1')
+    expect(roundAmount('0.4999', 0)).toBe('0')
+  })
+
+  it('pads to the requested places and keeps four exact', () => {
+    expect(roundAmount('12', 2)).toBe('12.00')
+    expect(roundAmount('12.3456', 4)).toBe('12.3456')
+    expect(roundAmount('12.34567', 4)).toBe('12.3457')
+  })
+
+  it('does not produce negative zero', () => {
+    expect(roundAmount('-0.0040', 2)).toBe('0.00')
+  })
+
+  it('leaves values that are not decimals alone', () => {
+    expect(roundAmount('n/a', 2)).toBe('n/a')
+  })
+})
+
+describe('roundFigures', () => {
+  it('rounds only the named figures, at any depth', () => {
+    const period = new Date('2025-01-01T00:00:00Z')
+    const report = {
+      totals: { income: '10.0050', expense: '3.3333', label: '1.2345' },
+      periods: [{ period, income: '0', expense: null, count: 2 }],
+    }
+    expect(roundFigures(report, 2, ['income', 'expense'])).toEqual({
+      totals: { income: '10.01', expense: '3.33', label: '1.2345' },
+      periods: [{ period, income: '0.00', expense: null, count: 2 }],
+    })
+  })
+})
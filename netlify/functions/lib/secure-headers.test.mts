@@ -0,0 +1,61 @@
+import { describe, expect, it } from 'vitest'
+import { isHttps, parseEnabled, withSecureHeaders } from './secure-headers.mts'
+
+describe('parseEnabled', () => {
+  it('is on unless explicitly disabled', () => {
+    expect(parseEnabled(undefined)).toBe(true)
+    expect(parseEnabled('1')).toBe(true)
+    expect(parseEnabled('0')).toBe(false)
+    expect(parseEnabled('FALSE')).toBe(false)
+  })
+})
+
+describe('isHttps', () => {
+  it('prefers X-Forwarded-Proto over the request URL', () => {
+    expect(isHttps(new Request('https://example.com/api'))).toBe(true)
+    expect(isHttps(new Request('http://localhost/api'))).toBe(false)
+    expect(
+      isHttps(
+        new Request('http://internal/api', {
+          headers: { 'X-Forwarded-Proto': 'https, http' },
+        }),
+      ),
+    ).toBe(true)
+  })
+})
+
+describe('withSecureHeaders', () => {
+  it('adds hardening headers and keeps the response', async () => {
+    const res = withSecureHeaders(
+      new Request('https://example.com/api'),
+      new Response('{}', { status: 201 }),
+      true,
+    )
+    expect(res.status).toBe(201)
+    expect(await res.text()).toBe('{}')
+    expect(res.headers.get('X-Content-Type-Options')).toBe('nosniff')
+    expect(res.headers.get('X-Frame-Options')).toBe('DENY')
+    expect(res.headers.get('Strict-Transport-Security')).toBe(
+      'max-age=31536000',
+    )
+  })
+
+  it('skips HSTS over plain HTTP', () => {
+    const res = withSecureHeaders(
+      new Request('http://localhost/api'),
+      new Response(null),
+      true,
+    )
+    expect(res.headers.get('X-Frame-Options')).toBe('DENY')
+    expect(res.headers.has('Strict-Transport-Security')).toBe(false)
+  })
+
+  it('can be disabled', () => {
+    const res = withSecureHeaders(
+      new Request('https://example.com/api'),
+      new Response(null),
+      false,
+    )
+    expect(res.headers.has('X-Frame-Options')).toBe(false)
+  })
+})
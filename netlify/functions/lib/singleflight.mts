@@ -0,0 +1,47 @@
+import type { Sql } from './db.mts'
+import { parseFlag } from './read-only.mts'
+
+/**
+ * Coalesces concurrent calls with the same key onto one in-flight promise.
+ * Nothing is kept once it settles, so results are never served stale and a
+ * failure is only seen by the callers that were already waiting on it.
+ */
+export class SingleFlight<T> {
+  private readonly inFlight = new Map<string, Promise<T>>()
+  /** Calls that joined an existing flight instead of starting one. */
+  shared = 0
+
+  do(key: string, load: () => Promise<T>): Promise<T> {
+    const existing = this.inFlight.get(key)
+    if (existing) {
+      this.shared++
+      return existing
+    }
+    const flight = load().finally(() => this.inFlight.delete(key))
+    this.inFlight.set(key, flight)
+    return flight
+  }
+}
+
+/** COALESCE_READS: set to `1` to share identical concurrent read queries. */
+export const COALESCE_READS = parseFlag(process.env.COALESCE_READS)
+
+type Rows = Awaited<ReturnType<Sql['query']>>
+
+const reads = new SingleFlight<Rows>()
+
+/**
+ * Runs a read query, sharing one database round trip between identical
+ * concurrent queries when COALESCE_READS is on. The key is the query text
+ * and parameters, so callers must scope the query to the user themselves.
+ * Shared callers receive the same rows; treat them as read-only.
+ */
+export function sharedQuery(
+  sql: Sql,
+  text: string,
+  params: unknown[],
+  enabled = COALESCE_READS,
+): Promise<Rows> {
+  if (!enabled) return sql.query(text, params)
+  return reads.do(JSON.stringify([text, params]), () => sql.query(text, params))
+}
@@ -0,0 +1,33 @@
+import { describe, expect, it, vi } from 'vitest'
+import { SingleFlight } from './singleflight.mts'
+
+describe('SingleFlight', () => {
+  it('shares one load between concurrent calls with the same key', async () => {
+    const flight = new SingleFlight<number>()
+    let resolve!: (value: number) => void
+    const load = vi.fn(() => new Promise<number>((r) => (resolve = r)))
+    const a = flight.do('k', load)
+    const b = flight.do('k', load)
+    resolve(7)
+    expect(await Promise.all([a, b])).toEqual([7, 7])
+    expect(load).toHaveBeenCalledTimes(1)
+    expect(flight.shared).toBe(1)
+  })
+
+  it('keeps different keys apart', async () => {
+    const flight = new SingleFlight<string>()
+    const load = vi.fn(async () => 'x')
+    await Promise.all([flight.do('a', load), flight.do('b', load)])
+    expect(load).toHaveBeenCalledTimes(2)
+  })
+
+  it('does not keep results or errors after the flight settles', async () => {
+    const flight = new SingleFlight<string>()
+    await expect(
+      flight.do('k', async () => {
+        throw new Error('boom')
+      }),
+    ).rejects.toThrow('boom')
+    expect(await flight.do('k', async () => 'ok')).toBe('ok')
+  })
+})
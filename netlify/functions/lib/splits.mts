@@ -0,0 +1,94 @@
+import { AMOUNT_UNITS, parseAmountIn, toMinorAmount } from './amount.mts'
+import type { AmountUnits } from './amount.mts'
+import { isUuid } from './params.mts'
+
+export interface SplitInput {
+  /** Canonical decimal string, as stored. */
+  amount: string
+  description: string
+  categoryId: string | null
+}
+
+/** NUMERIC(18,4) amounts as an exact count of ten-thousandths. */
+function toScaled(decimal: string): bigint {
+  const [, sign, whole, fraction = ''] = /^(-?)(\d+)(?:\.(\d+))?$/.exec(
+    decimal,
+  )!
+  const scaled = BigInt(whole + fraction.padEnd(4, '0').slice(0, 4))
+  return sign ? -scaled : scaled
+}
+
+function fromScaled(scaled: bigint): string {
+  const sign = scaled < 0n ? '-' : ''
+  const digits = (scaled < 0n ? -scaled : scaled).toString().padStart(5, '0')
+  return `${sign}${digits.slice(0, -4)}.${digits.slice(-4)}`
+}
+
+/**
+ * Parses and validates a list of split line items against the parent
+ * transaction's stored decimal amount. Split amounts are read in `units`
+ * and summed exactly; the total must round to the same number of cents
+ * as the parent, so three splits of 3.333 cover 10.00. Returns the
+ * normalized splits, or an error message suitable for a 400 response.
+ */
+export function parseSplits(
+  parentAmount: string,
+  raw: unknown,
+  units: AmountUnits = AMOUNT_UNITS,
+): { splits: SplitInput[] } | { error: string } {
+  if (!Array.isArray(raw) || raw.length === 0) {
+    return { error: 'splits must be a non-empty array' }
+  }
+
+  const splits: SplitInput[] = []
+  let total = 0n
+  for (const item of raw as unknown[]) {
+    if (typeof item !== 'object' || item === null || Array.isArray(item)) {
+      return { error: 'each split must be an object' }
+    }
+    const entry = item as Record<string, unknown>
+    const amount = parseAmountIn(entry.amount, units)
+    if (amount === null || toScaled(amount) <= 0n) {
+      return { error: 'each split amount must be a positive number' }
+    }
+    const categoryId = entry.category_id ?? null
+    if (
+      categoryId !== null &&
+      (typeof categoryId !== 'string' || !isUuid(categoryId))
+    ) {
+      return { error: 'each split category_id must be a UUID' }
+    }
+    const description =
+      typeof entry.description === 'string' ? entry.description : ''
+    splits.push({
+      amount,
+      description,
+      categoryId: categoryId === null ? null : categoryId.toLowerCase(),
+    })
+    total += toScaled(amount)
+  }
+
+  const parentCents = toMinorAmount(parentAmount)
+  if (toMinorAmount(fromScaled(total)) !== parentCents) {
+    const shown = units === 'minor' ? parentCents : parentAmount
+    return {
+      error: `split amounts must sum to the transaction amount (${shown})`,
+    }
+  }
+  return { splits }
+}
+
+/**
+ * SQL for a `t` relation shaped like `transactions` in which a split
+ * transaction is replaced by one row per split, carrying the split's
+ * amount and its category (the parent's when the split has none).
+ * Unsplit transactions pass through unchanged. Reports select from this
+ * instead of `transactions t` to break categories down by split.
+ */
+export const SPLIT_LINES = `(
+  SELECT t.id, t.account_id, t.date, t.type, t.status, t.scheduled, t.transfer_group,
+    COALESCE(s.amount, t.amount) AS amount,
+    COALESCE(s.category_id, t.category_id) AS category_id
+  FROM transactions t
+  LEFT JOIN transaction_splits s ON s.transaction_id = t.id
+) t`
@@ -0,0 +1,91 @@
+import { describe, expect, it } from 'vitest'
+import { parseSplits } from './splits.mts'
+
+describe('parseSplits', () => {
+  it('accepts splits that sum to the parent amount', () => {
+    const result = parseSplits('100.0000', [
+      { amount: 60.5, description: 'groceries' },
+      { amount: '39.50', description: 'household' },
+    ])
+    expect(result).toEqual({
+      splits: [
+        { amount: '60.5', description: 'groceries', categoryId: null },
+        { amount: '39.50', description: 'household', categoryId: null },
+      ],
+    })
+  })
+
+  it('tolerates rounding differences', () => {
+    const result = parseSplits('10.0000', [
+      { amount: 3.333 },
+      { amount: 3.333 },
+      { amount: 3.333 },
+    ])
+    expect('splits' in result).toBe(true)
+  })
+
+  it('sums exactly, without float drift', () => {
+    // 0.1 + 0.2 is 0.30000000000000004 as floats.
+    const result = parseSplits('0.3000', [{ amount: 0.1 }, { amount: 0.2 }])
+    expect('splits' in result).toBe(true)
+    expect(
+      parseSplits('0.3000', [{ amount: '0.1' }, { amount: '0.21' }]),
+    ).toHaveProperty('error')
+  })
+
+  it('rejects splits that do not sum to the parent amount', () => {
+    const result = parseSplits('100.0000', [{ amount: 60 }, { amount: 30 }])
+    expect(result).toEqual({
+      error: 'split amounts must sum to the transaction amount (100.0000)',
+    })
+  })
+
+  it('reads amounts in minor units', () => {
+    const result = parseSplits(
+      '12.5000',
+      [{ amount: 1000 }, { amount: '250' }],
+      'minor',
+    )
+    expect(result).toEqual({
+      splits: [
+        { amount: '10.00', description: '', categoryId: null },
+        { amount: '2.50', description: '', categoryId: null },
+      ],
+    })
+    expect(parseSplits('12.5000', [{ amount: 12.5 }], 'minor')).toEqual({
+      error: 'each split amount must be a positive number',
+    })
+    expect(parseSplits('12.5000', [{ amount: 1200 }], 'minor')).toEqual({
+      error: 'split amounts must sum to the transaction amount (1250)',
+    })
+  })
+
+  it('keeps a split category', () => {
+    const categoryId = '7c9e6679-7425-40de-944b-e07fc1f90ae7'
+    const result = parseSplits('5.0000', [
+      { amount: 5, category_id: categoryId },
+    ])
+    expect(result).toEqual({
+      splits: [{ amount: '5', description: '', categoryId }],
+    })
+    expect(parseSplits('5.0000', [{ amount: 5, category_id: 'food' }])).toEqual(
+      { error: 'each split category_id must be a UUID' },
+    )
+  })
+
+  it('rejects empty, non-array, and non-positive input', () => {
+    expect(parseSplits('10', [])).toHaveProperty('error')
+    expect(parseSplits('10', 'nope')).toHaveProperty('error')
+    expect(parseSplits('10', [{ amount: 'abc' }])).toHaveProperty('error')
+    expect(parseSplits('0', [{ amount: 0 }])).toHaveProperty('error')
+  })
+
+  it.each([null, 1, 'split', [{ amount: 1 }]])(
+    'rejects a split that is not an object (%j)',
+    (item) => {
+      expect(parseSplits('1', [item])).toEqual({
+        error: 'each split must be an object',
+      })
+    },
+  )
+})
@@ -0,0 +1,18 @@
+export const MAX_TAG_LENGTH = 50
+
+/**
+ * Validates a list of tags: strings, trimmed, non-empty, at most
+ * MAX_TAG_LENGTH characters. Duplicates are dropped. Returns null when
+ * invalid.
+ */
+export function parseTags(value: unknown): string[] | null {
+  if (!Array.isArray(value)) return null
+  const tags = new Set<string>()
+  for (const raw of value) {
+    if (typeof raw !== 'string') return null
+    const tag = raw.trim()
+    if (!tag || [...tag].length > MAX_TAG_LENGTH) return null
+    tags.add(tag)
+  }
+  return [...tags]
+}
@@ -0,0 +1,19 @@
+import { describe, expect, it } from 'vitest'
+import { MAX_TAG_LENGTH, parseTags } from './tags.mts'
+
+describe('parseTags', () => {
+  it('trims tags and drops duplicates', () => {
+    expect(parseTags([' travel', 'food', 'travel '])).toEqual([
+      'travel',
+      'food',
+    ])
+    expect(parseTags([])).toEqual([])
+  })
+
+  it('rejects non-arrays, non-strings, blanks, and overlong tags', () => {
+    expect(parseTags('travel')).toBeNull()
+    expect(parseTags(['ok', 3])).toBeNull()
+    expect(parseTags(['  '])).toBeNull()
+    expect(parseTags(['x'.repeat(MAX_TAG_LENGTH + 1)])).toBeNull()
+  })
+})
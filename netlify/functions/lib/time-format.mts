@@ -0,0 +1,41 @@
+export const TIME_PRECISIONS = ['seconds', 'milliseconds'] as const
+
+export type TimePrecision = (typeof TIME_PRECISIONS)[number]
+
+/**
+ * Parses JSON_TIME_PRECISION. Unset or unknown values fall back to
+ * `seconds`, i.e. plain RFC 3339 like `2025-02-01T09:30:00Z`.
+ */
+export function parseTimePrecision(raw: string | undefined): TimePrecision {
+  const value = raw?.trim().toLowerCase()
+  return (TIME_PRECISIONS as readonly string[]).includes(value ?? '')
+    ? (value as TimePrecision)
+    : 'seconds'
+}
+
+export const JSON_TIME_PRECISION = parseTimePrecision(
+  process.env.JSON_TIME_PRECISION,
+)
+
+/** Formats a timestamp as UTC RFC 3339 at the given precision. */
+export function formatTime(
+  date: Date,
+  precision: TimePrecision = JSON_TIME_PRECISION,
+): string {
+  const iso = date.toISOString()
+  return precision === 'seconds' ? iso.replace(/\.\d{3}Z$/, 'Z') : iso
+}
+
+/**
+ * JSON.stringify replacer that formats every Date with formatTime. Dates
+ * are already strings by the time a replacer sees the value, so it reads
+ * the original from the holder object.
+ */
+export function timeReplacer(precision: TimePrecision = JSON_TIME_PRECISION) {
+  return function (this: Record<string, unknown>, key: string, value: unknown) {
+    const original = this[key]
+    return original instanceof Date && !Number.isNaN(original.getTime())
+      ? formatTime(original, precision)
+      : value
+  }
+}
@@ -0,0 +1,39 @@
+import { describe, expect, it } from 'vitest'
+import { formatTime, parseTimePrecision, timeReplacer } from './time-format.mts'
+
+const date = new Date('2025-02-01T09:30:15.123Z')
+
+describe('formatTime', () => {
+  it('emits plain RFC 3339 at seconds precision by default', () => {
+    expect(formatTime(date, 'seconds')).toBe('2025-02-01T09:30:15Z')
+  })
+
+  it('keeps milliseconds when configured', () => {
+    expect(formatTime(date, 'milliseconds')).toBe('2025-02-01T09:30:15.123Z')
+  })
+
+  it('round-trips its own output', () => {
+    const once = formatTime(date, 'seconds')
+    expect(formatTime(new Date(once), 'seconds')).toBe(once)
+  })
+})
+
+describe('timeReplacer', () => {
+  it('formats nested dates and leaves strings alone', () => {
+    const body = JSON.stringify(
+      { rows: [{ date, note: '2025-02-01T09:30:15.123Z' }] },
+      timeReplacer('seconds'),
+    )
+    expect(body).toBe(
+      '{"rows":[{"date":"2025-02-01T09:30:15Z","note":"2025-02-01T09:30:15.123Z"}]}',
+    )
+  })
+})
+
+describe('parseTimePrecision', () => {
+  it('defaults to seconds', () => {
+    expect(parseTimePrecision(undefined)).toBe('seconds')
+    expect(parseTimePrecision('nanos')).toBe('seconds')
+    expect(parseTimePrecision(' Milliseconds ')).toBe('milliseconds')
+  })
+})
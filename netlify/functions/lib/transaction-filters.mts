@@ -0,0 +1,145 @@
+import { AMOUNT_UNITS, parseAmountIn } from './amount.mts'
+import type { AmountUnits } from './amount.mts'
+import {
+  isUuid,
+  parsePeriod,
+  parseRollingPeriod,
+  parseTransactionStatus,
+  parseTransactionType,
+} from './params.mts'
+import { escapeLike } from './query.mts'
+import type { QueryBuilder } from './query.mts'
+
+/**
+ * Applies the shared transaction list filters (`q`, `type`, `status`,
+ * `cleared`, `categoryId`, `uncategorized`, `emptyDescription`,
+ * `exactAmount`, `from`, `to`, `period`, `createdFrom`, `createdTo`) to a
+ * query over `transactions t`.
+ * `period` is resolved against `now`; see parseRollingPeriod, and
+ * `exactAmount` is read in `units`. Returns an error message for bad
+ * input.
+ */
+export function applyTransactionFilters(
+  q: QueryBuilder,
+  url: URL,
+  now: Date = new Date(),
+  units: AmountUnits = AMOUNT_UNITS,
+): string | null {
+  const search = url.searchParams.get('q')?.trim()
+  if (search) {
+    q.where(`t.description ILIKE ${q.param(`%${escapeLike(search)}%`)}`)
+  }
+
+  const rawType = url.searchParams.get('type')
+  if (rawType?.trim()) {
+    const type = parseTransactionType(rawType)
+    if (!type) {
+      return 'type must be income or expense'
+    }
+    q.where(`t.type = ${q.param(type)}`)
+  }
+
+  const rawStatus = url.searchParams.get('status')
+  if (rawStatus?.trim()) {
+    const status = parseTransactionStatus(rawStatus)
+    if (!status) return 'status must be pending or posted'
+    q.where(`t.status = ${q.param(status)}`)
+  }
+
+  const cleared = url.searchParams.get('cleared')?.trim()
+  if (cleared) {
+    if (cleared !== 'true' && cleared !== 'false') {
+      return 'cleared must be true or false'
+    }
+    q.where(`t.cleared = ${cleared}`)
+  }
+
+  const categoryId = url.searchParams.get('categoryId')?.trim()
+  const uncategorized = url.searchParams.get('uncategorized') === 'true'
+  if (categoryId && uncategorized) {
+    return 'categoryId and uncategorized cannot be combined'
+  }
+  if (categoryId) {
+    if (!isUuid(categoryId)) return 'categoryId must be a UUID'
+    q.where(`t.category_id = ${q.param(categoryId)}`)
+  }
+  if (uncategorized) q.where('t.category_id IS NULL')
+
+  // Blank descriptions, typically left by imports, for cleanup.
+  if (url.searchParams.get('emptyDescription') === 'true')
+    q.where("t.description = ''")
+
+  // Every instance of one charge, such as a subscription. The amount is
+  // compared as NUMERIC, so decimals match exactly, and by magnitude, so
+  // `-9.99` finds a 9.99 expense too.
+  const rawAmount = url.searchParams.get('exactAmount')
+  if (rawAmount?.trim()) {
+    const amount = parseAmountIn(rawAmount, units)
+    if (amount === null) return 'exactAmount must be a number'
+    q.where(`ABS(t.amount) = ABS(${q.param(amount)}::numeric)`)
+  }
+
+  const parsed = parsePeriod(url)
+  if ('error' in parsed) return parsed.error
+  const rolling = url.searchParams.get('period')?.trim()
+  if (rolling) {
+    if (parsed.period.from || parsed.period.to)
+      return 'period cannot be combined with from or to'
+    const range = parseRollingPeriod(rolling, now)
+    if (!range)
+      return 'period must be a count of days, weeks, months or years (e.g. 30d, 2w, 6m, 1y), mtd or ytd'
+    parsed.period = range
+  }
+  if (parsed.period.from) q.where(`t.date >= ${q.param(parsed.period.from)}`)
+  if (parsed.period.to) q.where(`t.date <= ${q.param(parsed.period.to)}`)
+
+  // When the transaction was entered, as opposed to when it happened.
+  const entered = parsePeriod(url, ['createdFrom', 'createdTo'])
+  if ('error' in entered) return entered.error
+  const { from: createdFrom, to: createdTo } = entered.period
+  if (createdFrom) q.where(`t.created_at >= ${q.param(createdFrom)}`)
+  if (createdTo) q.where(`t.created_at <= ${q.param(createdTo)}`)
+
+  return null
+}
+
+/**
+ * What makes a transaction incomplete, as SQL over `transactions t`: a
+ * blank description, no category, or a zero amount.
+ */
+export const COMPLETENESS_CHECKS = {
+  description: "t.description = ''",
+  category: 't.category_id IS NULL',
+  amount: 't.amount = 0',
+} as const
+
+export type CompletenessCheck = keyof typeof COMPLETENESS_CHECKS
+
+/**
+ * Reads `COMPLETENESS_FIELDS`, a comma-separated subset of
+ * COMPLETENESS_CHECKS; unset means all of them. Unknown names throw so a
+ * typo fails at startup instead of silently checking less.
+ */
+export function parseCompletenessChecks(
+  raw: string | undefined,
+): CompletenessCheck[] {
+  const names = (raw ?? '')
+    .split(',')
+    .map((name) => name.trim().toLowerCase())
+    .filter(Boolean)
+  if (!names.length) {
+    return Object.keys(COMPLETENESS_CHECKS) as CompletenessCheck[]
+  }
+  for (const name of names) {
+    if (!Object.hasOwn(COMPLETENESS_CHECKS, name)) {
+      throw new Error(
+        `COMPLETENESS_FIELDS must list only ${Object.keys(COMPLETENESS_CHECKS).join(', ')}, got ${JSON.stringify(raw)}`,
+      )
+    }
+  }
+  return [...new Set(names)] as CompletenessCheck[]
+}
+
+export const COMPLETENESS_FIELDS = parseCompletenessChecks(
+  process.env.COMPLETENESS_FIELDS,
+)
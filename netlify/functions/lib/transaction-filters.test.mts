@@ -0,0 +1,136 @@
+import { describe, expect, it } from 'vitest'
+import { QueryBuilder } from './query.mts'
+import {
+  applyTransactionFilters,
+  parseCompletenessChecks,
+} from './transaction-filters.mts'
+
+function apply(query: string, now?: Date) {
+  const q = new QueryBuilder()
+  const error = applyTransactionFilters(
+    q,
+    new URL(`https://example.com/transactions?${query}`),
+    now,
+  )
+  return { error, where: q.whereSql(), params: q.params }
+}
+
+describe('applyTransactionFilters', () => {
+  it('finds uncategorized transactions alongside other filters', () => {
+    expect(apply('uncategorized=true&type=expense')).toEqual({
+      error: null,
+      where: 'WHERE t.type = $1 AND t.category_id IS NULL',
+      params: ['expense'],
+    })
+  })
+
+  it('finds transactions with an empty description', () => {
+    expect(apply('emptyDescription=true&uncategorized=true')).toEqual({
+      error: null,
+      where: "WHERE t.category_id IS NULL AND t.description = ''",
+      params: [],
+    })
+    expect(apply('emptyDescription=false').where).toBe('')
+  })
+
+  it('matches an exact amount alongside the description search', () => {
+    expect(apply('exactAmount=9.99&q=netflix')).toEqual({
+      error: null,
+      where:
+        'WHERE t.description ILIKE $1 AND ABS(t.amount) = ABS($2::numeric)',
+      params: ['%netflix%', '9.99'],
+    })
+    expect(apply('exactAmount=9.99.1').error).toBe(
+      'exactAmount must be a number',
+    )
+  })
+
+  it('filters by category', () => {
+    const id = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+    expect(apply(`categoryId=${id}`)).toMatchObject({
+      where: 'WHERE t.category_id = $1',
+      params: [id],
+    })
+  })
+
+  it('rejects contradictory or malformed category filters', () => {
+    const id = '0b6f2f4e-4c5e-4f59-9a3e-1f2d3c4b5a60'
+    expect(apply(`categoryId=${id}&uncategorized=true`).error).toBe(
+      'categoryId and uncategorized cannot be combined',
+    )
+    expect(apply('categoryId=food').error).toBe('categoryId must be a UUID')
+  })
+
+  it('filters by pending or posted status', () => {
+    expect(apply('status=Pending')).toEqual({
+      error: null,
+      where: 'WHERE t.status = $1',
+      params: ['pending'],
+    })
+    expect(apply('status=settled').error).toBe(
+      'status must be pending or posted',
+    )
+  })
+
+  it('rejects an unknown cleared value', () => {
+    expect(apply('cleared=maybe').error).toBe('cleared must be true or false')
+  })
+
+  it('filters by entry time separately from the transaction date', () => {
+    expect(
+      apply('from=2025-01-01&createdFrom=2025-03-01&createdTo=2025-03-08'),
+    ).toEqual({
+      error: null,
+      where:
+        'WHERE t.date >= $1 AND t.created_at >= $2 AND t.created_at <= $3',
+      params: ['2025-01-01', '2025-03-01', '2025-03-08'],
+    })
+  })
+
+  it('rejects a reversed or malformed entry window', () => {
+    expect(apply('createdFrom=2025-03-08&createdTo=2025-03-01').error).toBe(
+      'createdFrom must not be after createdTo',
+    )
+    expect(apply('createdTo=yesterday').error).toBe(
+      'createdTo must be a valid date',
+    )
+  })
+
+  it('turns a rolling period into a date range ending now', () => {
+    expect(apply('period=7d', new Date('2025-03-15T12:00:00Z'))).toEqual({
+      error: null,
+      where: 'WHERE t.date >= $1 AND t.date <= $2',
+      params: ['2025-03-08T12:00:00.000Z', '2025-03-15T12:00:00.000Z'],
+    })
+  })
+
+  it('rejects an unknown period or one combined with from/to', () => {
+    expect(apply('period=fortnight').error).toMatch(/^period must be/)
+    expect(apply('period=mtd&from=2025-01-01').error).toBe(
+      'period cannot be combined with from or to',
+    )
+  })
+})
+
+describe('parseCompletenessChecks', () => {
+  it('checks everything when unset', () => {
+    expect(parseCompletenessChecks(undefined)).toEqual([
+      'description',
+      'category',
+      'amount',
+    ])
+  })
+
+  it('reads a comma-separated subset', () => {
+    expect(parseCompletenessChecks(' Description, amount,amount')).toEqual([
+      'description',
+      'amount',
+    ])
+  })
+
+  it('rejects unknown fields', () => {
+    expect(() => parseCompletenessChecks('description,payee')).toThrow(
+      /COMPLETENESS_FIELDS/,
+    )
+  })
+})
@@ -0,0 +1,380 @@
+import { createHash } from 'node:crypto'
+import { parseAmount } from './amount.mts'
+import { parseCsv } from './csv.mts'
+import type { Sql } from './db.mts'
+import { newId } from './ids.mts'
+import { parseTransactionType } from './params.mts'
+import type { TransactionType } from './params.mts'
+
+/** Accepted `format` values; QFX is Quicken's name for OFX. */
+export const IMPORT_FORMATS = ['csv', 'ofx', 'qfx'] as const
+
+/**
+ * What to do with a row whose external id is already in the account:
+ * `skip` it, `update` the existing transaction from it (to pick up a bank's
+ * corrections), or reject the whole import with an `error`.
+ */
+export const DUPLICATE_MODES = ['skip', 'update', 'error'] as const
+
+export type DuplicateMode = (typeof DUPLICATE_MODES)[number]
+
+export const CSV_COLUMNS = ['date', 'amount', 'description', 'type'] as const
+
+/**
+ * How a CSV row's income/expense is read: from its `type` column
+ * (`typeColumn`, the default), or from the sign of its amount, for banks
+ * that export spending as negative (`negativeIsExpense`) or, like most
+ * card statements, as positive (`positiveIsExpense`). Signed amounts are
+ * stored as their magnitude and any type column is ignored.
+ */
+export const SIGN_MODES = [
+  'typeColumn',
+  'negativeIsExpense',
+  'positiveIsExpense',
+] as const
+
+export type SignMode = (typeof SIGN_MODES)[number]
+
+export type CsvColumn = (typeof CSV_COLUMNS)[number]
+
+/** Column index per field; -1 when the file has no such column. */
+export type CsvMapping = Record<CsvColumn, number>
+
+export interface ImportRow {
+  date: string
+  amount: string
+  description: string
+  type: TransactionType
+  /** Bank-assigned id (OFX FITID); rows already imported are skipped. */
+  externalId?: string
+  /** Set by withContentHashes for rows without a bank id. */
+  contentHash?: string
+}
+
+export interface ImportError {
+  /** 1-based line number in the source file, counting the header. */
+  row: number
+  error: string
+}
+
+/** Columns a CSV needs under a sign mode; description is always optional. */
+function requiredCsvColumns(signMode: SignMode): CsvColumn[] {
+  return signMode === 'typeColumn'
+    ? ['date', 'amount', 'type']
+    : ['date', 'amount']
+}
+
+/** Validates one CSV record, reading each field from its mapped column. */
+export function parseCsvRecord(
+  record: string[],
+  index: CsvMapping,
+  signMode: SignMode = 'typeColumn',
+): ImportRow | { error: string } {
+  const field = (name: CsvColumn) =>
+    index[name] === -1 ? '' : (record[index[name]] ?? '').trim()
+
+  const date = new Date(field('date'))
+  if (!field('date') || Number.isNaN(date.getTime()))
+    return { error: 'date must be a valid date' }
+  let amount = parseAmount(field('amount'))
+  if (amount === null) return { error: 'amount must be a number' }
+  let type: TransactionType | null
+  if (signMode === 'typeColumn') {
+    type = parseTransactionType(field('type'))
+    if (!type) return { error: 'type must be income or expense' }
+  } else {
+    if (Number(amount) === 0)
+      return { error: 'amount must be non-zero to infer its type' }
+    const negative = amount.startsWith('-')
+    type =
+      negative === (signMode === 'negativeIsExpense') ? 'expense' : 'income'
+    amount = amount.replace(/^-/, '')
+  }
+  return {
+    date: date.toISOString(),
+    amount,
+    description: field('description'),
+    type,
+  }
+}
+
+/**
+ * Parses and validates a CSV export with a `date,amount,description,type`
+ * header (any column order, case-insensitive). Valid rows and per-row errors
+ * are both returned so callers can preview or persist the valid subset.
+ */
+export function parseCsvTransactions(
+  text: string,
+  signMode: SignMode = 'typeColumn',
+): {
+  rows: ImportRow[]
+  errors: ImportError[]
+} {
+  const [header, ...records] = parseCsv(text.replace(/^\uFEFF/, ''))
+  if (!header) return { rows: [], errors: [{ row: 1, error: 'file is empty' }] }
+
+  const columns = header.map((h) => h.trim().toLowerCase())
+  const index = Object.fromEntries(
+    CSV_COLUMNS.map((name) => [name, columns.indexOf(name)]),
+  ) as CsvMapping
+  const missing = requiredCsvColumns(signMode).filter(
+    (name) => index[name] === -1,
+  )
+  if (missing.length) {
+    return {
+      rows: [],
+      errors: [{ row: 1, error: `missing columns: ${missing.join(', ')}` }],
+    }
+  }
+
+  const rows: ImportRow[] = []
+  const errors: ImportError[] = []
+  records.forEach((record, i) => {
+    const parsed = parseCsvRecord(record, index, signMode)
+    if ('error' in parsed) errors.push({ row: i + 2, error: parsed.error })
+    else rows.push(parsed)
+  })
+
+  return { rows, errors }
+}
+
+/**
+ * Header names banks commonly use for each field, lowercased with
+ * punctuation collapsed to spaces, best match first.
+ */
+const CSV_COLUMN_ALIASES: Record<CsvColumn, readonly string[]> = {
+  date: [
+    'date',
+    'transaction date',
+    'posted date',
+    'posting date',
+    'booking date',
+    'value date',
+    'trans date',
+  ],
+  amount: ['amount', 'transaction amount', 'amt', 'value'],
+  description: [
+    'description',
+    'memo',
+    'payee',
+    'details',
+    'narrative',
+    'name',
+    'reference',
+  ],
+  type: [
+    'type',
+    'transaction type',
+    'credit debit',
+    'debit credit',
+    'cr dr',
+    'dr cr',
+  ],
+}
+
+function normalizeHeader(name: string): string {
+  return name
+    .toLowerCase()
+    .replace(/[^a-z0-9]+/g, ' ')
+    .trim()
+}
+
+/**
+ * Suggests the column for each field from a CSV header, recognising the
+ * names common bank exports use (`Posted Date`, `Memo`, `Credit/Debit`...).
+ */
+export function suggestCsvMapping(header: string[]): CsvMapping {
+  const names = header.map(normalizeHeader)
+  return Object.fromEntries(
+    CSV_COLUMNS.map((column) => {
+      const alias = CSV_COLUMN_ALIASES[column].find((a) => names.includes(a))
+      return [column, alias === undefined ? -1 : names.indexOf(alias)]
+    }),
+  ) as CsvMapping
+}
+
+/** Rows parsed for an inspection preview. */
+export const PREVIEW_ROWS = 5
+
+export interface CsvPreviewRow {
+  /** 1-based line number in the source file, counting the header. */
+  row: number
+  /** The raw value of each mapped field. */
+  fields: Record<CsvColumn, string>
+  /** The row as it would import under the suggested mapping. */
+  transaction: ImportRow | null
+  error: string | null
+}
+
+export interface CsvInspection {
+  columns: string[]
+  /** Suggested header column per field; null when nothing matched. */
+  mapping: Record<CsvColumn, string | null>
+  /** Required fields the suggestion could not place. */
+  missing: CsvColumn[]
+  /** Data rows in the file, not counting the header. */
+  totalRows: number
+  preview: CsvPreviewRow[]
+}
+
+/**
+ * Reads a CSV's header and first rows without importing anything, for a
+ * client building a column-mapping screen: the columns found, a suggested
+ * column for each field, and how the first PREVIEW_ROWS rows would parse
+ * under that suggestion and the sign mode.
+ */
+export function inspectCsv(
+  text: string,
+  signMode: SignMode = 'typeColumn',
+  previewRows = PREVIEW_ROWS,
+): CsvInspection | { error: string } {
+  const [header, ...records] = parseCsv(text.replace(/^\uFEFF/, ''))
+  if (!header) return { error: 'file is empty' }
+
+  const columns = header.map((h) => h.trim())
+  const index = suggestCsvMapping(columns)
+  const mapping = Object.fromEntries(
+    CSV_COLUMNS.map((name) => [
+      name,
+      index[name] === -1 ? null : columns[index[name]],
+    ]),
+  ) as Record<CsvColumn, string | null>
+  const missing = requiredCsvColumns(signMode).filter(
+    (name) => index[name] === -1,
+  )
+
+  const preview = records.slice(0, previewRows).map((record, i) => {
+    const fields = Object.fromEntries(
+      CSV_COLUMNS.map((name) => [
+        name,
+        index[name] === -1 ? '' : (record[index[name]] ?? '').trim(),
+      ]),
+    ) as Record<CsvColumn, string>
+    const parsed = parseCsvRecord(record, index, signMode)
+    return {
+      row: i + 2,
+      fields,
+      transaction: 'error' in parsed ? null : parsed,
+      error: 'error' in parsed ? parsed.error : null,
+    }
+  })
+
+  return { columns, mapping, missing, totalRows: records.length, preview }
+}
+
+/**
+ * Fingerprints rows that have no bank id (CSV rows) so re-importing the
+ * same file can skip them. The hash covers the account, date, amount,
+ * description and type, plus the row's occurrence among identical rows in
+ * the file: two genuine same-day coffees in one statement are both
+ * imported, and only the ones already present are skipped next time.
+ */
+export function withContentHashes(
+  accountId: string,
+  rows: ImportRow[],
+): ImportRow[] {
+  const occurrences = new Map<string, number>()
+  return rows.map((row) => {
+    if (row.externalId) return row
+    // Drop trailing zeros so 12.5 and 12.50 hash alike.
+    const amount = row.amount.replace(/(\.\d*?)0+$/, '$1').replace(/\.$/, '')
+    const content = JSON.stringify([
+      accountId,
+      row.date,
+      amount,
+      row.description,
+      row.type,
+    ])
+    const occurrence = (occurrences.get(content) ?? 0) + 1
+    occurrences.set(content, occurrence)
+    const contentHash = createHash('sha256')
+      .update(`${content}#${occurrence}`)
+      .digest('hex')
+    return { ...row, contentHash }
+  })
+}
+
+/**
+ * Inserts validated rows into an account with a single statement, tagged
+ * with the import's batch id. Rows whose external id was imported before are
+ * skipped, or with `update` overwrite the existing transaction's amount,
+ * date, description and type. Updated transactions keep their original batch
+ * id, so rolling back this import does not delete them.
+ */
+export async function insertImportRows(
+  sql: Sql,
+  accountId: string,
+  rows: ImportRow[],
+  batchId: string,
+  onDuplicate: DuplicateMode = 'skip',
+): Promise<{ imported: number; updated: number }> {
+  if (rows.length === 0) return { imported: 0, updated: 0 }
+  // A conflicting row only changes when the mode is update; otherwise the
+  // WHERE leaves it alone and it is not returned, as with DO NOTHING. xmax
+  // is zero on freshly inserted rows.
+  const written = await sql`
+    WITH written AS (
+      INSERT INTO transactions (id, account_id, amount, date, description, type, external_id, content_hash, import_batch_id)
+      SELECT r.id, ${accountId}, r.amount, r.date, r.description, r.type, r.external_id, r.content_hash, ${batchId}
+      FROM unnest(
+        ${rows.map(() => newId())}::uuid[],
+        ${rows.map((r) => r.amount)}::numeric[],
+        ${rows.map((r) => r.date)}::timestamptz[],
+        ${rows.map((r) => r.description)}::text[],
+        ${rows.map((r) => r.type)}::text[],
+        ${rows.map((r) => r.externalId ?? null)}::text[],
+        ${rows.map((r) => r.contentHash ?? null)}::text[]
+      ) AS r(id, amount, date, description, type, external_id, content_hash)
+      ON CONFLICT (account_id, external_id) WHERE external_id IS NOT NULL
+        DO UPDATE SET
+          amount = EXCLUDED.amount,
+          date = EXCLUDED.date,
+          description = EXCLUDED.description,
+          type = EXCLUDED.type,
+          updated_at = now()
+        WHERE ${onDuplicate}::text = 'update'
+      RETURNING id, xmax = 0 AS inserted
+    ), touched AS (
+      UPDATE bank_accounts SET last_used_at = now()
+      WHERE id = ${accountId} AND EXISTS (SELECT 1 FROM written)
+    )
+    SELECT inserted FROM written
+  `
+  const imported = written.filter((row) => row.inserted).length
+  return { imported, updated: written.length - imported }
+}
+
+/** The rows' external ids that already exist in the account. */
+export async function findExistingExternalIds(
+  sql: Sql,
+  accountId: string,
+  rows: ImportRow[],
+): Promise<string[]> {
+  const externalIds = rows.flatMap((r) =>
+    r.externalId ? [r.externalId] : [],
+  )
+  if (externalIds.length === 0) return []
+  const existing = await sql`
+    SELECT external_id
+    FROM transactions
+    WHERE account_id = ${accountId} AND external_id = ANY(${externalIds}::text[])
+    ORDER BY external_id
+  `
+  return existing.map((row) => row.external_id as string)
+}
+
+/** The rows' content hashes that already exist in the account. */
+export async function findExistingContentHashes(
+  sql: Sql,
+  accountId: string,
+  rows: ImportRow[],
+): Promise<Set<string>> {
+  const hashes = rows.flatMap((r) => (r.contentHash ? [r.contentHash] : []))
+  if (hashes.length === 0) return new Set()
+  const existing = await sql`
+    SELECT content_hash
+    FROM transactions
+    WHERE account_id = ${accountId} AND content_hash = ANY(${hashes}::text[])
+  `
+  return new Set(existing.map((row) => row.content_hash as string))
+}
@@ -0,0 +1,219 @@
+import { describe, expect, it } from 'vitest'
+import {
+  inspectCsv,
+  parseCsvTransactions,
+  suggestCsvMapping,
+  withContentHashes,
+} from './transaction-import.mts'
+
+describe('parseCsvTransactions', () => {
+  it('parses valid rows in any column order', () => {
+    const csv = [
+      'Type,Amount,Date,Description',
+      'expense,12.50,2025-02-01,Coffee',
+      'INCOME,1000,2025-02-03T09:00:00Z,Salary',
+    ].join('\n')
+
+    expect(parseCsvTransactions(csv)).toEqual({
+      rows: [
+        {
+          date: '2025-02-01T00:00:00.000Z',
+          amount: '12.50',
+          description: 'Coffee',
+          type: 'expense',
+        },
+        {
+          date: '2025-02-03T09:00:00.000Z',
+          amount: '1000',
+          description: 'Salary',
+          type: 'income',
+        },
+      ],
+      errors: [],
+    })
+  })
+
+  it('reports invalid rows by line number', () => {
+    const csv = [
+      'date,amount,description,type',
+      'not-a-date,1,,expense',
+      '2025-02-01,abc,,expense',
+      '2025-02-01,5,,refund',
+      '2025-02-01,5,ok,expense',
+    ].join('\n')
+
+    const { rows, errors } = parseCsvTransactions(csv)
+    expect(rows).toHaveLength(1)
+    expect(errors).toEqual([
+      { row: 2, error: 'date must be a valid date' },
+      { row: 3, error: 'amount must be a number' },
+      { row: 4, error: 'type must be income or expense' },
+    ])
+  })
+
+  it('rejects files missing required columns', () => {
+    const { rows, errors } = parseCsvTransactions('date,description\n')
+    expect(rows).toEqual([])
+    expect(errors).toEqual([{ row: 1, error: 'missing columns: amount, type' }])
+  })
+
+  it('rejects empty files', () => {
+    expect(parseCsvTransactions('').errors).toEqual([
+      { row: 1, error: 'file is empty' },
+    ])
+  })
+})
+
+describe('parseCsvTransactions signMode', () => {
+  const csv = [
+    'date,amount,description',
+    '2025-02-01,-12.50,Coffee',
+    '2025-02-03,1000,Salary',
+    '2025-02-04,0,Nothing',
+  ].join('\n')
+
+  it('requires a type column by default', () => {
+    expect(parseCsvTransactions(csv).errors).toEqual([
+      { row: 1, error: 'missing columns: type' },
+    ])
+  })
+
+  it('reads negative amounts as expenses with negativeIsExpense', () => {
+    const { rows, errors } = parseCsvTransactions(csv, 'negativeIsExpense')
+    expect(rows.map(({ amount, type }) => ({ amount, type }))).toEqual([
+      { amount: '12.50', type: 'expense' },
+      { amount: '1000', type: 'income' },
+    ])
+    expect(errors).toEqual([
+      { row: 4, error: 'amount must be non-zero to infer its type' },
+    ])
+  })
+
+  it('reads positive amounts as expenses with positiveIsExpense', () => {
+    const { rows } = parseCsvTransactions(csv, 'positiveIsExpense')
+    expect(rows.map(({ amount, type }) => ({ amount, type }))).toEqual([
+      { amount: '12.50', type: 'income' },
+      { amount: '1000', type: 'expense' },
+    ])
+  })
+
+  it('ignores a type column when the sign decides', () => {
+    const typed = ['date,amount,type', '2025-02-01,-5,income'].join('\n')
+    expect(
+      parseCsvTransactions(typed, 'negativeIsExpense').rows[0],
+    ).toMatchObject({ amount: '5', type: 'expense' })
+  })
+})
+
+describe('suggestCsvMapping', () => {
+  it('recognises common bank header names', () => {
+    expect(
+      suggestCsvMapping(['Posted Date', 'Memo', 'Credit/Debit', 'Amount']),
+    ).toEqual({ date: 0, amount: 3, description: 1, type: 2 })
+  })
+
+  it('prefers an exact field name and leaves unmatched fields at -1', () => {
+    expect(suggestCsvMapping(['Value Date', 'Date', 'Balance'])).toEqual({
+      date: 1,
+      amount: -1,
+      description: -1,
+      type: -1,
+    })
+  })
+})
+
+describe('inspectCsv', () => {
+  it('returns the columns, a suggested mapping and a parsed preview', () => {
+    const csv = [
+      'Transaction Date,Payee,Amount,Type,Balance',
+      '2025-02-01,Coffee,12.50,expense,100',
+      '2025-02-02,Refund,3,credit,103',
+    ].join('\n')
+
+    expect(inspectCsv(csv)).toEqual({
+      columns: ['Transaction Date', 'Payee', 'Amount', 'Type', 'Balance'],
+      mapping: {
+        date: 'Transaction Date',
+        amount: 'Amount',
+        description: 'Payee',
+        type: 'Type',
+      },
+      missing: [],
+      totalRows: 2,
+      preview: [
+        {
+          row: 2,
+          fields: {
+            date: '2025-02-01',
+            amount: '12.50',
+            description: 'Coffee',
+            type: 'expense',
+          },
+          transaction: {
+            date: '2025-02-01T00:00:00.000Z',
+            amount: '12.50',
+            description: 'Coffee',
+            type: 'expense',
+          },
+          error: null,
+        },
+        {
+          row: 3,
+          fields: {
+            date: '2025-02-02',
+            amount: '3',
+            description: 'Refund',
+            type: 'credit',
+          },
+          transaction: null,
+          error: 'type must be income or expense',
+        },
+      ],
+    })
+  })
+
+  it('lists required fields it could not place and caps the preview', () => {
+    const csv = ['Date,Details', '2025-02-01,a', '2025-02-02,b'].join('\n')
+    const inspection = inspectCsv(csv, 1)
+    expect(inspection).toMatchObject({
+      missing: ['amount', 'type'],
+      totalRows: 2,
+    })
+    expect('preview' in inspection && inspection.preview).toHaveLength(1)
+  })
+
+  it('rejects an empty file', () => {
+    expect(inspectCsv('')).toEqual({ error: 'file is empty' })
+  })
+})
+
+describe('withContentHashes', () => {
+  const row = {
+    date: '2025-02-01T00:00:00.000Z',
+    amount: '4.50',
+    description: 'Coffee',
+    type: 'expense' as const,
+  }
+
+  it('hashes equal content alike, ignoring trailing zeros', () => {
+    const [a] = withContentHashes('acc-1', [row])
+    const [b] = withContentHashes('acc-1', [{ ...row, amount: '4.5' }])
+    const [c] = withContentHashes('acc-2', [row])
+    expect(a.contentHash).toMatch(/^[0-9a-f]{64}$/)
+    expect(b.contentHash).toBe(a.contentHash)
+    expect(c.contentHash).not.toBe(a.contentHash)
+  })
+
+  it('keeps identical rows in one file distinct', () => {
+    const [first, second] = withContentHashes('acc-1', [row, row])
+    expect(second.contentHash).not.toBe(first.contentHash)
+    // Re-importing a longer statement matches the rows seen before.
+    const [again] = withContentHashes('acc-1', [row])
+    expect(again.contentHash).toBe(first.contentHash)
+  })
+
+  it('leaves rows with a bank id alone', () => {
+    const [ofx] = withContentHashes('acc-1', [{ ...row, externalId: 'A' }])
+    expect(ofx.contentHash).toBeUndefined()
+  })
+})
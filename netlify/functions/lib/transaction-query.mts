@@ -0,0 +1,185 @@
+import { AMOUNT_UNITS, parseAmountIn } from './amount.mts'
+import type { AmountUnits } from './amount.mts'
+import {
+  TRANSACTION_STATUSES,
+  TRANSACTION_TYPES,
+  isUuid,
+  parseTransactionStatus,
+  parseTransactionType,
+} from './params.mts'
+import { escapeLike } from './query.mts'
+import type { QueryBuilder } from './query.mts'
+
+/** Comparison operators, mapped to the fixed SQL they render as. */
+const COMPARISONS = {
+  eq: '=',
+  ne: '<>',
+  gt: '>',
+  gte: '>=',
+  lt: '<',
+  lte: '<=',
+} as const
+
+type Comparison = keyof typeof COMPARISONS
+
+/**
+ * Fields a query may filter on. Each maps to a fixed column and lists the
+ * operators that make sense for it; nothing from the request body is ever
+ * written into the SQL text, only bound as a parameter.
+ */
+export const QUERY_FIELDS = {
+  amount: { column: 't.amount', ops: ['eq', 'ne', 'gt', 'gte', 'lt', 'lte'] },
+  date: { column: 't.date', ops: ['eq', 'ne', 'gt', 'gte', 'lt', 'lte'] },
+  description: {
+    column: 't.description',
+    ops: ['eq', 'ne', 'contains', 'startsWith'],
+  },
+  type: { column: 't.type', ops: ['eq', 'ne'] },
+  status: { column: 't.status', ops: ['eq', 'ne'] },
+  categoryId: { column: 't.category_id', ops: ['eq', 'ne'] },
+  tags: { column: 't.tags', ops: ['contains'] },
+} as const
+
+export type QueryField = keyof typeof QUERY_FIELDS
+
+/** Nesting allowed below the top-level group. */
+export const MAX_QUERY_DEPTH = 4
+
+/** Conditions allowed in one query, counted across all groups. */
+export const MAX_QUERY_CONDITIONS = 50
+
+type Result = { sql: string } | { error: string }
+
+/**
+ * Compiles a JSON search query into a boolean SQL expression over
+ * `transactions t`, binding every value through `q`. A query is either a
+ * condition, `{"field": "amount", "op": "gte", "value": 100}`, or a group,
+ * `{"and": [...]}` / `{"or": [...]}` of queries. Amounts are read in
+ * `units`. The first problem found is reported with its path, e.g.
+ * `and[1].op must be one of eq, ne, contains, startsWith`.
+ */
+export function compileTransactionQuery(
+  q: QueryBuilder,
+  query: unknown,
+  units: AmountUnits = AMOUNT_UNITS,
+): Result {
+  let conditions = 0
+
+  function compile(node: unknown, path: string, depth: number): Result {
+    const at = path || 'query'
+    if (typeof node !== 'object' || node === null || Array.isArray(node)) {
+      return { error: `${at} must be an object` }
+    }
+    const entry = node as Record<string, unknown>
+
+    const group = 'and' in entry ? 'and' : 'or' in entry ? 'or' : null
+    if (group) {
+      if (Object.keys(entry).length !== 1) {
+        return { error: `${at} must have only one of and, or` }
+      }
+      const where = path ? `${path}.${group}` : group
+      const items = entry[group]
+      if (!Array.isArray(items) || items.length === 0) {
+        return { error: `${where} must be a non-empty array` }
+      }
+      if (depth > MAX_QUERY_DEPTH) {
+        return { error: `${where} is nested more than ${MAX_QUERY_DEPTH} deep` }
+      }
+      const parts: string[] = []
+      for (const [i, item] of items.entries()) {
+        const compiled = compile(item, `${where}[${i}]`, depth + 1)
+        if ('error' in compiled) return compiled
+        parts.push(compiled.sql)
+      }
+      return { sql: `(${parts.join(group === 'and' ? ' AND ' : ' OR ')})` }
+    }
+
+    conditions += 1
+    if (conditions > MAX_QUERY_CONDITIONS) {
+      return { error: `query has more than ${MAX_QUERY_CONDITIONS} conditions` }
+    }
+    return compileCondition(q, entry, at, units)
+  }
+
+  return compile(query, '', 0)
+}
+
+function compileCondition(
+  q: QueryBuilder,
+  entry: Record<string, unknown>,
+  at: string,
+  units: AmountUnits,
+): Result {
+  const { field: rawField, op: rawOp, value } = entry
+  if (typeof rawField !== 'string' || !Object.hasOwn(QUERY_FIELDS, rawField)) {
+    const fields = Object.keys(QUERY_FIELDS).join(', ')
+    return { error: `${at}.field must be one of ${fields}` }
+  }
+  const field = rawField as QueryField
+  const { column, ops } = QUERY_FIELDS[field]
+  if (
+    typeof rawOp !== 'string' ||
+    !(ops as readonly string[]).includes(rawOp)
+  ) {
+    return { error: `${at}.op must be one of ${ops.join(', ')}` }
+  }
+  const op = rawOp as (typeof ops)[number]
+  const invalid = (expected: string) => ({
+    error: `${at}.value must be ${expected}`,
+  })
+  const compare = (placeholder: string) => ({
+    sql: `${column} ${COMPARISONS[op as Comparison]} ${placeholder}`,
+  })
+
+  switch (field) {
+    case 'amount': {
+      const amount = parseAmountIn(value, units)
+      if (amount === null) return invalid('a number')
+      return compare(`${q.param(amount)}::numeric`)
+    }
+    case 'date': {
+      const date = typeof value === 'string' ? new Date(value) : null
+      if (!date || Number.isNaN(date.getTime())) return invalid('a date')
+      return compare(`${q.param(date.toISOString())}::timestamptz`)
+    }
+    case 'description': {
+      if (typeof value !== 'string') return invalid('a string')
+      if (op === 'contains') {
+        return { sql: `${column} ILIKE ${q.param(`%${escapeLike(value)}%`)}` }
+      }
+      if (op === 'startsWith') {
+        return { sql: `${column} ILIKE ${q.param(`${escapeLike(value)}%`)}` }
+      }
+      return compare(q.param(value))
+    }
+    case 'type': {
+      const type = parseTransactionType(value)
+      if (!type) return invalid(`one of ${TRANSACTION_TYPES.join(', ')}`)
+      return compare(q.param(type))
+    }
+    case 'status': {
+      const status = parseTransactionStatus(value)
+      if (!status) return invalid(`one of ${TRANSACTION_STATUSES.join(', ')}`)
+      return compare(q.param(status))
+    }
+    case 'categoryId': {
+      // null matches uncategorized transactions.
+      if (value === null) {
+        return { sql: `${column} IS ${op === 'eq' ? '' : 'NOT '}NULL` }
+      }
+      if (typeof value !== 'string' || !isUuid(value)) {
+        return invalid('a UUID or null')
+      }
+      // Uncategorized rows differ from every category.
+      return op === 'eq'
+        ? { sql: `${column} = ${q.param(value)}` }
+        : { sql: `${column} IS DISTINCT FROM ${q.param(value)}` }
+    }
+    case 'tags': {
+      if (typeof value !== 'string' || !value.trim()) {
+        return invalid('a non-empty string')
+      }
+      return { sql: `${q.param(value.trim())} = ANY(${column})` }
+    }
+  }
+}
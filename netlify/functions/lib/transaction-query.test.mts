@@ -0,0 +1,123 @@
+import { describe, expect, it } from 'vitest'
+import { QueryBuilder } from './query.mts'
+import {
+  MAX_QUERY_CONDITIONS,
+  MAX_QUERY_DEPTH,
+  compileTransactionQuery,
+} from './transaction-query.mts'
+
+const CATEGORY = '00000000-0000-4000-8000-000000000001'
+
+function compile(query: unknown, units: 'decimal' | 'minor' = 'decimal') {
+  const q = new QueryBuilder()
+  return { q, result: compileTransactionQuery(q, query, units) }
+}
+
+describe('compileTransactionQuery', () => {
+  it('joins groups with AND and OR and binds every value', () => {
+    const { q, result } = compile({
+      and: [
+        { field: 'amount', op: 'gte', value: 100 },
+        {
+          or: [
+            { field: 'description', op: 'contains', value: 'coffee' },
+            { field: 'tags', op: 'contains', value: 'cafe' },
+          ],
+        },
+      ],
+    })
+    expect(result).toEqual({
+      sql: '(t.amount >= $1::numeric AND (t.description ILIKE $2 OR $3 = ANY(t.tags)))',
+    })
+    expect(q.params).toEqual(['100', '%coffee%', 'cafe'])
+  })
+
+  it('accepts a single condition without a group', () => {
+    const { q, result } = compile({ field: 'type', op: 'ne', value: 'Income' })
+    expect(result).toEqual({ sql: 't.type <> $1' })
+    expect(q.params).toEqual(['income'])
+  })
+
+  it('never writes values into the SQL text', () => {
+    const value = "x' OR 1=1; DROP TABLE transactions; --"
+    const { q, result } = compile({
+      or: [
+        { field: 'description', op: 'eq', value },
+        { field: 'description', op: 'startsWith', value: '50%_off' },
+      ],
+    })
+    expect(result).toEqual({
+      sql: '(t.description = $1 OR t.description ILIKE $2)',
+    })
+    expect(q.params).toEqual([value, '50\\%\\_off%'])
+  })
+
+  it('reads amounts in the requested units and dates as instants', () => {
+    const query = {
+      and: [
+        { field: 'amount', op: 'lt', value: 1250 },
+        { field: 'date', op: 'gte', value: '2025-03-01' },
+      ],
+    }
+    const { q, result } = compile(query, 'minor')
+    expect(result).toEqual({
+      sql: '(t.amount < $1::numeric AND t.date >= $2::timestamptz)',
+    })
+    expect(q.params).toEqual(['12.50', '2025-03-01T00:00:00.000Z'])
+  })
+
+  it('treats a null categoryId as uncategorized', () => {
+    expect(
+      compile({ field: 'categoryId', op: 'eq', value: null }).result,
+    ).toEqual({ sql: 't.category_id IS NULL' })
+    expect(
+      compile({ field: 'categoryId', op: 'ne', value: CATEGORY }).result,
+    ).toEqual({ sql: 't.category_id IS DISTINCT FROM $1' })
+  })
+
+  it('rejects unknown fields and operators with their path', () => {
+    expect(
+      compile({ and: [{ field: 'user_id', op: 'eq', value: 'x' }] }).result,
+    ).toEqual({
+      error:
+        'and[0].field must be one of amount, date, description, type, status, categoryId, tags',
+    })
+    expect(
+      compile({
+        or: [
+          { field: 'amount', op: 'gt', value: 1 },
+          { field: 'description', op: 'gt', value: 'a' },
+        ],
+      }).result,
+    ).toEqual({
+      error: 'or[1].op must be one of eq, ne, contains, startsWith',
+    })
+    expect(
+      compile({ field: 'amount', op: 'eq', value: '1e3' }).result,
+    ).toEqual({ error: 'query.value must be a number' })
+  })
+
+  it('rejects malformed groups', () => {
+    expect(compile({ and: [] }).result).toEqual({
+      error: 'and must be a non-empty array',
+    })
+    expect(compile({ and: [{}], or: [{}] }).result).toEqual({
+      error: 'query must have only one of and, or',
+    })
+    expect(compile({ and: ['amount'] }).result).toEqual({
+      error: 'and[0] must be an object',
+    })
+  })
+
+  it('limits nesting and the number of conditions', () => {
+    const condition = { field: 'amount', op: 'gt', value: 0 }
+    let nested: unknown = condition
+    for (let i = 0; i <= MAX_QUERY_DEPTH + 1; i++) nested = { and: [nested] }
+    expect(compile(nested).result).toHaveProperty('error')
+
+    const wide = { or: Array(MAX_QUERY_CONDITIONS + 1).fill(condition) }
+    expect(compile(wide).result).toEqual({
+      error: `query has more than ${MAX_QUERY_CONDITIONS} conditions`,
+    })
+  })
+})
@@ -0,0 +1,62 @@
+export const DEFAULT_MAX_URL_LENGTH = 8192
+export const DEFAULT_MAX_PARAM_REPEATS = 50
+
+/**
+ * Parses a URL limit from the environment. Unset or invalid values fall
+ * back to the default; `0` disables the check (null).
+ */
+export function parseUrlLimit(
+  raw: string | undefined,
+  fallback: number,
+): number | null {
+  const value = Number(raw?.trim())
+  if (!raw?.trim() || !Number.isInteger(value) || value < 0) return fallback
+  return value === 0 ? null : value
+}
+
+export interface UrlLimits {
+  /** Longest accepted request URL, in characters. */
+  maxLength: number | null
+  /** Most times any one query parameter may appear. */
+  maxRepeats: number | null
+}
+
+export const URL_LIMITS: UrlLimits = {
+  maxLength: parseUrlLimit(process.env.MAX_URL_LENGTH, DEFAULT_MAX_URL_LENGTH),
+  maxRepeats: parseUrlLimit(
+    process.env.MAX_QUERY_PARAM_REPEATS,
+    DEFAULT_MAX_PARAM_REPEATS,
+  ),
+}
+
+/**
+ * Checks a request URL against the limits before any handler parses it,
+ * so an enormous `ids=` list or thousands of repeated parameters are
+ * turned away cheaply. Returns the status and message to reject with, or
+ * null when the URL is acceptable.
+ */
+export function checkUrlLimits(
+  req: Request,
+  limits: UrlLimits = URL_LIMITS,
+): { status: 400 | 414; error: string } | null {
+  if (limits.maxLength !== null && req.url.length > limits.maxLength) {
+    return {
+      status: 414,
+      error: `URL must be at most ${limits.maxLength} characters`,
+    }
+  }
+  if (limits.maxRepeats !== null) {
+    const counts = new Map<string, number>()
+    for (const key of new URL(req.url).searchParams.keys()) {
+      const count = (counts.get(key) ?? 0) + 1
+      if (count > limits.maxRepeats) {
+        return {
+          status: 400,
+          error: `query parameter '${key}' may appear at most ${limits.maxRepeats} times`,
+        }
+      }
+      counts.set(key, count)
+    }
+  }
+  return null
+}
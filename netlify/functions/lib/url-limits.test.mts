@@ -0,0 +1,57 @@
+import { describe, expect, it } from 'vitest'
+import {
+  DEFAULT_MAX_URL_LENGTH,
+  checkUrlLimits,
+  parseUrlLimit,
+} from './url-limits.mts'
+
+const limits = { maxLength: 100, maxRepeats: 3 }
+
+function request(query: string) {
+  return new Request(`https://example.com/api/bank_accounts?${query}`)
+}
+
+describe('parseUrlLimit', () => {
+  it('falls back to the default when unset or invalid', () => {
+    expect(parseUrlLimit(undefined, 8192)).toBe(8192)
+    expect(parseUrlLimit('lots', 8192)).toBe(8192)
+    expect(parseUrlLimit('-1', 8192)).toBe(8192)
+  })
+
+  it('reads a positive value and disables on zero', () => {
+    expect(parseUrlLimit(' 2048 ', 8192)).toBe(2048)
+    expect(parseUrlLimit('0', 8192)).toBeNull()
+  })
+})
+
+describe('checkUrlLimits', () => {
+  it('accepts URLs within the limits', () => {
+    expect(checkUrlLimits(request('ids=a,b&tag=x&tag=y'), limits)).toBeNull()
+  })
+
+  it('rejects an over-long query string with 414', () => {
+    const ids = Array.from({ length: 2000 }, (_, i) => `id-${i}`).join(',')
+    const req = request(`ids=${ids}`)
+    expect(req.url.length).toBeGreaterThan(DEFAULT_MAX_URL_LENGTH)
+    expect(checkUrlLimits(req, { ...limits, maxLength: 8192 })).toEqual({
+      status: 414,
+      error: 'URL must be at most 8192 characters',
+    })
+  })
+
+  it('rejects a parameter repeated too often with 400', () => {
+    expect(checkUrlLimits(request('tag=a&tag=b&tag=c&tag=d'), limits)).toEqual(
+      {
+        status: 400,
+        error: "query parameter 'tag' may appear at most 3 times",
+      },
+    )
+  })
+
+  it('skips disabled checks', () => {
+    const req = request(`tag=a&tag=b&tag=c&tag=d&q=${'x'.repeat(200)}`)
+    expect(
+      checkUrlLimits(req, { maxLength: null, maxRepeats: null }),
+    ).toBeNull()
+  })
+})
@@ -0,0 +1,126 @@
+import { createHmac, randomBytes } from 'node:crypto'
+import type { Context } from '@netlify/functions'
+import type { Sql } from './db.mts'
+import { newId } from './ids.mts'
+import { jsonReplacer } from './json-fields.mts'
+
+export const WEBHOOK_EVENTS = [
+  'transaction.created',
+  'transaction.updated',
+  'transaction.deleted',
+] as const
+
+export type WebhookEvent = (typeof WEBHOOK_EVENTS)[number]
+
+export function isWebhookEvent(value: unknown): value is WebhookEvent {
+  return (WEBHOOK_EVENTS as readonly unknown[]).includes(value)
+}
+
+/** A fresh signing secret, shown to the user once when the hook is created. */
+export function newWebhookSecret(): string {
+  return randomBytes(32).toString('hex')
+}
+
+/**
+ * Validates a webhook target. Only https URLs are accepted so payloads and
+ * signatures are never sent in the clear.
+ */
+export function parseWebhookUrl(value: unknown): string | null {
+  if (typeof value !== 'string') return null
+  try {
+    const url = new URL(value.trim())
+    return url.protocol === 'https:' ? url.toString() : null
+  } catch {
+    return null
+  }
+}
+
+/**
+ * Validates a subscription list: a non-empty array of known events, with
+ * duplicates removed. Returns null when invalid.
+ */
+export function parseWebhookEvents(value: unknown): WebhookEvent[] | null {
+  if (!Array.isArray(value) || value.length === 0) return null
+  if (!value.every(isWebhookEvent)) return null
+  return [...new Set(value)]
+}
+
+/** `X-Webhook-Signature` value: an HMAC-SHA256 of the raw body. */
+export function signPayload(secret: string, body: string): string {
+  return `sha256=${createHmac('sha256', secret).update(body).digest('hex')}`
+}
+
+export interface DeliveryOptions {
+  attempts?: number
+  baseDelayMs?: number
+  fetchImpl?: typeof fetch
+  sleep?: (ms: number) => Promise<void>
+}
+
+const wait = (ms: number) => new Promise<void>((r) => setTimeout(r, ms))
+
+/**
+ * POSTs a signed payload, retrying network errors and non-2xx responses
+ * with exponential backoff (base, 2×base, …). Resolves to whether a
+ * delivery succeeded; it never throws.
+ */
+export async function deliverWebhook(
+  hook: { url: string; secret: string },
+  event: WebhookEvent,
+  body: string,
+  {
+    attempts = 3,
+    baseDelayMs = 500,
+    fetchImpl = fetch,
+    sleep = wait,
+  }: DeliveryOptions = {},
+): Promise<boolean> {
+  const headers = {
+    'Content-Type': 'application/json',
+    'X-Webhook-Event': event,
+    'X-Webhook-Signature': signPayload(hook.secret, body),
+  }
+  for (let attempt = 0; attempt < attempts; attempt++) {
+    if (attempt > 0) await sleep(baseDelayMs * 2 ** (attempt - 1))
+    try {
+      const res = await fetchImpl(hook.url, { method: 'POST', headers, body })
+      if (res.ok) return true
+    } catch {
+      // Network errors are retried like failed responses.
+    }
+  }
+  console.error(`[webhook] giving up on ${event} delivery to ${hook.url}`)
+  return false
+}
+
+/**
+ * Notifies the user's webhooks subscribed to `event`. Lookup and delivery
+ * run after the response is sent (via `context.waitUntil`), so a slow or
+ * failing receiver never delays or fails the originating request.
+ */
+export function dispatchWebhooks(
+  sql: Sql,
+  context: Context,
+  userId: string,
+  event: WebhookEvent,
+  data: unknown,
+): void {
+  const run = async () => {
+    const hooks = await sql`
+      SELECT url, secret FROM webhooks
+      WHERE user_id = ${userId} AND ${event} = ANY(events)
+    `
+    if (hooks.length === 0) return
+    const body = JSON.stringify(
+      { id: newId(), event, createdAt: new Date(), data },
+      jsonReplacer(),
+    )
+    await Promise.all(
+      hooks.map((hook) =>
+        deliverWebhook(hook as { url: string; secret: string }, event, body),
+      ),
+    )
+  }
+  const pending = run().catch((e) => console.error('[webhook]', e))
+  context.waitUntil?.(pending)
+}
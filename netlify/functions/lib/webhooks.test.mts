@@ -0,0 +1,87 @@
+import { createHmac } from 'node:crypto'
+import { describe, expect, it, vi } from 'vitest'
+import {
+  deliverWebhook,
+  parseWebhookEvents,
+  parseWebhookUrl,
+  signPayload,
+} from './webhooks.mts'
+
+const hook = { url: 'https://hooks.example.com/ledger', secret: 's3cret' }
+const body = '{"event":"transaction.created"}'
+
+describe('signPayload', () => {
+  it('is an HMAC-SHA256 of the body with the hook secret', () => {
+    const expected = createHmac('sha256', 's3cret').update(body).digest('hex')
+    expect(signPayload('s3cret', body)).toBe(`sha256=${expected}`)
+  })
+})
+
+describe('deliverWebhook', () => {
+  it('posts the signed payload once when it succeeds', async () => {
+    const fetchImpl = vi.fn(async () => new Response(null, { status: 204 }))
+    const ok = await deliverWebhook(hook, 'transaction.created', body, {
+      fetchImpl,
+    })
+    expect(ok).toBe(true)
+    expect(fetchImpl).toHaveBeenCalledTimes(1)
+    const [url, init] = fetchImpl.mock.calls[0] as unknown as [
+      string,
+      RequestInit,
+    ]
+    expect(url).toBe(hook.url)
+    expect(init.body).toBe(body)
+    expect(init.headers).toMatchObject({
+      'X-Webhook-Event': 'transaction.created',
+      'X-Webhook-Signature': signPayload('s3cret', body),
+    })
+  })
+
+  it('retries failures with exponential backoff', async () => {
+    const responses = [
+      () => Promise.reject(new Error('ECONNRESET')),
+      () => Promise.resolve(new Response(null, { status: 503 })),
+      () => Promise.resolve(new Response(null, { status: 200 })),
+    ]
+    const fetchImpl = vi.fn(() => responses.shift()!())
+    const sleep = vi.fn(async () => {})
+    const ok = await deliverWebhook(hook, 'transaction.updated', body, {
+      fetchImpl,
+      sleep,
+      baseDelayMs: 100,
+    })
+    expect(ok).toBe(true)
+    expect(fetchImpl).toHaveBeenCalledTimes(3)
+    expect(sleep.mock.calls).toEqual([[100], [200]])
+  })
+
+  it('gives up after the last attempt without throwing', async () => {
+    vi.spyOn(console, 'error').mockImplementation(() => {})
+    const fetchImpl = vi.fn(async () => new Response(null, { status: 500 }))
+    const ok = await deliverWebhook(hook, 'transaction.deleted', body, {
+      fetchImpl,
+      sleep: async () => {},
+      attempts: 2,
+    })
+    expect(ok).toBe(false)
+    expect(fetchImpl).toHaveBeenCalledTimes(2)
+  })
+})
+
+describe('webhook input', () => {
+  it('accepts only https targets', () => {
+    expect(parseWebhookUrl('https://hooks.example.com/x')).toBe(
+      'https://hooks.example.com/x',
+    )
+    expect(parseWebhookUrl('http://hooks.example.com/x')).toBeNull()
+    expect(parseWebhookUrl('not a url')).toBeNull()
+  })
+
+  it('accepts a non-empty list of known events', () => {
+    expect(
+      parseWebhookEvents(['transaction.created', 'transaction.created']),
+    ).toEqual(['transaction.created'])
+    expect(parseWebhookEvents([])).toBeNull()
+    expect(parseWebhookEvents(['account.created'])).toBeNull()
+  })
+})
@@ -0,0 +1,13 @@
+import type { Config } from '@netlify/functions'
+import { apiHandler, err } from './lib/http.mts'
+
+/**
+ * Catch-all for `/api/*` paths no other function serves, so unknown routes
+ * get the API's JSON error body instead of the SPA's index.html.
+ */
+export default apiHandler(async () => err('Not found', 404))
+
+export const config: Config = {
+  path: '/api/*',
+  excludedPath: '/api/auth/*',
+}
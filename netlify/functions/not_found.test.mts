@@ -0,0 +1,23 @@
+import { describe, expect, it } from 'vitest'
+import type { Context } from '@netlify/functions'
+import handler, { config } from './not_found.mts'
+
+const context = {} as Context
+
+describe('not_found', () => {
+  it('answers unknown API routes with a JSON 404', async () => {
+    for (const method of ['GET', 'DELETE']) {
+      const res = await handler(
+        new Request('https://example.com/api/nope', { method }),
+        context,
+      )
+      expect(res.status).toBe(404)
+      expect(res.headers.get('Content-Type')).toBe('application/json')
+      expect(await res.json()).toEqual({ error: 'Not found' })
+    }
+  })
+
+  it('leaves the auth routes alone', () => {
+    expect(config.excludedPath).toBe('/api/auth/*')
+  })
+})
@@ -0,0 +1,31 @@
+import { vi } from 'vitest'
+import type { Context } from '@netlify/functions'
+
+/**
+ * Shared setup for handler tests. Importing this module mocks the session
+ * as `user-1` and getDb as `sql`, so it must come before the handler under
+ * test and anything else that loads lib/auth.mts or lib/db.mts. Tests
+ * queue results on `sql` for tagged-template queries and on `sql.query`
+ * and `sql.transaction` for the other two call styles.
+ */
+export const sql = Object.assign(vi.fn(), {
+  query: vi.fn(),
+  transaction: vi.fn(),
+})
+
+vi.mock('../lib/auth.mts', () => ({
+  getSessionFromRequest: vi.fn(async () => ({
+    user: { id: 'user-1', email: 'user@example.com', name: 'User' },
+  })),
+}))
+vi.mock('../lib/db.mts', async (importOriginal) => ({
+  ...(await importOriginal<typeof import('../lib/db.mts')>()),
+  getDb: async () => sql,
+}))
+
+export const context = { ip: '127.0.0.1' } as Context
+
+/** A request to the function at `path`, which includes its query string. */
+export function apiRequest(path: string, init?: RequestInit): Request {
+  return new Request(`https://example.com/${path}`, init)
+}
@@ -1,6 +1,6 @@
 import { readFileSync, existsSync, readdirSync } from 'node:fs'
 import { resolve, join } from 'node:path'
-import { neon } from '@neondatabase/serverless'
+import { Client } from '@neondatabase/serverless'
 
 const workspaceRoot = process.cwd()
 const migrationsDir = resolve(workspaceRoot, 'db/migrations')
@@ -49,7 +49,11 @@ if (!databaseUrl) {
   process.exit(1)
 }
 
-const sql = neon(databaseUrl)
+// Concurrent runs (e.g. several instances deploying at once) take turns on
+// this session-level advisory lock instead of racing to apply the same
+// migration.
+const MIGRATION_LOCK = 'expense-ledger:migrations'
+
 const migrationFiles = readdirSync(migrationsDir)
   .filter((name) => name.endsWith('.sql'))
   .sort((a, b) => a.localeCompare(b))
@@ -59,41 +63,67 @@ if (migrationFiles.length === 0) {
   process.exit(0)
 }
 
-await sql`
-  CREATE TABLE IF NOT EXISTS schema_migrations (
-    filename TEXT PRIMARY KEY,
-    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-  )
-`
-
-const appliedRows =
-  await sql`SELECT filename FROM schema_migrations ORDER BY filename ASC`
-const appliedSet = new Set(appliedRows.map((row) => row.filename))
+// The lock needs one session for its whole lifetime, so this uses a
+// WebSocket client rather than the per-query HTTP driver.
+const client = new Client(databaseUrl)
+await client.connect()
 
 let appliedCount = 0
 let skippedCount = 0
 
-for (const filename of migrationFiles) {
-  if (appliedSet.has(filename)) {
-    skippedCount += 1
-    continue
-  }
-
-  const migrationPath = join(migrationsDir, filename)
-  const migrationSql = readFileSync(migrationPath, 'utf8')
-  const statements = migrationSql
-    .split(';')
-    .map((statement) => statement.trim())
-    .filter(Boolean)
-
-  for (const statement of statements) {
-    // Execute plain SQL statements from each migration file in order.
-    await sql.query(statement)
+try {
+  await client.query('SELECT pg_advisory_lock(hashtext($1))', [
+    MIGRATION_LOCK,
+  ])
+  try {
+    await client.query(`
+      CREATE TABLE IF NOT EXISTS schema_migrations (
+        filename TEXT PRIMARY KEY,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+      )
+    `)
+
+    // Read what is applied only once the lock is held, so a run that waited
+    // sees the migrations the previous holder applied.
+    const { rows: appliedRows } = await client.query(
+      'SELECT filename FROM schema_migrations ORDER BY filename ASC',
+    )
+    const appliedSet = new Set(appliedRows.map((row) => row.filename))
+
+    for (const filename of migrationFiles) {
+      if (appliedSet.has(filename)) {
+        skippedCount += 1
+        continue
+      }
+
+      const migrationPath = join(migrationsDir, filename)
+      const migrationSql = readFileSync(migrationPath, 'utf8')
+
+      // Each file is sent whole, so function bodies and comments may
+      // contain semicolons, and applied together with its schema_migrations
+      // row: a failing migration leaves nothing half-done.
+      await client.query('BEGIN')
+      try {
+        await client.query(migrationSql)
+        await client.query(
+          'INSERT INTO schema_migrations (filename) VALUES ($1)',
+          [filename],
+        )
+        await client.query('COMMIT')
+      } catch (error) {
+        await client.query('ROLLBACK')
+        throw error
+      }
+      appliedCount += 1
+      console.log(`Applied migration: ${filename}.`)
+    }
+  } finally {
+    await client.query('SELECT pg_advisory_unlock(hashtext($1))', [
+      MIGRATION_LOCK,
+    ])
   }
-
-  await sql`INSERT INTO schema_migrations (filename) VALUES (${filename})`
-  appliedCount += 1
-  console.log(`Applied migration: ${filename} (${statements.length} statements).`)
+} finally {
+  await client.end()
 }
 
 console.log(
@@ -5,6 +5,21 @@ import { env } from '@/env'
  */
 const NETLIFY_FUNCTIONS = env.VITE_NETLIFY_FUNCTIONS_URL
 
+/**
+ * Reads the message from an API error body: either `{ error: string }` or a
+ * validation error, `{ error: { code: 'VALIDATION', fields } }`.
+ */
+export function apiErrorMessage(data: unknown): string | null {
+  const error = (data as { error?: unknown } | null)?.error
+  if (typeof error === 'string') return error
+  const fields = (error as { fields?: Record<string, string> } | undefined)
+    ?.fields
+  if (!fields) return null
+  return Object.entries(fields)
+    .map(([field, problem]) => `${field} ${problem}`)
+    .join(', ')
+}
+
 export function accountsUrl(): string {
   return `${NETLIFY_FUNCTIONS}/bank_accounts`
 }
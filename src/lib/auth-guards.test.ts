@@ -1,5 +1,9 @@
 import { describe, expect, it } from 'vitest'
-import { hasAuthenticatedUser, isPublicPath } from './auth-guards'
+import {
+  hasAuthenticatedUser,
+  isPublicPath,
+  normalizePathname,
+} from './auth-guards'
 
 describe('isPublicPath', () => {
   it('allows auth page and child routes', () => {
@@ -15,6 +19,81 @@ describe('isPublicPath', () => {
     expect(isPublicPath('/')).toBe(false)
     expect(isPublicPath('/accounts')).toBe(false)
   })
+
+  it('treats trailing slashes like the bare path', () => {
+    expect(isPublicPath('/auth/')).toBe(true)
+    expect(isPublicPath('/accounts/')).toBe(false)
+  })
+
+  it('matches whole segments only', () => {
+    expect(isPublicPath('/authors')).toBe(false)
+    expect(isPublicPath('/api/authx')).toBe(false)
+    expect(isPublicPath('/api/auth')).toBe(true)
+  })
+
+  it('cannot be reached by dot segments or doubled slashes', () => {
+    expect(isPublicPath('/auth/../accounts')).toBe(false)
+    expect(isPublicPath('/api/auth/../../accounts')).toBe(false)
+    expect(isPublicPath('//auth')).toBe(true)
+    expect(isPublicPath('/accounts//auth')).toBe(false)
+  })
+})
+
+describe('normalizePathname', () => {
+  it('strips a single trailing slash', () => {
+    expect(normalizePathname('/accounts/abc/')).toBe('/accounts/abc')
+    expect(normalizePathname('/accounts/abc/transactions/')).toBe(
+      '/accounts/abc/transactions',
+    )
+  })
+
+  it('leaves paths without a trailing slash unchanged', () => {
+    expect(normalizePathname('/accounts/abc')).toBe('/accounts/abc')
+  })
+
+  it('keeps the root path', () => {
+    expect(normalizePathname('/')).toBe('/')
+    expect(normalizePathname('')).toBe('/')
+    expect(normalizePathname('///')).toBe('/')
+  })
+
+  it('collapses repeated slashes', () => {
+    expect(normalizePathname('/accounts//transactions')).toBe(
+      '/accounts/transactions',
+    )
+    expect(normalizePathname('//accounts/abc//')).toBe('/accounts/abc')
+  })
+
+  it('resolves dot segments without escaping the root', () => {
+    expect(normalizePathname('/accounts/./abc/../def')).toBe('/accounts/def')
+    expect(normalizePathname('/../../auth')).toBe('/auth')
+  })
+
+  // A small seeded fuzz run: arbitrary mixes of separators, dots and names
+  // must always normalize to a canonical, stable path.
+  it('always produces a canonical path', () => {
+    const pieces = ['/', '//', '.', '..', 'a', 'auth', 'api', ' ', '%2F', '']
+    let seed = 42
+    const random = () => {
+      seed = (Math.imul(seed, 1_103_515_245) + 12_345) >>> 0
+      return seed / 2 ** 32
+    }
+    for (let run = 0; run < 2000; run++) {
+      let input = ''
+      const length = Math.floor(random() * 12)
+      for (let i = 0; i < length; i++) {
+        input += pieces[Math.floor(random() * pieces.length)]
+      }
+      const path = normalizePathname(input)
+      expect(path.startsWith('/')).toBe(true)
+      expect(path).not.toContain('//')
+      expect(path === '/' || !path.endsWith('/')).toBe(true)
+      const segments = path.split('/').slice(1)
+      expect(segments).not.toContain('.')
+      expect(segments).not.toContain('..')
+      expect(normalizePathname(path)).toBe(path)
+    }
+  })
 })
 
 describe('hasAuthenticatedUser', () => {
@@ -6,12 +6,34 @@ type SessionResult = {
 
 const PUBLIC_PATHS = ['/auth'] as const
 
+/**
+ * Canonicalizes a pathname for route checks: repeated slashes collapse,
+ * `.`/`..` segments are resolved, and trailing slashes are dropped, so
+ * `/accounts//abc/` and `/accounts/abc` are the same route. The result always
+ * starts with `/`, and the root path is `/`.
+ */
+export function normalizePathname(pathname: string) {
+  const segments: string[] = []
+  for (const segment of pathname.split('/')) {
+    if (segment === '' || segment === '.') continue
+    if (segment === '..') segments.pop()
+    else segments.push(segment)
+  }
+  return `/${segments.join('/')}`
+}
+
+/** Whether `path` is `prefix` itself or a route nested under it. */
+function isUnder(path: string, prefix: string) {
+  return path === prefix || path.startsWith(`${prefix}/`)
+}
+
 export function isPublicPath(pathname: string) {
-  if (PUBLIC_PATHS.some((p) => pathname === p || pathname.startsWith(`${p}/`))) {
+  const path = normalizePathname(pathname)
+  if (PUBLIC_PATHS.some((p) => isUnder(path, p))) {
     return true
   }
 
-  return pathname.startsWith('/api/auth')
+  return isUnder(path, '/api/auth')
 }
 
 export function hasAuthenticatedUser(result: SessionResult) {
@@ -31,5 +31,14 @@ export const auth = betterAuth({
   emailAndPassword: {
     enabled: true,
   },
+  advanced: {
+    // Self-hosted deployments behind TLS termination still get Secure
+    // cookies as long as BETTER_AUTH_URL is https.
+    useSecureCookies: authOrigin.startsWith('https:'),
+    defaultCookieAttributes: {
+      httpOnly: true,
+      sameSite: 'lax',
+    },
+  },
   plugins: [tanstackStartCookies()],
 })
@@ -10,6 +10,8 @@ export function getRouter() {
     context: getContext(),
 
     scrollRestoration: true,
+    // Redirect `/accounts/abc/` to `/accounts/abc` so both match the same route.
+    trailingSlash: 'never',
     defaultPreload: 'intent',
     defaultPreloadStaleTime: 0,
   })
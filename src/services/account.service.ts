@@ -1,13 +1,12 @@
 import type { Account, AccountCreate, AccountUpdate } from '@/types/ledger'
-import { accountsUrl, accountUrl } from '@/lib/api'
+import { apiErrorMessage, accountsUrl, accountUrl } from '@/lib/api'
 
 async function handleResponse<T>(res: Response): Promise<T> {
   const text = await res.text()
   if (!res.ok) {
     let message = res.statusText
     try {
-      const data = JSON.parse(text) as { error?: string }
-      if (data.error) message = data.error
+      message = apiErrorMessage(JSON.parse(text)) ?? message
     } catch {
       if (text) message = text
     }
@@ -47,7 +46,9 @@ export async function updateAccount(
     headers: { 'Content-Type': 'application/json' },
     body: JSON.stringify(data),
   })
-  return handleResponse<Account>(res)
+  // Warnings wrap the account; the form only needs the account itself.
+  const body = await handleResponse<Account | { account: Account }>(res)
+  return 'account' in body ? body.account : body
 }
 
 export async function deleteAccount(id: string): Promise<void> {
@@ -1,17 +1,17 @@
 import type {
   Transaction,
   TransactionCreate,
+  TransactionList,
   TransactionUpdate,
 } from '@/types/ledger'
-import { transactionsUrl, transactionUrl } from '@/lib/api'
+import { apiErrorMessage, transactionsUrl, transactionUrl } from '@/lib/api'
 
 async function handleResponse<T>(res: Response): Promise<T> {
   const text = await res.text()
   if (!res.ok) {
     let message = res.statusText
     try {
-      const data = JSON.parse(text) as { error?: string }
-      if (data.error) message = data.error
+      message = apiErrorMessage(JSON.parse(text)) ?? message
     } catch {
       if (text) message = text
     }
@@ -27,7 +27,8 @@ export async function fetchTransactions(
   const res = await fetch(transactionsUrl(accountId), {
     credentials: 'include',
   })
-  return handleResponse<Transaction[]>(res)
+  const list = await handleResponse<TransactionList>(res)
+  return list.data
 }
 
 export async function createTransaction(
@@ -1,33 +1,729 @@
 /**
  * Ledger types aligned with db/init.sql
+ *
+ * Optional (`?`) fields that are null or empty are left out of responses
+ * unless the deployment sets JSON_EMPTY_FIELDS=null.
  */
 
+export type TransactionType = 'income' | 'expense'
+
+/** Whether the bank has settled a transaction; `posted` unless set. */
+export type TransactionStatus = 'pending' | 'posted'
+
 export interface BankAccount {
   id: string
   name: string
   type: string
+  currency: string
+  sort_order: number
+  default_transaction_type?: TransactionType | null
+  last_used_at?: string | null
+  group_id?: string | null
+  /** Balance before the first transaction, as a decimal string. */
+  opening_balance: string
+  /**
+   * When false, creating a transaction that would take the balance below
+   * zero fails with 409.
+   */
+  allow_negative: boolean
+}
+
+export interface AccountGroup {
+  id: string
+  name: string
 }
 
 export type BankAccountType = 'bank' | 'cash' | 'card'
 
-export type BankAccountCreate = Pick<BankAccount, 'name' | 'type'>
+/** An account type in use, with how many accounts have it. */
+export interface BankAccountTypeCount {
+  type: string
+  count: number
+}
+
+export type BankAccountCreate = Pick<BankAccount, 'name' | 'type'> &
+  Partial<
+    Pick<
+      BankAccount,
+      | 'currency'
+      | 'default_transaction_type'
+      | 'group_id'
+      | 'opening_balance'
+      | 'allow_negative'
+    >
+  >
 export type BankAccountUpdate = Partial<BankAccountCreate>
 
-export type TransactionType = 'income' | 'expense'
+/**
+ * `PATCH bank_account` response: the account, or, when the change went
+ * through but may surprise (e.g. a new type for an account that already
+ * has transactions), the account wrapped together with `warnings`.
+ */
+export type BankAccountUpdated =
+  | BankAccount
+  | { account: BankAccount; warnings: string[] }
 
 export interface Transaction {
   id: string
   account_id: string
+  /**
+   * Number within the account, from 1 in order of entry; look it up with
+   * `transaction?accountId=…&seq=…`. A bigint, so sent as a string.
+   */
+  seq: string
   amount: string
   date: string
   description: string
   type: TransactionType
+  transfer_group?: string | null
+  cleared: boolean
+  status: TransactionStatus
+  /**
+   * Left out of the balance until its date arrives and
+   * `POST scheduled_activate` turns it active.
+   */
+  scheduled: boolean
+  /**
+   * Currency actually charged, for a foreign purchase; `amount` is the
+   * converted figure in the account currency. Set together with
+   * `original_amount`.
+   */
+  currency?: string | null
+  original_amount?: string | null
+  /** Import run that created the transaction; null if entered manually. */
+  import_batch_id?: string | null
+  category_id?: string | null
+  tags?: string[]
+  /** Display string in the account currency, with `formatted=true`. */
+  amountFormatted?: string
+  /** Derived fields, each only when named in `compute=`. */
+  absoluteAmount?: string | number
+  /** Negative for expenses. */
+  signedAmount?: string | number
+  isExpense?: boolean
+}
+
+export interface Category {
+  id: string
+  name: string
 }
 
+/** A row of `bank_account_tags?id=…`, most used first. */
+export interface TagUsage {
+  tag: string
+  count: number
+}
+
+/** `POST category_reassign?id=…` body; the source category is kept. */
+export interface CategoryReassign {
+  toCategoryId: string
+}
+
+/**
+ * `amount` must be non-zero unless created or edited with `allowZero=true`.
+ */
 export type TransactionCreate = Pick<
   Transaction,
   'account_id' | 'amount' | 'date' | 'description' | 'type'
->
+> &
+  Partial<
+    Pick<
+      Transaction,
+      | 'status'
+      | 'scheduled'
+      | 'transfer_group'
+      | 'category_id'
+      | 'currency'
+      | 'original_amount'
+    >
+  >
+/**
+ * Omitted fields keep their value. `description`, `transfer_group` and
+ * `category_id` can be cleared, as can `currency` with `original_amount`;
+ * `date` cannot be empty.
+ */
 export type TransactionUpdate = Partial<
-  Pick<Transaction, 'amount' | 'date' | 'description' | 'type'>
+  Pick<
+    Transaction,
+    | 'amount'
+    | 'date'
+    | 'description'
+    | 'type'
+    | 'status'
+    | 'scheduled'
+    | 'transfer_group'
+    | 'category_id'
+    | 'currency'
+    | 'original_amount'
+  >
 >
+
+export interface TransactionSplit {
+  id: string
+  transaction_id: string
+  amount: string
+  description: string
+  /** Falls back to the transaction's category in reports when unset. */
+  category_id?: string | null
+}
+
+export type TransactionSplitCreate = Pick<
+  TransactionSplit,
+  'amount' | 'description' | 'category_id'
+>
+
+export interface TransactionDateRange {
+  earliest: string | null
+  latest: string | null
+}
+
+export interface SummaryTotals {
+  income: string
+  expense: string
+  net: string
+}
+
+export interface SummaryReport {
+  groupBy: 'day' | 'week' | 'month' | 'year'
+  /** Present when grouping by week. */
+  weekStart?: 'monday' | 'sunday'
+  /** IANA zone whose calendar periods follow; defaults to `UTC`. */
+  timeZone: string
+  includeTransfers: boolean
+  totals: SummaryTotals
+  /** `label` (ISO `YYYY-Www`) is present when grouping by week. */
+  periods: Array<SummaryTotals & { period: string; label?: string }>
+}
+
+export interface Paginated<T> {
+  data: T[]
+  total: number
+  page: number
+  pageSize: number
+}
+
+/**
+ * An account's transactions, newest first. `truncated` is true when the
+ * list stopped at the server's MAX_RESULT_ROWS.
+ */
+export interface TransactionList {
+  data: Transaction[]
+  truncated: boolean
+}
+
+export type TransactionSearchResult = Transaction & { accountName: string }
+
+export interface TrendsReport {
+  window: number
+  timeZone: string
+  months: Array<{ month: string; expense: string; rollingAverage: string }>
+}
+
+/** A `transactions_incomplete` row; `missing` names the failed checks. */
+export type IncompleteTransaction = Pick<
+  Transaction,
+  | 'id'
+  | 'account_id'
+  | 'amount'
+  | 'date'
+  | 'description'
+  | 'type'
+  | 'status'
+  | 'scheduled'
+  | 'category_id'
+  | 'tags'
+> & { missing: Array<'description' | 'category' | 'amount'> }
+
+/** A condition of a `transactions_query` search. */
+export type TransactionQueryCondition =
+  | {
+      field: 'amount' | 'date'
+      op: 'eq' | 'ne' | 'gt' | 'gte' | 'lt' | 'lte'
+      value: string | number
+    }
+  | {
+      field: 'description'
+      op: 'eq' | 'ne' | 'contains' | 'startsWith'
+      value: string
+    }
+  | { field: 'type' | 'status'; op: 'eq' | 'ne'; value: string }
+  | { field: 'categoryId'; op: 'eq' | 'ne'; value: string | null }
+  | { field: 'tags'; op: 'contains'; value: string }
+
+/** The body of a `transactions_query` search: a condition or a group. */
+export type TransactionQuery =
+  | TransactionQueryCondition
+  | { and: TransactionQuery[] }
+  | { or: TransactionQuery[] }
+
+export type TransactionChange = Transaction & {
+  created_at: string
+  updated_at: string
+  changeType: 'created' | 'updated'
+}
+
+export interface AuditEntry {
+  /** Increases with every change; newer entries have larger ids. */
+  id: string
+  transactionId: string
+  action: 'create' | 'update' | 'delete'
+  /** Every field on create and delete; only the edited ones on update. */
+  changedFields: string[]
+  changedAt: string
+  /** The row before the change; null on create. */
+  before: Record<string, unknown> | null
+  /** The row after the change; null on delete. */
+  after: Record<string, unknown> | null
+}
+
+export interface BankAccountWithRecent {
+  account: BankAccount
+  recentTransactions: Transaction[]
+}
+
+export type BankAccountWithCount = BankAccount & { transactionCount: number }
+
+export interface AccountBalance {
+  /** Posted transactions only, unless `includePending` is set. */
+  balance: string
+  /** Part of the balance from cleared transactions. */
+  cleared: string
+  /** Part of the balance still awaiting clearing. */
+  uncleared: string
+  /** Net of transactions with status `pending`, counted or not. */
+  pending: string
+  includePending: boolean
+  asOf: string | null
+  /** Display strings in the account currency, with `formatted=true`. */
+  balanceFormatted?: string
+  clearedFormatted?: string
+  unclearedFormatted?: string
+  pendingFormatted?: string
+}
+
+export interface ImportReport {
+  /** Id of this import run, for rolling it back; null on a dry run. */
+  batchId: string | null
+  imported: number
+  wouldImport: number
+  /**
+   * Existing transactions overwritten from a row with the same bank id (OFX
+   * FITID), with `onDuplicate=update`.
+   */
+  updated: number
+  /**
+   * Rows skipped because their bank id, or with `dedupe=true` their
+   * content, was already imported.
+   */
+  skipped: number
+  errors: Array<{ row: number; error: string }>
+}
+
+export type ImportField = 'date' | 'amount' | 'description' | 'type'
+
+/** `POST transactions_import_inspect`: nothing is imported. */
+export interface ImportInspection {
+  columns: string[]
+  /** Suggested header column per field; null when nothing matched. */
+  mapping: Record<ImportField, string | null>
+  missing: ImportField[]
+  totalRows: number
+  preview: Array<{
+    row: number
+    fields: Record<ImportField, string>
+    transaction: Pick<
+      Transaction,
+      'date' | 'amount' | 'description' | 'type'
+    > | null
+    error: string | null
+  }>
+}
+
+export interface CalendarDay {
+  date: string
+  income: string
+  expense: string
+  count: number
+}
+
+export interface AccountBackup {
+  version: 1
+  account: Pick<
+    BankAccount,
+    | 'name'
+    | 'type'
+    | 'currency'
+    | 'default_transaction_type'
+    | 'opening_balance'
+  >
+  transactions: Array<
+    Pick<Transaction, 'amount' | 'date' | 'description' | 'type'> & {
+      splits: Array<Pick<TransactionSplit, 'amount' | 'description'>>
+    }
+  >
+}
+
+export interface IncomeExpenseRatio {
+  income: string
+  expense: string
+  ratio: number | null
+  savingsRate: number | null
+}
+
+export interface ExpenseRatioReport {
+  groupBy: 'day' | 'week' | 'month' | 'year'
+  /** Present when grouping by week. */
+  weekStart?: 'monday' | 'sunday'
+  /** Every period in range; those without transactions have zero totals. */
+  periods: Array<IncomeExpenseRatio & { period: string; label?: string }>
+}
+
+export interface TopDescriptionsReport {
+  type: 'expense' | 'all'
+  descriptions: Array<{ description: string; total: string; count: number }>
+}
+
+/** `reports_largest`: fewer than `limit` rows when the period has fewer. */
+export interface LargestTransactionsReport {
+  type: 'income' | 'expense'
+  transactions: Array<
+    Pick<
+      Transaction,
+      | 'id'
+      | 'amount'
+      | 'date'
+      | 'description'
+      | 'type'
+      | 'status'
+      | 'category_id'
+      | 'tags'
+    >
+  >
+}
+
+/**
+ * `reports_anomalies`: transactions over `deviations` standard deviations
+ * from the mean of their type, most unusual first. `deviations` on a row
+ * is signed: negative for unusually small amounts.
+ */
+export interface AnomaliesReport {
+  deviations: number
+  transactions: Array<
+    Pick<
+      Transaction,
+      'id' | 'amount' | 'date' | 'description' | 'type' | 'category_id'
+    > & { mean: string; deviations: number }
+  >
+}
+
+/**
+ * `reports_carryover`: `opening` is the balance brought into the first
+ * month; each month's `carryover` is the previous one plus its `net`.
+ */
+export interface CarryoverReport {
+  opening: string
+  months: Array<{
+    month: string
+    income: string
+    expense: string
+    net: string
+    carryover: string
+  }>
+}
+
+export interface BalanceSeries {
+  interval: 'day' | 'week' | 'month' | 'year'
+  /** Balance at the end of each interval, keyed by the interval's start. */
+  points: Array<{ periodStart: string; balance: string }>
+}
+
+export interface DuplicateGroup {
+  amount: string
+  type: TransactionType
+  /** Calendar day, `YYYY-MM-DD`. */
+  day: string
+  description: string
+  count: number
+  /** Member ids, oldest first. */
+  ids: string[]
+}
+
+/** Expense totals by when they happened, in UTC. */
+export interface SpendingPatterns {
+  /** Seven entries, 0 = Sunday. */
+  byDayOfWeek: Array<{ dayOfWeek: number; total: string; count: number }>
+  /** Twenty-four entries, 0–23. */
+  byHour: Array<{ hour: number; total: string; count: number }>
+}
+
+export interface SpendingPercentile {
+  month: string
+  expense: string
+  /**
+   * Share of months with lower spending (0–1, via `percent_rank`); null
+   * when there is only one month.
+   */
+  percentile: number | null
+  monthsCompared: number
+}
+
+export type WebhookEvent =
+  | 'transaction.created'
+  | 'transaction.updated'
+  | 'transaction.deleted'
+
+export interface Webhook {
+  id: string
+  url: string
+  events: WebhookEvent[]
+  created_at: string
+  /** Signing secret; only present in the response that created the hook. */
+  secret?: string
+}
+
+export interface MonthOverMonthReport {
+  month: string
+  previousMonth: string
+  includeTransfers: boolean
+  current: { income: string; expense: string }
+  previous: { income: string; expense: string }
+  /** Percent change per type; null when the previous month was zero. */
+  change: { income: number | null; expense: number | null }
+}
+
+export interface NetWorth {
+  base: string
+  asOf: string | null
+  /** Sum of the converted balances, in `base`. */
+  total: string
+  accounts: Array<{
+    id: string
+    name: string
+    currency: string
+    balance: string
+    converted: string
+  }>
+}
+
+export interface AmountStats {
+  count: number
+  total: string
+  average: string | null
+  min: string | null
+  max: string | null
+}
+
+export type AccountStats = Record<TransactionType, AmountStats>
+
+export interface CategoryRulePreview {
+  /** Every uncategorized transaction the rule would match. */
+  count: number
+  /** The newest few of them. */
+  sample: Array<
+    Pick<
+      Transaction,
+      'id' | 'account_id' | 'amount' | 'date' | 'description' | 'type'
+    >
+  >
+}
+
+/** Applied to uncategorized transactions in ascending `priority`. */
+export interface CategoryRule {
+  id: string
+  /** Substring (case-insensitive) or POSIX regex, per `match_type`. */
+  pattern: string
+  match_type: 'literal' | 'regex'
+  /** Only match transactions of this type; null matches both. */
+  type: TransactionType | null
+  category_id: string
+  priority: number
+}
+
+export interface AutoCategorizeResult {
+  categorized: number
+  /** Transactions categorized per rule; rules that matched nothing are omitted. */
+  rules: Array<{ ruleId: string; categorized: number }>
+}
+
+export interface CombinedReport {
+  base: string
+  totals: { income: string; expense: string; net: string }
+  accounts: Array<{
+    id: string
+    name: string
+    currency: string
+    income: string
+    expense: string
+    converted: { income: string; expense: string }
+  }>
+}
+
+export interface AccountTypeTotals {
+  balance: string
+  income: string
+  expense: string
+}
+
+/** Amounts are converted into `base`; balances are as of `to`. */
+export interface AccountTypeReport {
+  base: string
+  from: string | null
+  to: string | null
+  totals: AccountTypeTotals
+  /** Every account type, in the usual order, even without accounts. */
+  types: Array<
+    AccountTypeTotals & { type: BankAccount['type']; accounts: number }
+  >
+}
+
+/**
+ * Lifetime figures for one account; scheduled transactions are left out.
+ * `balance` counts posted transactions only, as the default balance does.
+ */
+export interface AccountOverview
+  extends Pick<BankAccount, 'id' | 'name' | 'type' | 'currency'> {
+  /** Null while the account has no transactions. */
+  firstTransactionAt: string | null
+  lastTransactionAt: string | null
+  income: string
+  expense: string
+  transactionCount: number
+  balance: string
+}
+
+/** What deleting an account would remove; nothing is changed. */
+export interface AccountDeletePreview extends Pick<BankAccount, 'id' | 'name'> {
+  transactionCount: number
+  /** Null when the account has no transactions. */
+  firstTransactionAt: string | null
+  lastTransactionAt: string | null
+  income: string
+  expense: string
+  /** Transfer legs; the other account keeps its side. */
+  transferCount: number
+  splitCount: number
+  savingsGoalCount: number
+}
+
+/**
+ * A month of one account, oldest first. Counts posted, active
+ * transactions only, like the default balance.
+ */
+export interface AccountStatement {
+  account: Pick<BankAccount, 'id' | 'name' | 'type' | 'currency'>
+  month: string
+  /** First instant of the month and of the next, UTC. */
+  periodStart: string
+  periodEnd: string
+  openingBalance: string
+  closingBalance: string
+  totals: { income: string; expense: string; count: number }
+  transactions: Array<
+    Pick<
+      Transaction,
+      | 'id'
+      | 'seq'
+      | 'date'
+      | 'description'
+      | 'type'
+      | 'amount'
+      | 'category_id'
+      | 'transfer_group'
+    > & {
+      /** Balance after this transaction. */
+      runningBalance: string
+    }
+  >
+}
+
+export interface ScheduledActivation {
+  /** Scheduled transactions that fell due and now count. */
+  activated: number
+}
+
+export interface UsageLimit {
+  limit: number
+  used: number
+}
+
+export type CallerContext =
+  | { authenticated: false; readOnly: boolean }
+  | {
+      authenticated: true
+      user: { id: string; email: string; name: string }
+      readOnly: boolean
+      /** null where the deployment sets no cap. */
+      limits: {
+        accounts: UsageLimit | null
+        /** `used` is the count in the user's fullest account. */
+        transactionsPerAccount: UsageLimit | null
+      }
+    }
+
+export interface DeploymentVersion {
+  apiVersion: string
+  deployId: string | null
+  commit: string | null
+  /** Newest applied migration file; null before the first migration run. */
+  migration: { latest: string; appliedAt: string; applied: number } | null
+}
+
+export interface SavingsGoal {
+  id: string
+  account_id: string
+  name: string
+  target_amount: string
+  /** `YYYY-MM-DD`; null for a goal without a deadline. */
+  target_date?: string | null
+  created_at: string
+}
+
+export type SavingsGoalCreate = Pick<SavingsGoal, 'name' | 'target_amount'> &
+  Partial<Pick<SavingsGoal, 'target_date'>>
+/** Omitted fields keep their value; `target_date` can be cleared. */
+export type SavingsGoalUpdate = Partial<SavingsGoalCreate>
+
+export interface SavingsGoalProgress {
+  goalId: string
+  name: string
+  targetDate: string | null
+  balance: string
+  target: string
+  /** Zero once the goal is met. */
+  remaining: string
+  /** Share of the target reached, 0–100. */
+  percent: number
+  /**
+   * `met` whenever the balance reaches the target; otherwise `past_due`
+   * after the target date, or whether the recent pace gets there in time.
+   */
+  status: 'met' | 'past_due' | 'on_track' | 'behind' | 'no_deadline'
+  /** Null without a target date; negative once it has passed. */
+  daysRemaining: number | null
+  /** Average daily net change over the last 90 days. */
+  pacePerDay: number
+  /** Balance expected on the target date at that pace. */
+  projectedBalance: number | null
+}
+
+export interface PeriodTotals {
+  income: string
+  expense: string
+  net: string
+}
+
+export interface PeriodComparison {
+  includeTransfers: boolean
+  /** Whether split transactions were broken down by their splits. */
+  splits: boolean
+  /** Zeros for a month without transactions. */
+  periodA: PeriodTotals & { month: string }
+  periodB: PeriodTotals & { month: string }
+  /** Categories used in either month; `categoryId` null is uncategorized. */
+  categories: Array<{
+    categoryId: string | null
+    name: string | null
+    periodA: PeriodTotals
+    periodB: PeriodTotals
+  }>
+}